@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"testovoe/internal/config"
+	"testovoe/internal/domain"
+	"testovoe/internal/events"
+	"testovoe/internal/storage"
+	"testovoe/internal/usecase"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	file := flag.String("file", "", "path to a JSON array of subscriptions")
+	user := flag.String("user", "", "id of the user the subscriptions are imported for")
+	flag.Parse()
+
+	userID, err := uuid.Parse(*user)
+	if *file == "" || err != nil {
+		fmt.Fprintf(os.Stderr, "usage: %s --file subs.json --user <uuid>\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	cfg := config.MustLoadConfig()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	log := slog.New(slog.NewJSONHandler(os.Stderr, nil)).With(slog.String("file", *file))
+
+	subs, err := readSubs(*file)
+	if err != nil {
+		log.Error("failed to read subscriptions", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := storage.New(ctx, cfg.Storage.Addr, cfg.Storage.AcquireTimeout)
+	if err != nil {
+		log.Error("failed to connect to storage", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	publisher, err := events.New(cfg.Events.Publisher, log)
+	if err != nil {
+		log.Error("failed to set up events publisher", "error", err)
+		os.Exit(1)
+	}
+
+	useCase := usecase.New(log, db, cfg, publisher)
+
+	imported, failed, err := importSubs(ctx, os.Stdout, useCase, userID, subs, time.Now().UTC())
+	if err != nil {
+		log.Error("failed to import subscriptions", "error", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("imported: %d, failed: %d\n", imported, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// importSubs assigns subs to userID, reports each record that fails
+// validation to out and creates the rest in one batch. Records without a
+// start date start at now.
+func importSubs(ctx context.Context, out io.Writer, useCase *usecase.UseCase, userID uuid.UUID, subs []domain.UserSub, now time.Time) (int64, int, error) {
+	valid := make([]domain.UserSub, 0, len(subs))
+	failed := 0
+	for i, sub := range subs {
+		sub.UserID = userID
+		if sub.StartedAt.IsZero() {
+			sub.StartedAt = now
+		}
+
+		if err := useCase.ValidateSub(sub); err != nil {
+			fmt.Fprintf(out, "record %d: %v\n", i, err)
+			failed++
+			continue
+		}
+
+		valid = append(valid, sub)
+	}
+
+	if len(valid) == 0 {
+		return 0, failed, nil
+	}
+
+	imported, err := useCase.CreateSubs(ctx, valid)
+	if err != nil {
+		return 0, failed, err
+	}
+
+	return imported, failed, nil
+}
+
+func readSubs(path string) ([]domain.UserSub, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var subs []domain.UserSub
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return subs, nil
+}
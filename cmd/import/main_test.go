@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"testovoe/internal/config"
+	"testovoe/internal/events"
+	"testovoe/internal/storage/inmemory"
+	"testovoe/internal/usecase"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestImportFixture(t *testing.T) {
+	subs, err := readSubs("testdata/subs.json")
+	if err != nil {
+		t.Fatalf("readSubs: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Money.DefaultCurrency = "RUB"
+	cfg.Money.Currencies = []string{"RUB"}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := inmemory.New()
+	useCase := usecase.New(log, db, cfg, events.Noop{})
+
+	var out bytes.Buffer
+	userID := uuid.New()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	imported, failed, err := importSubs(context.Background(), &out, useCase, userID, subs, now)
+	if err != nil {
+		t.Fatalf("importSubs: %v", err)
+	}
+	if imported != 2 || failed != 1 {
+		t.Errorf("imported %d, failed %d; want 2 and 1", imported, failed)
+	}
+	if !strings.HasPrefix(out.String(), "record 2: ") || strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("output = %q, want one line for record 2", out.String())
+	}
+
+	stored, err := db.GetUserSubs(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("stored %d subscriptions, want 2", len(stored))
+	}
+	for _, sub := range stored {
+		if sub.ServiceName == "Spotify" && !sub.StartedAt.Equal(now) {
+			t.Errorf("Spotify started_at = %v, want the import time %v", sub.StartedAt, now)
+		}
+	}
+}
+
+func TestReadSubsRejectsMalformedJSON(t *testing.T) {
+	path := t.TempDir() + "/subs.json"
+	if err := os.WriteFile(path, []byte(`{"service_name":"Netflix"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readSubs(path); err == nil || !strings.HasPrefix(err.Error(), path+": ") {
+		t.Errorf("err = %v, want a decode error naming the file", err)
+	}
+}
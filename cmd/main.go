@@ -2,56 +2,145 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
 	"testovoe/internal/application"
 	"testovoe/internal/config"
 	"testovoe/internal/domain"
+	"testovoe/internal/events"
 	"testovoe/internal/http/handlers"
+	"testovoe/internal/http/middleware/idempotency"
 	"testovoe/internal/http/router"
+	"testovoe/internal/reminders"
+	"testovoe/internal/sandbox"
 	"testovoe/internal/storage"
+	"testovoe/internal/storage/inmemory"
 	"testovoe/internal/usecase"
+	"time"
 
 	_ "testovoe/docs"
 
 	"github.com/go-chi/chi/v5"
 )
 
+const idempotencyCleanupInterval = time.Minute
+
 func main() {
 	cfg := config.MustLoadConfig()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	log := setupLogger(cfg.Env)
+	log := setupLogger(cfg.Env, cfg.Instance)
+
+	var db usecase.Storage
+	if cfg.Storage.Driver == config.StorageDriverMemory {
+		log.Warn("Using in-memory storage, data is lost on restart")
+		db = inmemory.New()
+	} else {
+		pg, ok := setupPostgres(ctx, log, cfg)
+		if !ok {
+			return
+		}
+		defer pg.Close()
+
+		db = pg
+	}
+
+	httpRouter := chi.NewRouter()
 
-	db, err := storage.New(ctx, cfg.Storage.Addr)
+	publisher, err := events.New(cfg.Events.Publisher, log)
 	if err != nil {
-		log.Error("Failed to connect to storage", "error", err)
+		log.Error("Failed to set up events publisher", "error", err)
 		return
 	}
-	defer db.Close()
 
-	httpRouter := chi.NewRouter()
+	useCase := usecase.New(log, db, cfg, publisher)
 
-	useCase := usecase.New(log, db, cfg)
+	if sandboxID, ok := cfg.Sandbox.ID(); ok {
+		sandboxCtx, stopSandbox := context.WithCancel(ctx)
+		defer stopSandbox()
 
-	httpHandlers := handlers.New(log, useCase)
+		go sandbox.Run(sandboxCtx, log, useCase, sandboxID, cfg.Sandbox.ResetInterval)
+	}
+
+	if cfg.Reminders.Interval > 0 {
+		go reminders.Run(ctx, log, useCase, cfg.Reminders.Interval)
+	}
 
-	router.Router(httpRouter, httpHandlers, log)
+	httpHandlers := handlers.New(log, useCase, cfg)
+
+	var idem *idempotency.Store
+	if cfg.HttpServer.IdempotencyWindow > 0 {
+		idem = idempotency.New(cfg.HttpServer.IdempotencyWindow, cfg.HttpServer.IdempotencyMaxBytes)
+		go idem.Cleanup(ctx, log, idempotencyCleanupInterval)
+	}
+
+	router.Router(httpRouter, httpHandlers, idem, log, cfg)
 
 	app := application.New(ctx, cfg, log, httpRouter)
 
 	app.MustRun()
 
 	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, os.Interrupt)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 	<-shutdown
 
-	app.Shutdown()
+	application.Drain(log, httpHandlers, cfg.HttpServer.PreStopDelay, app.Shutdown)
+}
+
+// setupPostgres applies migrations if enabled, opens the pool and starts the
+// health check, which stops with ctx.
+func setupPostgres(ctx context.Context, log *slog.Logger, cfg *config.Config) (*storage.Storage, bool) {
+	if cfg.Storage.AutoMigrate {
+		var aheadErr *storage.SchemaAheadError
+		err := storage.Migrate(ctx, cfg.Storage.Addr, "up")
+		switch {
+		case errors.As(err, &aheadErr):
+			log.Warn("Database schema is ahead of this build, skipping migrations",
+				"db_version", aheadErr.DBVersion,
+				"latest_version", aheadErr.LatestVersion,
+			)
+		case err != nil:
+			logMigrationError(log, err)
+			return nil, false
+		default:
+			log.Info("Migrations applied")
+		}
+	} else {
+		log.Info("Auto migrate disabled, skipping migrations")
+	}
+
+	db, err := storage.New(ctx, cfg.Storage.Addr, cfg.Storage.AcquireTimeout)
+	if err != nil {
+		log.Error("Failed to connect to storage", "error", err)
+		return nil, false
+	}
+
+	if cfg.Storage.HealthCheckInterval > 0 {
+		go db.WatchHealth(ctx, log, cfg.Storage.HealthCheckInterval)
+	}
+
+	return db, true
 }
 
-func setupLogger(env string) *slog.Logger {
+func logMigrationError(log *slog.Logger, err error) {
+	var migrationErr *storage.MigrationError
+	if errors.As(err, &migrationErr) {
+		log.Error("Failed to apply migration",
+			"version", migrationErr.Version,
+			"file", migrationErr.File,
+			"error", migrationErr.Err,
+		)
+		return
+	}
+
+	log.Error("Failed to run migrations", "error", err)
+}
+
+func setupLogger(env string, instance config.Instance) *slog.Logger {
 	var log *slog.Logger
 
 	switch env {
@@ -61,7 +150,13 @@ func setupLogger(env string) *slog.Logger {
 		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 	case domain.EnvProd:
 		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	default:
+		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		log.Warn("Unknown env, falling back to info level logging", "env", env)
 	}
 
-	return log
+	return log.With(
+		slog.String("service", instance.ServiceName),
+		slog.String("instance_id", instance.ID),
+	)
 }
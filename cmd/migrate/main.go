@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"testovoe/internal/config"
+	"testovoe/internal/storage"
+	"time"
+)
+
+func main() {
+	commands := slices.Concat(storage.MigrationCommands, storage.BackfillCommands)
+	if len(os.Args) != 2 || !slices.Contains(commands, os.Args[1]) {
+		fmt.Fprintf(os.Stderr, "usage: %s <%s>\n", os.Args[0], strings.Join(commands, "|"))
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	cfg := config.MustLoadConfig()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil)).With(slog.String("command", command))
+
+	if slices.Contains(storage.BackfillCommands, command) {
+		if err := runBackfill(ctx, log, cfg, command); err != nil {
+			log.Error("backfill failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	log.Info("running migrations")
+
+	if err := storage.Migrate(ctx, cfg.Storage.Addr, command); err != nil {
+		var migrationErr *storage.MigrationError
+		var aheadErr *storage.SchemaAheadError
+		if errors.As(err, &aheadErr) {
+			log.Warn("database schema is ahead of this build, nothing to apply", "db_version", aheadErr.DBVersion, "latest_version", aheadErr.LatestVersion)
+			return
+		}
+		if errors.As(err, &migrationErr) {
+			log.Error("migration failed", "version", migrationErr.Version, "file", migrationErr.File, "error", migrationErr.Err)
+		} else {
+			log.Error("migration failed", "error", err)
+		}
+		os.Exit(1)
+	}
+
+	log.Info("migrations finished")
+}
+
+func runBackfill(ctx context.Context, log *slog.Logger, cfg *config.Config, command string) error {
+	backfill := cfg.Storage.Backfill
+	if !backfill.Enabled {
+		return errors.New("backfill is disabled, set storage.backfill.enabled to run it")
+	}
+	if backfill.MaxAgeMonths <= 0 {
+		return errors.New("storage.backfill.max_age_months must be positive")
+	}
+
+	db, err := storage.New(ctx, cfg.Storage.Addr, cfg.Storage.AcquireTimeout)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var affected int64
+	if command == storage.BackfillEndedAtCommand {
+		affected, err = db.BackfillEndedAt(ctx, backfill.MaxAgeMonths, time.Now().UTC())
+	} else {
+		affected, err = db.RevertEndedAtBackfill(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("backfill finished", "max_age_months", backfill.MaxAgeMonths, "rows_affected", affected)
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"testovoe/internal/config"
+	"testovoe/internal/storage"
+)
+
+func TestRunBackfillIsGuarded(t *testing.T) {
+	tests := []struct {
+		name     string
+		backfill config.Backfill
+		wantErr  string
+	}{
+		{name: "disabled", backfill: config.Backfill{MaxAgeMonths: 12}, wantErr: "backfill is disabled"},
+		{name: "no max age", backfill: config.Backfill{Enabled: true}, wantErr: "max_age_months must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Storage.Backfill = tt.backfill
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			err := runBackfill(context.Background(), log, cfg, storage.BackfillEndedAtCommand)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
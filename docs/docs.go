@@ -15,31 +15,58 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/subscriptions": {
+        "/": {
             "get": {
-                "description": "Возвращает все подписки или подписки конкретного пользователя (если передан user_id)",
+                "description": "Возвращает имя сервиса (instance.service_name), его версию и путь к документации, чтобы обращение к базовому адресу не заканчивалось 404",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "service"
                 ],
-                "summary": "Получить список подписок",
+                "summary": "Описание сервиса",
+                "responses": {
+                    "200": {
+                        "description": "Описание сервиса",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RootResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/deduplicate": {
+            "post": {
+                "description": "Находит активные подписки с одинаковыми user_id и service_name, оставляет самую позднюю по дате начала, а остальные завершает текущим временем (ended_at). Выполняется одной транзакцией. Требует заголовок Authorization: Bearer \u003cadmin.token\u003e",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Удалить дубликаты подписок",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID пользователя (UUID)",
-                        "name": "user_id",
-                        "in": "query"
+                        "description": "Bearer \u003cadmin.token\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Список подписок",
+                        "description": "Что было объединено",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/domain.UserSub"
+                            "$ref": "#/definitions/domain.DedupReport"
+                        }
+                    },
+                    "401": {
+                        "description": "Неверный токен",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     },
@@ -53,9 +80,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Обновляет запись об онлайн-подписке для конкретного пользователя",
+            }
+        },
+        "/admin/rename-service": {
+            "post": {
+                "description": "Заменяет service_name from на to во всех подписках одним запросом в транзакции, например чтобы объединить данные, разнесенные опечаткой. Категории не меняются. Требует заголовок Authorization: Bearer \u003cadmin.token\u003e",
                 "consumes": [
                     "application/json"
                 ],
@@ -63,32 +92,48 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Обновить запись о подписке",
+                "summary": "Переименовать сервис",
                 "parameters": [
                     {
-                        "description": "Данные подписки",
+                        "type": "string",
+                        "description": "Bearer \u003cadmin.token\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Старое и новое название",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/domain.UserSub"
+                            "$ref": "#/definitions/handlers.RenameServiceRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Успешное обновление",
+                    "200": {
+                        "description": "Количество переименованных подписок",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "type": "string"
+                                "type": "integer"
                             }
                         }
                     },
                     "400": {
-                        "description": "Ошибка валидации или некорректный JSON",
+                        "description": "Некорректный JSON",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Неверный токен",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -96,6 +141,12 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
@@ -106,42 +157,52 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Создает запись об онлайн-подписке для конкретного пользователя",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/api/v1/reports/active-count": {
+            "get": {
+                "description": "Для каждого месяца с from по to возвращает, сколько подписок всех пользователей было активно в нем хотя бы день. Месяцы без подписок возвращаются с нулем. С weighted=true каждая подписка учитывается долей дней месяца, в которые была активна (как при prorate в /total), и count возвращается десятичной строкой (domain.WeightedMonthCount)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "reports"
                 ],
-                "summary": "Создать новую подписку",
+                "summary": "Число активных подписок по месяцам",
                 "parameters": [
                     {
-                        "description": "Данные подписки",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/domain.UserSub"
-                        }
+                        "type": "string",
+                        "description": "Первый месяц (01-2025)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Последний месяц (12-2025)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Учитывать долю месяца, в которую подписка была активна",
+                        "name": "weighted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Успешное создание",
+                    "200": {
+                        "description": "Число подписок по месяцам",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.MonthCount"
                             }
                         }
                     },
                     "400": {
-                        "description": "Ошибка валидации или некорректный JSON",
+                        "description": "Ошибка валидации параметров",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -161,54 +222,30 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/subscriptions/total": {
+        "/api/v1/reports/mrr": {
             "get": {
-                "description": "Считает сумму трат за период. Формат дат: MM-YYYY",
+                "description": "Сумма месячных цен всех подписок, активных в указанном месяце, по всем пользователям. Годовые подписки учитываются как 1/12 цены. mrr возвращается в единицах хранения цены (money.price_unit), mrrFormatted — десятичной строкой",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "reports"
                 ],
-                "summary": "Рассчитать итоговую стоимость",
+                "summary": "Ежемесячная регулярная выручка (MRR)",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID пользователя (UUID)",
-                        "name": "user_id",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Название сервиса",
-                        "name": "service_name",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Дата начала (01-2025)",
-                        "name": "from",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Дата окончания (03-2025)",
-                        "name": "to",
+                        "description": "Месяц (MM-YYYY)",
+                        "name": "month",
                         "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Результат",
+                        "description": "MRR за месяц",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
-                            }
+                            "$ref": "#/definitions/handlers.MRRResponse"
                         }
                     },
                     "400": {
@@ -232,43 +269,79 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/subscriptions/{id}": {
+        "/api/v1/reports/subscriptions-by-user": {
             "get": {
-                "description": "Возвращает подписку по её ID (передается в пути)",
+                "description": "Возвращает подписки, подходящие под фильтры, сгруппированными по user_id (внутри группы — по дате начала). Выбирается одним запросом",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "reports"
                 ],
-                "summary": "Получить одну подписку",
+                "summary": "Подписки, сгруппированные по пользователям",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID подписки (UUID)",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Несколько сервисов через запятую (например, Netflix,Spotify)",
+                        "name": "services",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Способ оплаты (например, visa-1234)",
+                        "name": "payment_method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Теги через запятую (например, work,streaming)",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "any",
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "any — любой из тегов (по умолчанию), all — все теги",
+                        "name": "tag_mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Не учитывать бесплатные подписки (цена 0)",
+                        "name": "exclude_free",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Данные подписки",
-                        "schema": {
-                            "$ref": "#/definitions/domain.UserSub"
-                        }
-                    },
-                    "400": {
-                        "description": "Некорректный ID",
+                        "description": "Подписки по user_id",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "type": "string"
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/handlers.SubResponse"
+                                }
                             }
                         }
                     },
-                    "404": {
-                        "description": "Подписка не найдена",
+                    "400": {
+                        "description": "Ошибка валидации параметров",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -286,41 +359,121 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Удаляет запись по ID подписки (path) и ID пользователя (query)",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/api/v1/subscriptions": {
+            "get": {
+                "description": "Возвращает подписки с фильтрами по user_id, service_name, payment_method и тегам постранично. Размер страницы по умолчанию и максимальный, а также порядок (по умолчанию started_at по убыванию, затем id) задаются в конфиге. С заголовком Accept: application/x-ndjson все подходящие подписки отдаются потоком, по одному JSON-объекту в строке, отсортированными по дате начала; limit и offset при этом не применяются",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/x-ndjson"
                 ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Удаляет запись о подписке",
+                "summary": "Получить список подписок",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID подписки (UUID)",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "ID пользователя (UUID)",
-                        "name": "user_id",
-                        "in": "query",
-                        "required": true
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Несколько сервисов через запятую (например, Netflix,Spotify)",
+                        "name": "services",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Способ оплаты (например, visa-1234)",
+                        "name": "payment_method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Теги через запятую (например, work,streaming)",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "any",
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "any — любой из тегов (по умолчанию), all — все теги",
+                        "name": "tag_mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Не учитывать бесплатные подписки (цена 0)",
+                        "name": "exclude_free",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить подписки, которыми поделились с user_id",
+                        "name": "include_shared",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Созданы не раньше (RFC 3339, включительно); с этим фильтром список сортируется по created_at",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Созданы не позже (RFC 3339, включительно)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Вернуть только эти поля через запятую (например, id,service_name,service_price)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение (не больше pagination.max_offset, по умолчанию 10000)",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "Список подписок",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.SubResponse"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "Ссылки first/prev/next/last на соседние страницы (RFC 5988)"
+                            }
+                        }
                     },
                     "400": {
-                        "description": "Ошибка валидации ID",
+                        "description": "Ошибка валидации параметров",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -338,21 +491,2851 @@ const docTemplate = `{
                         }
                     }
                 }
+            },
+            "post": {
+                "description": "Создает запись об онлайн-подписке для конкретного пользователя",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать новую подписку",
+                "parameters": [
+                    {
+                        "description": "Данные подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserSub"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Успешное создание",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный JSON",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет все подписки, подходящие под фильтры. Нужен хотя бы один фильтр",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удаляет подписки по фильтру",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Способ оплаты",
+                        "name": "payment_method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Не учитывать бесплатные подписки (цена 0)",
+                        "name": "exclude_free",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Количество удаленных подписок",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Не передан ни один фильтр или некорректный ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/batch": {
+            "post": {
+                "description": "Создает подписки из массива. По умолчанию (atomic=true) все записи сохраняются одной транзакцией: при любой ошибке не сохраняется ничего. С atomic=false каждая запись сохраняется отдельно, а ответ 207 содержит результат по каждому индексу",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать несколько подписок",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Сохранять все записи одной транзакцией (по умолчанию true)",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Данные подписок",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.UserSub"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Все подписки созданы",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer",
+                                "format": "int64"
+                            }
+                        }
+                    },
+                    "207": {
+                        "description": "Результат по каждой записи (atomic=false)",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.BatchItemResult"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный JSON",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/by-service": {
+            "get": {
+                "description": "Возвращает единственную активную подписку пользователя на сервис. Если активных несколько, отвечает 409, а с latest=true возвращает начавшуюся последней",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Активная подписка пользователя на сервис",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть последнюю из нескольких активных подписок",
+                        "name": "latest",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SubResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Активная подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Найдено несколько активных подписок",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/compare": {
+            "get": {
+                "description": "Считает сумму трат за два периода и разницу между ними. Период задается как MM-YYYY:MM-YYYY или MM-YYYY для одного месяца",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Сравнить траты за два периода",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса или несколько через запятую (Netflix,Spotify)",
+                        "name": "service_name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Первый период (01-2025:03-2025)",
+                        "name": "period_a",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Второй период (04-2025:06-2025)",
+                        "name": "period_b",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Учитывать неполные месяцы пропорционально дням активности",
+                        "name": "prorate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Часовой пояс IANA для границ месяцев (по умолчанию UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "half_up",
+                            "bankers",
+                            "floor"
+                        ],
+                        "type": "string",
+                        "description": "Округление дробной суммы при prorate: half_up (по умолчанию), bankers, floor",
+                        "name": "rounding",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сравнение",
+                        "schema": {
+                            "$ref": "#/definitions/domain.PeriodComparison"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/currencies": {
+            "get": {
+                "description": "Возвращает коды валют (ISO 4217), встречающиеся в подписках, без повторов и по алфавиту. Без user_id — по подпискам всех пользователей",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Используемые валюты",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Коды валют",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/ended": {
+            "get": {
+                "description": "Возвращает подписки, у которых ended_at попадает в период с начала месяца from до конца месяца to, по возрастанию ended_at. Бессрочные подписки не возвращаются. Без user_id — подписки всех пользователей",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Завершившиеся подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Первый месяц периода (01-2025)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Последний месяц периода (03-2025)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список подписок",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.SubResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/export": {
+            "get": {
+                "description": "Выгружает все подписки, подходящие под фильтры, в CSV, отсортированными по дате начала. Строки читаются из базы потоком, поэтому выгрузка не ограничена размером страницы",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Выгрузить подписки в CSV",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Несколько сервисов через запятую (например, Netflix,Spotify)",
+                        "name": "services",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Способ оплаты (например, visa-1234)",
+                        "name": "payment_method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Теги через запятую (например, work,streaming)",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "any",
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "any — любой из тегов (по умолчанию), all — все теги",
+                        "name": "tag_mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Не учитывать бесплатные подписки (цена 0)",
+                        "name": "exclude_free",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "CSV с подписками",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/facets": {
+            "get": {
+                "description": "Возвращает агрегаты для фильтров: количество подписок по сервисам и статусам (active, ended и upcoming — еще не начавшиеся), минимальную, максимальную и среднюю цену",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Фасеты подписок пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Фасеты",
+                        "schema": {
+                            "$ref": "#/definitions/domain.SubFacets"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID пользователя",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/forecast": {
+            "get": {
+                "description": "Прогнозирует траты пользователя по месяцам, начиная с текущего, на основе активных сейчас подписок и их периода оплаты. Подписки перестают учитываться после месяца окончания",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Прогноз трат",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество месяцев (по умолчанию 12, максимум 120)",
+                        "name": "months",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Прогноз",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ForecastResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/price": {
+            "patch": {
+                "description": "Устанавливает новую цену всем подпискам сервиса и/или пользователя одним запросом, например при повышении цены сервисом. Нужен хотя бы один из service_name и user_id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Изменить цену подписок",
+                "parameters": [
+                    {
+                        "description": "Фильтр и новая цена",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BulkPriceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Количество обновленных подписок",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный JSON или не передан ни один фильтр",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/renew-expiring": {
+            "post": {
+                "description": "Сдвигает ended_at на months месяцев у всех подписок пользователя, которые заканчиваются в ближайшие within_days дней, одной транзакцией. Уже завершившиеся подписки не продлеваются",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Продлить истекающие подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сколько дней вперед считать подписку истекающей (по умолчанию 30, максимум 365)",
+                        "name": "within_days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "На сколько месяцев продлить (по умолчанию 1, максимум 120)",
+                        "name": "months",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Количество продленных подписок",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/service-price-stats": {
+            "get": {
+                "description": "Возвращает минимальную, максимальную, среднюю и медианную цену активных подписок на сервис у всех пользователей, чтобы понять, не переплачивает ли пользователь. Цены приведены к месяцу (годовые — 1/12) и сравниваются только в одной валюте",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Статистика цен сервиса",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Код валюты (по умолчанию money.default_currency)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Статистика цен",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ServicePriceStats"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/stats": {
+            "get": {
+                "description": "Возвращает одной выборкой: число подписок всего и активных, месячные траты по активным подпискам (годовые — 1/12 цены), среднюю цену и самый дорогой из активных сервисов",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Статистика подписок пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Статистика",
+                        "schema": {
+                            "$ref": "#/definitions/domain.SubStats"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID пользователя",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/timeline": {
+            "get": {
+                "description": "Возвращает события начала и окончания подписок пользователя по возрастанию даты, для диаграммы Ганта. Окончание есть только у подписок с ended_at",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Хронология подписок пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "События",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.TimelineEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID пользователя",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/top": {
+            "get": {
+                "description": "Возвращает подписки пользователя, отсортированные по цене по убыванию",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Самые дорогие подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество подписок (по умолчанию 5, максимум 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список подписок",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.SubResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/total": {
+            "get": {
+                "description": "Считает сумму трат за период по одному или нескольким сервисам. Формат дат: MM-YYYY. Каждый месяц, в котором подписка была активна, оплачивается полностью (годовая — в месяц годовщины); с prorate=true — пропорционально дням активности. totalCost возвращается в единицах хранения цены (money.price_unit: копейки или рубли), totalCostFormatted — десятичной строкой",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Рассчитать итоговую стоимость",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса или несколько через запятую (Netflix,Spotify)",
+                        "name": "service_name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала (01-2025)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата окончания (03-2025)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Учитывать неполные месяцы пропорционально дням активности",
+                        "name": "prorate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Часовой пояс IANA для границ месяцев (по умолчанию UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "half_up",
+                            "bankers",
+                            "floor"
+                        ],
+                        "type": "string",
+                        "description": "Округление дробной суммы при prorate: half_up (по умолчанию), bankers, floor",
+                        "name": "rounding",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Отвечать 404, если ни одна подписка не подошла, вместо нулевой суммы",
+                        "name": "require_match",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Ни одна подписка не подошла (с require_match=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/totals": {
+            "post": {
+                "description": "Считает сумму трат за период для каждого из user_ids одним сгруппированным запросом (не больше 100 пользователей). Формат дат: MM-YYYY. service_name необязателен и может содержать несколько сервисов через запятую. Пользователи без подписок возвращаются с нулем",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Суммарная стоимость для нескольких пользователей",
+                "parameters": [
+                    {
+                        "description": "Пользователи и период",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TotalsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сумма по user_id",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/validate": {
+            "post": {
+                "description": "Прогоняет полную валидацию создания подписки без сохранения в БД",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Проверить данные подписки",
+                "parameters": [
+                    {
+                        "description": "Данные подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserSub"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Данные корректны",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный JSON",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}": {
+            "get": {
+                "description": "Возвращает подписку по её ID (передается в пути). С with_neighbors=true добавляет ID предыдущей и следующей подписки того же пользователя по started_at",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить одну подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть ID соседних подписок",
+                        "name": "with_neighbors",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Вернуть только эти поля через запятую (например, id,service_name,service_price)",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Данные подписки",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SubResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Обновляет запись об онлайн-подписке для конкретного пользователя. Если ended_at не передан, дата окончания не меняется; явный null очищает её",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Обновить запись о подписке",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Данные подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.SubUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Успешное обновление",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный JSON",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка этого пользователя не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет запись по ID подписки (path) и ID пользователя (query)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удаляет запись о подписке",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Ошибка валидации ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка этого пользователя не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Применяет JSON merge patch (RFC 7396) к сохраненной подписке: null очищает поле, отсутствующие ключи не меняются. Поля id, user_id и started_at не изменяются",
+                "consumes": [
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Частично обновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserSub"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновленная подписка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SubResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный patch",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "Неподдерживаемый Content-Type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/price-history": {
+            "get": {
+                "description": "Возвращает изменения цены подписки в порядке, в котором они были сделаны через PUT, PATCH и массовое изменение цены. Каждая запись содержит старую и новую цену и время изменения. Пустой список — цена не менялась",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "История цены подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменения цены",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.PriceChange"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/reactivate": {
+            "post": {
+                "description": "Снимает дату окончания с подписки, которая уже закончилась. С reset_started_at=true подписка начинается заново с текущего момента. Подписка без ended_at или с ended_at в будущем не отменена — 409",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Возобновить отмененную подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Перенести started_at на текущий момент",
+                        "name": "reset_started_at",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Возобновленная подписка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SubResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные параметры",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка не отменена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/savings": {
+            "get": {
+                "description": "Считает, сколько пользователь сэкономит за следующие months месяцев, если отменит подписку сейчас. Текущий месяц уже оплачен и не учитывается, подписка с автопродлением считается продолжающейся. Для завершенной подписки экономия нулевая",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Экономия при отмене подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество месяцев (по умолчанию 12, максимум 120)",
+                        "name": "months",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Экономия",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SavingsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/shares": {
+            "post": {
+                "description": "Открывает подписку другому пользователю: она появится в его списке при include_shared=true. Повторный вызов не считается ошибкой",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Поделиться подпиской",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Пользователь",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ShareSubRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Доступ открыт"
+                    },
+                    "400": {
+                        "description": "Некорректный JSON",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Пользователь — владелец подписки",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/shares/{user_id}": {
+            "delete": {
+                "description": "Отзывает доступ пользователя к подписке. Отзыв отсутствующего доступа не считается ошибкой",
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Закрыть доступ к подписке",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Доступ закрыт"
+                    },
+                    "400": {
+                        "description": "Некорректный ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/tags": {
+            "post": {
+                "description": "Добавляет подписке произвольные теги. Уже имеющиеся теги пропускаются",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Добавить теги подписке",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Теги",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AddTagsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка с тегами",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SubResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный JSON",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/tags/{tag}": {
+            "delete": {
+                "description": "Удаляет тег у подписки. Удаление отсутствующего тега не считается ошибкой",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить тег подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Тег",
+                        "name": "tag",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка с оставшимися тегами",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SubResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{user_id}/reminder-preferences": {
+            "get": {
+                "description": "Возвращает, за сколько дней до окончания подписки и по какому каналу пользователь хочет получать напоминание",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reminders"
+                ],
+                "summary": "Получить настройки напоминаний",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Настройки напоминаний",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ReminderPreference"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Настройки не заданы",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Сохраняет срок напоминания (0–90 дней до окончания подписки) и канал (email, push, sms). Существующие настройки перезаписываются",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reminders"
+                ],
+                "summary": "Задать настройки напоминаний",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Настройки напоминаний (user_id берется из пути)",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.ReminderPreference"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сохраненные настройки",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ReminderPreference"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный JSON или ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Отвечает 503, когда сервис завершает работу или последняя проверка соединения с БД не прошла, чтобы балансировщик перестал направлять на него трафик",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "service"
+                ],
+                "summary": "Готовность принимать запросы",
+                "responses": {
+                    "200": {
+                        "description": "Готов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Не готов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Возвращает версию, коммит и время сборки сервиса, а также версию Go",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "service"
+                ],
+                "summary": "Информация о сборке",
+                "responses": {
+                    "200": {
+                        "description": "Информация о сборке",
+                        "schema": {
+                            "$ref": "#/definitions/domain.BuildInfo"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "domain.BillingPeriod": {
+            "type": "string",
+            "enum": [
+                "monthly",
+                "yearly"
+            ],
+            "x-enum-varnames": [
+                "BillingMonthly",
+                "BillingYearly"
+            ]
+        },
+        "domain.BuildInfo": {
+            "type": "object",
+            "properties": {
+                "build_time": {
+                    "type": "string",
+                    "example": "2025-07-01T00:00:00Z"
+                },
+                "commit": {
+                    "type": "string",
+                    "example": "eba06e0"
+                },
+                "go_version": {
+                    "type": "string",
+                    "example": "go1.25.3"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "v1.2.3"
+                }
+            }
+        },
+        "domain.DedupGroup": {
+            "type": "object",
+            "properties": {
+                "ended_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "kept_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655441111"
+                }
+            }
+        },
+        "domain.DedupReport": {
+            "type": "object",
+            "properties": {
+                "ended": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.DedupGroup"
+                    }
+                }
+            }
+        },
+        "domain.FacetBucket": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "value": {
+                    "type": "string",
+                    "example": "Netflix"
+                }
+            }
+        },
+        "domain.MonthCost": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "type": "integer",
+                    "example": 990
+                },
+                "month": {
+                    "type": "string",
+                    "example": "07-2025"
+                }
+            }
+        },
+        "domain.MonthCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "month": {
+                    "type": "string",
+                    "example": "07-2025"
+                }
+            }
+        },
+        "domain.PeriodComparison": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "type": "integer",
+                    "example": 990
+                },
+                "percent_change": {
+                    "type": "string",
+                    "example": "33.33"
+                },
+                "period_a": {
+                    "$ref": "#/definitions/domain.PeriodTotal"
+                },
+                "period_b": {
+                    "$ref": "#/definitions/domain.PeriodTotal"
+                }
+            }
+        },
+        "domain.PeriodTotal": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "example": "01-2025"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "03-2025"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 2970
+                }
+            }
+        },
+        "domain.PriceChange": {
+            "type": "object",
+            "properties": {
+                "changed_at": {
+                    "type": "string",
+                    "example": "2026-07-01T00:00:00Z"
+                },
+                "new_price": {
+                    "type": "integer",
+                    "example": 990
+                },
+                "old_price": {
+                    "type": "integer",
+                    "example": 799
+                },
+                "subscription_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "domain.PriceFacet": {
+            "type": "object",
+            "properties": {
+                "avg": {
+                    "type": "integer",
+                    "example": 594
+                },
+                "max": {
+                    "type": "integer",
+                    "example": 990
+                },
+                "min": {
+                    "type": "integer",
+                    "example": 199
+                }
+            }
+        },
+        "domain.ReminderChannel": {
+            "type": "string",
+            "enum": [
+                "email",
+                "push",
+                "sms"
+            ],
+            "x-enum-varnames": [
+                "ReminderEmail",
+                "ReminderPush",
+                "ReminderSMS"
+            ]
+        },
+        "domain.ReminderPreference": {
+            "type": "object",
+            "properties": {
+                "channel": {
+                    "enum": [
+                        "email",
+                        "push",
+                        "sms"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.ReminderChannel"
+                        }
+                    ],
+                    "example": "email"
+                },
+                "lead_time_days": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655441111"
+                }
+            }
+        },
+        "domain.ServicePriceStats": {
+            "type": "object",
+            "properties": {
+                "avg": {
+                    "type": "integer",
+                    "example": 845
+                },
+                "count": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "max": {
+                    "type": "integer",
+                    "example": 1190
+                },
+                "median": {
+                    "type": "integer",
+                    "example": 799
+                },
+                "min": {
+                    "type": "integer",
+                    "example": 599
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Netflix"
+                }
+            }
+        },
+        "domain.SubFacets": {
+            "type": "object",
+            "properties": {
+                "price": {
+                    "$ref": "#/definitions/domain.PriceFacet"
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.FacetBucket"
+                    }
+                },
+                "statuses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.FacetBucket"
+                    }
+                }
+            }
+        },
+        "domain.SubNeighbors": {
+            "type": "object",
+            "properties": {
+                "next_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440002"
+                },
+                "prev_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440001"
+                }
             }
-        }
-    },
-    "definitions": {
+        },
+        "domain.SubStats": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "avg_price": {
+                    "type": "integer",
+                    "example": 830
+                },
+                "monthly_spend": {
+                    "description": "MonthlySpend is the monthly-normalized price of the active\nsubscriptions (yearly ones at a twelfth), rounded half-up.",
+                    "type": "integer",
+                    "example": 2490
+                },
+                "most_expensive_service": {
+                    "description": "MostExpensiveService has the highest monthly-normalized price among\nactive subscriptions; null when none is active.",
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        },
+        "domain.SubUpdate": {
+            "type": "object",
+            "properties": {
+                "auto_renew": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "billing_period": {
+                    "description": "BillingPeriod is left unchanged when empty.",
+                    "enum": [
+                        "monthly",
+                        "yearly"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.BillingPeriod"
+                        }
+                    ],
+                    "example": "monthly"
+                },
+                "category": {
+                    "description": "Category is left unchanged when empty.",
+                    "type": "string",
+                    "example": "entertainment"
+                },
+                "currency": {
+                    "description": "Currency is left unchanged when empty.",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "ended_at": {
+                    "type": "string",
+                    "example": "2026-07-01T00:00:00Z"
+                },
+                "payment_method": {
+                    "type": "string",
+                    "example": "visa-1234"
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "service_price": {
+                    "type": "integer",
+                    "example": 990
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655441111"
+                }
+            }
+        },
+        "domain.TimelineEvent": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "type": "string",
+                    "example": "2025-07-01T00:00:00Z"
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "subscription_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "type": {
+                    "enum": [
+                        "start",
+                        "end"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.TimelineEventType"
+                        }
+                    ],
+                    "example": "start"
+                }
+            }
+        },
+        "domain.TimelineEventType": {
+            "type": "string",
+            "enum": [
+                "start",
+                "end"
+            ],
+            "x-enum-varnames": [
+                "TimelineStart",
+                "TimelineEnd"
+            ]
+        },
         "domain.UserSub": {
             "type": "object",
             "properties": {
+                "auto_renew": {
+                    "description": "AutoRenew makes forecasts assume the subscription keeps renewing past EndedAt.",
+                    "type": "boolean",
+                    "example": false
+                },
+                "billing_period": {
+                    "enum": [
+                        "monthly",
+                        "yearly"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.BillingPeriod"
+                        }
+                    ],
+                    "example": "monthly"
+                },
+                "category": {
+                    "description": "Category is inferred from ServiceName when a subscription is created\nwithout one; unknown services get \"other\".",
+                    "type": "string",
+                    "example": "entertainment"
+                },
+                "created_at": {
+                    "description": "CreatedAt is set by the database on insert and ignored on create/update.",
+                    "type": "string",
+                    "example": "2025-07-01T12:30:00Z"
+                },
+                "currency": {
+                    "description": "Currency is an ISO 4217 code; new subscriptions default to money.default_currency.",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "ended_at": {
+                    "type": "string",
+                    "example": "2026-07-01T00:00:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "payment_method": {
+                    "type": "string",
+                    "example": "visa-1234"
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "service_price": {
+                    "type": "integer",
+                    "example": 990
+                },
+                "started_at": {
+                    "type": "string",
+                    "example": "2025-07-01T00:00:00Z"
+                },
+                "tags": {
+                    "description": "Tags are managed through the tags endpoints and ignored on create/update.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "work",
+                        "streaming"
+                    ]
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655441111"
+                }
+            }
+        },
+        "handlers.AddTagsRequest": {
+            "type": "object",
+            "properties": {
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "work",
+                        "streaming"
+                    ]
+                }
+            }
+        },
+        "handlers.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "validation failed"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/validation.FieldError"
+                    }
+                },
+                "index": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 201
+                }
+            }
+        },
+        "handlers.BulkPriceRequest": {
+            "type": "object",
+            "properties": {
+                "new_price": {
+                    "type": "integer",
+                    "example": 1190
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655441111"
+                }
+            }
+        },
+        "handlers.ForecastResponse": {
+            "type": "object",
+            "properties": {
+                "months": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.MonthCost"
+                    }
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 11880
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655441111"
+                }
+            }
+        },
+        "handlers.MRRResponse": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "type": "string",
+                    "example": "07-2025"
+                },
+                "mrr": {
+                    "type": "integer",
+                    "example": 10990
+                },
+                "mrrFormatted": {
+                    "type": "string",
+                    "example": "109.90"
+                }
+            }
+        },
+        "handlers.RenameServiceRequest": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "example": "Netflx"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "Netflix"
+                }
+            }
+        },
+        "handlers.RootResponse": {
+            "type": "object",
+            "properties": {
+                "docs": {
+                    "type": "string",
+                    "example": "/swagger/index.html"
+                },
+                "service": {
+                    "type": "string",
+                    "example": "subscription-service"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "v1.2.3"
+                }
+            }
+        },
+        "handlers.SavingsResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "months": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "savings": {
+                    "type": "integer",
+                    "example": 10890
+                },
+                "savingsFormatted": {
+                    "type": "string",
+                    "example": "108.90"
+                }
+            }
+        },
+        "handlers.ShareSubRequest": {
+            "type": "object",
+            "properties": {
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655442222"
+                }
+            }
+        },
+        "handlers.SubResponse": {
+            "type": "object",
+            "properties": {
+                "auto_renew": {
+                    "description": "AutoRenew makes forecasts assume the subscription keeps renewing past EndedAt.",
+                    "type": "boolean",
+                    "example": false
+                },
+                "billing_label": {
+                    "description": "BillingLabel is the display name of the cadence: monthly, yearly or\none-time.",
+                    "type": "string",
+                    "example": "monthly"
+                },
+                "billing_period": {
+                    "enum": [
+                        "monthly",
+                        "yearly"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.BillingPeriod"
+                        }
+                    ],
+                    "example": "monthly"
+                },
+                "category": {
+                    "description": "Category is inferred from ServiceName when a subscription is created\nwithout one; unknown services get \"other\".",
+                    "type": "string",
+                    "example": "entertainment"
+                },
+                "created_at": {
+                    "description": "CreatedAt is set by the database on insert and ignored on create/update.",
+                    "type": "string",
+                    "example": "2025-07-01T12:30:00Z"
+                },
+                "currency": {
+                    "description": "Currency is an ISO 4217 code; new subscriptions default to money.default_currency.",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "days_active": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "days_remaining": {
+                    "type": "integer",
+                    "example": 335
+                },
                 "ended_at": {
                     "type": "string",
                     "example": "2026-07-01T00:00:00Z"
                 },
+                "formatted_price": {
+                    "description": "FormattedPrice is ServicePrice with the currency symbol from\nmoney.currency_symbols.",
+                    "type": "string",
+                    "example": "9.90 ₽"
+                },
                 "id": {
                     "type": "string",
                     "example": "550e8400-e29b-41d4-a716-446655440000"
                 },
+                "neighbors": {
+                    "description": "Neighbors is only filled by GetUserSub with with_neighbors=true.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.SubNeighbors"
+                        }
+                    ]
+                },
+                "next_billing_date": {
+                    "description": "NextBillingDate is null for subscriptions that will not bill again.",
+                    "type": "string",
+                    "example": "2025-08-01T00:00:00Z"
+                },
+                "payment_method": {
+                    "type": "string",
+                    "example": "visa-1234"
+                },
+                "sandbox": {
+                    "description": "Sandbox marks demo data of the sandbox user, which is reset periodically.",
+                    "type": "boolean",
+                    "example": false
+                },
                 "service_name": {
                     "type": "string",
                     "example": "Netflix"
@@ -362,14 +3345,77 @@ const docTemplate = `{
                     "example": 990
                 },
                 "started_at": {
+                    "description": "StartedAt and EndedAt shadow those of UserSub to render them in\nhttp_server.time_format.",
                     "type": "string",
                     "example": "2025-07-01T00:00:00Z"
                 },
+                "tags": {
+                    "description": "Tags are managed through the tags endpoints and ignored on create/update.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "work",
+                        "streaming"
+                    ]
+                },
                 "user_id": {
                     "type": "string",
                     "example": "550e8400-e29b-41d4-a716-446655441111"
                 }
             }
+        },
+        "handlers.TotalsRequest": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "example": "01-2025"
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Netflix,Spotify"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "12-2025"
+                },
+                "user_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.ValidationErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "validation failed"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/validation.FieldError"
+                    }
+                }
+            }
+        },
+        "validation.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "service_price"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "must not be negative"
+                }
+            }
         }
     }
 }`
@@ -2,6 +2,7 @@ package application
 
 import (
 	"context"
+	"crypto/tls"
 	"log/slog"
 	"net/http"
 	"sync"
@@ -21,11 +22,17 @@ func New(ctx context.Context, cfg *config.Config, log *slog.Logger, router *chi.
 	srv := &http.Server{
 		Addr:         cfg.HttpServer.Addr,
 		Handler:      router,
-		ReadTimeout:  cfg.HttpServer.Timeout,
-		WriteTimeout: cfg.HttpServer.Timeout,
+		ReadTimeout:  cfg.HttpServer.ReadTimeout,
+		WriteTimeout: cfg.HttpServer.WriteTimeout,
 		IdleTimeout:  cfg.HttpServer.IdleTimeout,
 	}
 
+	if cfg.HttpServer.TLS.Enabled() {
+		// Validated by config.MustLoadConfig.
+		minVersion, _ := cfg.HttpServer.TLS.MinTLSVersion()
+		srv.TLSConfig = &tls.Config{MinVersion: minVersion}
+	}
+
 	return &Application{
 		ctx:    ctx,
 		cfg:    cfg,
@@ -47,11 +54,17 @@ func (a *Application) Run() error {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		a.log.Info("Run: starting http server", "addr", a.cfg.HttpServer.Addr)
+		tlsCfg := a.cfg.HttpServer.TLS
+		a.log.Info("Run: starting http server", "addr", a.cfg.HttpServer.Addr, "tls", tlsCfg.Enabled())
 
-		err := a.server.ListenAndServe()
+		var err error
+		if tlsCfg.Enabled() {
+			err = a.server.ListenAndServeTLS(tlsCfg.CertFile, tlsCfg.KeyFile)
+		} else {
+			err = a.server.ListenAndServe()
+		}
 		if err != nil {
-			a.log.Error("ListenAndServe: ", err)
+			a.log.Error("ListenAndServe: failed to serve", "error", err)
 		}
 	}()
 
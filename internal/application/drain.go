@@ -0,0 +1,24 @@
+package application
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Drainer stops reporting the service as ready, as HttpHandler does for
+// /readyz.
+type Drainer interface {
+	StartDraining()
+}
+
+// Drain turns drainer not ready, then waits delay so load balancers stop
+// routing new requests here before stop shuts the server down.
+func Drain(log *slog.Logger, drainer Drainer, delay time.Duration, stop func()) {
+	drainer.StartDraining()
+	if delay > 0 {
+		log.Info("Draining before shutdown", "delay", delay)
+		time.Sleep(delay)
+	}
+
+	stop()
+}
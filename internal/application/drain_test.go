@@ -0,0 +1,57 @@
+package application_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/application"
+	"testovoe/internal/config"
+	"testovoe/internal/events"
+	"testovoe/internal/http/handlers"
+	"testovoe/internal/storage/inmemory"
+	"testovoe/internal/usecase"
+	"time"
+)
+
+func TestDrainTurnsNotReadyBeforeStopping(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	cfg := &config.Config{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := handlers.New(log, usecase.New(log, inmemory.New(), cfg, events.Noop{}), cfg)
+
+	readyz := func() int {
+		w := httptest.NewRecorder()
+		h.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("readyz before drain = %d, want 200", code)
+	}
+
+	start := time.Now()
+	stopped := make(chan time.Duration, 1)
+	go application.Drain(log, h, delay, func() { stopped <- time.Since(start) })
+
+	deadline := time.Now().Add(delay / 2)
+	for readyz() != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("readyz did not turn 503 within half the pre-stop delay")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("stopped before the pre-stop delay ended")
+	default:
+	}
+
+	if elapsed := <-stopped; elapsed < delay {
+		t.Errorf("stopped after %v, want at least %v", elapsed, delay)
+	}
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("readyz after stop = %d, want 503", code)
+	}
+}
@@ -0,0 +1,26 @@
+// Package buildinfo holds build metadata injected at link time:
+//
+//	go build -ldflags "-X testovoe/internal/buildinfo.Version=v1.2.3 \
+//		-X testovoe/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X testovoe/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"runtime"
+	"testovoe/internal/domain"
+)
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+func Get() domain.BuildInfo {
+	return domain.BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -1,28 +1,298 @@
 package config
 
 import (
+	"crypto/tls"
+	"fmt"
 	"log"
 	"os"
+	"slices"
+	"testovoe/internal/domain"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	Env        string     `yaml:"env" env-default:"local"`
+	Instance   Instance   `yaml:"instance"`
 	HttpServer HttpServer `yaml:"http_server"`
 	Storage    Storage    `yaml:"storage"`
+	Pagination Pagination `yaml:"pagination"`
+	Money      Money      `yaml:"money"`
+	Sandbox    Sandbox    `yaml:"sandbox"`
+	Features   Features   `yaml:"features" env:"FEATURES"`
+	Admin      Admin      `yaml:"admin"`
+	Events     Events     `yaml:"events"`
+	Reminders  Reminders  `yaml:"reminders"`
+	Reports    Reports    `yaml:"reports"`
+	Billing    Billing    `yaml:"billing"`
 }
 
+// Billing holds the cost-model choices operators may make.
+type Billing struct {
+	// ZeroLength is what a subscription with ended_at equal to started_at
+	// means: "reject" refuses it on create and update, "one_time" accepts it
+	// as a one-time charge of one period. Stored ones are billed as one-time
+	// charges either way.
+	ZeroLength domain.ZeroLengthPolicy `yaml:"zero_length" env:"BILLING_ZERO_LENGTH" env-default:"one_time"`
+}
+
+// Reports limits aggregate queries over a period of months.
+type Reports struct {
+	// MaxRangeMonths caps the from-to span of totals, comparisons and ended
+	// subscription listings, both months included. Zero disables the cap.
+	MaxRangeMonths int `yaml:"max_range_months" env:"REPORTS_MAX_RANGE_MONTHS" env-default:"60"`
+}
+
+// Events selects where subscription change events go: "noop" drops them,
+// "log" writes them to the service log.
+type Events struct {
+	Publisher string `yaml:"publisher" env:"EVENTS_PUBLISHER" env-default:"noop"`
+}
+
+// Reminders configures the job publishing subscription.expiring events
+// according to each user's reminder preferences.
+type Reminders struct {
+	// Interval is how often due reminders are sent. Zero disables the job.
+	Interval time.Duration `yaml:"interval" env:"REMINDERS_INTERVAL" env-default:"1h"`
+}
+
+// Admin guards the maintenance endpoints under /admin. They are not mounted
+// at all while Token is empty.
+type Admin struct {
+	Token string `yaml:"token" env:"ADMIN_TOKEN"`
+}
+
+// Features toggles optional endpoints per environment. A flag missing from the
+// config is off.
+type Features map[string]bool
+
+const (
+	FeatureExport    = "export"
+	FeatureMRRReport = "mrr_report"
+)
+
+func (f Features) Enabled(name string) bool {
+	return f[name]
+}
+
+// Instance identifies this process in logs. ID defaults to the hostname, so
+// logs from several replicas can be told apart once aggregated.
+type Instance struct {
+	ServiceName string `yaml:"service_name" env:"SERVICE_NAME" env-default:"subscription-service"`
+	ID          string `yaml:"id" env:"INSTANCE_ID"`
+}
+
+// Sandbox designates a demo user whose subscriptions are reset to a seed set
+// every ResetInterval. Leave UserID empty to disable it.
+type Sandbox struct {
+	UserID        string        `yaml:"user_id" env:"SANDBOX_USER_ID"`
+	ResetInterval time.Duration `yaml:"reset_interval" env:"SANDBOX_RESET_INTERVAL" env-default:"1h"`
+}
+
+// ID returns the sandbox user id and whether a sandbox is configured.
+func (s Sandbox) ID() (uuid.UUID, bool) {
+	id, err := uuid.Parse(s.UserID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+type Money struct {
+	// PriceUnit is "minor" when prices are stored in kopecks/cents or "major"
+	// when they are whole units. It only changes how amounts are interpreted
+	// and formatted; stored values are never rescaled, so switching it on an
+	// existing database changes the meaning of every price.
+	PriceUnit domain.PriceUnit `yaml:"price_unit" env:"PRICE_UNIT" env-default:"minor"`
+	// Currencies is the allowlist of currency codes accepted on create and
+	// update; DefaultCurrency is used when a new subscription has none and
+	// must be in the list.
+	Currencies      []string `yaml:"currencies" env:"CURRENCIES" env-default:"RUB,USD,EUR,GBP,CNY,KZT"`
+	DefaultCurrency string   `yaml:"default_currency" env:"DEFAULT_CURRENCY" env-default:"RUB"`
+	// MaxPrice caps service_price on create and update, in price units; 0
+	// means no cap. ServiceMaxPrices overrides it per service name.
+	MaxPrice         int            `yaml:"max_price" env:"MAX_PRICE" env-default:"0"`
+	ServiceMaxPrices map[string]int `yaml:"service_max_prices"`
+	// CurrencySymbols maps currency codes to the symbol shown in
+	// formatted_price; codes without one are shown as is.
+	CurrencySymbols map[string]string `yaml:"currency_symbols"`
+}
+
+// FormatPrice renders a stored amount in currency for display, e.g.
+// "9.90 ₽".
+func (m Money) FormatPrice(amount int64, currency string) string {
+	symbol, ok := m.CurrencySymbols[currency]
+	if !ok || symbol == "" {
+		symbol = currency
+	}
+
+	return m.PriceUnit.Format(amount) + " " + symbol
+}
+
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverMemory   = "memory"
+)
+
 type Storage struct {
-	Addr string `yaml:"addr" env-default:":8081"`
+	// Driver is "postgres" or "memory". The in-memory storage needs no
+	// database and loses all data on restart; it is meant for demos.
+	Driver string `yaml:"driver" env:"STORAGE_DRIVER" env-default:"postgres"`
+	Addr   string `yaml:"addr" env-default:":8081"`
+	// AutoMigrate applies pending migrations on startup. Disable it when
+	// migrations run as a separate step (see cmd/migrate).
+	AutoMigrate bool `yaml:"auto_migrate" env:"AUTO_MIGRATE" env-default:"true"`
+	// HealthCheckInterval is how often the pool is pinged in the background.
+	// Zero disables the check.
+	HealthCheckInterval time.Duration `yaml:"health_check_interval" env:"HEALTH_CHECK_INTERVAL" env-default:"30s"`
+	// AcquireTimeout bounds how long a query waits for a free connection
+	// before failing with 503. Zero waits for the request's own deadline.
+	AcquireTimeout time.Duration `yaml:"acquire_timeout" env:"STORAGE_ACQUIRE_TIMEOUT" env-default:"1s"`
+	Backfill       Backfill      `yaml:"backfill"`
+}
+
+// Backfill configures the ended_at data migration run by cmd/migrate. It is
+// off unless Enabled is set, so the command can't be run by accident.
+type Backfill struct {
+	Enabled bool `yaml:"enabled" env:"BACKFILL_ENABLED" env-default:"false"`
+	// MaxAgeMonths closes open-ended subscriptions older than this many
+	// months, ending them MaxAgeMonths after their start.
+	MaxAgeMonths int `yaml:"max_age_months" env:"BACKFILL_MAX_AGE_MONTHS" env-default:"24"`
 }
 
 type HttpServer struct {
-	Addr        string        `yaml:"address"`
-	Timeout     time.Duration `yaml:"timeout"`
-	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	Addr string `yaml:"address"`
+	// Timeout bounds how long a handler may run before the request is
+	// cancelled. Streamed responses, the export and the NDJSON list, are not
+	// bounded by it.
+	Timeout time.Duration `yaml:"timeout"`
+	// ReadTimeout and WriteTimeout bound reading the request and writing the
+	// response on the connection, so slow clients cannot hold it open.
+	// WriteTimeout should exceed Timeout or the handler's 503 is never sent.
+	// Streamed responses restart WriteTimeout with every row.
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" env-default:"5s"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" env-default:"10s"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// MaxInFlight caps concurrently executing requests; 0 disables the cap.
+	// A request over the cap waits up to QueueTimeout for a slot, then gets 503.
+	MaxInFlight  int           `yaml:"max_in_flight" env:"HTTP_MAX_IN_FLIGHT" env-default:"100"`
+	QueueTimeout time.Duration `yaml:"queue_timeout" env:"HTTP_QUEUE_TIMEOUT" env-default:"200ms"`
+	// StrictQueryParams rejects requests with query parameters the endpoint
+	// does not read instead of ignoring them.
+	StrictQueryParams bool `yaml:"strict_query_params" env:"HTTP_STRICT_QUERY_PARAMS" env-default:"false"`
+	// IdempotencyWindow is how long the response to a POST with an
+	// Idempotency-Key is kept for replay. Zero disables idempotency keys.
+	IdempotencyWindow time.Duration `yaml:"idempotency_window" env:"HTTP_IDEMPOTENCY_WINDOW" env-default:"24h"`
+	// IdempotencyMaxBytes caps the body of a POST with an Idempotency-Key,
+	// which is read whole to be hashed, and the response kept for replay.
+	// Larger bodies are refused with 413; larger responses are not kept.
+	// Zero means no cap.
+	IdempotencyMaxBytes int64 `yaml:"idempotency_max_bytes" env:"HTTP_IDEMPOTENCY_MAX_BYTES" env-default:"1048576"`
+	// LenientFieldNames accepts camelCase and PascalCase keys in request
+	// bodies, e.g. serviceName for service_name.
+	LenientFieldNames bool `yaml:"lenient_field_names" env:"HTTP_LENIENT_FIELD_NAMES" env-default:"false"`
+	// PreStopDelay is how long /readyz reports 503 after a shutdown signal
+	// before the server stops, so load balancers stop routing to it first.
+	PreStopDelay time.Duration `yaml:"pre_stop_delay" env:"HTTP_PRE_STOP_DELAY" env-default:"5s"`
+	// TimeFormat renders started_at and ended_at of subscriptions in
+	// responses: "rfc3339", "date" (2006-01-02) or "month" (MM-YYYY).
+	TimeFormat domain.TimeFormat `yaml:"time_format" env:"HTTP_TIME_FORMAT" env-default:"rfc3339"`
+	// LogContextKeys lists request context values added to every request
+	// log line, e.g. "sub_id". Values are recorded by the middlewares that
+	// resolve them; keys not set for a request are left out.
+	LogContextKeys []string `yaml:"log_context_keys" env:"HTTP_LOG_CONTEXT_KEYS"`
+	// RequestIDHeader is read for an incoming correlation id and echoed back.
+	// Ids longer than 128 characters or with characters other than letters,
+	// digits and -_.: are replaced by a generated one.
+	RequestIDHeader string `yaml:"request_id_header" env:"HTTP_REQUEST_ID_HEADER" env-default:"X-Request-Id"`
+	TLS             TLS    `yaml:"tls"`
+}
+
+// TLS makes the server terminate TLS itself when both files are set.
+type TLS struct {
+	CertFile   string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile    string `yaml:"key_file" env:"TLS_KEY_FILE"`
+	MinVersion string `yaml:"min_version" env:"TLS_MIN_VERSION" env-default:"1.2"`
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// MinTLSVersion maps MinVersion ("1.2", "1.3", ...) to its crypto/tls constant.
+func (t TLS) MinTLSVersion() (uint16, error) {
+	version, ok := tlsVersions[t.MinVersion]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls min_version %q", t.MinVersion)
+	}
+
+	return version, nil
+}
+
+type Pagination struct {
+	DefaultLimit uint64              `yaml:"default_limit" env-default:"50"`
+	MaxLimit     uint64              `yaml:"max_limit" env-default:"500"`
+	Endpoints    map[string]PageSize `yaml:"endpoints"`
+	// Sort is the order of list results.
+	Sort ListSort `yaml:"sort"`
+	// MaxOffset is the largest offset accepted. Deeper pages make Postgres
+	// read and discard every skipped row, so they are rejected instead.
+	MaxOffset uint64 `yaml:"max_offset" env:"PAGINATION_MAX_OFFSET" env-default:"10000"`
+	// LinkHeader adds RFC 5988 Link headers to paged responses. It costs an
+	// extra COUNT query per request.
+	LinkHeader bool `yaml:"link_header" env:"PAGINATION_LINK_HEADER" env-default:"true"`
+}
+
+// ListSort is the configured default order of list results: Column is one
+// of started_at, created_at, service_name or service_price and Direction is
+// asc or desc.
+type ListSort struct {
+	Column    string `yaml:"column" env:"LIST_SORT_COLUMN" env-default:"started_at"`
+	Direction string `yaml:"direction" env:"LIST_SORT_DIRECTION" env-default:"desc"`
+}
+
+func (s ListSort) Sort() domain.Sort {
+	return domain.Sort{Column: s.Column, Desc: s.Direction == "desc"}
+}
+
+// PageSize holds page limits for one endpoint. Fields left zero take the
+// global pagination values, so an endpoint can narrow the limits but not
+// lift them.
+type PageSize struct {
+	DefaultLimit uint64 `yaml:"default_limit"`
+	MaxLimit     uint64 `yaml:"max_limit"`
+	MaxOffset    uint64 `yaml:"max_offset"`
+}
+
+// For returns the page limits configured for endpoint, falling back to the
+// global defaults for anything the endpoint leaves unset.
+func (p Pagination) For(endpoint string) PageSize {
+	size := p.Endpoints[endpoint]
+
+	if size.DefaultLimit == 0 {
+		size.DefaultLimit = p.DefaultLimit
+	}
+
+	if size.MaxLimit == 0 {
+		size.MaxLimit = p.MaxLimit
+	}
+
+	if size.MaxOffset == 0 {
+		size.MaxOffset = p.MaxOffset
+	}
+
+	return size
 }
 
 func MustLoadConfig() *Config {
@@ -44,5 +314,66 @@ func MustLoadConfig() *Config {
 
 	cfg.Storage.Addr = os.Getenv("POSTGRES_URL")
 
+	if cfg.Instance.ID == "" {
+		cfg.Instance.ID, err = os.Hostname()
+		if err != nil {
+			log.Printf("Failed to get hostname for instance.id: %v", err)
+		}
+	}
+
+	if !cfg.Money.PriceUnit.Valid() {
+		log.Fatalf("Unknown money.price_unit %q, expected minor or major", cfg.Money.PriceUnit)
+	}
+
+	if !cfg.HttpServer.TimeFormat.Valid() {
+		log.Fatalf("Unknown http_server.time_format %q, expected rfc3339, date or month", cfg.HttpServer.TimeFormat)
+	}
+
+	if len(cfg.Money.Currencies) > 0 && !slices.Contains(cfg.Money.Currencies, cfg.Money.DefaultCurrency) {
+		log.Fatalf("money.default_currency %q is not in money.currencies", cfg.Money.DefaultCurrency)
+	}
+
+	if cfg.Storage.Driver != StorageDriverPostgres && cfg.Storage.Driver != StorageDriverMemory {
+		log.Fatalf("Unknown storage.driver %q, expected postgres or memory", cfg.Storage.Driver)
+	}
+
+	if !cfg.Billing.ZeroLength.Valid() {
+		log.Fatalf("Unknown billing.zero_length %q, expected reject or one_time", cfg.Billing.ZeroLength)
+	}
+
+	if cfg.Reports.MaxRangeMonths < 0 {
+		log.Fatal("reports.max_range_months must not be negative")
+	}
+
+	if cfg.Money.MaxPrice < 0 {
+		log.Fatal("money.max_price must not be negative")
+	}
+
+	for service, max := range cfg.Money.ServiceMaxPrices {
+		if max <= 0 {
+			log.Fatalf("money.service_max_prices[%q] must be positive", service)
+		}
+	}
+
+	if _, ok := cfg.Sandbox.ID(); cfg.Sandbox.UserID != "" && !ok {
+		log.Fatalf("Invalid sandbox.user_id %q, expected a UUID", cfg.Sandbox.UserID)
+	}
+
+	if _, ok := cfg.Sandbox.ID(); ok && cfg.Sandbox.ResetInterval <= 0 {
+		log.Fatal("sandbox.reset_interval must be positive")
+	}
+
+	if !cfg.Pagination.Sort.Sort().Valid() {
+		log.Fatalf("Unknown pagination.sort.column %q", cfg.Pagination.Sort.Column)
+	}
+
+	if dir := cfg.Pagination.Sort.Direction; dir != "asc" && dir != "desc" {
+		log.Fatalf("Unknown pagination.sort.direction %q, expected asc or desc", dir)
+	}
+
+	if _, err := cfg.HttpServer.TLS.MinTLSVersion(); err != nil {
+		log.Fatalf("Invalid http_server.tls: %v", err)
+	}
+
 	return &cfg
 }
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"testovoe/internal/domain"
+)
+
+func TestPaginationFor(t *testing.T) {
+	p := Pagination{
+		DefaultLimit: 50,
+		MaxLimit:     500,
+		MaxOffset:    10000,
+		Endpoints: map[string]PageSize{
+			"list":   {DefaultLimit: 20, MaxLimit: 100, MaxOffset: 1000},
+			"top":    {MaxLimit: 10},
+			"export": {},
+		},
+	}
+
+	tests := []struct {
+		endpoint string
+		want     PageSize
+	}{
+		{endpoint: "list", want: PageSize{DefaultLimit: 20, MaxLimit: 100, MaxOffset: 1000}},
+		{endpoint: "top", want: PageSize{DefaultLimit: 50, MaxLimit: 10, MaxOffset: 10000}},
+		{endpoint: "export", want: PageSize{DefaultLimit: 50, MaxLimit: 500, MaxOffset: 10000}},
+		{endpoint: "unconfigured", want: PageSize{DefaultLimit: 50, MaxLimit: 500, MaxOffset: 10000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			if got := p.For(tt.endpoint); got != tt.want {
+				t.Errorf("For(%q) = %+v, want %+v", tt.endpoint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPrice(t *testing.T) {
+	m := Money{
+		PriceUnit:       domain.PriceUnitMinor,
+		CurrencySymbols: map[string]string{"RUB": "₽", "USD": "$", "KZT": ""},
+	}
+
+	tests := []struct {
+		currency string
+		want     string
+	}{
+		{currency: "RUB", want: "9.90 ₽"},
+		{currency: "USD", want: "9.90 $"},
+		{currency: "EUR", want: "9.90 EUR"},
+		{currency: "KZT", want: "9.90 KZT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			if got := m.FormatPrice(990, tt.currency); got != tt.want {
+				t.Errorf("FormatPrice(990, %q) = %q, want %q", tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinTLSVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    uint16
+		wantErr bool
+	}{
+		{version: "1.2", want: tls.VersionTLS12},
+		{version: "1.3", want: tls.VersionTLS13},
+		{version: "1.4", wantErr: true},
+		{version: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := TLS{MinVersion: tt.version}.MinTLSVersion()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("MinTLSVersion(%q) = %#x, %v; want %#x, error %v", tt.version, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestInstanceIDDefaultsToHostname(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("env: local\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("no hostname: %v", err)
+	}
+
+	cfg := MustLoadConfig()
+	if cfg.Instance.ID != hostname {
+		t.Errorf("Instance.ID = %q, want %q", cfg.Instance.ID, hostname)
+	}
+	if cfg.Instance.ServiceName != "subscription-service" {
+		t.Errorf("Instance.ServiceName = %q, want subscription-service", cfg.Instance.ServiceName)
+	}
+}
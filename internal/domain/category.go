@@ -0,0 +1,12 @@
+package domain
+
+// Categories inferred from well-known service names. Clients may send any
+// other category as well.
+const (
+	CategoryEntertainment = "entertainment"
+	CategoryMusic         = "music"
+	CategoryWork          = "work"
+	CategoryCloud         = "cloud"
+	CategoryEducation     = "education"
+	CategoryOther         = "other"
+)
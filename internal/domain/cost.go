@@ -0,0 +1,431 @@
+package domain
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type BillingPeriod string
+
+const (
+	BillingMonthly BillingPeriod = "monthly"
+	BillingYearly  BillingPeriod = "yearly"
+)
+
+func (p BillingPeriod) Valid() bool {
+	switch p {
+	case BillingMonthly, BillingYearly:
+		return true
+	}
+
+	return false
+}
+
+// Label is the display name of the billing cadence, so clients don't have to
+// map raw values themselves.
+func (p BillingPeriod) Label() string {
+	switch p {
+	case BillingMonthly:
+		return "monthly"
+	case BillingYearly:
+		return "yearly"
+	}
+
+	return string(p)
+}
+
+// ZeroLengthPolicy decides what a subscription whose ended_at equals its
+// started_at means.
+type ZeroLengthPolicy string
+
+const (
+	// ZeroLengthReject refuses such subscriptions on create and update.
+	ZeroLengthReject ZeroLengthPolicy = "reject"
+	// ZeroLengthOneTime accepts them as a one-time charge of one period.
+	ZeroLengthOneTime ZeroLengthPolicy = "one_time"
+)
+
+func (p ZeroLengthPolicy) Valid() bool {
+	return p == ZeroLengthReject || p == ZeroLengthOneTime
+}
+
+// MonthCost is the spend attributed to one calendar month (MM-YYYY).
+type MonthCost struct {
+	Month string `json:"month" example:"07-2025"`
+	Cost  int    `json:"cost" example:"990"`
+}
+
+// MonthCount is how many subscriptions were active in one calendar month
+// (MM-YYYY).
+type MonthCount struct {
+	Month string `json:"month" example:"07-2025"`
+	Count int    `json:"count" example:"42"`
+}
+
+// WeightedMonthCount is MonthCount with every subscription weighted by the
+// share of the month it was active, as a decimal string.
+type WeightedMonthCount struct {
+	Month string `json:"month" example:"07-2025"`
+	Count string `json:"count" example:"41.52"`
+}
+
+const MonthLayout = "01-2006"
+
+// MonthStart returns the first moment of t's month in t's location.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// ZeroLength reports whether the subscription ends the instant it starts.
+// Whatever ZeroLengthPolicy is configured, the cost model bills one stored
+// this way as a one-time charge of one period in the month it starts.
+func (s UserSub) ZeroLength() bool {
+	return s.EndedAt != nil && s.EndedAt.Equal(s.StartedAt)
+}
+
+// BillingLabel is the display name of how the subscription bills: one-time
+// for a zero-length subscription, the label of its billing period otherwise.
+func (s UserSub) BillingLabel() string {
+	if s.ZeroLength() {
+		return "one-time"
+	}
+
+	return s.BillingPeriod.Label()
+}
+
+// ActiveAt reports whether the subscription has started and not yet ended at t.
+func (s UserSub) ActiveAt(t time.Time) bool {
+	return !s.StartedAt.After(t) && (s.EndedAt == nil || s.EndedAt.After(t))
+}
+
+// ActiveInMonth reports whether the subscription overlaps the month starting
+// at month at all.
+func (s UserSub) ActiveInMonth(month time.Time) bool {
+	next := month.AddDate(0, 1, 0)
+
+	return s.StartedAt.Before(next) && (s.EndedAt == nil || !s.EndedAt.Before(month))
+}
+
+// ChargeInMonth is what the subscription bills in the month starting at month.
+// Any overlap with the month bills a full period: monthly subscriptions every
+// month, yearly ones in the month of their start anniversary.
+func (s UserSub) ChargeInMonth(month time.Time) int {
+	if !s.ActiveInMonth(month) {
+		return 0
+	}
+
+	if s.BillingPeriod == BillingYearly && month.Month() != s.StartedAt.Month() {
+		return 0
+	}
+
+	return s.ServicePrice
+}
+
+// NextBillingDate is the first billing anniversary of StartedAt strictly
+// after now: every month for monthly subscriptions, every year for yearly
+// ones. Anniversaries on days the month lacks fall on its last day, so a
+// subscription started on Jan 31 bills on Feb 28. It is nil once the
+// subscription has ended or will end before it is billed again.
+func (s UserSub) NextBillingDate(now time.Time) *time.Time {
+	if s.EndedAt != nil && !s.EndedAt.After(now) {
+		return nil
+	}
+
+	step := 1
+	if s.BillingPeriod == BillingYearly {
+		step = 12
+	}
+
+	// Jump close to now instead of stepping period by period from the start.
+	periods := 0
+	if s.StartedAt.Before(now) {
+		months := (now.Year()-s.StartedAt.Year())*12 + int(now.Month()-s.StartedAt.Month())
+		periods = months / step
+	}
+
+	next := AddMonthsClamped(s.StartedAt, periods*step)
+	for !next.After(now) {
+		periods++
+		next = AddMonthsClamped(s.StartedAt, periods*step)
+	}
+
+	if s.EndedAt != nil && !next.Before(*s.EndedAt) {
+		return nil
+	}
+
+	return &next
+}
+
+// AddMonthsClamped adds months to t, moving to the last day of the target
+// month when t's day does not exist in it.
+func AddMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+
+	day := t.Day()
+	if last := DaysInMonth(first); day > last {
+		day = last
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
+
+// Projected is the subscription as forecasts see it: an auto-renewing
+// subscription is assumed to continue past its end date.
+func (s UserSub) Projected() UserSub {
+	if s.AutoRenew {
+		s.EndedAt = nil
+	}
+
+	return s
+}
+
+// SavingsIfCancelled is what cancelling at now would save over the next
+// months months, charged the way forecasts charge them. The current month is
+// already billed and not counted. An ended subscription saves nothing.
+func (s UserSub) SavingsIfCancelled(now time.Time, months int) int {
+	projected := s.Projected()
+	if !projected.ActiveAt(now) {
+		return 0
+	}
+
+	savings := 0
+	month := MonthStart(now)
+	for range months {
+		month = month.AddDate(0, 1, 0)
+		savings += projected.ChargeInMonth(month)
+	}
+
+	return savings
+}
+
+var (
+	ErrInvalidPeriod = errors.New("invalid period")
+	ErrPeriodTooLong = errors.New("period is too long")
+	// ErrNoMatch means no subscription matched the filters of a total, as
+	// opposed to matching ones that cost nothing.
+	ErrNoMatch = errors.New("no subscriptions matched")
+)
+
+// MonthsBetween counts the calendar months from the month of from to the
+// month of to; 0 when both fall in the same month.
+func MonthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
+// Period is a range of whole months given as MM-YYYY strings, inclusive.
+type Period struct {
+	From string `json:"from" example:"01-2025"`
+	To   string `json:"to" example:"03-2025"`
+}
+
+// ParsePeriod splits "MM-YYYY:MM-YYYY" into a Period. A single month
+// "MM-YYYY" yields a period of that month alone.
+func ParsePeriod(s string) Period {
+	from, to, found := strings.Cut(s, ":")
+	if !found {
+		to = from
+	}
+
+	return Period{From: strings.TrimSpace(from), To: strings.TrimSpace(to)}
+}
+
+type PeriodTotal struct {
+	Period
+	Total int `json:"total" example:"2970"`
+}
+
+// PeriodComparison holds totals for two periods. PercentChange is relative to
+// PeriodA as a decimal string, or null when PeriodA's total is zero.
+type PeriodComparison struct {
+	PeriodA       PeriodTotal `json:"period_a"`
+	PeriodB       PeriodTotal `json:"period_b"`
+	Delta         int         `json:"delta" example:"990"`
+	PercentChange *string     `json:"percent_change" example:"33.33"`
+}
+
+func NewPeriodComparison(a, b PeriodTotal) *PeriodComparison {
+	cmp := &PeriodComparison{PeriodA: a, PeriodB: b, Delta: b.Total - a.Total}
+
+	if a.Total != 0 {
+		pct := big.NewRat(int64(cmp.Delta)*100, int64(a.Total)).FloatString(2)
+		cmp.PercentChange = &pct
+	}
+
+	return cmp
+}
+
+// CostOptions tweaks how GetTotalCost computes a total.
+type CostOptions struct {
+	// Prorate charges a month by the share of its days the subscription was
+	// active instead of counting it in full.
+	Prorate bool
+	// Location is the time zone month boundaries are computed in. Nil means UTC.
+	Location *time.Location
+	// Rounding is how a fractional total is brought to whole minor units.
+	// Empty means RoundingHalfUp.
+	Rounding RoundingMode
+	// RequireMatch fails with ErrNoMatch instead of returning 0 when no
+	// subscription matched.
+	RequireMatch bool
+}
+
+// MonthlyPrice is the price normalized to one month: yearly prices are spread
+// evenly over twelve months.
+func (s UserSub) MonthlyPrice() *big.Rat {
+	if s.BillingPeriod == BillingYearly {
+		return big.NewRat(int64(s.ServicePrice), 12)
+	}
+
+	return big.NewRat(int64(s.ServicePrice), 1)
+}
+
+// MRR is the monthly recurring revenue of subs in the month starting at month:
+// the monthly-normalized price of every subscription active in it.
+func MRR(subs []*UserSub, month time.Time) *big.Rat {
+	total := new(big.Rat)
+	for _, sub := range subs {
+		if sub.ActiveInMonth(month) {
+			total.Add(total, sub.MonthlyPrice())
+		}
+	}
+
+	return total
+}
+
+// ActiveDaysInMonth counts the calendar days of the month starting at month
+// on which the subscription was active. A partial day counts as a full one.
+func (s UserSub) ActiveDaysInMonth(month time.Time) int {
+	next := month.AddDate(0, 1, 0)
+
+	start := s.StartedAt
+	if start.Before(month) {
+		start = month
+	}
+
+	end := next
+	if s.EndedAt != nil && s.EndedAt.Before(next) {
+		end = *s.EndedAt
+	}
+
+	if !end.After(start) {
+		return 0
+	}
+
+	start, end = start.In(month.Location()), end.In(month.Location())
+
+	days := dayNumber(end) - dayNumber(start)
+	if end.Hour() != 0 || end.Minute() != 0 || end.Second() != 0 || end.Nanosecond() != 0 {
+		days++
+	}
+
+	return days
+}
+
+// ProratedChargeInMonth is the monthly price scaled by the share of the
+// month's days the subscription was active, kept exact as a fraction. A
+// zero-length subscription has no days to prorate by and is charged in full,
+// as ChargeInMonth charges it.
+func (s UserSub) ProratedChargeInMonth(month time.Time) *big.Rat {
+	if s.ZeroLength() {
+		return big.NewRat(int64(s.ChargeInMonth(month)), 1)
+	}
+
+	share := s.ActiveShareInMonth(month)
+
+	return share.Mul(share, s.MonthlyPrice())
+}
+
+// ActiveShareInMonth is the share of the days of the month starting at month
+// on which the subscription was active, the factor ProratedChargeInMonth
+// scales the price by.
+func (s UserSub) ActiveShareInMonth(month time.Time) *big.Rat {
+	return big.NewRat(int64(s.ActiveDaysInMonth(month)), int64(DaysInMonth(month)))
+}
+
+// WeightedActiveCount sums the ActiveShareInMonth of subs for the month
+// starting at month. A zero-length subscription has no active days and adds
+// nothing.
+func WeightedActiveCount(subs []*UserSub, month time.Time) *big.Rat {
+	total := new(big.Rat)
+	for _, sub := range subs {
+		total.Add(total, sub.ActiveShareInMonth(month))
+	}
+
+	return total
+}
+
+// BilledInMonth reports whether the month counts towards a prorated total:
+// the subscription was active on some day of it, or it is a zero-length
+// one starting in it.
+func (s UserSub) BilledInMonth(month time.Time) bool {
+	if s.ZeroLength() {
+		return s.ActiveInMonth(month)
+	}
+
+	return s.ActiveDaysInMonth(month) > 0
+}
+
+func DaysInMonth(month time.Time) int {
+	return MonthStart(month).AddDate(0, 1, -1).Day()
+}
+
+// RoundHalfUp rounds a non-negative amount to the nearest whole minor unit.
+func RoundHalfUp(amount *big.Rat) int64 {
+	num := new(big.Int).Mul(amount.Num(), big.NewInt(2))
+	num.Add(num, amount.Denom())
+	den := new(big.Int).Mul(amount.Denom(), big.NewInt(2))
+
+	return new(big.Int).Quo(num, den).Int64()
+}
+
+// RoundingMode selects how a fractional non-negative amount is rounded to a
+// whole minor unit.
+type RoundingMode string
+
+const (
+	// RoundingHalfUp rounds halves away from zero: 2.5 -> 3.
+	RoundingHalfUp RoundingMode = "half_up"
+	// RoundingBankers rounds halves to the even neighbour: 2.5 -> 2, 3.5 -> 4.
+	RoundingBankers RoundingMode = "bankers"
+	// RoundingFloor drops the fraction: 2.9 -> 2.
+	RoundingFloor RoundingMode = "floor"
+)
+
+func (m RoundingMode) Valid() bool {
+	switch m {
+	case RoundingHalfUp, RoundingBankers, RoundingFloor:
+		return true
+	}
+
+	return false
+}
+
+// Round rounds amount according to m. An empty mode rounds half-up.
+func (m RoundingMode) Round(amount *big.Rat) int64 {
+	switch m {
+	case RoundingFloor:
+		return new(big.Int).Quo(amount.Num(), amount.Denom()).Int64()
+	case RoundingBankers:
+		quo, rem := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+		switch new(big.Int).Mul(rem, big.NewInt(2)).Cmp(amount.Denom()) {
+		case 1:
+			quo.Add(quo, big.NewInt(1))
+		case 0:
+			if quo.Bit(0) == 1 {
+				quo.Add(quo, big.NewInt(1))
+			}
+		}
+		return quo.Int64()
+	}
+
+	return RoundHalfUp(amount)
+}
+
+// dayNumber is the count of days since the Unix epoch for t's calendar date.
+func dayNumber(t time.Time) int {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	return int(date.Unix() / 86400)
+}
@@ -0,0 +1,265 @@
+package domain
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestBillingLabel(t *testing.T) {
+	start := date(2025, 3, 1)
+	end := date(2025, 6, 1)
+
+	tests := []struct {
+		name string
+		sub  UserSub
+		want string
+	}{
+		{name: "monthly", sub: UserSub{BillingPeriod: BillingMonthly, StartedAt: start}, want: "monthly"},
+		{name: "yearly", sub: UserSub{BillingPeriod: BillingYearly, StartedAt: start}, want: "yearly"},
+		{name: "ended monthly", sub: UserSub{BillingPeriod: BillingMonthly, StartedAt: start, EndedAt: &end}, want: "monthly"},
+		{name: "one-time monthly", sub: UserSub{BillingPeriod: BillingMonthly, StartedAt: start, EndedAt: &start}, want: "one-time"},
+		{name: "one-time yearly", sub: UserSub{BillingPeriod: BillingYearly, StartedAt: start, EndedAt: &start}, want: "one-time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.BillingLabel(); got != tt.want {
+				t.Errorf("BillingLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextBillingDate(t *testing.T) {
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name string
+		sub  UserSub
+		now  time.Time
+		want *time.Time
+	}{
+		{name: "clamped to the end of february", sub: UserSub{StartedAt: date(2025, 1, 31)}, now: date(2025, 2, 10), want: ptr(date(2025, 2, 28))},
+		{name: "back to the 31st after february", sub: UserSub{StartedAt: date(2025, 1, 31)}, now: date(2025, 2, 28), want: ptr(date(2025, 3, 31))},
+		{name: "strictly after now", sub: UserSub{StartedAt: date(2025, 1, 15)}, now: date(2025, 1, 15), want: ptr(date(2025, 2, 15))},
+		{name: "many periods later", sub: UserSub{StartedAt: date(2020, 5, 20)}, now: date(2025, 5, 21), want: ptr(date(2025, 6, 20))},
+		{name: "not started yet", sub: UserSub{StartedAt: date(2025, 3, 1)}, now: date(2025, 2, 1), want: ptr(date(2025, 3, 1))},
+		{name: "yearly from a leap day", sub: UserSub{StartedAt: date(2024, 2, 29), BillingPeriod: BillingYearly}, now: date(2024, 3, 1), want: ptr(date(2025, 2, 28))},
+		{name: "yearly anniversary", sub: UserSub{StartedAt: date(2023, 6, 1), BillingPeriod: BillingYearly}, now: date(2025, 6, 1), want: ptr(date(2026, 6, 1))},
+		{name: "ended", sub: UserSub{StartedAt: date(2025, 1, 1), EndedAt: ptr(date(2025, 2, 1))}, now: date(2025, 3, 1)},
+		{name: "ends on the next billing date", sub: UserSub{StartedAt: date(2025, 1, 1), EndedAt: ptr(date(2025, 2, 1))}, now: date(2025, 1, 10)},
+		{name: "ends after the next billing date", sub: UserSub{StartedAt: date(2025, 1, 1), EndedAt: ptr(date(2025, 2, 2))}, now: date(2025, 1, 10), want: ptr(date(2025, 2, 1))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.sub.NextBillingDate(tt.now)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || !got.Equal(*tt.want):
+				t.Errorf("NextBillingDate(%s) = %v, want %v", tt.now.Format(time.DateOnly), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddMonthsClamped(t *testing.T) {
+	tests := []struct {
+		from   time.Time
+		months int
+		want   time.Time
+	}{
+		{from: date(2025, 1, 31), months: 1, want: date(2025, 2, 28)},
+		{from: date(2024, 1, 31), months: 1, want: date(2024, 2, 29)},
+		{from: date(2025, 3, 31), months: -1, want: date(2025, 2, 28)},
+		{from: date(2025, 12, 15), months: 2, want: date(2026, 2, 15)},
+		{from: date(2025, 5, 31), months: 0, want: date(2025, 5, 31)},
+	}
+
+	for _, tt := range tests {
+		if got := AddMonthsClamped(tt.from, tt.months); !got.Equal(tt.want) {
+			t.Errorf("AddMonthsClamped(%s, %d) = %s, want %s", tt.from.Format(time.DateOnly), tt.months, got.Format(time.DateOnly), tt.want.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestRoundingModeRound(t *testing.T) {
+	tests := []struct {
+		amount                   *big.Rat
+		halfUp, bankers, floored int64
+	}{
+		{amount: big.NewRat(4, 1), halfUp: 4, bankers: 4, floored: 4},
+		{amount: big.NewRat(5, 2), halfUp: 3, bankers: 2, floored: 2},
+		{amount: big.NewRat(7, 2), halfUp: 4, bankers: 4, floored: 3},
+		{amount: big.NewRat(21, 10), halfUp: 2, bankers: 2, floored: 2},
+		{amount: big.NewRat(29, 10), halfUp: 3, bankers: 3, floored: 2},
+		{amount: big.NewRat(1, 3), halfUp: 0, bankers: 0, floored: 0},
+	}
+
+	for _, tt := range tests {
+		for mode, want := range map[RoundingMode]int64{
+			"":              tt.halfUp,
+			RoundingHalfUp:  tt.halfUp,
+			RoundingBankers: tt.bankers,
+			RoundingFloor:   tt.floored,
+		} {
+			if got := mode.Round(tt.amount); got != want {
+				t.Errorf("%q.Round(%s) = %d, want %d", mode, tt.amount.RatString(), got, want)
+			}
+		}
+	}
+}
+
+func TestChargeInMonth(t *testing.T) {
+	end := date(2025, 3, 10)
+	monthly := UserSub{ServicePrice: 100, BillingPeriod: BillingMonthly, StartedAt: date(2025, 1, 15), EndedAt: &end}
+	yearly := UserSub{ServicePrice: 1200, BillingPeriod: BillingYearly, StartedAt: date(2025, 3, 20)}
+
+	tests := []struct {
+		name  string
+		sub   UserSub
+		month time.Time
+		want  int
+	}{
+		{name: "monthly before start", sub: monthly, month: date(2024, 12, 1)},
+		{name: "monthly partial first month", sub: monthly, month: date(2025, 1, 1), want: 100},
+		{name: "monthly partial last month", sub: monthly, month: date(2025, 3, 1), want: 100},
+		{name: "monthly after end", sub: monthly, month: date(2025, 4, 1)},
+		{name: "yearly start month", sub: yearly, month: date(2025, 3, 1), want: 1200},
+		{name: "yearly other month", sub: yearly, month: date(2025, 4, 1)},
+		{name: "yearly anniversary", sub: yearly, month: date(2026, 3, 1), want: 1200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.ChargeInMonth(tt.month); got != tt.want {
+				t.Errorf("ChargeInMonth(%s) = %d, want %d", tt.month.Format(MonthLayout), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProratedChargeInMonth(t *testing.T) {
+	endMidnight := date(2025, 3, 10)
+	endNoon := endMidnight.Add(12 * time.Hour)
+	start := date(2025, 6, 5)
+
+	tests := []struct {
+		name  string
+		sub   UserSub
+		month time.Time
+		days  int
+		want  *big.Rat
+	}{
+		{name: "from the 15th", sub: UserSub{ServicePrice: 310, StartedAt: date(2025, 1, 15)}, month: date(2025, 1, 1), days: 17, want: big.NewRat(170, 1)},
+		{name: "whole month", sub: UserSub{ServicePrice: 310, StartedAt: date(2025, 1, 15)}, month: date(2025, 2, 1), days: 28, want: big.NewRat(310, 1)},
+		{name: "ends at midnight", sub: UserSub{ServicePrice: 310, StartedAt: date(2025, 1, 15), EndedAt: &endMidnight}, month: date(2025, 3, 1), days: 9, want: big.NewRat(90, 1)},
+		{name: "partial last day counts", sub: UserSub{ServicePrice: 310, StartedAt: date(2025, 1, 15), EndedAt: &endNoon}, month: date(2025, 3, 1), days: 10, want: big.NewRat(100, 1)},
+		{name: "yearly spread over twelve months", sub: UserSub{ServicePrice: 1200, BillingPeriod: BillingYearly, StartedAt: date(2025, 1, 1)}, month: date(2025, 4, 1), days: 30, want: big.NewRat(100, 1)},
+		{name: "zero length charged in full", sub: UserSub{ServicePrice: 1200, BillingPeriod: BillingYearly, StartedAt: start, EndedAt: &start}, month: date(2025, 6, 1), want: big.NewRat(1200, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.ActiveDaysInMonth(tt.month); got != tt.days {
+				t.Errorf("ActiveDaysInMonth = %d, want %d", got, tt.days)
+			}
+			if got := tt.sub.ProratedChargeInMonth(tt.month); got.Cmp(tt.want) != 0 {
+				t.Errorf("ProratedChargeInMonth = %s, want %s", got.RatString(), tt.want.RatString())
+			}
+		})
+	}
+}
+
+func TestSavingsIfCancelled(t *testing.T) {
+	now := date(2025, 3, 10)
+	end := date(2025, 4, 15)
+	past := date(2025, 2, 1)
+
+	tests := []struct {
+		name string
+		sub  UserSub
+		want int
+	}{
+		{name: "open monthly", sub: UserSub{ServicePrice: 100, StartedAt: date(2024, 1, 1)}, want: 300},
+		{name: "ends next month", sub: UserSub{ServicePrice: 100, StartedAt: date(2024, 1, 1), EndedAt: &end}, want: 100},
+		{name: "auto-renew ignores the end", sub: UserSub{ServicePrice: 100, StartedAt: date(2024, 1, 1), EndedAt: &end, AutoRenew: true}, want: 300},
+		{name: "already ended", sub: UserSub{ServicePrice: 100, StartedAt: date(2024, 1, 1), EndedAt: &past}},
+		{name: "yearly anniversary in range", sub: UserSub{ServicePrice: 1200, BillingPeriod: BillingYearly, StartedAt: date(2024, 5, 1)}, want: 1200},
+		{name: "yearly anniversary out of range", sub: UserSub{ServicePrice: 1200, BillingPeriod: BillingYearly, StartedAt: date(2024, 9, 1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.SavingsIfCancelled(now, 3); got != tt.want {
+				t.Errorf("SavingsIfCancelled = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPeriodComparison(t *testing.T) {
+	tests := []struct {
+		a, b  int
+		delta int
+		pct   string
+	}{
+		{a: 300, b: 400, delta: 100, pct: "33.33"},
+		{a: 300, b: 200, delta: -100, pct: "-33.33"},
+		{a: 300, b: 300, pct: "0.00"},
+		{a: 0, b: 300, delta: 300},
+	}
+
+	for _, tt := range tests {
+		got := NewPeriodComparison(PeriodTotal{Total: tt.a}, PeriodTotal{Total: tt.b})
+
+		if got.Delta != tt.delta {
+			t.Errorf("%d -> %d: delta = %d, want %d", tt.a, tt.b, got.Delta, tt.delta)
+		}
+
+		var pct string
+		if got.PercentChange != nil {
+			pct = *got.PercentChange
+		}
+		if pct != tt.pct {
+			t.Errorf("%d -> %d: percent change = %q, want %q", tt.a, tt.b, pct, tt.pct)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Period
+	}{
+		{in: "01-2025:03-2025", want: Period{From: "01-2025", To: "03-2025"}},
+		{in: " 01-2025 : 02-2025 ", want: Period{From: "01-2025", To: "02-2025"}},
+		{in: "05-2025", want: Period{From: "05-2025", To: "05-2025"}},
+	}
+
+	for _, tt := range tests {
+		if got := ParsePeriod(tt.in); got != tt.want {
+			t.Errorf("ParsePeriod(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMRRNormalizesYearly(t *testing.T) {
+	month := date(2025, 3, 1)
+	ended := date(2025, 2, 1)
+	subs := []*UserSub{
+		{ServicePrice: 1000, StartedAt: date(2024, 1, 1), BillingPeriod: BillingMonthly},
+		{ServicePrice: 1200, StartedAt: date(2024, 6, 1), BillingPeriod: BillingYearly},
+		{ServicePrice: 1000, StartedAt: date(2024, 9, 1), BillingPeriod: BillingYearly},
+		{ServicePrice: 500, StartedAt: date(2024, 1, 1), EndedAt: &ended, BillingPeriod: BillingMonthly},
+	}
+
+	if got, want := MRR(subs, month), big.NewRat(14200, 12); got.Cmp(want) != 0 {
+		t.Errorf("MRR = %s, want %s", got.RatString(), want.RatString())
+	}
+}
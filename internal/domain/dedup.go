@@ -0,0 +1,18 @@
+package domain
+
+import "github.com/google/uuid"
+
+// DedupGroup is one set of active subscriptions duplicated on
+// (user_id, service_name): KeptID stayed active and EndedIDs were ended.
+type DedupGroup struct {
+	UserID      uuid.UUID   `json:"user_id" example:"550e8400-e29b-41d4-a716-446655441111"`
+	ServiceName string      `json:"service_name" example:"Netflix"`
+	KeptID      uuid.UUID   `json:"kept_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	EndedIDs    []uuid.UUID `json:"ended_ids"`
+}
+
+// DedupReport lists what a deduplication run merged.
+type DedupReport struct {
+	Groups []DedupGroup `json:"groups"`
+	Ended  int          `json:"ended" example:"3"`
+}
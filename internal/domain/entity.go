@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,11 +13,216 @@ const (
 	EnvProd  = "prod"
 )
 
+type BuildInfo struct {
+	Version   string `json:"version" example:"v1.2.3"`
+	Commit    string `json:"commit" example:"eba06e0"`
+	BuildTime string `json:"build_time" example:"2025-07-01T00:00:00Z"`
+	GoVersion string `json:"go_version" example:"go1.25.3"`
+}
+
 type UserSub struct {
-	ID           uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	ServiceName  string     `json:"service_name" example:"Netflix"`
-	ServicePrice int        `json:"service_price" example:"990"`
-	UserID       uuid.UUID  `json:"user_id" example:"550e8400-e29b-41d4-a716-446655441111"`
-	StartedAt    time.Time  `json:"started_at" example:"2025-07-01T00:00:00Z"`
-	EndedAt      *time.Time `json:"ended_at,omitempty" example:"2026-07-01T00:00:00Z"`
+	ID           uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	ServiceName  string    `json:"service_name" example:"Netflix"`
+	ServicePrice int       `json:"service_price" example:"990"`
+	// Currency is an ISO 4217 code; new subscriptions default to money.default_currency.
+	Currency      string        `json:"currency,omitempty" example:"RUB"`
+	UserID        uuid.UUID     `json:"user_id" example:"550e8400-e29b-41d4-a716-446655441111"`
+	StartedAt     time.Time     `json:"started_at" example:"2025-07-01T00:00:00Z"`
+	EndedAt       *time.Time    `json:"ended_at,omitempty" example:"2026-07-01T00:00:00Z"`
+	BillingPeriod BillingPeriod `json:"billing_period" enums:"monthly,yearly" example:"monthly"`
+	PaymentMethod *string       `json:"payment_method,omitempty" example:"visa-1234"`
+	// AutoRenew makes forecasts assume the subscription keeps renewing past EndedAt.
+	AutoRenew bool `json:"auto_renew" example:"false"`
+	// Category is inferred from ServiceName when a subscription is created
+	// without one; unknown services get "other".
+	Category string `json:"category,omitempty" example:"entertainment"`
+	// Tags are managed through the tags endpoints and ignored on create/update.
+	Tags []string `json:"tags,omitempty" example:"work,streaming"`
+	// CreatedAt is set by the database on insert and ignored on create/update.
+	CreatedAt time.Time `json:"created_at" example:"2025-07-01T12:30:00Z"`
+}
+
+// Facet statuses of a subscription at a given moment. An upcoming one has
+// not started yet.
+const (
+	StatusActive   = "active"
+	StatusEnded    = "ended"
+	StatusUpcoming = "upcoming"
+)
+
+type FacetBucket struct {
+	Value string `json:"value" example:"Netflix"`
+	Count int    `json:"count" example:"2"`
+}
+
+type PriceFacet struct {
+	Min int `json:"min" example:"199"`
+	Max int `json:"max" example:"990"`
+	Avg int `json:"avg" example:"594"`
+}
+
+// SubFacets summarizes a user's subscriptions for filter sidebars.
+type SubFacets struct {
+	Services []FacetBucket `json:"services"`
+	Statuses []FacetBucket `json:"statuses"`
+	Price    PriceFacet    `json:"price"`
+}
+
+// SubStats is a compact summary of a user's subscriptions for dashboards.
+type SubStats struct {
+	Total  int `json:"total" example:"5"`
+	Active int `json:"active" example:"3"`
+	// MonthlySpend is the monthly-normalized price of the active
+	// subscriptions (yearly ones at a twelfth), rounded half-up.
+	MonthlySpend int `json:"monthly_spend" example:"2490"`
+	AvgPrice     int `json:"avg_price" example:"830"`
+	// MostExpensiveService has the highest monthly-normalized price among
+	// active subscriptions; null when none is active.
+	MostExpensiveService *string `json:"most_expensive_service" example:"Netflix"`
+}
+
+// ServicePriceStats summarizes what all users currently pay for one service
+// in one currency, so a user can tell whether they overpay. Prices are
+// monthly-normalized (yearly ones at a twelfth) and rounded half-up; all are
+// zero when Count is.
+type ServicePriceStats struct {
+	ServiceName string `json:"service_name" example:"Netflix"`
+	Currency    string `json:"currency" example:"RUB"`
+	Count       int    `json:"count" example:"12"`
+	Min         int    `json:"min" example:"599"`
+	Max         int    `json:"max" example:"1190"`
+	Avg         int    `json:"avg" example:"845"`
+	Median      int    `json:"median" example:"799"`
+}
+
+// SubNeighbors are the ids of the subscriptions of the same user started just
+// before and just after a given one; nil at either end.
+type SubNeighbors struct {
+	PrevID *uuid.UUID `json:"prev_id" swaggertype:"string" example:"550e8400-e29b-41d4-a716-446655440001"`
+	NextID *uuid.UUID `json:"next_id" swaggertype:"string" example:"550e8400-e29b-41d4-a716-446655440002"`
+}
+
+// SubUpdate is the payload of a subscription update. Optional fields tell an
+// omitted field (left unchanged) from an explicit null (cleared).
+type SubUpdate struct {
+	ID           uuid.UUID `json:"-"`
+	ServiceName  string    `json:"service_name" example:"Netflix"`
+	ServicePrice int       `json:"service_price" example:"990"`
+	// Currency is left unchanged when empty.
+	Currency string               `json:"currency,omitempty" example:"RUB"`
+	UserID   uuid.UUID            `json:"user_id" example:"550e8400-e29b-41d4-a716-446655441111"`
+	EndedAt  Optional[*time.Time] `json:"ended_at" swaggertype:"string" example:"2026-07-01T00:00:00Z"`
+	// BillingPeriod is left unchanged when empty.
+	BillingPeriod BillingPeriod     `json:"billing_period,omitempty" enums:"monthly,yearly" example:"monthly"`
+	PaymentMethod Optional[*string] `json:"payment_method" swaggertype:"string" example:"visa-1234"`
+	AutoRenew     Optional[bool]    `json:"auto_renew" swaggertype:"boolean" example:"false"`
+	// Category is left unchanged when empty.
+	Category string `json:"category,omitempty" example:"entertainment"`
+}
+
+// Apply writes the update onto sub, leaving omitted fields unchanged.
+func (u SubUpdate) Apply(sub *UserSub) {
+	sub.ServiceName = u.ServiceName
+	sub.ServicePrice = u.ServicePrice
+	if u.EndedAt.Set {
+		sub.EndedAt = u.EndedAt.Value
+	}
+	if u.Currency != "" {
+		sub.Currency = u.Currency
+	}
+	if u.BillingPeriod != "" {
+		sub.BillingPeriod = u.BillingPeriod
+	}
+	if u.PaymentMethod.Set {
+		sub.PaymentMethod = u.PaymentMethod.Value
+	}
+	if u.AutoRenew.Set {
+		sub.AutoRenew = u.AutoRenew.Value
+	}
+	if u.Category != "" {
+		sub.Category = u.Category
+	}
+}
+
+var (
+	ErrEmptyFilter  = errors.New("at least one filter is required")
+	ErrSubNotFound  = errors.New("subscription not found")
+	ErrInvalidPatch = errors.New("invalid merge patch")
+	// ErrSubNotCancelled means the subscription has no ended_at in the past.
+	ErrSubNotCancelled = errors.New("subscription is not cancelled")
+	// ErrAmbiguousSub means a lookup expecting one subscription matched several.
+	ErrAmbiguousSub = errors.New("more than one active subscription matches")
+	// ErrStorageBusy means no database connection freed up in time.
+	ErrStorageBusy = errors.New("storage is busy")
+)
+
+type SubFilter struct {
+	UserID      *uuid.UUID
+	ServiceName string
+	// ServiceNames matches any of the listed services.
+	ServiceNames  []string
+	PaymentMethod string
+	Tags          []string
+	TagMode       TagMode
+	// IncludeShared also matches subscriptions shared with UserID.
+	IncludeShared bool
+	// ExcludeFree drops subscriptions priced at zero, such as free trials.
+	// It narrows other filters and does not count as one on its own.
+	ExcludeFree bool
+	// CreatedFrom and CreatedTo bound created_at inclusively.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// TagMode decides whether a tag filter matches subscriptions carrying any of
+// the tags or all of them.
+type TagMode string
+
+const (
+	TagModeAny TagMode = "any"
+	TagModeAll TagMode = "all"
+)
+
+// HasCreatedRange reports whether the filter bounds created_at.
+func (f SubFilter) HasCreatedRange() bool {
+	return f.CreatedFrom != nil || f.CreatedTo != nil
+}
+
+func (m TagMode) Valid() bool {
+	return m == TagModeAny || m == TagModeAll
+}
+
+func (f SubFilter) IsEmpty() bool {
+	return f.UserID == nil && f.ServiceName == "" && len(f.ServiceNames) == 0 && f.PaymentMethod == "" && len(f.Tags) == 0
+}
+
+// Page bounds a list query. A zero Limit means no limit, which only internal
+// callers use: pages parsed from requests always carry one bounded by
+// pagination.max_limit.
+type Page struct {
+	Limit  uint64
+	Offset uint64
+	Sort   Sort
+}
+
+// Sort orders list results by Column. Rows with equal values are ordered by
+// id in the same direction, so consecutive pages neither repeat nor skip rows.
+// The zero Sort is DefaultSort.
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+const (
+	SortStartedAt    = "started_at"
+	SortCreatedAt    = "created_at"
+	SortServiceName  = "service_name"
+	SortServicePrice = "service_price"
+)
+
+var DefaultSort = Sort{Column: SortStartedAt, Desc: true}
+
+func (s Sort) Valid() bool {
+	_, ok := SortColumn(s.Column)
+	return ok
 }
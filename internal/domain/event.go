@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventSubscriptionCreated EventType = "subscription.created"
+	EventSubscriptionUpdated EventType = "subscription.updated"
+	EventSubscriptionDeleted EventType = "subscription.deleted"
+	// EventSubscriptionExpiring is a reminder due to the user that the
+	// subscription ends soon.
+	EventSubscriptionExpiring EventType = "subscription.expiring"
+)
+
+// SubEvent announces a committed change to a subscription. Sub is the data
+// written; it is nil for deletions.
+type SubEvent struct {
+	Type           EventType `json:"type"`
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Sub            *UserSub  `json:"subscription,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	// Channel is where the user wants a subscription.expiring reminder
+	// delivered; it is empty for other events.
+	Channel ReminderChannel `json:"channel,omitempty"`
+}
+
+func NewSubEvent(eventType EventType, subID, userID uuid.UUID, sub *UserSub) SubEvent {
+	return SubEvent{
+		Type:           eventType,
+		SubscriptionID: subID,
+		UserID:         userID,
+		Sub:            sub,
+		OccurredAt:     time.Now().UTC(),
+	}
+}
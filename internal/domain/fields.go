@@ -0,0 +1,46 @@
+package domain
+
+// SubField is a subscription field as the API names it. Column is the SQL
+// column behind it, empty for fields computed for each response.
+type SubField struct {
+	Column   string
+	Sortable bool
+}
+
+// SubFields is the allowlist of subscription field names, keyed by API
+// name. Field selection, list sorting and the storage filters only accept
+// names listed here, and SQL is only built from their Column, never from
+// client input.
+var SubFields = map[string]SubField{
+	"id":                {Column: "id"},
+	"service_name":      {Column: "service_name", Sortable: true},
+	"service_price":     {Column: "sub_price", Sortable: true},
+	"currency":          {Column: "currency"},
+	"user_id":           {Column: "user_id"},
+	"started_at":        {Column: "started_at", Sortable: true},
+	"ended_at":          {Column: "ended_at"},
+	"billing_period":    {Column: "billing_period"},
+	"payment_method":    {Column: "payment_method"},
+	"auto_renew":        {Column: "auto_renew"},
+	"category":          {Column: "category"},
+	"created_at":        {Column: "created_at", Sortable: true},
+	"tags":              {},
+	"days_active":       {},
+	"days_remaining":    {},
+	"billing_label":     {},
+	"formatted_price":   {},
+	"next_billing_date": {},
+	"sandbox":           {},
+	"neighbors":         {},
+}
+
+// SortColumn returns the SQL column for ordering by the field name, or false
+// when name is unknown or not sortable.
+func SortColumn(name string) (string, bool) {
+	field, ok := SubFields[name]
+	if !ok || !field.Sortable {
+		return "", false
+	}
+
+	return field.Column, true
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "testing"
+
+func TestSortColumn(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: SortServicePrice, want: "sub_price", wantOK: true},
+		{name: SortStartedAt, want: "started_at", wantOK: true},
+		{name: "currency"},
+		{name: "days_active"},
+		{name: "sub_price"},
+		{name: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SortColumn(tt.name)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("SortColumn(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+			}
+			if valid := (Sort{Column: tt.name}).Valid(); valid != tt.wantOK {
+				t.Errorf("Sort{%q}.Valid() = %v, want %v", tt.name, valid, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MinorUnitDigits is the number of minor-unit digits in a price, e.g. 2 for
+// cents: 2997 minor units are 29.97.
+const MinorUnitDigits = 2
+
+// PriceUnit says how prices are stored: as minor units (kopecks, cents) or as
+// whole major units. The database column is an integer either way.
+type PriceUnit string
+
+const (
+	PriceUnitMinor PriceUnit = "minor"
+	PriceUnitMajor PriceUnit = "major"
+)
+
+func (u PriceUnit) Valid() bool {
+	return u == PriceUnitMinor || u == PriceUnitMajor
+}
+
+// ToMinor converts a stored amount to minor units.
+func (u PriceUnit) ToMinor(amount int64) int64 {
+	if u == PriceUnitMajor {
+		for range MinorUnitDigits {
+			amount *= 10
+		}
+	}
+
+	return amount
+}
+
+// Format renders a stored amount as a decimal string with MinorUnitDigits
+// fraction digits, whatever the storage unit.
+func (u PriceUnit) Format(amount int64) string {
+	return FormatMinorUnits(u.ToMinor(amount), MinorUnitDigits)
+}
+
+// FormatMinorUnits renders an amount given in minor units as a decimal string
+// using integer math only, so no float rounding can creep in.
+func FormatMinorUnits(amount int64, digits int) string {
+	if digits <= 0 {
+		return fmt.Sprintf("%d", amount)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	scale := int64(1)
+	for range digits {
+		scale *= 10
+	}
+
+	whole := amount / scale
+	frac := fmt.Sprintf("%d", amount%scale)
+
+	return fmt.Sprintf("%s%d.%s%s", sign, whole, strings.Repeat("0", digits-len(frac)), frac)
+}
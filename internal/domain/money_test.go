@@ -0,0 +1,43 @@
+package domain
+
+import "testing"
+
+func TestFormatMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount int64
+		digits int
+		want   string
+	}{
+		{amount: 2997, digits: 2, want: "29.97"},
+		{amount: 5, digits: 2, want: "0.05"},
+		{amount: 100, digits: 2, want: "1.00"},
+		{amount: 0, digits: 2, want: "0.00"},
+		{amount: -1050, digits: 2, want: "-10.50"},
+		{amount: 1234, digits: 0, want: "1234"},
+		{amount: 9_007_199_254_740_993, digits: 2, want: "90071992547409.93"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatMinorUnits(tt.amount, tt.digits); got != tt.want {
+			t.Errorf("FormatMinorUnits(%d, %d) = %q, want %q", tt.amount, tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestPriceUnitFormat(t *testing.T) {
+	if got := PriceUnitMinor.Format(2997); got != "29.97" {
+		t.Errorf("minor Format(2997) = %q, want 29.97", got)
+	}
+	if got := PriceUnitMajor.Format(30); got != "30.00" {
+		t.Errorf("major Format(30) = %q, want 30.00", got)
+	}
+}
+
+func TestPriceUnitToMinor(t *testing.T) {
+	if got := PriceUnitMinor.ToMinor(2997); got != 2997 {
+		t.Errorf("minor ToMinor(2997) = %d, want 2997", got)
+	}
+	if got := PriceUnitMajor.ToMinor(30); got != 3000 {
+		t.Errorf("major ToMinor(30) = %d, want 3000", got)
+	}
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "encoding/json"
+
+// Optional records whether a JSON field was present at all, which a plain
+// pointer cannot: both an absent key and an explicit null decode to nil.
+type Optional[T any] struct {
+	Value T
+	Set   bool
+}
+
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{Value: value, Set: true}
+}
+
+// UnmarshalJSON is only called for keys present in the input, null included.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Value)
+}
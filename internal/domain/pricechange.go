@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PriceChange records that an update moved the price of a subscription from
+// OldPrice to NewPrice at ChangedAt.
+type PriceChange struct {
+	SubscriptionID uuid.UUID `json:"subscription_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	OldPrice       int       `json:"old_price" example:"799"`
+	NewPrice       int       `json:"new_price" example:"990"`
+	ChangedAt      time.Time `json:"changed_at" example:"2026-07-01T00:00:00Z"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReminderChannel string
+
+const (
+	ReminderEmail ReminderChannel = "email"
+	ReminderPush  ReminderChannel = "push"
+	ReminderSMS   ReminderChannel = "sms"
+)
+
+func (c ReminderChannel) Valid() bool {
+	return c == ReminderEmail || c == ReminderPush || c == ReminderSMS
+}
+
+// ReminderPreference is how a user wants to be told about subscriptions
+// about to expire: LeadTimeDays before ended_at, over Channel.
+type ReminderPreference struct {
+	UserID       uuid.UUID       `json:"user_id" example:"550e8400-e29b-41d4-a716-446655441111"`
+	LeadTimeDays int             `json:"lead_time_days" example:"7"`
+	Channel      ReminderChannel `json:"channel" enums:"email,push,sms" example:"email"`
+}
+
+var ErrReminderPreferenceNotFound = errors.New("reminder preferences not found")
+
+// Reminder is a notice that Sub is about to end, due LeadTimeDays before its
+// ended_at and delivered over Channel, as its user's preference asks.
+type Reminder struct {
+	Sub          UserSub
+	LeadTimeDays int
+	Channel      ReminderChannel
+}
+
+// DueAt is when the reminder should be sent.
+func (r Reminder) DueAt() time.Time {
+	return r.Sub.EndedAt.AddDate(0, 0, -r.LeadTimeDays)
+}
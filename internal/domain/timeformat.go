@@ -0,0 +1,35 @@
+package domain
+
+// TimeFormat selects how started_at and ended_at are rendered in responses.
+type TimeFormat string
+
+const (
+	// TimeFormatRFC3339 keeps the full timestamp, with nanoseconds when set.
+	TimeFormatRFC3339 TimeFormat = "rfc3339"
+	// TimeFormatDate renders the date alone: 2025-07-01.
+	TimeFormatDate TimeFormat = "date"
+	// TimeFormatMonth renders the month as MM-YYYY, like period parameters.
+	TimeFormatMonth TimeFormat = "month"
+)
+
+func (f TimeFormat) Valid() bool {
+	switch f {
+	case TimeFormatRFC3339, TimeFormatDate, TimeFormatMonth:
+		return true
+	}
+
+	return false
+}
+
+// Layout is the time layout of the format, empty for TimeFormatRFC3339,
+// which is time.Time's own JSON encoding.
+func (f TimeFormat) Layout() string {
+	switch f {
+	case TimeFormatDate:
+		return "2006-01-02"
+	case TimeFormatMonth:
+		return MonthLayout
+	}
+
+	return ""
+}
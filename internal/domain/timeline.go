@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TimelineEventType string
+
+const (
+	TimelineStart TimelineEventType = "start"
+	TimelineEnd   TimelineEventType = "end"
+)
+
+// TimelineEvent is a subscription starting or ending, for Gantt-style views.
+type TimelineEvent struct {
+	Date           time.Time         `json:"date" example:"2025-07-01T00:00:00Z"`
+	Type           TimelineEventType `json:"type" enums:"start,end" example:"start"`
+	ServiceName    string            `json:"service_name" example:"Netflix"`
+	SubscriptionID uuid.UUID         `json:"subscription_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+}
+
+// Timeline lists the start of every subscription and the end of those with
+// an ended_at, oldest first. Events on the same date are ordered starts
+// first, then by service name and subscription id.
+func Timeline(subs []*UserSub) []TimelineEvent {
+	events := make([]TimelineEvent, 0, len(subs)*2)
+	for _, sub := range subs {
+		events = append(events, TimelineEvent{
+			Date:           sub.StartedAt,
+			Type:           TimelineStart,
+			ServiceName:    sub.ServiceName,
+			SubscriptionID: sub.ID,
+		})
+		if sub.EndedAt != nil {
+			events = append(events, TimelineEvent{
+				Date:           *sub.EndedAt,
+				Type:           TimelineEnd,
+				ServiceName:    sub.ServiceName,
+				SubscriptionID: sub.ID,
+			})
+		}
+	}
+
+	slices.SortFunc(events, func(a, b TimelineEvent) int {
+		return cmp.Or(
+			a.Date.Compare(b.Date),
+			// "end" sorts before "start", so compare reversed.
+			cmp.Compare(b.Type, a.Type),
+			cmp.Compare(a.ServiceName, b.ServiceName),
+			slices.Compare(a.SubscriptionID[:], b.SubscriptionID[:]),
+		)
+	})
+
+	return events
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTimeline(t *testing.T) {
+	netflixEnd := date(2025, 3, 1)
+	spotifyEnd := date(2025, 5, 1)
+
+	subs := []*UserSub{
+		{ID: uuid.New(), ServiceName: "Spotify", StartedAt: date(2025, 2, 1), EndedAt: &spotifyEnd},
+		{ID: uuid.New(), ServiceName: "Okko", StartedAt: date(2025, 3, 1)},
+		{ID: uuid.New(), ServiceName: "Netflix", StartedAt: date(2025, 1, 1), EndedAt: &netflixEnd},
+		{ID: uuid.New(), ServiceName: "Amediateka", StartedAt: date(2025, 3, 1)},
+	}
+
+	var got []string
+	for _, event := range Timeline(subs) {
+		got = append(got, fmt.Sprintf("%s %s %s", event.Date.Format("2006-01-02"), event.Type, event.ServiceName))
+	}
+
+	// On 2025-03-01 the two starts, by name, come before Netflix's end.
+	want := []string{
+		"2025-01-01 start Netflix",
+		"2025-02-01 start Spotify",
+		"2025-03-01 start Amediateka",
+		"2025-03-01 start Okko",
+		"2025-03-01 end Netflix",
+		"2025-05-01 end Spotify",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Timeline =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestTimelineEmpty(t *testing.T) {
+	if got := Timeline(nil); got == nil || len(got) != 0 {
+		t.Errorf("Timeline(nil) = %#v, want an empty, non-nil slice", got)
+	}
+}
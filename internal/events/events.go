@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testovoe/internal/domain"
+)
+
+const (
+	PublisherNoop = "noop"
+	PublisherLog  = "log"
+)
+
+// Publisher delivers subscription events to integrations.
+type Publisher interface {
+	Publish(ctx context.Context, event domain.SubEvent) error
+}
+
+// New returns the publisher named by kind.
+func New(kind string, log *slog.Logger) (Publisher, error) {
+	switch kind {
+	case PublisherNoop, "":
+		return Noop{}, nil
+	case PublisherLog:
+		return Log{log: log}, nil
+	}
+
+	return nil, fmt.Errorf("unknown events publisher %q", kind)
+}
+
+// Noop drops every event.
+type Noop struct{}
+
+func (Noop) Publish(context.Context, domain.SubEvent) error {
+	return nil
+}
+
+// Log writes every event to the log, for development and for shipping events
+// through the log pipeline.
+type Log struct {
+	log *slog.Logger
+}
+
+func (l Log) Publish(ctx context.Context, event domain.SubEvent) error {
+	attrs := []any{
+		slog.String("type", string(event.Type)),
+		slog.String("subscription_id", event.SubscriptionID.String()),
+		slog.String("user_id", event.UserID.String()),
+		slog.Time("occurred_at", event.OccurredAt),
+	}
+	if event.Channel != "" {
+		attrs = append(attrs, slog.String("channel", string(event.Channel)))
+	}
+
+	l.log.InfoContext(ctx, "subscription event", attrs...)
+
+	return nil
+}
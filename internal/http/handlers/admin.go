@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"testovoe/internal/http/middleware/logger"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DeduplicateSubs
+// @Summary Удалить дубликаты подписок
+// @Description Находит активные подписки с одинаковыми user_id и service_name, оставляет самую позднюю по дате начала, а остальные завершает текущим временем (ended_at). Выполняется одной транзакцией. Требует заголовок Authorization: Bearer <admin.token>
+// @Tags admin
+// @Produce  json
+// @Param   Authorization  header  string  true  "Bearer <admin.token>"
+// @Success 200  {object}  domain.DedupReport "Что было объединено"
+// @Failure 401  {object}  map[string]string "Неверный токен"
+// @Failure 500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/deduplicate [post]
+func (h *HttpHandler) DeduplicateSubs(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.DeduplicateSubs"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	report, err := h.useCase.DeduplicateSubs(ctx)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to deduplicate subs")
+		return
+	}
+
+	log.Info("subs deduplicated", slog.Int("ended", report.Ended))
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, report)
+}
+
+// RenameService
+// @Summary Переименовать сервис
+// @Description Заменяет service_name from на to во всех подписках одним запросом в транзакции, например чтобы объединить данные, разнесенные опечаткой. Категории не меняются. Требует заголовок Authorization: Bearer <admin.token>
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   Authorization  header  string                true  "Bearer <admin.token>"
+// @Param   input          body    RenameServiceRequest  true  "Старое и новое название"
+// @Success 200  {object}  map[string]int "Количество переименованных подписок"
+// @Failure 400  {object}  map[string]string "Некорректный JSON"
+// @Failure 401  {object}  map[string]string "Неверный токен"
+// @Failure 422  {object}  ValidationErrorResponse "Ошибка валидации"
+// @Failure 500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /admin/rename-service [post]
+func (h *HttpHandler) RenameService(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.RenameService"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	var req RenameServiceRequest
+
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		respondDecodeError(w, r, log, err)
+		return
+	}
+
+	renamed, err := h.useCase.RenameService(ctx, req.From, req.To)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to rename service")
+		return
+	}
+
+	log.Info("service renamed", slog.Int64("renamed", renamed))
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, map[string]int64{"renamed": renamed})
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testovoe/internal/domain"
+)
+
+// BodyFields are the JSON keys of every request body, for rewriting
+// differently cased keys such as serviceName to them.
+var BodyFields = jsonFields(domain.UserSub{}, domain.SubUpdate{}, domain.ReminderPreference{}, TotalsRequest{}, ShareSubRequest{}, AddTagsRequest{}, BulkPriceRequest{}, RenameServiceRequest{})
+
+func jsonFields(values ...any) []string {
+	var fields []string
+	for _, v := range values {
+		t := reflect.TypeOf(v)
+		for i := range t.NumField() {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" && !slices.Contains(fields, name) {
+				fields = append(fields, name)
+			}
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"testovoe/internal/domain"
+	"time"
+)
+
+var subCSVHeader = []string{
+	"id", "service_name", "service_price", "currency", "user_id", "started_at", "ended_at",
+	"billing_period", "payment_method", "auto_renew", "tags",
+}
+
+// subCSVWriter writes subscriptions as CSV rows, one per call, so exports can
+// be streamed.
+type subCSVWriter struct {
+	w *csv.Writer
+}
+
+func newSubCSVWriter(w io.Writer) *subCSVWriter {
+	return &subCSVWriter{w: csv.NewWriter(w)}
+}
+
+func (c *subCSVWriter) header() error {
+	return c.w.Write(subCSVHeader)
+}
+
+func (c *subCSVWriter) write(sub *domain.UserSub) error {
+	var endedAt, paymentMethod string
+	if sub.EndedAt != nil {
+		endedAt = sub.EndedAt.Format(time.RFC3339)
+	}
+	if sub.PaymentMethod != nil {
+		paymentMethod = *sub.PaymentMethod
+	}
+
+	return c.w.Write([]string{
+		sub.ID.String(),
+		sub.ServiceName,
+		strconv.Itoa(sub.ServicePrice),
+		sub.Currency,
+		sub.UserID.String(),
+		sub.StartedAt.Format(time.RFC3339),
+		endedAt,
+		string(sub.BillingPeriod),
+		paymentMethod,
+		strconv.FormatBool(sub.AutoRenew),
+		strings.Join(sub.Tags, ";"),
+	})
+}
+
+func (c *subCSVWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testovoe/internal/domain"
+	"testovoe/internal/validation"
+
+	"github.com/go-chi/render"
+)
+
+// retryAfter is the Retry-After of every 503, in seconds. A 503 here means
+// a saturated pool or server, which clears within about a second.
+const retryAfter = "1"
+
+// respondError writes {"error": msg} with status and logs msg with args.
+// Client mistakes (4xx) log at WARN, server failures (5xx) at ERROR, so only
+// the latter page anyone. A 503 also tells the client when to retry.
+func respondError(w http.ResponseWriter, r *http.Request, log *slog.Logger, status int, msg string, args ...any) {
+	level := slog.LevelWarn
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	log.Log(r.Context(), level, msg, append(args, slog.Int("status", status))...)
+
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", retryAfter)
+	}
+
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": msg})
+}
+
+var errEmptyBody = errors.New("request body is empty")
+
+// respondDecodeError answers a request whose JSON body failed to decode. An
+// empty body is reported as such rather than as a generic decode failure.
+func respondDecodeError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	if errors.Is(err, io.EOF) {
+		respondError(w, r, log, http.StatusBadRequest, errEmptyBody.Error())
+		return
+	}
+
+	respondError(w, r, log, http.StatusBadRequest, "invalid request body", "error", err)
+}
+
+// respondUseCaseError maps an error returned by the usecase to a response:
+// well-formed input breaking business rules is a 422, other domain errors
+// become 4xx and anything else is a 500 logged as failure.
+func respondUseCaseError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, failure string) {
+	var fieldErrs validation.Errors
+
+	switch {
+	case errors.As(err, &fieldErrs):
+		log.Warn("validation failed", "error", err, slog.Int("status", http.StatusUnprocessableEntity))
+		render.Status(r, http.StatusUnprocessableEntity)
+		render.JSON(w, r, validationErrorResponse(fieldErrs))
+	case errors.Is(err, domain.ErrSubNotFound):
+		respondError(w, r, log, http.StatusNotFound, "subscription not found", "error", err)
+	case errors.Is(err, domain.ErrNoMatch):
+		respondError(w, r, log, http.StatusNotFound, domain.ErrNoMatch.Error())
+	case errors.Is(err, domain.ErrReminderPreferenceNotFound):
+		respondError(w, r, log, http.StatusNotFound, domain.ErrReminderPreferenceNotFound.Error(), "error", err)
+	case errors.Is(err, domain.ErrSubNotCancelled):
+		respondError(w, r, log, http.StatusConflict, domain.ErrSubNotCancelled.Error())
+	case errors.Is(err, domain.ErrAmbiguousSub):
+		respondError(w, r, log, http.StatusConflict, domain.ErrAmbiguousSub.Error())
+	case errors.Is(err, domain.ErrInvalidPatch):
+		respondError(w, r, log, http.StatusBadRequest, "invalid merge patch", "error", err)
+	case errors.Is(err, domain.ErrInvalidPeriod):
+		respondError(w, r, log, http.StatusBadRequest, "dates must be in MM-YYYY format", "error", err)
+	case errors.Is(err, domain.ErrPeriodTooLong):
+		respondError(w, r, log, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrEmptyFilter):
+		respondError(w, r, log, http.StatusBadRequest, domain.ErrEmptyFilter.Error())
+	case errors.Is(err, domain.ErrStorageBusy):
+		respondError(w, r, log, http.StatusServiceUnavailable, "server is busy", "error", err)
+	default:
+		log.Error(failure, "error", err, slog.Int("status", http.StatusInternalServerError))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "internal server error"})
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/validation"
+)
+
+// levels returns the level of every line log wrote to buf.
+func levels(buf *bytes.Buffer) []string {
+	var out []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if _, rest, ok := strings.Cut(line, "level="); ok {
+			level, _, _ := strings.Cut(rest, " ")
+			out = append(out, level)
+		}
+	}
+
+	return out
+}
+
+func TestRespondErrorLevels(t *testing.T) {
+	tests := []struct {
+		status int
+		level  string
+	}{
+		{status: http.StatusBadRequest, level: "WARN"},
+		{status: http.StatusNotFound, level: "WARN"},
+		{status: http.StatusUnprocessableEntity, level: "WARN"},
+		{status: http.StatusInternalServerError, level: "ERROR"},
+		{status: http.StatusServiceUnavailable, level: "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var buf bytes.Buffer
+			log := slog.New(slog.NewTextHandler(&buf, nil))
+
+			w := httptest.NewRecorder()
+			respondError(w, httptest.NewRequest(http.MethodGet, "/", nil), log, tt.status, "boom")
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := levels(&buf); len(got) != 1 || got[0] != tt.level {
+				t.Errorf("levels = %v, want [%s]", got, tt.level)
+			}
+		})
+	}
+}
+
+func TestRespondUseCaseErrorLevels(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		level  string
+	}{
+		{name: "validation", err: validation.Errors{{Field: "service_price", Message: "must not be negative"}}, status: http.StatusUnprocessableEntity, level: "WARN"},
+		{name: "not found", err: fmt.Errorf("storage: %w", domain.ErrSubNotFound), status: http.StatusNotFound, level: "WARN"},
+		{name: "bad period", err: fmt.Errorf("%w: bad month", domain.ErrInvalidPeriod), status: http.StatusBadRequest, level: "WARN"},
+		{name: "empty filter", err: domain.ErrEmptyFilter, status: http.StatusBadRequest, level: "WARN"},
+		{name: "busy", err: domain.ErrStorageBusy, status: http.StatusServiceUnavailable, level: "ERROR"},
+		{name: "internal", err: errors.New("connection reset"), status: http.StatusInternalServerError, level: "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := slog.New(slog.NewTextHandler(&buf, nil))
+
+			w := httptest.NewRecorder()
+			respondUseCaseError(w, httptest.NewRequest(http.MethodGet, "/", nil), log, tt.err, "failed")
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := levels(&buf); len(got) != 1 || got[0] != tt.level {
+				t.Errorf("levels = %v, want [%s]", got, tt.level)
+			}
+		})
+	}
+}
+
+func TestRetryAfterOnlyOn503(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// With err set the response goes through respondUseCaseError, otherwise
+	// through respondError with status.
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		want   string
+	}{
+		{name: "unavailable", status: http.StatusServiceUnavailable, want: retryAfter},
+		{name: "storage busy", err: domain.ErrStorageBusy, status: http.StatusServiceUnavailable, want: retryAfter},
+		{name: "internal", err: errors.New("boom"), status: http.StatusInternalServerError},
+		{name: "bad request", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.err != nil {
+				respondUseCaseError(w, r, log, tt.err, "failed")
+			} else {
+				respondError(w, r, log, tt.status, "failed")
+			}
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.want {
+				t.Errorf("Retry-After = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testovoe/internal/domain"
+)
+
+var errInvalidFields = errors.New("unknown fields")
+
+// parseFields reads the comma-separated fields parameter, checked against
+// domain.SubFields. Nil means the full response.
+func parseFields(r *http.Request) ([]string, error) {
+	fields := listParam(r, "fields")
+
+	var unknown []string
+	for _, field := range fields {
+		if _, ok := domain.SubFields[field]; !ok {
+			unknown = append(unknown, field)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %s", errInvalidFields, strings.Join(unknown, ", "))
+	}
+
+	return fields, nil
+}
+
+// projectFields returns v, a SubResponse or a slice of them, reduced to
+// fields. Fields a response omits, such as empty tags, stay absent. With no
+// fields v is returned unchanged.
+func projectFields(v any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) > 0 && raw[0] == '[' {
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+
+		for i := range items {
+			items[i] = pickFields(items[i], fields)
+		}
+
+		return items, nil
+	}
+
+	var item map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, err
+	}
+
+	return pickFields(item, fields), nil
+}
+
+func pickFields(item map[string]json.RawMessage, fields []string) map[string]json.RawMessage {
+	picked := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := item[field]; ok {
+			picked[field] = value
+		}
+	}
+
+	return picked
+}
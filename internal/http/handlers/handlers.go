@@ -1,37 +1,89 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testovoe/internal/buildinfo"
+	"testovoe/internal/config"
 	"testovoe/internal/domain"
+	"testovoe/internal/http/middleware/logger"
+	"testovoe/internal/http/middleware/subid"
+	"testovoe/internal/mergepatch"
+	"testovoe/internal/validation"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 type UseCase interface {
-	CreateSub(ctx context.Context, userSub domain.UserSub) error
-	UpdateSub(ctx context.Context, userSub domain.UserSub) error
-	DeleteSub(ctx context.Context, subID, userID uuid.UUID) error
-	GetSubs(ctx context.Context) ([]*domain.UserSub, error)
+	CreateSub(ctx context.Context, userSub domain.UserSub) (int64, error)
+	CreateSubs(ctx context.Context, userSubs []domain.UserSub) (int64, error)
+	CreateSubsEach(ctx context.Context, userSubs []domain.UserSub) []error
+	ValidateSub(userSub domain.UserSub) error
+	UpdateSub(ctx context.Context, update domain.SubUpdate) (int64, error)
+	PatchSub(ctx context.Context, subID uuid.UUID, patch []byte) (*domain.UserSub, error)
+	ReactivateSub(ctx context.Context, subID uuid.UUID, resetStart bool) (*domain.UserSub, error)
+	DeleteSub(ctx context.Context, subID, userID uuid.UUID) (int64, error)
+	AddTags(ctx context.Context, subID uuid.UUID, tags []string) (*domain.UserSub, error)
+	RemoveTag(ctx context.Context, subID uuid.UUID, tag string) (*domain.UserSub, error)
+	ShareSub(ctx context.Context, subID, userID uuid.UUID) error
+	UnshareSub(ctx context.Context, subID, userID uuid.UUID) (int64, error)
+	DeleteSubsByFilter(ctx context.Context, filter domain.SubFilter) (int64, error)
+	UpdatePrices(ctx context.Context, filter domain.SubFilter, price int) (int64, error)
+	RenewExpiring(ctx context.Context, userID uuid.UUID, withinDays, months int) (int64, error)
+	ListSubs(ctx context.Context, filter domain.SubFilter, page domain.Page) ([]*domain.UserSub, error)
+	CountSubs(ctx context.Context, filter domain.SubFilter) (uint64, error)
+	StreamSubs(ctx context.Context, filter domain.SubFilter, fn func(*domain.UserSub) error) error
+	SubsByUser(ctx context.Context, filter domain.SubFilter) (map[uuid.UUID][]*domain.UserSub, error)
 	GetUserSub(ctx context.Context, subID uuid.UUID) (*domain.UserSub, error)
+	SubNeighbors(ctx context.Context, subID uuid.UUID) (*domain.SubNeighbors, error)
+	DeduplicateSubs(ctx context.Context) (*domain.DedupReport, error)
+	RenameService(ctx context.Context, from, to string) (int64, error)
 	GetUserSubs(ctx context.Context, userID uuid.UUID) ([]*domain.UserSub, error)
-	GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName, fromStr, toStr string) (int, error)
+	TopSubs(ctx context.Context, userID uuid.UUID, limit uint64) ([]*domain.UserSub, error)
+	EndedSubs(ctx context.Context, userID *uuid.UUID, fromStr, toStr string) ([]*domain.UserSub, error)
+	SubByService(ctx context.Context, userID uuid.UUID, serviceName string, latest bool) (*domain.UserSub, error)
+	Currencies(ctx context.Context, userID *uuid.UUID) ([]string, error)
+	ServicePriceStats(ctx context.Context, service, currency string) (*domain.ServicePriceStats, error)
+	Facets(ctx context.Context, userID uuid.UUID) (*domain.SubFacets, error)
+	Timeline(ctx context.Context, userID uuid.UUID) ([]domain.TimelineEvent, error)
+	Stats(ctx context.Context, userID uuid.UUID) (*domain.SubStats, error)
+	GetTotalCost(ctx context.Context, userID uuid.UUID, serviceNames []string, fromStr, toStr string, opts domain.CostOptions) (int, error)
+	GetTotalCosts(ctx context.Context, userIDs []uuid.UUID, serviceNames []string, fromStr, toStr string) (map[uuid.UUID]int, error)
+	ComparePeriods(ctx context.Context, userID uuid.UUID, serviceNames []string, a, b domain.Period, opts domain.CostOptions) (*domain.PeriodComparison, error)
+	MRR(ctx context.Context, month string) (int, error)
+	ActiveCounts(ctx context.Context, fromStr, toStr string) ([]domain.MonthCount, error)
+	WeightedActiveCounts(ctx context.Context, fromStr, toStr string) ([]domain.WeightedMonthCount, error)
+	Forecast(ctx context.Context, userID uuid.UUID, months int) ([]domain.MonthCost, error)
+	Savings(ctx context.Context, subID uuid.UUID, months int) (int, error)
+	PriceHistory(ctx context.Context, subID uuid.UUID) ([]domain.PriceChange, error)
+	GetReminderPreference(ctx context.Context, userID uuid.UUID) (*domain.ReminderPreference, error)
+	SetReminderPreference(ctx context.Context, pref domain.ReminderPreference) error
+	Healthy() bool
 }
 
 type HttpHandler struct {
 	log     *slog.Logger
 	useCase UseCase
+	cfg     *config.Config
+	// draining is set once shutdown begins, so /readyz turns load balancers
+	// away before the server stops accepting connections.
+	draining atomic.Bool
 }
 
-func New(log *slog.Logger, useCase UseCase) *HttpHandler {
-	return &HttpHandler{log: log, useCase: useCase}
+func New(log *slog.Logger, useCase UseCase, cfg *config.Config) *HttpHandler {
+	return &HttpHandler{log: log, useCase: useCase, cfg: cfg}
 }
 
 // CreateSub
@@ -42,14 +94,15 @@ func New(log *slog.Logger, useCase UseCase) *HttpHandler {
 // @Produce  json
 // @Param   input  body      domain.UserSub  true  "Данные подписки"
 // @Success 201    {object}  map[string]string "Успешное создание"
-// @Failure 400    {object}  map[string]string "Ошибка валидации или некорректный JSON"
+// @Failure 400    {object}  map[string]string "Некорректный JSON"
+// @Failure 422    {object}  ValidationErrorResponse "Ошибка валидации"
 // @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router /api/v1/subscriptions [post]
 func (h *HttpHandler) CreateSub(w http.ResponseWriter, r *http.Request) {
 	const op = "httpHandlers.CreateSub"
 	ctx := r.Context()
 
-	log := h.log.With(
+	log := logger.FromContext(r.Context(), h.log).With(
 		slog.String("op", op),
 		slog.String("method", r.Method),
 		slog.String("request_id", middleware.GetReqID(r.Context())),
@@ -59,76 +112,175 @@ func (h *HttpHandler) CreateSub(w http.ResponseWriter, r *http.Request) {
 
 	err := render.DecodeJSON(r.Body, &req)
 	if err != nil {
-		var validateErr validator.ValidationErrors
-		errors.As(err, &validateErr)
-		log.Error("validation error", "error", err)
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, map[string]string{"error": "invalid request body"})
+		respondDecodeError(w, r, log, err)
 		return
 	}
 
 	req.StartedAt = time.Now()
 
-	err = h.useCase.CreateSub(ctx, req)
+	affected, err := h.useCase.CreateSub(ctx, req)
 	if err != nil {
-		log.Error("create sub failed", "error", err)
-		render.Status(r, http.StatusInternalServerError)
-		render.JSON(w, r, map[string]string{"error": "internal server error"})
+		respondUseCaseError(w, r, log, err, "create sub failed")
 		return
 	}
 
+	log.Info("sub created", slog.Int64("rows_affected", affected))
+
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, map[string]string{"status": "sub created successfully"})
 }
 
+// CreateSubs
+// @Summary Создать несколько подписок
+// @Description Создает подписки из массива. По умолчанию (atomic=true) все записи сохраняются одной транзакцией: при любой ошибке не сохраняется ничего. С atomic=false каждая запись сохраняется отдельно, а ответ 207 содержит результат по каждому индексу
+// @Tags subscriptions
+// @Accept  json
+// @Produce  json
+// @Param   atomic  query     bool              false  "Сохранять все записи одной транзакцией (по умолчанию true)"
+// @Param   input   body      []domain.UserSub  true   "Данные подписок"
+// @Success 201     {object}  map[string]int64 "Все подписки созданы"
+// @Success 207     {array}   BatchItemResult "Результат по каждой записи (atomic=false)"
+// @Failure 400     {object}  map[string]string "Некорректный JSON"
+// @Failure 422     {object}  ValidationErrorResponse "Ошибка валидации"
+// @Failure 500     {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/batch [post]
+func (h *HttpHandler) CreateSubs(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.CreateSubs"
+	ctx := r.Context()
+
+	log := logger.FromContext(r.Context(), h.log).With(
+		slog.String("op", op),
+		slog.String("method", r.Method),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	atomic, err := parseBool(r, "atomic", true, errInvalidAtomic)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	var req []domain.UserSub
+
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		respondDecodeError(w, r, log, err)
+		return
+	}
+
+	now := time.Now()
+	for i := range req {
+		req[i].StartedAt = now
+	}
+
+	if !atomic {
+		results := newBatchItemResults(h.useCase.CreateSubsEach(ctx, req))
+
+		log.Info("batch processed", slog.Int("records", len(req)))
+		render.Status(r, http.StatusMultiStatus)
+		render.JSON(w, r, results)
+		return
+	}
+
+	affected, err := h.useCase.CreateSubs(ctx, req)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "create subs failed")
+		return
+	}
+
+	log.Info("subs created", slog.Int64("rows_affected", affected))
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, map[string]int64{"created": affected})
+}
+
+// ValidateSub
+// @Summary Проверить данные подписки
+// @Description Прогоняет полную валидацию создания подписки без сохранения в БД
+// @Tags subscriptions
+// @Accept  json
+// @Produce  json
+// @Param   input  body      domain.UserSub  true  "Данные подписки"
+// @Success 200    {object}  map[string]bool "Данные корректны"
+// @Failure 400    {object}  map[string]string "Некорректный JSON"
+// @Failure 422    {object}  ValidationErrorResponse "Ошибка валидации"
+// @Router /api/v1/subscriptions/validate [post]
+func (h *HttpHandler) ValidateSub(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.ValidateSub"
+
+	log := logger.FromContext(r.Context(), h.log).With(
+		slog.String("op", op),
+		slog.String("method", r.Method),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	var req domain.UserSub
+
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		respondDecodeError(w, r, log, err)
+		return
+	}
+
+	req.StartedAt = time.Now()
+
+	var fieldErrs validation.Errors
+	if err := h.useCase.ValidateSub(req); errors.As(err, &fieldErrs) {
+		log.Debug("payload is invalid", "error", err)
+		render.Status(r, http.StatusUnprocessableEntity)
+		render.JSON(w, r, validationErrorResponse(fieldErrs))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, map[string]bool{"valid": true})
+}
+
 // UpdateSub
 // @Summary Обновить запись о подписке
-// @Description Обновляет запись об онлайн-подписке для конкретного пользователя
+// @Description Обновляет запись об онлайн-подписке для конкретного пользователя. Если ended_at не передан, дата окончания не меняется; явный null очищает её
 // @Tags subscriptions
 // @Accept  json
 // @Produce  json
-// @Param   input  body      domain.UserSub  true  "Данные подписки"
+// @Param   id     path      string            true  "ID подписки (UUID)"
+// @Param   input  body      domain.SubUpdate  true  "Данные подписки"
 // @Success 201    {object}  map[string]string "Успешное обновление"
-// @Failure 400    {object}  map[string]string "Ошибка валидации или некорректный JSON"
+// @Failure 400    {object}  map[string]string "Некорректный JSON"
+// @Failure 404    {object}  map[string]string "Подписка этого пользователя не найдена"
+// @Failure 422    {object}  ValidationErrorResponse "Ошибка валидации"
 // @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
-// @Router /api/v1/subscriptions [put]
+// @Router /api/v1/subscriptions/{id} [put]
 func (h *HttpHandler) UpdateSub(w http.ResponseWriter, r *http.Request) {
 	const op = "httpHandlers.UpdateSub"
 	ctx := r.Context()
 
-	log := h.log.With(
+	log := logger.FromContext(r.Context(), h.log).With(
 		slog.String("op", op),
 		slog.String("method", r.Method),
 		slog.String("request_id", middleware.GetReqID(r.Context())),
 	)
 
-	var req domain.UserSub
+	var req domain.SubUpdate
 
 	err := render.DecodeJSON(r.Body, &req)
 	if err != nil {
-		var validateErr validator.ValidationErrors
-		errors.As(err, &validateErr)
-		log.Error("validation error", "error", err)
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, map[string]string{"error": "invalid request body"})
+		respondDecodeError(w, r, log, err)
 		return
 	}
 
-	subIDStr := chi.URLParam(r, "id")
-	subID, err := uuid.Parse(subIDStr)
+	subID := subid.FromContext(ctx)
+
+	req.ID = subID
+
+	affected, err := h.useCase.UpdateSub(ctx, req)
 	if err != nil {
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, map[string]string{"error": "invalid request body"})
+		respondUseCaseError(w, r, log, err, "update sub failed")
 		return
 	}
 
-	req.ID = subID
+	log.Info("sub updated", slog.Int64("rows_affected", affected))
 
-	err = h.useCase.UpdateSub(ctx, req)
-	if err != nil {
-		log.Error("update sub failed", "error", err)
-		render.Status(r, http.StatusInternalServerError)
-		render.JSON(w, r, map[string]string{"error": "internal server error"})
+	if affected == 0 {
+		respondError(w, r, log, http.StatusNotFound, "subscription not found")
 		return
 	}
 
@@ -136,6 +288,102 @@ func (h *HttpHandler) UpdateSub(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, map[string]string{"status": "sub updated successfully"})
 }
 
+// PatchSub
+// @Summary Частично обновить подписку
+// @Description Применяет JSON merge patch (RFC 7396) к сохраненной подписке: null очищает поле, отсутствующие ключи не меняются. Поля id, user_id и started_at не изменяются
+// @Tags subscriptions
+// @Accept  application/merge-patch+json
+// @Produce  json
+// @Param   id     path      string          true  "ID подписки (UUID)"
+// @Param   input  body      domain.UserSub  true  "Merge patch"
+// @Success 200    {object}  SubResponse "Обновленная подписка"
+// @Failure 400    {object}  map[string]string "Некорректный patch"
+// @Failure 422    {object}  ValidationErrorResponse "Ошибка валидации"
+// @Failure 404    {object}  map[string]string "Подписка не найдена"
+// @Failure 415    {object}  map[string]string "Неподдерживаемый Content-Type"
+// @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/{id} [patch]
+func (h *HttpHandler) PatchSub(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.PatchSub"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("method", r.Method),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != mergepatch.ContentType {
+		respondError(w, r, log, http.StatusUnsupportedMediaType, "content type must be "+mergepatch.ContentType, "content_type", r.Header.Get("Content-Type"))
+		return
+	}
+
+	subID := subid.FromContext(ctx)
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid request body", "error", err)
+		return
+	}
+
+	if len(bytes.TrimSpace(patch)) == 0 {
+		respondError(w, r, log, http.StatusBadRequest, errEmptyBody.Error())
+		return
+	}
+
+	sub, err := h.useCase.PatchSub(ctx, subID, patch)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "patch sub failed")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, h.subResponse(sub))
+}
+
+// ReactivateSub
+// @Summary Возобновить отмененную подписку
+// @Description Снимает дату окончания с подписки, которая уже закончилась. С reset_started_at=true подписка начинается заново с текущего момента. Подписка без ended_at или с ended_at в будущем не отменена — 409
+// @Tags subscriptions
+// @Produce  json
+// @Param   id                path      string  true   "ID подписки (UUID)"
+// @Param   reset_started_at  query     bool    false  "Перенести started_at на текущий момент"
+// @Success 200               {object}  SubResponse "Возобновленная подписка"
+// @Failure 400               {object}  map[string]string "Некорректные параметры"
+// @Failure 404               {object}  map[string]string "Подписка не найдена"
+// @Failure 409               {object}  map[string]string "Подписка не отменена"
+// @Failure 500               {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/{id}/reactivate [post]
+func (h *HttpHandler) ReactivateSub(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.ReactivateSub"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	subID := subid.FromContext(ctx)
+
+	resetStart, err := parseBool(r, "reset_started_at", false, errInvalidReset)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "reset_started_at", queryParam(r, "reset_started_at"))
+		return
+	}
+
+	sub, err := h.useCase.ReactivateSub(ctx, subID, resetStart)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "reactivate sub failed")
+		return
+	}
+
+	log.Info("sub reactivated", slog.String("id", subID.String()))
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, h.subResponse(sub))
+}
+
 // DeleteSub
 // @Summary Удаляет запись о подписке
 // @Description Удаляет запись по ID подписки (path) и ID пользователя (query)
@@ -146,183 +394,1495 @@ func (h *HttpHandler) UpdateSub(w http.ResponseWriter, r *http.Request) {
 // @Param   user_id  query     string  true  "ID пользователя (UUID)"
 // @Success 204    "No Content"
 // @Failure 400    {object}  map[string]string "Ошибка валидации ID"
+// @Failure 404    {object}  map[string]string "Подписка этого пользователя не найдена"
 // @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
 // @Router /api/v1/subscriptions/{id} [delete]
 func (h *HttpHandler) DeleteSub(w http.ResponseWriter, r *http.Request) {
 	const op = "httpHandlers.DeleteSub"
 	ctx := r.Context()
 
-	log := h.log.With(
+	log := logger.FromContext(ctx, h.log).With(
 		slog.String("op", op),
 		slog.String("request_id", middleware.GetReqID(ctx)),
 	)
 
-	subIDStr := chi.URLParam(r, "id")
-	subID, err := uuid.Parse(subIDStr)
+	subID := subid.FromContext(ctx)
+
+	userIDStr := queryParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		log.Warn("invalid sub id", "id", subIDStr)
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, map[string]string{"error": "invalid subscription id"})
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
 		return
 	}
 
-	userIDStr := r.URL.Query().Get("user_id")
-	userID, err := uuid.Parse(userIDStr)
+	affected, err := h.useCase.DeleteSub(ctx, subID, userID)
 	if err != nil {
-		log.Warn("invalid user id", "id", userIDStr)
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, map[string]string{"error": "invalid user id"})
+		respondUseCaseError(w, r, log, err, "failed to delete sub")
 		return
 	}
 
-	if err := h.useCase.DeleteSub(ctx, subID, userID); err != nil {
-		log.Error("failed to delete sub", "error", err)
-		render.Status(r, http.StatusInternalServerError)
-		render.JSON(w, r, map[string]string{"error": "internal server error"})
+	log.Info("sub deleted", slog.Int64("rows_affected", affected))
+
+	if affected == 0 {
+		respondError(w, r, log, http.StatusNotFound, "subscription not found")
 		return
 	}
 
-	render.Status(r, http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListSubs
-// @Summary Получить список подписок
-// @Description Возвращает все подписки или подписки конкретного пользователя (если передан user_id)
+// DeleteSubs
+// @Summary Удаляет подписки по фильтру
+// @Description Удаляет все подписки, подходящие под фильтры. Нужен хотя бы один фильтр
 // @Tags subscriptions
 // @Produce  json
-// @Param   user_id  query     string  false  "ID пользователя (UUID)"
-// @Success 200      {array}   domain.UserSub "Список подписок"
-// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
-// @Router /api/v1/subscriptions [get]
-func (h *HttpHandler) ListSubs(w http.ResponseWriter, r *http.Request) {
-	const op = "httpHandlers.ListSubs"
+// @Param   user_id         query     string  false  "ID пользователя (UUID)"
+// @Param   service_name    query     string  false  "Название сервиса"
+// @Param   payment_method  query     string  false  "Способ оплаты"
+// @Param   exclude_free    query     bool    false  "Не учитывать бесплатные подписки (цена 0)"
+// @Success 200             {object}  map[string]int "Количество удаленных подписок"
+// @Failure 400             {object}  map[string]string "Не передан ни один фильтр или некорректный ID"
+// @Failure 500             {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions [delete]
+func (h *HttpHandler) DeleteSubs(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.DeleteSubs"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	filter, err := parseSubFilter(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	if filter.IsEmpty() {
+		respondError(w, r, log, http.StatusBadRequest, domain.ErrEmptyFilter.Error())
+		return
+	}
+
+	deleted, err := h.useCase.DeleteSubsByFilter(ctx, filter)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to delete subs")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, map[string]int64{"deleted": deleted})
+}
+
+// UpdatePrices
+// @Summary Изменить цену подписок
+// @Description Устанавливает новую цену всем подпискам сервиса и/или пользователя одним запросом, например при повышении цены сервисом. Нужен хотя бы один из service_name и user_id
+// @Tags subscriptions
+// @Accept  json
+// @Produce  json
+// @Param   input  body      BulkPriceRequest  true  "Фильтр и новая цена"
+// @Success 200    {object}  map[string]int "Количество обновленных подписок"
+// @Failure 400    {object}  map[string]string "Некорректный JSON или не передан ни один фильтр"
+// @Failure 422    {object}  ValidationErrorResponse "Ошибка валидации"
+// @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/price [patch]
+func (h *HttpHandler) UpdatePrices(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.UpdatePrices"
 	ctx := r.Context()
 
-	log := h.log.With(
+	log := logger.FromContext(ctx, h.log).With(
 		slog.String("op", op),
 		slog.String("request_id", middleware.GetReqID(ctx)),
 	)
 
-	userIDStr := r.URL.Query().Get("user_id")
+	var req BulkPriceRequest
 
-	var subs []*domain.UserSub
-	var err error
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		respondDecodeError(w, r, log, err)
+		return
+	}
 
-	if userIDStr != "" {
-		uid, parseErr := uuid.Parse(userIDStr)
-		if parseErr != nil {
-			log.Warn("invalid user_id", "id", userIDStr)
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, map[string]string{"error": "invalid user_id format"})
-			return
-		}
-		subs, err = h.useCase.GetUserSubs(ctx, uid)
-	} else {
-		subs, err = h.useCase.GetSubs(ctx)
+	if req.NewPrice == nil {
+		respondUseCaseError(w, r, log, validation.Errors{{Field: "new_price", Message: "is required"}}, "invalid price")
+		return
 	}
 
+	filter := domain.SubFilter{UserID: req.UserID, ServiceName: req.ServiceName}
+
+	updated, err := h.useCase.UpdatePrices(ctx, filter, *req.NewPrice)
 	if err != nil {
-		log.Error("failed to fetch subs", "error", err)
-		render.Status(r, http.StatusInternalServerError)
-		render.JSON(w, r, map[string]string{"error": "internal server error"})
+		respondUseCaseError(w, r, log, err, "failed to update prices")
 		return
 	}
 
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, subs)
+	render.JSON(w, r, map[string]int64{"updated": updated})
 }
 
-// GetTotalCost
-// @Summary Рассчитать итоговую стоимость
-// @Description Считает сумму трат за период. Формат дат: MM-YYYY
+const (
+	defaultRenewWithinDays = 30
+	maxRenewWithinDays     = 365
+	defaultRenewMonths     = 1
+	maxRenewMonths         = 120
+)
+
+// RenewExpiring
+// @Summary Продлить истекающие подписки
+// @Description Сдвигает ended_at на months месяцев у всех подписок пользователя, которые заканчиваются в ближайшие within_days дней, одной транзакцией. Уже завершившиеся подписки не продлеваются
 // @Tags subscriptions
 // @Produce  json
-// @Param   user_id      query     string  true  "ID пользователя (UUID)"
-// @Param   service_name query     string  true  "Название сервиса"
-// @Param   from         query     string  true  "Дата начала (01-2025)"
-// @Param   to           query     string  true  "Дата окончания (03-2025)"
-// @Success 200          {object}  map[string]int "Результат"
+// @Param   user_id      query     string  true   "ID пользователя (UUID)"
+// @Param   within_days  query     int     false  "Сколько дней вперед считать подписку истекающей (по умолчанию 30, максимум 365)"
+// @Param   months       query     int     false  "На сколько месяцев продлить (по умолчанию 1, максимум 120)"
+// @Success 200          {object}  map[string]int "Количество продленных подписок"
 // @Failure 400          {object}  map[string]string "Ошибка валидации параметров"
 // @Failure 500          {object}  map[string]string "Внутренняя ошибка сервера"
-// @Router /api/v1/subscriptions/total [get]
-func (h *HttpHandler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
-	const op = "httpHandler.GetTotalCost"
+// @Router /api/v1/subscriptions/renew-expiring [post]
+func (h *HttpHandler) RenewExpiring(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.RenewExpiring"
 	ctx := r.Context()
 
-	log := h.log.With(
+	log := logger.FromContext(ctx, h.log).With(
 		slog.String("op", op),
-		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.String("request_id", middleware.GetReqID(ctx)),
 	)
 
-	userIDStr := r.URL.Query().Get("user_id")
-	serviceName := r.URL.Query().Get("service_name")
-	from := r.URL.Query().Get("from")
-	to := r.URL.Query().Get("to")
+	userIDStr := queryParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	withinDays := defaultRenewWithinDays
+	if daysStr := queryParam(r, "within_days"); daysStr != "" {
+		withinDays, err = strconv.Atoi(daysStr)
+		if err != nil || withinDays < 1 || withinDays > maxRenewWithinDays {
+			respondError(w, r, log, http.StatusBadRequest, "within_days must be between 1 and 365", "within_days", daysStr)
+			return
+		}
+	}
+
+	months := defaultRenewMonths
+	if monthsStr := queryParam(r, "months"); monthsStr != "" {
+		months, err = strconv.Atoi(monthsStr)
+		if err != nil || months < 1 || months > maxRenewMonths {
+			respondError(w, r, log, http.StatusBadRequest, "months must be between 1 and 120", "months", monthsStr)
+			return
+		}
+	}
 
-	if userIDStr == "" || serviceName == "" || from == "" || to == "" {
-		log.Warn("missing query params")
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, map[string]string{"error": "missing query params"})
+	renewed, err := h.useCase.RenewExpiring(ctx, userID, withinDays, months)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to renew subs")
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, map[string]int64{"renewed": renewed})
+}
+
+// AddTags
+// @Summary Добавить теги подписке
+// @Description Добавляет подписке произвольные теги. Уже имеющиеся теги пропускаются
+// @Tags subscriptions
+// @Accept  json
+// @Produce  json
+// @Param   id     path      string          true  "ID подписки (UUID)"
+// @Param   input  body      AddTagsRequest  true  "Теги"
+// @Success 200    {object}  SubResponse "Подписка с тегами"
+// @Failure 400    {object}  map[string]string "Некорректный JSON"
+// @Failure 422    {object}  ValidationErrorResponse "Ошибка валидации"
+// @Failure 404    {object}  map[string]string "Подписка не найдена"
+// @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/{id}/tags [post]
+func (h *HttpHandler) AddTags(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.AddTags"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	subID := subid.FromContext(ctx)
+
+	var req AddTagsRequest
+
+	err := render.DecodeJSON(r.Body, &req)
 	if err != nil {
-		log.Warn("invalid user id", "id", userIDStr)
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, map[string]string{"error": "invalid user id"})
+		respondDecodeError(w, r, log, err)
 		return
 	}
 
-	totalCost, err := h.useCase.GetTotalCost(ctx, userID, serviceName, from, to)
+	sub, err := h.useCase.AddTags(ctx, subID, req.Tags)
 	if err != nil {
-		log.Error("failed to fetch total cost", "error", err)
-		render.Status(r, http.StatusInternalServerError)
-		render.JSON(w, r, map[string]string{"error": "internal server error"})
+		respondUseCaseError(w, r, log, err, "failed to add tags")
 		return
 	}
 
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, map[string]interface{}{"totalCost": totalCost})
+	render.JSON(w, r, h.subResponse(sub))
 }
 
-// GetUserSub
-// @Summary Получить одну подписку
-// @Description Возвращает подписку по её ID (передается в пути)
+// RemoveTag
+// @Summary Удалить тег подписки
+// @Description Удаляет тег у подписки. Удаление отсутствующего тега не считается ошибкой
 // @Tags subscriptions
 // @Produce  json
 // @Param   id   path      string  true  "ID подписки (UUID)"
-// @Success 200  {object}  domain.UserSub "Данные подписки"
+// @Param   tag  path      string  true  "Тег"
+// @Success 200  {object}  SubResponse "Подписка с оставшимися тегами"
 // @Failure 400  {object}  map[string]string "Некорректный ID"
 // @Failure 404  {object}  map[string]string "Подписка не найдена"
 // @Failure 500  {object}  map[string]string "Внутренняя ошибка сервера"
-// @Router /api/v1/subscriptions/{id} [get]
-func (h *HttpHandler) GetUserSub(w http.ResponseWriter, r *http.Request) {
-	const op = "httpHandler.GetUserSub"
+// @Router /api/v1/subscriptions/{id}/tags/{tag} [delete]
+func (h *HttpHandler) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.RemoveTag"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	subID := subid.FromContext(ctx)
+
+	sub, err := h.useCase.RemoveTag(ctx, subID, chi.URLParam(r, "tag"))
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to remove tag")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, h.subResponse(sub))
+}
+
+// ShareSub
+// @Summary Поделиться подпиской
+// @Description Открывает подписку другому пользователю: она появится в его списке при include_shared=true. Повторный вызов не считается ошибкой
+// @Tags subscriptions
+// @Accept  json
+// @Param   id     path  string             true  "ID подписки (UUID)"
+// @Param   input  body  ShareSubRequest    true  "Пользователь"
+// @Success 204    "Доступ открыт"
+// @Failure 400    {object}  map[string]string "Некорректный JSON"
+// @Failure 404    {object}  map[string]string "Подписка не найдена"
+// @Failure 422    {object}  ValidationErrorResponse "Пользователь — владелец подписки"
+// @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/{id}/shares [post]
+func (h *HttpHandler) ShareSub(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.ShareSub"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	subID := subid.FromContext(ctx)
+
+	var req ShareSubRequest
+
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		respondDecodeError(w, r, log, err)
+		return
+	}
+
+	if req.UserID == uuid.Nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	err = h.useCase.ShareSub(ctx, subID, req.UserID)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to share sub")
+		return
+	}
+
+	log.Info("sub shared", slog.String("user_id", req.UserID.String()))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnshareSub
+// @Summary Закрыть доступ к подписке
+// @Description Отзывает доступ пользователя к подписке. Отзыв отсутствующего доступа не считается ошибкой
+// @Tags subscriptions
+// @Param   id       path  string  true  "ID подписки (UUID)"
+// @Param   user_id  path  string  true  "ID пользователя (UUID)"
+// @Success 204      "Доступ закрыт"
+// @Failure 400      {object}  map[string]string "Некорректный ID"
+// @Failure 404      {object}  map[string]string "Подписка не найдена"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/{id}/shares/{user_id} [delete]
+func (h *HttpHandler) UnshareSub(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.UnshareSub"
 	ctx := r.Context()
 
-	log := h.log.With(
+	log := logger.FromContext(ctx, h.log).With(
 		slog.String("op", op),
 		slog.String("request_id", middleware.GetReqID(ctx)),
 	)
 
-	userIDStr := chi.URLParam(r, "id")
+	subID := subid.FromContext(ctx)
+
+	userIDStr := chi.URLParam(r, "user_id")
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		log.Warn("invalid sub id", "id", userIDStr)
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, map[string]string{"error": "invalid subscription id"})
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
 		return
 	}
 
-	sub, err := h.useCase.GetUserSub(ctx, userID)
+	affected, err := h.useCase.UnshareSub(ctx, subID, userID)
 	if err != nil {
-		log.Error("failed to fetch sub", "error", err)
-		render.Status(r, http.StatusInternalServerError)
-		render.JSON(w, r, map[string]string{"error": "internal server error"})
+		respondUseCaseError(w, r, log, err, "failed to unshare sub")
 		return
 	}
 
+	log.Info("sub unshared", slog.Int64("rows_affected", affected))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSubs
+// @Summary Получить список подписок
+// @Description Возвращает подписки с фильтрами по user_id, service_name, payment_method и тегам постранично. Размер страницы по умолчанию и максимальный, а также порядок (по умолчанию started_at по убыванию, затем id) задаются в конфиге. С заголовком Accept: application/x-ndjson все подходящие подписки отдаются потоком, по одному JSON-объекту в строке, отсортированными по дате начала; limit и offset при этом не применяются
+// @Tags subscriptions
+// @Produce  json
+// @Produce  application/x-ndjson
+// @Param   user_id         query     string  false  "ID пользователя (UUID)"
+// @Param   service_name    query     string  false  "Название сервиса"
+// @Param   services        query     string  false  "Несколько сервисов через запятую (например, Netflix,Spotify)"
+// @Param   payment_method  query     string  false  "Способ оплаты (например, visa-1234)"
+// @Param   tags            query     string  false  "Теги через запятую (например, work,streaming)"
+// @Param   tag_mode        query     string  false  "any — любой из тегов (по умолчанию), all — все теги"  Enums(any, all)
+// @Param   exclude_free    query     bool    false  "Не учитывать бесплатные подписки (цена 0)"
+// @Param   include_shared  query     bool    false  "Добавить подписки, которыми поделились с user_id"
+// @Param   created_from    query     string  false  "Созданы не раньше (RFC 3339, включительно); с этим фильтром список сортируется по created_at"
+// @Param   created_to      query     string  false  "Созданы не позже (RFC 3339, включительно)"
+// @Param   fields          query     string  false  "Вернуть только эти поля через запятую (например, id,service_name,service_price)"
+// @Param   limit           query     int     false  "Размер страницы"
+// @Param   offset          query     int     false  "Смещение (не больше pagination.max_offset, по умолчанию 10000)"
+// @Success 200             {array}   SubResponse "Список подписок"
+// @Header  200             {string}  Link "Ссылки first/prev/next/last на соседние страницы (RFC 5988)"
+// @Failure 400             {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500             {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions [get]
+func (h *HttpHandler) ListSubs(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.ListSubs"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	filter, err := parseSubFilter(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	if err := parseIncludeShared(r, &filter); err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	if err := parseCreatedRange(r, &filter); err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	fields, err := parseFields(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	page, err := parsePage(r, h.cfg.Pagination.For("list"))
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+	page.Sort = h.cfg.Pagination.Sort.Sort()
+
+	w.Header().Add("Vary", "Accept")
+	if AcceptsNDJSON(r) {
+		h.streamSubs(w, r, log, filter, fields)
+		return
+	}
+
+	subs, err := h.useCase.ListSubs(ctx, filter, page)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch subs")
+		return
+	}
+
+	if h.cfg.Pagination.LinkHeader && page.Limit > 0 {
+		total, err := h.useCase.CountSubs(ctx, filter)
+		if err != nil {
+			respondUseCaseError(w, r, log, err, "failed to count subs")
+			return
+		}
+
+		w.Header().Set("Link", pageLinks(r.URL, page, total))
+	}
+
+	body, err := projectFields(h.subResponses(subs), fields)
+	if err != nil {
+		respondError(w, r, log, http.StatusInternalServerError, "failed to select fields", "error", err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, body)
+}
+
+// streamSubs writes every subscription matching filter as NDJSON, reading
+// them from storage as a stream like ExportSubs does.
+func (h *HttpHandler) streamSubs(w http.ResponseWriter, r *http.Request, log *slog.Logger, filter domain.SubFilter, fields []string) {
+	ndjson := newNDJSONWriter(w)
+	deadline := h.newStreamDeadline(w)
+	started := false
+	rows := 0
+
+	// As with the CSV export, the status is only committed once there is a
+	// row to send, so an early failure still gets a JSON error.
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	err := h.useCase.StreamSubs(r.Context(), filter, func(sub *domain.UserSub) error {
+		item, err := projectFields(h.subResponse(sub), fields)
+		if err != nil {
+			return err
+		}
+
+		start()
+		rows++
+		deadline.extend()
+
+		return ndjson.write(item)
+	})
+	if err != nil && !started {
+		respondUseCaseError(w, r, log, err, "failed to stream subs")
+		return
+	}
+	if err != nil {
+		// Part of the body may already be sent, so the status can't change.
+		log.Error("stream interrupted", "error", err, slog.Int("rows", rows))
+		return
+	}
+
+	start()
+
+	log.Info("subs streamed", slog.Int("rows", rows))
+}
+
+// ExportSubs
+// @Summary Выгрузить подписки в CSV
+// @Description Выгружает все подписки, подходящие под фильтры, в CSV, отсортированными по дате начала. Строки читаются из базы потоком, поэтому выгрузка не ограничена размером страницы
+// @Tags subscriptions
+// @Produce  text/csv
+// @Param   user_id         query     string  false  "ID пользователя (UUID)"
+// @Param   service_name    query     string  false  "Название сервиса"
+// @Param   services        query     string  false  "Несколько сервисов через запятую (например, Netflix,Spotify)"
+// @Param   payment_method  query     string  false  "Способ оплаты (например, visa-1234)"
+// @Param   tags            query     string  false  "Теги через запятую (например, work,streaming)"
+// @Param   tag_mode        query     string  false  "any — любой из тегов (по умолчанию), all — все теги"  Enums(any, all)
+// @Param   exclude_free    query     bool    false  "Не учитывать бесплатные подписки (цена 0)"
+// @Success 200             {string}  string "CSV с подписками"
+// @Failure 400             {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500             {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/export [get]
+func (h *HttpHandler) ExportSubs(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandlers.ExportSubs"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	filter, err := parseSubFilter(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	csvWriter := newSubCSVWriter(w)
+	deadline := h.newStreamDeadline(w)
+	started := false
+	rows := 0
+
+	// The CSV headers are only sent once the query has produced a row (or
+	// finished), so an early failure can still be answered with a JSON error.
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.csv"`)
+		return csvWriter.header()
+	}
+
+	err = h.useCase.StreamSubs(ctx, filter, func(sub *domain.UserSub) error {
+		if err := start(); err != nil {
+			return err
+		}
+		rows++
+		deadline.extend()
+
+		return csvWriter.write(sub)
+	})
+	if err != nil && !started {
+		respondUseCaseError(w, r, log, err, "failed to export subs")
+		return
+	}
+	if err != nil {
+		// Part of the body may already be sent, so the status can't change.
+		log.Error("export interrupted", "error", err, slog.Int("rows", rows))
+		return
+	}
+
+	if err := start(); err != nil {
+		log.Error("export interrupted", "error", err)
+		return
+	}
+
+	if err := csvWriter.flush(); err != nil {
+		log.Error("export interrupted", "error", err, slog.Int("rows", rows))
+		return
+	}
+
+	log.Info("subs exported", slog.Int("rows", rows))
+}
+
+// GetTotalCost
+// @Summary Рассчитать итоговую стоимость
+// @Description Считает сумму трат за период по одному или нескольким сервисам. Формат дат: MM-YYYY. Каждый месяц, в котором подписка была активна, оплачивается полностью (годовая — в месяц годовщины); с prorate=true — пропорционально дням активности. totalCost возвращается в единицах хранения цены (money.price_unit: копейки или рубли), totalCostFormatted — десятичной строкой
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id      query     string  true  "ID пользователя (UUID)"
+// @Param   service_name query     string  true  "Название сервиса или несколько через запятую (Netflix,Spotify)"
+// @Param   from         query     string  true  "Дата начала (01-2025)"
+// @Param   to           query     string  true  "Дата окончания (03-2025)"
+// @Param   prorate      query     bool    false  "Учитывать неполные месяцы пропорционально дням активности"
+// @Param   tz           query     string  false  "Часовой пояс IANA для границ месяцев (по умолчанию UTC)"
+// @Param   rounding     query     string  false  "Округление дробной суммы при prorate: half_up (по умолчанию), bankers, floor"  Enums(half_up, bankers, floor)
+// @Param   require_match query    bool    false  "Отвечать 404, если ни одна подписка не подошла, вместо нулевой суммы"
+// @Success 200          {object}  map[string]interface{} "Результат"
+// @Failure 400          {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 404          {object}  map[string]string "Ни одна подписка не подошла (с require_match=true)"
+// @Failure 500          {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/total [get]
+func (h *HttpHandler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.GetTotalCost"
+	ctx := r.Context()
+
+	log := logger.FromContext(r.Context(), h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	userIDStr := queryParam(r, "user_id")
+	from := queryParam(r, "from")
+	to := queryParam(r, "to")
+
+	if userIDStr == "" || queryParam(r, "service_name") == "" || from == "" || to == "" {
+		respondError(w, r, log, http.StatusBadRequest, "missing query params")
+		return
+	}
+
+	serviceNames, err := parseServiceNames(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "service_name", queryParam(r, "service_name"))
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	opts, err := parseCostOptions(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	opts.RequireMatch, err = parseBool(r, "require_match", false, errInvalidMatch)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	totalCost, err := h.useCase.GetTotalCost(ctx, userID, serviceNames, from, to, opts)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch total cost")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, map[string]interface{}{
+		"totalCost":          totalCost,
+		"totalCostFormatted": h.cfg.Money.PriceUnit.Format(int64(totalCost)),
+	})
+}
+
+// GetUserSub
+// @Summary Получить одну подписку
+// @Description Возвращает подписку по её ID (передается в пути). С with_neighbors=true добавляет ID предыдущей и следующей подписки того же пользователя по started_at
+// @Tags subscriptions
+// @Produce  json
+// @Param   id              path      string  true   "ID подписки (UUID)"
+// @Param   with_neighbors  query     bool    false  "Вернуть ID соседних подписок"
+// @Param   fields          query     string  false  "Вернуть только эти поля через запятую (например, id,service_name,service_price)"
+// @Success 200             {object}  SubResponse "Данные подписки"
+// @Failure 400             {object}  map[string]string "Некорректный ID"
+// @Failure 404             {object}  map[string]string "Подписка не найдена"
+// @Failure 500             {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/{id} [get]
+func (h *HttpHandler) GetUserSub(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.GetUserSub"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	subID := subid.FromContext(ctx)
+
+	withNeighbors, err := parseBool(r, "with_neighbors", false, errInvalidNeighbors)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "with_neighbors", queryParam(r, "with_neighbors"))
+		return
+	}
+
+	fields, err := parseFields(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	sub, err := h.useCase.GetUserSub(ctx, subID)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch sub")
+		return
+	}
+
+	resp := h.subResponse(sub)
+	if withNeighbors {
+		resp.Neighbors, err = h.useCase.SubNeighbors(ctx, sub.ID)
+		if err != nil {
+			respondUseCaseError(w, r, log, err, "failed to fetch neighbors")
+			return
+		}
+	}
+
+	body, err := projectFields(resp, fields)
+	if err != nil {
+		respondError(w, r, log, http.StatusInternalServerError, "failed to select fields", "error", err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, body)
+}
+
+const (
+	defaultForecastMonths = 12
+	maxForecastMonths     = 120
+)
+
+// Forecast
+// @Summary Прогноз трат
+// @Description Прогнозирует траты пользователя по месяцам, начиная с текущего, на основе активных сейчас подписок и их периода оплаты. Подписки перестают учитываться после месяца окончания
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id  query     string  true   "ID пользователя (UUID)"
+// @Param   months   query     int     false  "Количество месяцев (по умолчанию 12, максимум 120)"
+// @Success 200      {object}  ForecastResponse "Прогноз"
+// @Failure 400      {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/forecast [get]
+func (h *HttpHandler) Forecast(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.Forecast"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := queryParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	months := defaultForecastMonths
+	if monthsStr := queryParam(r, "months"); monthsStr != "" {
+		months, err = strconv.Atoi(monthsStr)
+		if err != nil || months < 1 || months > maxForecastMonths {
+			respondError(w, r, log, http.StatusBadRequest, "months must be between 1 and 120", "months", monthsStr)
+			return
+		}
+	}
+
+	forecast, err := h.useCase.Forecast(ctx, userID, months)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to build forecast")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, newForecastResponse(userID, forecast))
+}
+
+// Savings
+// @Summary Экономия при отмене подписки
+// @Description Считает, сколько пользователь сэкономит за следующие months месяцев, если отменит подписку сейчас. Текущий месяц уже оплачен и не учитывается, подписка с автопродлением считается продолжающейся. Для завершенной подписки экономия нулевая
+// @Tags subscriptions
+// @Produce  json
+// @Param   id      path      string  true   "ID подписки (UUID)"
+// @Param   months  query     int     false  "Количество месяцев (по умолчанию 12, максимум 120)"
+// @Success 200     {object}  SavingsResponse "Экономия"
+// @Failure 400     {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 404     {object}  map[string]string "Подписка не найдена"
+// @Failure 500     {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/{id}/savings [get]
+func (h *HttpHandler) Savings(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.Savings"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	subID := subid.FromContext(ctx)
+
+	months := defaultForecastMonths
+	if monthsStr := queryParam(r, "months"); monthsStr != "" {
+		var err error
+		months, err = strconv.Atoi(monthsStr)
+		if err != nil || months < 1 || months > maxForecastMonths {
+			respondError(w, r, log, http.StatusBadRequest, "months must be between 1 and 120", "months", monthsStr)
+			return
+		}
+	}
+
+	savings, err := h.useCase.Savings(ctx, subID, months)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to estimate savings")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, SavingsResponse{
+		ID:               subID,
+		Months:           months,
+		Savings:          savings,
+		SavingsFormatted: h.cfg.Money.PriceUnit.Format(int64(savings)),
+	})
+}
+
+// PriceHistory
+// @Summary История цены подписки
+// @Description Возвращает изменения цены подписки в порядке, в котором они были сделаны через PUT, PATCH и массовое изменение цены. Каждая запись содержит старую и новую цену и время изменения. Пустой список — цена не менялась
+// @Tags subscriptions
+// @Produce  json
+// @Param   id   path      string  true   "ID подписки (UUID)"
+// @Success 200  {array}   domain.PriceChange "Изменения цены"
+// @Failure 400  {object}  map[string]string "Некорректный ID"
+// @Failure 404  {object}  map[string]string "Подписка не найдена"
+// @Failure 500  {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/{id}/price-history [get]
+func (h *HttpHandler) PriceHistory(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.PriceHistory"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	subID := subid.FromContext(ctx)
+
+	changes, err := h.useCase.PriceHistory(ctx, subID)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch price history")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, changes)
+}
+
+// MRR
+// @Summary Ежемесячная регулярная выручка (MRR)
+// @Description Сумма месячных цен всех подписок, активных в указанном месяце, по всем пользователям. Годовые подписки учитываются как 1/12 цены. mrr возвращается в единицах хранения цены (money.price_unit), mrrFormatted — десятичной строкой
+// @Tags reports
+// @Produce  json
+// @Param   month  query     string  true  "Месяц (MM-YYYY)"
+// @Success 200    {object}  MRRResponse "MRR за месяц"
+// @Failure 400    {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/reports/mrr [get]
+func (h *HttpHandler) MRR(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.MRR"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	month := queryParam(r, "month")
+	if month == "" {
+		respondError(w, r, log, http.StatusBadRequest, "missing query params")
+		return
+	}
+
+	mrr, err := h.useCase.MRR(ctx, month)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to calculate mrr")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, MRRResponse{
+		Month:        month,
+		MRR:          mrr,
+		MRRFormatted: h.cfg.Money.PriceUnit.Format(int64(mrr)),
+	})
+}
+
+// ActiveCounts
+// @Summary Число активных подписок по месяцам
+// @Description Для каждого месяца с from по to возвращает, сколько подписок всех пользователей было активно в нем хотя бы день. Месяцы без подписок возвращаются с нулем. С weighted=true каждая подписка учитывается долей дней месяца, в которые была активна (как при prorate в /total), и count возвращается десятичной строкой (domain.WeightedMonthCount)
+// @Tags reports
+// @Produce  json
+// @Param   from      query     string  true   "Первый месяц (01-2025)"
+// @Param   to        query     string  true   "Последний месяц (12-2025)"
+// @Param   weighted  query     bool    false  "Учитывать долю месяца, в которую подписка была активна"
+// @Success 200       {array}   domain.MonthCount "Число подписок по месяцам"
+// @Failure 400       {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500       {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/reports/active-count [get]
+func (h *HttpHandler) ActiveCounts(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.ActiveCounts"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	from := queryParam(r, "from")
+	to := queryParam(r, "to")
+	if from == "" || to == "" {
+		respondError(w, r, log, http.StatusBadRequest, "missing query params")
+		return
+	}
+
+	weighted, err := parseBool(r, "weighted", false, errInvalidWeighted)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	if weighted {
+		counts, err := h.useCase.WeightedActiveCounts(ctx, from, to)
+		if err != nil {
+			respondUseCaseError(w, r, log, err, "failed to count active subs")
+			return
+		}
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, counts)
+		return
+	}
+
+	counts, err := h.useCase.ActiveCounts(ctx, from, to)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to count active subs")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, counts)
+}
+
+// SubsByUser
+// @Summary Подписки, сгруппированные по пользователям
+// @Description Возвращает подписки, подходящие под фильтры, сгруппированными по user_id (внутри группы — по дате начала). Выбирается одним запросом
+// @Tags reports
+// @Produce  json
+// @Param   user_id         query     string  false  "ID пользователя (UUID)"
+// @Param   service_name    query     string  false  "Название сервиса"
+// @Param   services        query     string  false  "Несколько сервисов через запятую (например, Netflix,Spotify)"
+// @Param   payment_method  query     string  false  "Способ оплаты (например, visa-1234)"
+// @Param   tags            query     string  false  "Теги через запятую (например, work,streaming)"
+// @Param   tag_mode        query     string  false  "any — любой из тегов (по умолчанию), all — все теги"  Enums(any, all)
+// @Param   exclude_free    query     bool    false  "Не учитывать бесплатные подписки (цена 0)"
+// @Success 200             {object}  map[string][]SubResponse "Подписки по user_id"
+// @Failure 400             {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500             {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/reports/subscriptions-by-user [get]
+func (h *HttpHandler) SubsByUser(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.SubsByUser"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	filter, err := parseSubFilter(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	grouped, err := h.useCase.SubsByUser(ctx, filter)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch subs")
+		return
+	}
+
+	resp := make(map[uuid.UUID][]SubResponse, len(grouped))
+	for userID, subs := range grouped {
+		resp[userID] = h.subResponses(subs)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, resp)
+}
+
+const (
+	defaultTopLimit = 5
+	maxTopLimit     = 100
+)
+
+// TopSubs
+// @Summary Самые дорогие подписки
+// @Description Возвращает подписки пользователя, отсортированные по цене по убыванию
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id  query     string  true   "ID пользователя (UUID)"
+// @Param   limit    query     int     false  "Количество подписок (по умолчанию 5, максимум 100)"
+// @Success 200      {array}   SubResponse "Список подписок"
+// @Failure 400      {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/top [get]
+func (h *HttpHandler) TopSubs(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.TopSubs"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := queryParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	limit := uint64(defaultTopLimit)
+	if limitStr := queryParam(r, "limit"); limitStr != "" {
+		limit, err = strconv.ParseUint(limitStr, 10, 64)
+		if err != nil || limit < 1 || limit > maxTopLimit {
+			respondError(w, r, log, http.StatusBadRequest, "limit must be between 1 and 100", "limit", limitStr)
+			return
+		}
+	}
+
+	subs, err := h.useCase.TopSubs(ctx, userID, limit)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch top subs")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, h.subResponses(subs))
+}
+
+// SubByService
+// @Summary Активная подписка пользователя на сервис
+// @Description Возвращает единственную активную подписку пользователя на сервис. Если активных несколько, отвечает 409, а с latest=true возвращает начавшуюся последней
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id       query     string  true   "ID пользователя (UUID)"
+// @Param   service_name  query     string  true   "Название сервиса"
+// @Param   latest        query     bool    false  "Вернуть последнюю из нескольких активных подписок"
+// @Success 200           {object}  SubResponse "Подписка"
+// @Failure 400           {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 404           {object}  map[string]string "Активная подписка не найдена"
+// @Failure 409           {object}  map[string]string "Найдено несколько активных подписок"
+// @Failure 500           {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/by-service [get]
+func (h *HttpHandler) SubByService(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.SubByService"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := queryParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	serviceName := queryParam(r, "service_name")
+	if serviceName == "" {
+		respondError(w, r, log, http.StatusBadRequest, "missing query params")
+		return
+	}
+
+	latest, err := parseBool(r, "latest", false, errInvalidLatest)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	sub, err := h.useCase.SubByService(ctx, userID, serviceName, latest)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch sub by service")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, h.subResponse(sub))
+}
+
+// EndedSubs
+// @Summary Завершившиеся подписки
+// @Description Возвращает подписки, у которых ended_at попадает в период с начала месяца from до конца месяца to, по возрастанию ended_at. Бессрочные подписки не возвращаются. Без user_id — подписки всех пользователей
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id  query     string  false  "ID пользователя (UUID)"
+// @Param   from     query     string  true   "Первый месяц периода (01-2025)"
+// @Param   to       query     string  true   "Последний месяц периода (03-2025)"
+// @Success 200      {array}   SubResponse "Список подписок"
+// @Failure 400      {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/ended [get]
+func (h *HttpHandler) EndedSubs(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.EndedSubs"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	from := queryParam(r, "from")
+	to := queryParam(r, "to")
+	if from == "" || to == "" {
+		respondError(w, r, log, http.StatusBadRequest, "missing query params")
+		return
+	}
+
+	var userID *uuid.UUID
+	if userIDStr := queryParam(r, "user_id"); userIDStr != "" {
+		id, err := uuid.Parse(userIDStr)
+		if err != nil {
+			respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+			return
+		}
+		userID = &id
+	}
+
+	subs, err := h.useCase.EndedSubs(ctx, userID, from, to)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch ended subs")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, h.subResponses(subs))
+}
+
+// Currencies
+// @Summary Используемые валюты
+// @Description Возвращает коды валют (ISO 4217), встречающиеся в подписках, без повторов и по алфавиту. Без user_id — по подпискам всех пользователей
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id  query     string  false  "ID пользователя (UUID)"
+// @Success 200      {array}   string "Коды валют"
+// @Failure 400      {object}  map[string]string "Некорректный ID"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/currencies [get]
+func (h *HttpHandler) Currencies(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.Currencies"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	var userID *uuid.UUID
+	if userIDStr := queryParam(r, "user_id"); userIDStr != "" {
+		id, err := uuid.Parse(userIDStr)
+		if err != nil {
+			respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+			return
+		}
+		userID = &id
+	}
+
+	currencies, err := h.useCase.Currencies(ctx, userID)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch currencies")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, currencies)
+}
+
+// ServicePriceStats
+// @Summary Статистика цен сервиса
+// @Description Возвращает минимальную, максимальную, среднюю и медианную цену активных подписок на сервис у всех пользователей, чтобы понять, не переплачивает ли пользователь. Цены приведены к месяцу (годовые — 1/12) и сравниваются только в одной валюте
+// @Tags subscriptions
+// @Produce  json
+// @Param   service_name  query     string  true   "Название сервиса"
+// @Param   currency      query     string  false  "Код валюты (по умолчанию money.default_currency)"
+// @Success 200           {object}  domain.ServicePriceStats "Статистика цен"
+// @Failure 400           {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500           {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/service-price-stats [get]
+func (h *HttpHandler) ServicePriceStats(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.ServicePriceStats"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	service := queryParam(r, "service_name")
+	if service == "" {
+		respondError(w, r, log, http.StatusBadRequest, "missing query params")
+		return
+	}
+
+	stats, err := h.useCase.ServicePriceStats(ctx, service, queryParam(r, "currency"))
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch service price stats")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, stats)
+}
+
+// Stats
+// @Summary Статистика подписок пользователя
+// @Description Возвращает одной выборкой: число подписок всего и активных, месячные траты по активным подпискам (годовые — 1/12 цены), среднюю цену и самый дорогой из активных сервисов
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id  query     string  true  "ID пользователя (UUID)"
+// @Success 200      {object}  domain.SubStats "Статистика"
+// @Failure 400      {object}  map[string]string "Некорректный ID пользователя"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/stats [get]
+func (h *HttpHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.Stats"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := queryParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	stats, err := h.useCase.Stats(ctx, userID)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch stats")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, stats)
+}
+
+// Facets
+// @Summary Фасеты подписок пользователя
+// @Description Возвращает агрегаты для фильтров: количество подписок по сервисам и статусам (active, ended и upcoming — еще не начавшиеся), минимальную, максимальную и среднюю цену
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id  query     string  true  "ID пользователя (UUID)"
+// @Success 200      {object}  domain.SubFacets "Фасеты"
+// @Failure 400      {object}  map[string]string "Некорректный ID пользователя"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/facets [get]
+func (h *HttpHandler) Facets(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.Facets"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := queryParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	facets, err := h.useCase.Facets(ctx, userID)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch facets")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, facets)
+}
+
+// Timeline
+// @Summary Хронология подписок пользователя
+// @Description Возвращает события начала и окончания подписок пользователя по возрастанию даты, для диаграммы Ганта. Окончание есть только у подписок с ended_at
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id  query     string  true  "ID пользователя (UUID)"
+// @Success 200      {array}   domain.TimelineEvent "События"
+// @Failure 400      {object}  map[string]string "Некорректный ID пользователя"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/timeline [get]
+func (h *HttpHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.Timeline"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := queryParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	events, err := h.useCase.Timeline(ctx, userID)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to build timeline")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, events)
+}
+
+// maxTotalsUsers caps how many users one GetTotalCosts request may ask for.
+const maxTotalsUsers = 100
+
+// GetTotalCosts
+// @Summary Суммарная стоимость для нескольких пользователей
+// @Description Считает сумму трат за период для каждого из user_ids одним сгруппированным запросом (не больше 100 пользователей). Формат дат: MM-YYYY. service_name необязателен и может содержать несколько сервисов через запятую. Пользователи без подписок возвращаются с нулем
+// @Tags subscriptions
+// @Accept  json
+// @Produce  json
+// @Param   input  body      TotalsRequest   true  "Пользователи и период"
+// @Success 200    {object}  map[string]int  "Сумма по user_id"
+// @Failure 400    {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500    {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/totals [post]
+func (h *HttpHandler) GetTotalCosts(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.GetTotalCosts"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	var req TotalsRequest
+
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		respondDecodeError(w, r, log, err)
+		return
+	}
+
+	if len(req.UserIDs) == 0 || req.From == "" || req.To == "" {
+		respondError(w, r, log, http.StatusBadRequest, "user_ids, from and to are required")
+		return
+	}
+
+	if len(req.UserIDs) > maxTotalsUsers {
+		respondError(w, r, log, http.StatusBadRequest, fmt.Sprintf("at most %d user_ids are allowed", maxTotalsUsers), "users", len(req.UserIDs))
+		return
+	}
+
+	serviceNames, err := splitServiceNames(req.ServiceName)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "service_name", req.ServiceName)
+		return
+	}
+
+	totals, err := h.useCase.GetTotalCosts(ctx, req.UserIDs, serviceNames, req.From, req.To)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch total costs")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, totals)
+}
+
+// ComparePeriods
+// @Summary Сравнить траты за два периода
+// @Description Считает сумму трат за два периода и разницу между ними. Период задается как MM-YYYY:MM-YYYY или MM-YYYY для одного месяца
+// @Tags subscriptions
+// @Produce  json
+// @Param   user_id       query     string  true   "ID пользователя (UUID)"
+// @Param   service_name  query     string  true   "Название сервиса или несколько через запятую (Netflix,Spotify)"
+// @Param   period_a      query     string  true   "Первый период (01-2025:03-2025)"
+// @Param   period_b      query     string  true   "Второй период (04-2025:06-2025)"
+// @Param   prorate       query     bool    false  "Учитывать неполные месяцы пропорционально дням активности"
+// @Param   tz            query     string  false  "Часовой пояс IANA для границ месяцев (по умолчанию UTC)"
+// @Param   rounding      query     string  false  "Округление дробной суммы при prorate: half_up (по умолчанию), bankers, floor"  Enums(half_up, bankers, floor)
+// @Success 200           {object}  domain.PeriodComparison "Сравнение"
+// @Failure 400           {object}  map[string]string "Ошибка валидации параметров"
+// @Failure 500           {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/compare [get]
+func (h *HttpHandler) ComparePeriods(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.ComparePeriods"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := queryParam(r, "user_id")
+	periodA := queryParam(r, "period_a")
+	periodB := queryParam(r, "period_b")
+
+	if userIDStr == "" || queryParam(r, "service_name") == "" || periodA == "" || periodB == "" {
+		respondError(w, r, log, http.StatusBadRequest, "missing query params")
+		return
+	}
+
+	serviceNames, err := parseServiceNames(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "service_name", queryParam(r, "service_name"))
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	opts, err := parseCostOptions(r)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, err.Error(), "error", err)
+		return
+	}
+
+	comparison, err := h.useCase.ComparePeriods(ctx, userID, serviceNames, domain.ParsePeriod(periodA), domain.ParsePeriod(periodB), opts)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to compare periods")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, comparison)
+}
+
+// Root
+// @Summary Описание сервиса
+// @Description Возвращает имя сервиса (instance.service_name), его версию и путь к документации, чтобы обращение к базовому адресу не заканчивалось 404
+// @Tags service
+// @Produce  json
+// @Success 200  {object}  RootResponse "Описание сервиса"
+// @Router / [get]
+func (h *HttpHandler) Root(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, RootResponse{
+		Service: h.cfg.Instance.ServiceName,
+		Version: buildinfo.Get().Version,
+		Docs:    "/swagger/index.html",
+	})
+}
+
+// StartDraining makes /readyz answer 503 from now on.
+func (h *HttpHandler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// Readyz
+// @Summary Готовность принимать запросы
+// @Description Отвечает 503, когда сервис завершает работу или последняя проверка соединения с БД не прошла, чтобы балансировщик перестал направлять на него трафик
+// @Tags service
+// @Produce  json
+// @Success 200  {object}  map[string]string "Готов"
+// @Failure 503  {object}  map[string]string "Не готов"
+// @Router /readyz [get]
+func (h *HttpHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case h.draining.Load():
+		h.notReady(w, r, "shutting down")
+	case !h.useCase.Healthy():
+		h.notReady(w, r, "storage unavailable")
+	default:
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"status": "ok"})
+	}
+}
+
+func (h *HttpHandler) notReady(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Retry-After", retryAfter)
+	render.Status(r, http.StatusServiceUnavailable)
+	render.JSON(w, r, map[string]string{"status": status})
+}
+
+// Version
+// @Summary Информация о сборке
+// @Description Возвращает версию, коммит и время сборки сервиса, а также версию Go
+// @Tags service
+// @Produce  json
+// @Success 200  {object}  domain.BuildInfo "Информация о сборке"
+// @Router /version [get]
+func (h *HttpHandler) Version(w http.ResponseWriter, r *http.Request) {
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, sub)
+	render.JSON(w, r, buildinfo.Get())
 }
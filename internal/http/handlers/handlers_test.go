@@ -0,0 +1,1032 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"testovoe/internal/buildinfo"
+	"testovoe/internal/config"
+	"testovoe/internal/domain"
+	"testovoe/internal/http/handlers"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUnmatchedUpdateAndDeleteAre404(t *testing.T) {
+	s := newServer(t)
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+
+	update := func(userID uuid.UUID) string {
+		return `{"service_name":"Netflix","service_price":150,"user_id":"` + userID.String() + `"}`
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{name: "update unknown", method: http.MethodPut, target: "/api/v1/subscriptions/" + uuid.NewString(), body: update(sub.UserID), want: http.StatusNotFound},
+		{name: "update another owner's", method: http.MethodPut, target: "/api/v1/subscriptions/" + sub.ID.String(), body: update(uuid.New()), want: http.StatusNotFound},
+		{name: "update", method: http.MethodPut, target: "/api/v1/subscriptions/" + sub.ID.String(), body: update(sub.UserID), want: http.StatusCreated},
+		{name: "delete another owner's", method: http.MethodDelete, target: "/api/v1/subscriptions/" + sub.ID.String() + "?user_id=" + uuid.NewString(), want: http.StatusNotFound},
+		{name: "delete", method: http.MethodDelete, target: "/api/v1/subscriptions/" + sub.ID.String() + "?user_id=" + sub.UserID.String(), want: http.StatusNoContent},
+		{name: "delete again", method: http.MethodDelete, target: "/api/v1/subscriptions/" + sub.ID.String() + "?user_id=" + sub.UserID.String(), want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, s.do(tt.method, tt.target, tt.body), tt.want)
+		})
+	}
+}
+
+func TestTotalCostRejectsInvalidTZ(t *testing.T) {
+	s := newServer(t)
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+
+	target := "/api/v1/subscriptions/total?service_name=Netflix&from=01-2025&to=01-2025&user_id=" + sub.UserID.String()
+
+	w := s.do(http.MethodGet, target+"&tz=Invalid/Zone", "")
+	expectStatus(t, w, http.StatusBadRequest)
+	if !strings.Contains(w.Body.String(), "invalid time zone") {
+		t.Errorf("body = %s, want the invalid time zone error", w.Body.String())
+	}
+
+	w = s.do(http.MethodGet, target+"&tz=Asia/Tokyo", "")
+	expectStatus(t, w, http.StatusOK)
+}
+
+func TestSubResponsesCarryDays(t *testing.T) {
+	s := newServer(t)
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+
+	for _, target := range []string{
+		"/api/v1/subscriptions/" + sub.ID.String(),
+		"/api/v1/subscriptions?user_id=" + sub.UserID.String(),
+	} {
+		w := s.do(http.MethodGet, target, "")
+		expectStatus(t, w, http.StatusOK)
+
+		body := w.Body.String()
+		if !strings.Contains(body, `"days_active":`) || !strings.Contains(body, `"days_remaining":null`) {
+			t.Errorf("%s: body = %s, want days_active and a null days_remaining", target, body)
+		}
+	}
+}
+
+func TestQueryParamsAreTrimmed(t *testing.T) {
+	s := newServer(t)
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+
+	tests := []struct {
+		name   string
+		query  string
+		want   int
+		amount string
+	}{
+		{name: "padded user and dates", query: "user_id=%20" + sub.UserID.String() + "%20&from=%2001-2025&to=02-2025%20", want: http.StatusOK, amount: `"totalCost":200`},
+		{name: "invalid user", query: "user_id=%20not-a-uuid%20&from=01-2025&to=02-2025", want: http.StatusBadRequest},
+		{name: "invalid date", query: "user_id=" + sub.UserID.String() + "&from=%2013-2025&to=02-2025", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, "/api/v1/subscriptions/total?service_name=Netflix&"+tt.query, "")
+			expectStatus(t, w, tt.want)
+			if tt.amount != "" && !strings.Contains(w.Body.String(), tt.amount) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.amount)
+			}
+		})
+	}
+}
+
+func TestTotalCostFormatted(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.New()
+	for _, service := range []string{"Netflix", "Spotify", "YouTube"} {
+		s.seed(domain.UserSub{UserID: userID, ServiceName: service, ServicePrice: 999})
+	}
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions/total?service_name=Netflix,Spotify,YouTube&from=01-2025&to=01-2025&user_id="+userID.String(), "")
+	expectStatus(t, w, http.StatusOK)
+	if want := `{"totalCost":2997,"totalCostFormatted":"29.97"}`; strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("body = %s, want %s", w.Body.String(), want)
+	}
+}
+
+func TestUpdateSubOmittedVersusNullEndedAt(t *testing.T) {
+	ended := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		endedAt string
+		want    *time.Time
+	}{
+		{name: "omitted", want: &ended},
+		{name: "null", endedAt: `,"ended_at":null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer(t)
+			sub := s.seed(domain.UserSub{ServicePrice: 100, EndedAt: &ended})
+
+			body := `{"service_name":"Netflix","service_price":150,"user_id":"` + sub.UserID.String() + `"` + tt.endedAt + `}`
+			expectStatus(t, s.do(http.MethodPut, "/api/v1/subscriptions/"+sub.ID.String(), body), http.StatusCreated)
+
+			stored, err := s.storage.GetUserSub(context.Background(), sub.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (stored.EndedAt == nil) != (tt.want == nil) || (stored.EndedAt != nil && !stored.EndedAt.Equal(*tt.want)) {
+				t.Errorf("ended_at = %v, want %v", stored.EndedAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopSubsLimit(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.New()
+	for price := 100; price <= 700; price += 100 {
+		s.seed(domain.UserSub{UserID: userID, ServicePrice: price})
+	}
+
+	tests := []struct {
+		name   string
+		limit  string
+		want   int
+		prices []int
+	}{
+		{name: "default", want: http.StatusOK, prices: []int{700, 600, 500, 400, 300}},
+		{name: "explicit", limit: "&limit=2", want: http.StatusOK, prices: []int{700, 600}},
+		{name: "zero", limit: "&limit=0", want: http.StatusBadRequest},
+		{name: "above maximum", limit: "&limit=101", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, "/api/v1/subscriptions/top?user_id="+userID.String()+tt.limit, "")
+			expectStatus(t, w, tt.want)
+			if tt.prices == nil {
+				return
+			}
+
+			var subs []domain.UserSub
+			if err := json.Unmarshal(w.Body.Bytes(), &subs); err != nil {
+				t.Fatalf("decode: %v; body %s", err, w.Body.String())
+			}
+			prices := make([]int, 0, len(subs))
+			for _, sub := range subs {
+				prices = append(prices, sub.ServicePrice)
+			}
+			if !slices.Equal(prices, tt.prices) {
+				t.Errorf("prices = %v, want %v", prices, tt.prices)
+			}
+		})
+	}
+}
+
+func TestPaymentMethodRoundTrip(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.NewString()
+
+	for _, body := range []string{
+		`{"service_name":"Netflix","service_price":100,"user_id":"` + userID + `","started_at":"2025-01-01T00:00:00Z","payment_method":"visa-1234"}`,
+		`{"service_name":"Spotify","service_price":200,"user_id":"` + userID + `","started_at":"2025-01-01T00:00:00Z"}`,
+	} {
+		expectStatus(t, s.do(http.MethodPost, "/api/v1/subscriptions", body), http.StatusCreated)
+	}
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions?payment_method=visa-1234&user_id="+userID, "")
+	expectStatus(t, w, http.StatusOK)
+
+	var subs []domain.UserSub
+	if err := json.Unmarshal(w.Body.Bytes(), &subs); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+	if len(subs) != 1 || subs[0].ServiceName != "Netflix" || subs[0].PaymentMethod == nil || *subs[0].PaymentMethod != "visa-1234" {
+		t.Errorf("subs = %s, want only Netflix paid by visa-1234", w.Body.String())
+	}
+
+	body := `{"service_name":"Netflix","service_price":100,"user_id":"` + userID + `","started_at":"2025-01-01T00:00:00Z","payment_method":"` + strings.Repeat("x", 65) + `"}`
+	expectStatus(t, s.do(http.MethodPost, "/api/v1/subscriptions", body), http.StatusUnprocessableEntity)
+}
+
+func TestValidateSubDoesNotWrite(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.NewString()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+		resp string
+	}{
+		{name: "valid", body: `{"service_name":"Netflix","service_price":100,"user_id":"` + userID + `","started_at":"2025-01-01T00:00:00Z"}`, want: http.StatusOK, resp: `{"valid":true}`},
+		{name: "invalid", body: `{"service_name":"","service_price":-1,"user_id":"` + userID + `","started_at":"2025-01-01T00:00:00Z"}`, want: http.StatusUnprocessableEntity, resp: `"fields":[{"field":"service_name"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/api/v1/subscriptions/validate", tt.body)
+			expectStatus(t, w, tt.want)
+			if !strings.Contains(w.Body.String(), tt.resp) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.resp)
+			}
+		})
+	}
+
+	stored, err := s.storage.GetUserSubs(context.Background(), uuid.MustParse(userID))
+	if err != nil || len(stored) != 0 {
+		t.Errorf("stored subscriptions = %d, %v; want none", len(stored), err)
+	}
+}
+
+func TestTotalCostInBothPriceUnits(t *testing.T) {
+	tests := []struct {
+		unit  domain.PriceUnit
+		price int
+		want  string
+	}{
+		{unit: domain.PriceUnitMinor, price: 2999, want: `{"totalCost":8997,"totalCostFormatted":"89.97"}`},
+		{unit: domain.PriceUnitMajor, price: 30, want: `{"totalCost":90,"totalCostFormatted":"90.00"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.unit), func(t *testing.T) {
+			s := newServer(t, func(cfg *config.Config) { cfg.Money.PriceUnit = tt.unit })
+			sub := s.seed(domain.UserSub{ServicePrice: tt.price})
+
+			w := s.do(http.MethodGet, "/api/v1/subscriptions/total?service_name=Netflix&from=01-2025&to=03-2025&user_id="+sub.UserID.String(), "")
+			expectStatus(t, w, http.StatusOK)
+			if strings.TrimSpace(w.Body.String()) != tt.want {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	s := newServer(t)
+
+	w := s.do(http.MethodGet, "/version", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var info map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+	want := map[string]string{"version": buildinfo.Version, "commit": buildinfo.Commit, "build_time": buildinfo.BuildTime, "go_version": runtime.Version()}
+	if !maps.Equal(info, want) {
+		t.Errorf("version = %v, want %v", info, want)
+	}
+}
+
+func TestCreateSubsPartialSuccess(t *testing.T) {
+	userID := uuid.NewString()
+	valid := `{"service_name":"Netflix","service_price":100,"user_id":"` + userID + `","started_at":"2025-01-01T00:00:00Z"}`
+	invalid := `{"service_name":"","service_price":100,"user_id":"` + userID + `","started_at":"2025-01-01T00:00:00Z"}`
+	body := "[" + valid + "," + invalid + "," + valid + "]"
+
+	t.Run("non-atomic", func(t *testing.T) {
+		s := newServer(t)
+
+		w := s.do(http.MethodPost, "/api/v1/subscriptions/batch?atomic=false", body)
+		expectStatus(t, w, http.StatusMultiStatus)
+
+		var results []handlers.BatchItemResult
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+			t.Fatalf("decode: %v; body %s", err, w.Body.String())
+		}
+		statuses := make([]int, 0, len(results))
+		for i, result := range results {
+			if result.Index != i {
+				t.Errorf("results[%d].index = %d", i, result.Index)
+			}
+			statuses = append(statuses, result.Status)
+		}
+		if want := []int{http.StatusCreated, http.StatusUnprocessableEntity, http.StatusCreated}; !slices.Equal(statuses, want) {
+			t.Errorf("statuses = %v, want %v", statuses, want)
+		}
+		if len(results) == 3 && (results[1].Error != "validation failed" || len(results[1].Fields) != 1 || results[1].Fields[0].Field != "service_name") {
+			t.Errorf("results[1] = %+v, want a service_name validation error", results[1])
+		}
+
+		stored, err := s.storage.GetUserSubs(context.Background(), uuid.MustParse(userID))
+		if err != nil || len(stored) != 2 {
+			t.Errorf("stored %d subscriptions, %v; want the 2 valid ones", len(stored), err)
+		}
+	})
+
+	t.Run("atomic by default", func(t *testing.T) {
+		s := newServer(t)
+
+		expectStatus(t, s.do(http.MethodPost, "/api/v1/subscriptions/batch", body), http.StatusUnprocessableEntity)
+
+		stored, err := s.storage.GetUserSubs(context.Background(), uuid.MustParse(userID))
+		if err != nil || len(stored) != 0 {
+			t.Errorf("stored %d subscriptions, %v; want none", len(stored), err)
+		}
+	})
+}
+
+func TestSandboxSubsAreFlagged(t *testing.T) {
+	sandboxID := uuid.New()
+	s := newServer(t, func(cfg *config.Config) { cfg.Sandbox.UserID = sandboxID.String() })
+
+	demo := s.seed(domain.UserSub{UserID: sandboxID, ServicePrice: 100})
+	real := s.seed(domain.UserSub{ServicePrice: 100})
+
+	for sub, want := range map[uuid.UUID]bool{demo.ID: true, real.ID: false} {
+		w := s.do(http.MethodGet, "/api/v1/subscriptions/"+sub.String(), "")
+		expectStatus(t, w, http.StatusOK)
+		if got := strings.Contains(w.Body.String(), `"sandbox":true`); got != want {
+			t.Errorf("%s flagged = %v, want %v; body %s", sub, got, want, w.Body.String())
+		}
+	}
+}
+
+func TestExportStreamsEveryRow(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.New()
+	for i := range 3 {
+		s.seed(domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: time.Date(2025, time.Month(i+1), 1, 0, 0, 0, 0, time.UTC)})
+	}
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions/export?user_id="+userID.String(), "")
+	expectStatus(t, w, http.StatusOK)
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "id,") {
+		t.Errorf("export = %q, want a header and 3 rows", w.Body.String())
+	}
+}
+
+func TestCreateSubCurrencyAllowlist(t *testing.T) {
+	s := newServer(t, func(cfg *config.Config) { cfg.Money.Currencies = []string{"RUB", "USD"} })
+
+	tests := []struct {
+		currency string
+		want     int
+	}{
+		{currency: "USD", want: http.StatusCreated},
+		{currency: "RUB", want: http.StatusCreated},
+		{currency: "EUR", want: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			body := `{"service_name":"Netflix","service_price":100,"currency":"` + tt.currency + `","user_id":"` + uuid.NewString() + `","started_at":"2025-01-01T00:00:00Z"}`
+			w := s.do(http.MethodPost, "/api/v1/subscriptions", body)
+			expectStatus(t, w, tt.want)
+			if tt.want != http.StatusCreated && !strings.Contains(w.Body.String(), `"message":"must be one of RUB, USD"`) {
+				t.Errorf("body = %s, want the allowed currencies", w.Body.String())
+			}
+		})
+	}
+
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+	update := `{"service_name":"Netflix","service_price":100,"currency":"EUR","user_id":"` + sub.UserID.String() + `"}`
+	expectStatus(t, s.do(http.MethodPut, "/api/v1/subscriptions/"+sub.ID.String(), update), http.StatusUnprocessableEntity)
+}
+
+func TestMalformedBodyIs400AndInvalidIs422(t *testing.T) {
+	s := newServer(t)
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+	userID := sub.UserID.String()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{name: "create malformed", method: http.MethodPost, target: "/api/v1/subscriptions", body: `{"service_name":`, want: http.StatusBadRequest},
+		{name: "create wrong type", method: http.MethodPost, target: "/api/v1/subscriptions", body: `{"service_price":"cheap"}`, want: http.StatusBadRequest},
+		{name: "create invalid", method: http.MethodPost, target: "/api/v1/subscriptions", body: `{"service_name":"Netflix","service_price":-1,"user_id":"` + userID + `","started_at":"2025-01-01T00:00:00Z"}`, want: http.StatusUnprocessableEntity},
+		{name: "update malformed", method: http.MethodPut, target: "/api/v1/subscriptions/" + sub.ID.String(), body: `[`, want: http.StatusBadRequest},
+		{name: "update invalid", method: http.MethodPut, target: "/api/v1/subscriptions/" + sub.ID.String(), body: `{"service_name":"","service_price":100,"user_id":"` + userID + `"}`, want: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(tt.method, tt.target, tt.body)
+			expectStatus(t, w, tt.want)
+			if tt.want == http.StatusUnprocessableEntity && !strings.Contains(w.Body.String(), `"error":"validation failed"`) {
+				t.Errorf("body = %s, want the structured validation errors", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetUserSubWithNeighbors(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.New()
+	first := s.seed(domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	second := s.seed(domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)})
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions/"+first.ID.String()+"?with_neighbors=true", "")
+	expectStatus(t, w, http.StatusOK)
+	if want := `"neighbors":{"prev_id":null,"next_id":"` + second.ID.String() + `"}`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("body = %s, want %s", w.Body.String(), want)
+	}
+
+	w = s.do(http.MethodGet, "/api/v1/subscriptions/"+first.ID.String(), "")
+	expectStatus(t, w, http.StatusOK)
+	if strings.Contains(w.Body.String(), `"neighbors"`) {
+		t.Errorf("body = %s, want no neighbors unless asked", w.Body.String())
+	}
+}
+
+func TestSubsByUserGroups(t *testing.T) {
+	s := newServer(t)
+	alice, bob := uuid.New(), uuid.New()
+	s.seed(domain.UserSub{UserID: alice, ServicePrice: 100})
+	s.seed(domain.UserSub{UserID: alice, ServicePrice: 200})
+	s.seed(domain.UserSub{UserID: bob, ServicePrice: 300})
+
+	w := s.do(http.MethodGet, "/api/v1/reports/subscriptions-by-user?service_name=Netflix", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var grouped map[uuid.UUID][]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &grouped); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+	if len(grouped) != 2 || len(grouped[alice]) != 2 || len(grouped[bob]) != 1 {
+		t.Errorf("grouped = %s, want alice with 2 and bob with 1", w.Body.String())
+	}
+}
+
+func TestListRejectsOffsetPastMaximum(t *testing.T) {
+	s := newServer(t, func(cfg *config.Config) { cfg.Pagination.MaxOffset = 100 })
+	userID := uuid.NewString()
+
+	expectStatus(t, s.do(http.MethodGet, "/api/v1/subscriptions?offset=100&user_id="+userID, ""), http.StatusOK)
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions?offset=101&user_id="+userID, "")
+	expectStatus(t, w, http.StatusBadRequest)
+	if !strings.Contains(w.Body.String(), "the maximum is 100") {
+		t.Errorf("body = %s, want the maximum offset", w.Body.String())
+	}
+}
+
+func TestStrictQueryParams(t *testing.T) {
+	userID := uuid.NewString()
+	known := "/api/v1/subscriptions?limit=5&user_id=" + userID
+	unknown := "/api/v1/subscriptions?user_di=" + userID + "&limt=5&limit=5"
+
+	lenient := newServer(t, func(cfg *config.Config) { cfg.HttpServer.StrictQueryParams = false })
+	expectStatus(t, lenient.do(http.MethodGet, known, ""), http.StatusOK)
+	expectStatus(t, lenient.do(http.MethodGet, unknown, ""), http.StatusOK)
+
+	strict := newServer(t, func(cfg *config.Config) { cfg.HttpServer.StrictQueryParams = true })
+	expectStatus(t, strict.do(http.MethodGet, known, ""), http.StatusOK)
+
+	w := strict.do(http.MethodGet, unknown, "")
+	expectStatus(t, w, http.StatusBadRequest)
+	if !strings.Contains(w.Body.String(), "unknown query parameters: limt, user_di") {
+		t.Errorf("body = %s, want the unknown params sorted", w.Body.String())
+	}
+}
+
+func TestFieldSelection(t *testing.T) {
+	s := newServer(t)
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+	want := []string{"id", "service_name", "service_price"}
+
+	decode := func(t *testing.T, body []byte) []map[string]json.RawMessage {
+		t.Helper()
+
+		if len(body) > 0 && body[0] == '{' {
+			body = append(append([]byte{'['}, body...), ']')
+		}
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			t.Fatalf("decode: %v; body %s", err, body)
+		}
+
+		return items
+	}
+
+	for _, target := range []string{
+		"/api/v1/subscriptions/" + sub.ID.String() + "?fields=id,service_name,service_price",
+		"/api/v1/subscriptions?user_id=" + sub.UserID.String() + "&fields=id,service_name,service_price",
+	} {
+		w := s.do(http.MethodGet, target, "")
+		expectStatus(t, w, http.StatusOK)
+
+		items := decode(t, w.Body.Bytes())
+		if len(items) != 1 {
+			t.Fatalf("%s: body = %s, want one subscription", target, w.Body.String())
+		}
+		if got := slices.Sorted(maps.Keys(items[0])); !slices.Equal(got, want) {
+			t.Errorf("%s: fields = %v, want %v", target, got, want)
+		}
+	}
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions?user_id="+sub.UserID.String()+"&fields=id,sub_price,password", "")
+	expectStatus(t, w, http.StatusBadRequest)
+	if !strings.Contains(w.Body.String(), "unknown fields: sub_price, password") {
+		t.Errorf("body = %s, want the unknown fields named", w.Body.String())
+	}
+}
+
+func TestListDefaultSortIsConfigurable(t *testing.T) {
+	s := newServer(t, func(cfg *config.Config) {
+		cfg.Pagination.Sort = config.ListSort{Column: domain.SortServicePrice, Direction: "asc"}
+	})
+	userID := uuid.New()
+	s.seed(domain.UserSub{UserID: userID, ServicePrice: 300, StartedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	s.seed(domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)})
+	s.seed(domain.UserSub{UserID: userID, ServicePrice: 200, StartedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)})
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions?user_id="+userID.String(), "")
+	expectStatus(t, w, http.StatusOK)
+
+	var subs []struct {
+		ServicePrice int `json:"service_price"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &subs); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+
+	var got []int
+	for _, sub := range subs {
+		got = append(got, sub.ServicePrice)
+	}
+	if want := []int{100, 200, 300}; !slices.Equal(got, want) {
+		t.Errorf("prices = %v, want %v", got, want)
+	}
+}
+
+func TestEmptyBodyIsReportedAsSuch(t *testing.T) {
+	s := newServer(t)
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   string
+	}{
+		{name: "create empty", method: http.MethodPost, target: "/api/v1/subscriptions", want: "request body is empty"},
+		{name: "create whitespace", method: http.MethodPost, target: "/api/v1/subscriptions", body: " \n", want: "request body is empty"},
+		{name: "update empty", method: http.MethodPut, target: "/api/v1/subscriptions/" + sub.ID.String(), want: "request body is empty"},
+		{name: "create truncated", method: http.MethodPost, target: "/api/v1/subscriptions", body: `{`, want: "invalid request body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(tt.method, tt.target, tt.body)
+			expectStatus(t, w, http.StatusBadRequest)
+
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v; body %s", err, w.Body.String())
+			}
+			if body["error"] != tt.want {
+				t.Errorf("error = %q, want %q", body["error"], tt.want)
+			}
+		})
+	}
+}
+
+func TestServicePriceCaps(t *testing.T) {
+	s := newServer(t, func(cfg *config.Config) {
+		cfg.Money.MaxPrice = 5000
+		cfg.Money.ServiceMaxPrices = map[string]int{"Netflix": 1000}
+	})
+	sub := s.seed(domain.UserSub{ServicePrice: 100})
+	userID := sub.UserID.String()
+
+	body := func(service string, price int) string {
+		return fmt.Sprintf(`{"service_name":%q,"service_price":%d,"user_id":%q,"started_at":"2025-01-01T00:00:00Z"}`, service, price, userID)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{name: "create at the service cap", method: http.MethodPost, target: "/api/v1/subscriptions", body: body("Netflix", 1000), want: http.StatusCreated},
+		{name: "create above the service cap", method: http.MethodPost, target: "/api/v1/subscriptions", body: body("netflix", 1001), want: http.StatusUnprocessableEntity},
+		{name: "create other service under the global cap", method: http.MethodPost, target: "/api/v1/subscriptions", body: body("Spotify", 5000), want: http.StatusCreated},
+		{name: "create other service above the global cap", method: http.MethodPost, target: "/api/v1/subscriptions", body: body("Spotify", 5001), want: http.StatusUnprocessableEntity},
+		{name: "update at the service cap", method: http.MethodPut, target: "/api/v1/subscriptions/" + sub.ID.String(), body: body("Netflix", 1000), want: http.StatusCreated},
+		{name: "update above the service cap", method: http.MethodPut, target: "/api/v1/subscriptions/" + sub.ID.String(), body: body("Netflix", 1001), want: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(tt.method, tt.target, tt.body)
+			expectStatus(t, w, tt.want)
+			if tt.want == http.StatusUnprocessableEntity && !strings.Contains(w.Body.String(), "must be at most") {
+				t.Errorf("body = %s, want the cap named", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestStatsPayload(t *testing.T) {
+	s := newServer(t)
+	sub := s.seed(domain.UserSub{ServicePrice: 100, StartedAt: time.Now().UTC().AddDate(0, -1, 0)})
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions/stats?user_id="+sub.UserID.String(), "")
+	expectStatus(t, w, http.StatusOK)
+
+	var stats domain.SubStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+	if stats.Total != 1 || stats.Active != 1 || stats.MonthlySpend != 100 || stats.AvgPrice != 100 || stats.MostExpensiveService == nil || *stats.MostExpensiveService != "Netflix" {
+		t.Errorf("stats = %s, want one active Netflix at 100", w.Body.String())
+	}
+
+	expectStatus(t, s.do(http.MethodGet, "/api/v1/subscriptions/stats", ""), http.StatusBadRequest)
+}
+
+func TestMaxRangeMonths(t *testing.T) {
+	s := newServer(t, func(cfg *config.Config) { cfg.Reports.MaxRangeMonths = 12 })
+	userID := uuid.NewString()
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{name: "total at the limit", target: "/api/v1/subscriptions/total?service_name=Netflix&user_id=" + userID + "&from=01-2025&to=12-2025", want: http.StatusOK},
+		{name: "total above the limit", target: "/api/v1/subscriptions/total?service_name=Netflix&user_id=" + userID + "&from=01-2025&to=01-2026", want: http.StatusBadRequest},
+		{name: "ended at the limit", target: "/api/v1/subscriptions/ended?user_id=" + userID + "&from=01-2025&to=12-2025", want: http.StatusOK},
+		{name: "ended above the limit", target: "/api/v1/subscriptions/ended?user_id=" + userID + "&from=01-2025&to=01-2026", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, tt.target, "")
+			expectStatus(t, w, tt.want)
+			if tt.want == http.StatusBadRequest && !strings.Contains(w.Body.String(), "at most 12 months") {
+				t.Errorf("body = %s, want the limit named", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFormattedPriceUsesConfiguredSymbol(t *testing.T) {
+	s := newServer(t, func(cfg *config.Config) {
+		cfg.Money.PriceUnit = domain.PriceUnitMinor
+		cfg.Money.CurrencySymbols = map[string]string{"RUB": "руб."}
+	})
+	rub := s.seed(domain.UserSub{ServicePrice: 99900, Currency: "RUB"})
+	usd := s.seed(domain.UserSub{ServicePrice: 1099, Currency: "USD"})
+
+	for _, tt := range []struct {
+		sub  domain.UserSub
+		want string
+	}{
+		{sub: rub, want: "999.00 руб."},
+		{sub: usd, want: "10.99 USD"},
+	} {
+		w := s.do(http.MethodGet, "/api/v1/subscriptions/"+tt.sub.ID.String(), "")
+		expectStatus(t, w, http.StatusOK)
+
+		var body struct {
+			FormattedPrice string `json:"formatted_price"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v; body %s", err, w.Body.String())
+		}
+		if body.FormattedPrice != tt.want {
+			t.Errorf("%s: formatted_price = %q, want %q", tt.sub.Currency, body.FormattedPrice, tt.want)
+		}
+	}
+}
+
+func TestReactivateSub(t *testing.T) {
+	s := newServer(t)
+	ended := time.Now().UTC().AddDate(0, -1, 0)
+	cancelled := s.seed(domain.UserSub{ServicePrice: 100, EndedAt: &ended})
+	active := s.seed(domain.UserSub{ServicePrice: 100})
+
+	w := s.do(http.MethodPost, "/api/v1/subscriptions/"+cancelled.ID.String()+"/reactivate", "")
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"id":"`+cancelled.ID.String()+`"`) || strings.Contains(w.Body.String(), `"ended_at":"`) {
+		t.Errorf("body = %s, want the subscription without ended_at", w.Body.String())
+	}
+
+	w = s.do(http.MethodPost, "/api/v1/subscriptions/"+active.ID.String()+"/reactivate", "")
+	expectStatus(t, w, http.StatusConflict)
+}
+
+func TestDrainingReadyzCarriesRetryAfter(t *testing.T) {
+	s := newServer(t)
+	expectStatus(t, s.do(http.MethodGet, "/readyz", ""), http.StatusOK)
+
+	s.h.StartDraining()
+
+	w := s.do(http.MethodGet, "/readyz", "")
+	expectStatus(t, w, http.StatusServiceUnavailable)
+	if got := w.Header().Get("Retry-After"); got == "" {
+		t.Error("draining /readyz has no Retry-After")
+	}
+}
+
+func TestUpdatePricesEndpoint(t *testing.T) {
+	s := newServer(t)
+	netflix := s.seed(domain.UserSub{ServicePrice: 100})
+	spotify := s.seed(domain.UserSub{UserID: netflix.UserID, ServiceName: "Spotify", ServicePrice: 300})
+
+	w := s.do(http.MethodPatch, "/api/v1/subscriptions/price", `{"service_name":"Netflix","new_price":200,"user_id":"`+netflix.UserID.String()+`"}`)
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"updated":1`) {
+		t.Errorf("body = %s, want one updated", w.Body.String())
+	}
+
+	for id, want := range map[uuid.UUID]int{netflix.ID: 200, spotify.ID: 300} {
+		sub, err := s.storage.GetUserSub(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetUserSub: %v", err)
+		}
+		if sub.ServicePrice != want {
+			t.Errorf("%s price = %d, want %d", sub.ServiceName, sub.ServicePrice, want)
+		}
+	}
+
+	expectStatus(t, s.do(http.MethodPatch, "/api/v1/subscriptions/price", `{"new_price":200}`), http.StatusBadRequest)
+	expectStatus(t, s.do(http.MethodPatch, "/api/v1/subscriptions/price", `{"service_name":"Netflix","new_price":-1}`), http.StatusUnprocessableEntity)
+}
+
+func TestSubByService(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.New()
+	now := time.Now().UTC()
+	ended := now.AddDate(0, -1, 0)
+
+	spotify := s.seed(domain.UserSub{UserID: userID, ServiceName: "Spotify", StartedAt: now.AddDate(0, -6, 0)})
+	s.seed(domain.UserSub{UserID: userID, ServiceName: "Okko", StartedAt: now.AddDate(0, -6, 0), EndedAt: &ended})
+	s.seed(domain.UserSub{UserID: userID, ServiceName: "Netflix", StartedAt: now.AddDate(0, -6, 0)})
+	newer := s.seed(domain.UserSub{UserID: userID, ServiceName: "Netflix", StartedAt: now.AddDate(0, -1, 0)})
+
+	tests := []struct {
+		name   string
+		query  string
+		want   int
+		wantID uuid.UUID
+	}{
+		{name: "single", query: "service_name=Spotify", want: http.StatusOK, wantID: spotify.ID},
+		{name: "none", query: "service_name=Kinopoisk", want: http.StatusNotFound},
+		{name: "only an ended one", query: "service_name=Okko", want: http.StatusNotFound},
+		{name: "several", query: "service_name=Netflix", want: http.StatusConflict},
+		{name: "several, latest", query: "service_name=Netflix&latest=true", want: http.StatusOK, wantID: newer.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, "/api/v1/subscriptions/by-service?user_id="+userID.String()+"&"+tt.query, "")
+			expectStatus(t, w, tt.want)
+			if tt.want == http.StatusOK && !strings.Contains(w.Body.String(), `"id":"`+tt.wantID.String()+`"`) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantID)
+			}
+		})
+	}
+}
+
+func TestRoot(t *testing.T) {
+	s := newServer(t, func(cfg *config.Config) { cfg.Instance.ServiceName = "subscription-service" })
+
+	w := s.do(http.MethodGet, "/", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var root map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &root); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+	want := map[string]string{"service": "subscription-service", "version": buildinfo.Get().Version, "docs": "/swagger/index.html"}
+	if !maps.Equal(root, want) {
+		t.Errorf("root = %v, want %v", root, want)
+	}
+}
+
+func TestRenameServiceEndpoint(t *testing.T) {
+	s := newServer(t, func(cfg *config.Config) { cfg.Admin.Token = "secret" })
+	s.seed(domain.UserSub{ServiceName: "Netflx", ServicePrice: 100})
+	s.seed(domain.UserSub{ServiceName: "Netflx", ServicePrice: 100})
+
+	w := s.do(http.MethodPost, "/admin/rename-service", `{"from":"Netflx","to":"Netflix"}`, "Authorization", "Bearer secret")
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"renamed":2`) {
+		t.Errorf("body = %s, want two renamed", w.Body.String())
+	}
+
+	expectStatus(t, s.do(http.MethodPost, "/admin/rename-service", `{"from":"Netflix","to":"Netflix"}`, "Authorization", "Bearer secret"), http.StatusUnprocessableEntity)
+	expectStatus(t, s.do(http.MethodPost, "/admin/rename-service", `{"from":"Netflx","to":"Netflix"}`), http.StatusUnauthorized)
+}
+
+func TestListExcludeFree(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.New()
+	s.seed(domain.UserSub{UserID: userID, ServicePrice: 0})
+	paid := s.seed(domain.UserSub{UserID: userID, ServicePrice: 100})
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions?exclude_free=true&user_id="+userID.String(), "")
+	expectStatus(t, w, http.StatusOK)
+
+	var subs []struct {
+		ID uuid.UUID `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &subs); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+	if len(subs) != 1 || subs[0].ID != paid.ID {
+		t.Errorf("body = %s, want only the paid subscription", w.Body.String())
+	}
+
+	expectStatus(t, s.do(http.MethodGet, "/api/v1/subscriptions?exclude_free=maybe&user_id="+userID.String(), ""), http.StatusBadRequest)
+}
+
+func TestInvalidSubIDIs400OnEveryIDRoute(t *testing.T) {
+	s := newServer(t)
+
+	for _, tt := range []struct{ method, target, body string }{
+		{method: http.MethodGet, target: "/api/v1/subscriptions/not-a-uuid"},
+		{method: http.MethodPut, target: "/api/v1/subscriptions/not-a-uuid", body: `{"service_name":"Netflix","service_price":100}`},
+		{method: http.MethodDelete, target: "/api/v1/subscriptions/not-a-uuid"},
+		{method: http.MethodPost, target: "/api/v1/subscriptions/not-a-uuid/reactivate"},
+	} {
+		w := s.do(tt.method, tt.target, tt.body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid subscription id") {
+			t.Errorf("%s %s = %d %s, want 400 invalid subscription id", tt.method, tt.target, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTimelineEndpoint(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.New()
+	ended := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	s.seed(domain.UserSub{UserID: userID, ServiceName: "Spotify", StartedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), EndedAt: &ended})
+	s.seed(domain.UserSub{UserID: userID, ServiceName: "Netflix", StartedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions/timeline?user_id="+userID.String(), "")
+	expectStatus(t, w, http.StatusOK)
+
+	var events []domain.TimelineEvent
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+
+	var got []string
+	for _, event := range events {
+		got = append(got, string(event.Type)+" "+event.ServiceName)
+	}
+	if want := []string{"start Netflix", "start Spotify", "end Spotify"}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestResponseTimeFormats(t *testing.T) {
+	start := time.Date(2025, 7, 1, 10, 30, 0, 500, time.UTC)
+	end := time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		format    domain.TimeFormat
+		wantStart string
+		wantEnd   string
+	}{
+		{format: domain.TimeFormatRFC3339, wantStart: `"started_at":"2025-07-01T10:30:00.0000005Z"`, wantEnd: `"ended_at":"2025-09-15T00:00:00Z"`},
+		{format: domain.TimeFormatDate, wantStart: `"started_at":"2025-07-01"`, wantEnd: `"ended_at":"2025-09-15"`},
+		{format: domain.TimeFormatMonth, wantStart: `"started_at":"07-2025"`, wantEnd: `"ended_at":"09-2025"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			s := newServer(t, func(cfg *config.Config) { cfg.HttpServer.TimeFormat = tt.format })
+			sub := s.seed(domain.UserSub{ServicePrice: 100, StartedAt: start, EndedAt: &end})
+
+			for _, target := range []string{
+				"/api/v1/subscriptions/" + sub.ID.String(),
+				"/api/v1/subscriptions?user_id=" + sub.UserID.String(),
+			} {
+				w := s.do(http.MethodGet, target, "")
+				expectStatus(t, w, http.StatusOK)
+
+				body := w.Body.String()
+				if !strings.Contains(body, tt.wantStart) || !strings.Contains(body, tt.wantEnd) {
+					t.Errorf("%s: body = %s, want %s and %s", target, body, tt.wantStart, tt.wantEnd)
+				}
+			}
+		})
+	}
+}
+
+func TestRenewExpiringParams(t *testing.T) {
+	s := newServer(t)
+	end := time.Now().UTC().AddDate(0, 0, 10)
+	sub := s.seed(domain.UserSub{ServicePrice: 100, EndedAt: &end})
+	target := "/api/v1/subscriptions/renew-expiring?user_id=" + sub.UserID.String()
+
+	for _, query := range []string{"&within_days=0", "&within_days=366", "&within_days=soon", "&months=0", "&months=121"} {
+		expectStatus(t, s.do(http.MethodPost, target+query, ""), http.StatusBadRequest)
+	}
+	expectStatus(t, s.do(http.MethodPost, "/api/v1/subscriptions/renew-expiring?user_id=nobody", ""), http.StatusBadRequest)
+
+	w := s.do(http.MethodPost, target+"&within_days=30&months=1", "")
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"renewed":1`) {
+		t.Errorf("body = %s, want one renewed", w.Body.String())
+	}
+}
+
+func TestActiveCountReport(t *testing.T) {
+	s := newServer(t)
+	s.seed(domain.UserSub{StartedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)})
+
+	w := s.do(http.MethodGet, "/api/v1/reports/active-count?from=01-2025&to=02-2025", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var counts []domain.MonthCount
+	if err := json.Unmarshal(w.Body.Bytes(), &counts); err != nil {
+		t.Fatalf("decode: %v; body %s", err, w.Body.String())
+	}
+	if want := []domain.MonthCount{{Month: "01-2025", Count: 0}, {Month: "02-2025", Count: 1}}; !slices.Equal(counts, want) {
+		t.Errorf("counts = %+v, want %+v", counts, want)
+	}
+
+	expectStatus(t, s.do(http.MethodGet, "/api/v1/reports/active-count?from=2025-01&to=02-2025", ""), http.StatusBadRequest)
+}
+
+func TestListStreamsNDJSON(t *testing.T) {
+	s := newServer(t)
+	userID := uuid.New()
+	want := make(map[uuid.UUID]bool)
+	for range 3 {
+		want[s.seed(domain.UserSub{UserID: userID, ServicePrice: 100}).ID] = true
+	}
+
+	w := s.do(http.MethodGet, "/api/v1/subscriptions?fields=id,service_name&user_id="+userID.String(), "", "Accept", "application/json;q=0.5, application/x-ndjson")
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", got)
+	}
+	if !w.Flushed {
+		t.Error("the stream was never flushed")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("body = %q, want %d lines", w.Body.String(), len(want))
+	}
+	for _, line := range lines {
+		var sub map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &sub); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if got := slices.Sorted(maps.Keys(sub)); !slices.Equal(got, []string{"id", "service_name"}) {
+			t.Errorf("line %q has fields %v, want id and service_name", line, got)
+		}
+
+		var id uuid.UUID
+		if err := json.Unmarshal(sub["id"], &id); err != nil || !want[id] {
+			t.Errorf("line %q: unexpected id", line)
+		}
+		delete(want, id)
+	}
+
+	w = s.do(http.MethodGet, "/api/v1/subscriptions?user_id="+uuid.NewString(), "", "Accept", "application/x-ndjson")
+	expectStatus(t, w, http.StatusOK)
+	if w.Body.Len() != 0 {
+		t.Errorf("body without rows = %q, want empty", w.Body.String())
+	}
+}
+
+func TestActiveCountReportWeighted(t *testing.T) {
+	s := newServer(t)
+	s.seed(domain.UserSub{StartedAt: time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)})
+
+	w := s.do(http.MethodGet, "/api/v1/reports/active-count?from=01-2025&to=01-2025&weighted=true", "")
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"count":"0.52"`) {
+		t.Errorf("body = %s, want a decimal count of 0.52", w.Body.String())
+	}
+
+	w = s.do(http.MethodGet, "/api/v1/reports/active-count?from=01-2025&to=01-2025", "")
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"count":1`) {
+		t.Errorf("body = %s, want a whole count of 1", w.Body.String())
+	}
+
+	expectStatus(t, s.do(http.MethodGet, "/api/v1/reports/active-count?from=01-2025&to=01-2025&weighted=half", ""), http.StatusBadRequest)
+}
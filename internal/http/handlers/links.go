@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"testovoe/internal/domain"
+)
+
+// pageLinks builds an RFC 5988 Link header value with first, prev, next and
+// last pages around page, keeping every other query parameter of u. prev and
+// next are left out on the first and last page.
+func pageLinks(u *url.URL, page domain.Page, total uint64) string {
+	var last uint64
+	if total > 0 {
+		last = (total - 1) / page.Limit * page.Limit
+	}
+
+	links := []string{pageLink(u, page.Limit, 0, "first")}
+
+	if page.Offset > 0 {
+		prev := uint64(0)
+		if page.Offset > page.Limit {
+			prev = page.Offset - page.Limit
+		}
+		links = append(links, pageLink(u, page.Limit, prev, "prev"))
+	}
+
+	if page.Offset+page.Limit < total {
+		links = append(links, pageLink(u, page.Limit, page.Offset+page.Limit, "next"))
+	}
+
+	links = append(links, pageLink(u, page.Limit, last, "last"))
+
+	return strings.Join(links, ", ")
+}
+
+func pageLink(u *url.URL, limit, offset uint64, rel string) string {
+	query := u.Query()
+	query.Set("limit", strconv.FormatUint(limit, 10))
+	query.Set("offset", strconv.FormatUint(offset, 10))
+
+	link := url.URL{Path: u.Path, RawQuery: query.Encode()}
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, link.String(), rel)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"testovoe/internal/domain"
+)
+
+func TestPageLinks(t *testing.T) {
+	u, err := url.Parse("/api/v1/subscriptions?service_name=Netflix&limit=10&offset=20")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := func(offset, rel string) string {
+		return `</api/v1/subscriptions?limit=10&offset=` + offset + `&service_name=Netflix>; rel="` + rel + `"`
+	}
+
+	tests := []struct {
+		name   string
+		offset uint64
+		total  uint64
+		want   []string
+	}{
+		{name: "first page", offset: 0, total: 35, want: []string{link("0", "first"), link("10", "next"), link("30", "last")}},
+		{name: "middle page", offset: 20, total: 35, want: []string{link("0", "first"), link("10", "prev"), link("30", "next"), link("30", "last")}},
+		{name: "last page", offset: 30, total: 35, want: []string{link("0", "first"), link("20", "prev"), link("30", "last")}},
+		{name: "exact multiple", offset: 0, total: 30, want: []string{link("0", "first"), link("10", "next"), link("20", "last")}},
+		{name: "offset inside the first page", offset: 5, total: 35, want: []string{link("0", "first"), link("0", "prev"), link("15", "next"), link("30", "last")}},
+		{name: "no results", offset: 0, total: 0, want: []string{link("0", "first"), link("0", "last")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pageLinks(u, domain.Page{Limit: 10, Offset: tt.offset}, tt.total)
+
+			if want := strings.Join(tt.want, ", "); got != want {
+				t.Errorf("pageLinks =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// AcceptsNDJSON reports whether the Accept header of r lists NDJSON.
+func AcceptsNDJSON(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(part)
+			if err == nil && mediaType == ndjsonContentType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ndjsonWriter writes one JSON value per line and flushes after each, so a
+// client reads rows as they are produced.
+type ndjsonWriter struct {
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	flusher, _ := w.(http.Flusher)
+	return &ndjsonWriter{enc: json.NewEncoder(w), flusher: flusher}
+}
+
+func (n *ndjsonWriter) write(v any) error {
+	if err := n.enc.Encode(v); err != nil {
+		return err
+	}
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptsNDJSON(t *testing.T) {
+	tests := []struct {
+		accept []string
+		want   bool
+	}{
+		{accept: nil, want: false},
+		{accept: []string{"application/json"}, want: false},
+		{accept: []string{"application/x-ndjson"}, want: true},
+		{accept: []string{"application/json, application/x-ndjson;q=0.9"}, want: true},
+		{accept: []string{"text/csv", "application/x-ndjson"}, want: true},
+		{accept: []string{"application/x-ndjsonp"}, want: false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, value := range tt.accept {
+			r.Header.Add("Accept", value)
+		}
+
+		if got := AcceptsNDJSON(r); got != tt.want {
+			t.Errorf("AcceptsNDJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
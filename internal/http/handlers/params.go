@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testovoe/internal/config"
+	"testovoe/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// queryParam returns the query value for key with surrounding whitespace
+// removed, so values like "%20<uuid>" still parse.
+func queryParam(r *http.Request, key string) string {
+	return strings.TrimSpace(r.URL.Query().Get(key))
+}
+
+var (
+	errInvalidUserID    = errors.New("invalid user id")
+	errInvalidLimit     = errors.New("invalid limit")
+	errInvalidOffset    = errors.New("invalid offset")
+	errOffsetTooLarge   = errors.New("offset too large")
+	errInvalidProrate   = errors.New("invalid prorate flag")
+	errInvalidTZ        = errors.New("invalid time zone")
+	errInvalidRounding  = errors.New("rounding must be half_up, bankers or floor")
+	errInvalidAtomic    = errors.New("invalid atomic flag")
+	errInvalidReset     = errors.New("invalid reset_started_at flag")
+	errInvalidLatest    = errors.New("invalid latest flag")
+	errInvalidFree      = errors.New("invalid exclude_free flag")
+	errInvalidMatch     = errors.New("invalid require_match flag")
+	errInvalidWeighted  = errors.New("invalid weighted flag")
+	errInvalidNeighbors = errors.New("invalid with_neighbors flag")
+	errInvalidShared    = errors.New("include_shared must be a boolean and requires user_id")
+	errInvalidCreated   = errors.New("created_from and created_to must be RFC 3339 timestamps")
+	errInvalidTagMode   = errors.New("tag_mode must be any or all")
+	errEmptyService     = errors.New("service_name entries must not be empty")
+)
+
+// listParam splits a comma-separated query value, trimming items and dropping
+// empty and repeated ones.
+func listParam(r *http.Request, key string) []string {
+	var items []string
+	for _, item := range strings.Split(queryParam(r, key), ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// parseServiceNames reads the comma-separated service_name of the cost
+// endpoints. Unlike listParam it rejects empty entries such as "Netflix,,".
+func parseServiceNames(r *http.Request) ([]string, error) {
+	return splitServiceNames(queryParam(r, "service_name"))
+}
+
+// splitServiceNames splits a comma-separated list of services, rejecting
+// empty entries. A blank value yields no names.
+func splitServiceNames(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, errEmptyService
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// parseSubFilter reads the subscription filters shared by list-style endpoints.
+func parseSubFilter(r *http.Request) (domain.SubFilter, error) {
+	var filter domain.SubFilter
+
+	if userIDStr := queryParam(r, "user_id"); userIDStr != "" {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			return filter, errInvalidUserID
+		}
+		filter.UserID = &userID
+	}
+
+	filter.ServiceName = queryParam(r, "service_name")
+	filter.PaymentMethod = queryParam(r, "payment_method")
+
+	filter.ServiceNames = listParam(r, "services")
+	filter.Tags = listParam(r, "tags")
+
+	filter.TagMode = domain.TagModeAny
+	if mode := queryParam(r, "tag_mode"); mode != "" {
+		filter.TagMode = domain.TagMode(mode)
+		if !filter.TagMode.Valid() {
+			return filter, errInvalidTagMode
+		}
+	}
+
+	excludeFree, err := parseBool(r, "exclude_free", false, errInvalidFree)
+	if err != nil {
+		return filter, err
+	}
+	filter.ExcludeFree = excludeFree
+
+	return filter, nil
+}
+
+// parsePage reads limit and offset within the given page limits. A missing
+// limit takes the default, and limits above the maximum are clamped to it.
+// Offsets above the maximum are rejected rather than clamped, since that
+// would silently return a different page.
+func parsePage(r *http.Request, size config.PageSize) (domain.Page, error) {
+	page := domain.Page{Limit: size.DefaultLimit}
+
+	if limitStr := queryParam(r, "limit"); limitStr != "" {
+		limit, err := strconv.ParseUint(limitStr, 10, 64)
+		if err != nil || limit == 0 {
+			return page, errInvalidLimit
+		}
+		page.Limit = limit
+	}
+
+	if size.MaxLimit > 0 && (page.Limit == 0 || page.Limit > size.MaxLimit) {
+		page.Limit = size.MaxLimit
+	}
+
+	if offsetStr := queryParam(r, "offset"); offsetStr != "" {
+		offset, err := strconv.ParseUint(offsetStr, 10, 64)
+		if err != nil {
+			return page, errInvalidOffset
+		}
+		if size.MaxOffset > 0 && offset > size.MaxOffset {
+			return page, fmt.Errorf("%w: the maximum is %d, narrow the filters or use the export endpoint", errOffsetTooLarge, size.MaxOffset)
+		}
+		page.Offset = offset
+	}
+
+	return page, nil
+}
+
+// parseCostOptions reads the options shared by total-cost style endpoints.
+func parseCostOptions(r *http.Request) (domain.CostOptions, error) {
+	var opts domain.CostOptions
+
+	prorate, err := parseBool(r, "prorate", false, errInvalidProrate)
+	if err != nil {
+		return opts, err
+	}
+	opts.Prorate = prorate
+
+	if tz := queryParam(r, "tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return opts, errInvalidTZ
+		}
+		opts.Location = loc
+	}
+
+	if rounding := queryParam(r, "rounding"); rounding != "" {
+		opts.Rounding = domain.RoundingMode(rounding)
+		if !opts.Rounding.Valid() {
+			return opts, errInvalidRounding
+		}
+	}
+
+	return opts, nil
+}
+
+// parseBool reads the optional boolean query parameter name, def when
+// absent. A value strconv.ParseBool rejects fails with errInvalid.
+func parseBool(r *http.Request, name string, def bool, errInvalid error) (bool, error) {
+	value := queryParam(r, name)
+	if value == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errInvalid
+	}
+
+	return b, nil
+}
+
+// parseIncludeShared sets filter.IncludeShared from include_shared. It is
+// read only by the list endpoint, so filters for bulk deletes and exports
+// never reach other users' subscriptions.
+func parseIncludeShared(r *http.Request, filter *domain.SubFilter) error {
+	shared, err := parseBool(r, "include_shared", false, errInvalidShared)
+	if err != nil || (shared && filter.UserID == nil) {
+		return errInvalidShared
+	}
+	filter.IncludeShared = shared
+
+	return nil
+}
+
+// parseCreatedRange sets the inclusive created_at bounds of filter from
+// created_from and created_to. Stored timestamps are UTC, so the bounds are too.
+func parseCreatedRange(r *http.Request, filter *domain.SubFilter) error {
+	for key, bound := range map[string]**time.Time{
+		"created_from": &filter.CreatedFrom,
+		"created_to":   &filter.CreatedTo,
+	} {
+		value := queryParam(r, key)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errInvalidCreated
+		}
+		t = t.UTC()
+		*bound = &t
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"testovoe/internal/config"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestParseBool(t *testing.T) {
+	errInvalid := errors.New("invalid flag")
+
+	tests := []struct {
+		name    string
+		query   string
+		def     bool
+		want    bool
+		wantErr error
+	}{
+		{name: "absent takes false default", query: "", def: false, want: false},
+		{name: "absent takes true default", query: "", def: true, want: true},
+		{name: "blank takes default", query: "?flag=%20", def: true, want: true},
+		{name: "true", query: "?flag=true", want: true},
+		{name: "false overrides true default", query: "?flag=false", def: true, want: false},
+		{name: "numeric", query: "?flag=1", want: true},
+		{name: "padded", query: "?flag=%20true%20", want: true},
+		{name: "invalid", query: "?flag=yes", def: true, wantErr: errInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/"+tt.query, nil)
+
+			got, err := parseBool(r, "flag", tt.def, errInvalid)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("parseBool() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCostOptionsTZ(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr error
+	}{
+		{name: "absent is UTC", query: ""},
+		{name: "iana name", query: "?tz=Europe/Berlin", want: "Europe/Berlin"},
+		{name: "invalid", query: "?tz=Invalid/Zone", wantErr: errInvalidTZ},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/"+tt.query, nil)
+
+			opts, err := parseCostOptions(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := ""
+			if opts.Location != nil {
+				got = opts.Location.String()
+			}
+			if got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryParamTrimsWhitespace(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?user_id=%20abc%09&from=01-2025%20&blank=%20%20", nil)
+
+	for key, want := range map[string]string{"user_id": "abc", "from": "01-2025", "blank": "", "missing": ""} {
+		if got := queryParam(r, key); got != want {
+			t.Errorf("queryParam(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestParseSubFilterTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantTags []string
+		wantMode domain.TagMode
+		wantErr  error
+	}{
+		{name: "any by default", query: "?tags=work,streaming", wantTags: []string{"work", "streaming"}, wantMode: domain.TagModeAny},
+		{name: "all", query: "?tags=work,streaming&tag_mode=all", wantTags: []string{"work", "streaming"}, wantMode: domain.TagModeAll},
+		{name: "unknown mode", query: "?tags=work&tag_mode=some", wantErr: errInvalidTagMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseSubFilter(httptest.NewRequest("GET", "/"+tt.query, nil))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.Equal(filter.Tags, tt.wantTags) || filter.TagMode != tt.wantMode {
+				t.Errorf("tags = %v %q, want %v %q", filter.Tags, filter.TagMode, tt.wantTags, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestParseCreatedRange(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?created_from=2025-01-01T03:00:00%2B03:00&created_to=2025-01-31T23:59:59Z", nil)
+	var filter domain.SubFilter
+	if err := parseCreatedRange(r, &filter); err != nil {
+		t.Fatalf("parseCreatedRange: %v", err)
+	}
+
+	wantFrom := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	wantTo := time.Date(2025, 1, 31, 23, 59, 59, 0, time.UTC)
+	if filter.CreatedFrom == nil || !filter.CreatedFrom.Equal(wantFrom) || filter.CreatedFrom.Location() != time.UTC {
+		t.Errorf("CreatedFrom = %v, want %v", filter.CreatedFrom, wantFrom)
+	}
+	if filter.CreatedTo == nil || !filter.CreatedTo.Equal(wantTo) {
+		t.Errorf("CreatedTo = %v, want %v", filter.CreatedTo, wantTo)
+	}
+
+	r = httptest.NewRequest("GET", "/?created_to=2025-01-31", nil)
+	if err := parseCreatedRange(r, &filter); !errors.Is(err, errInvalidCreated) {
+		t.Errorf("date-only bound: err = %v, want %v", err, errInvalidCreated)
+	}
+}
+
+func TestListParam(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?services=%20Netflix,Spotify%20,,Netflix,", nil)
+
+	if got, want := listParam(r, "services"), []string{"Netflix", "Spotify"}; !slices.Equal(got, want) {
+		t.Errorf("listParam = %q, want %q", got, want)
+	}
+	if got := listParam(r, "missing"); got != nil {
+		t.Errorf("listParam of a missing key = %q, want nil", got)
+	}
+}
+
+func TestSplitServiceNames(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr error
+	}{
+		{value: "Netflix", want: []string{"Netflix"}},
+		{value: " Netflix , Spotify,Netflix", want: []string{"Netflix", "Spotify"}},
+		{value: "  "},
+		{value: "Netflix,,Spotify", wantErr: errEmptyService},
+		{value: "Netflix, ", wantErr: errEmptyService},
+	}
+
+	for _, tt := range tests {
+		got, err := splitServiceNames(tt.value)
+		if !errors.Is(err, tt.wantErr) || !slices.Equal(got, tt.want) {
+			t.Errorf("splitServiceNames(%q) = %q, %v; want %q, %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePageMaxOffset(t *testing.T) {
+	size := config.PageSize{DefaultLimit: 50, MaxLimit: 200, MaxOffset: 1000}
+
+	tests := []struct {
+		name       string
+		query      string
+		size       config.PageSize
+		wantOffset uint64
+		wantErr    error
+	}{
+		{name: "at the threshold", query: "?offset=1000", size: size, wantOffset: 1000},
+		{name: "past the threshold", query: "?offset=1001", size: size, wantErr: errOffsetTooLarge},
+		{name: "no threshold", query: "?offset=1000000", size: config.PageSize{DefaultLimit: 50}, wantOffset: 1000000},
+		{name: "not a number", query: "?offset=-1", size: size, wantErr: errInvalidOffset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := parsePage(httptest.NewRequest("GET", "/"+tt.query, nil), tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && page.Offset != tt.wantOffset {
+				t.Errorf("offset = %d, want %d", page.Offset, tt.wantOffset)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"testovoe/internal/domain"
+	"testovoe/internal/http/middleware/logger"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// GetReminderPreference
+// @Summary Получить настройки напоминаний
+// @Description Возвращает, за сколько дней до окончания подписки и по какому каналу пользователь хочет получать напоминание
+// @Tags reminders
+// @Produce  json
+// @Param   user_id  path      string  true  "ID пользователя (UUID)"
+// @Success 200      {object}  domain.ReminderPreference "Настройки напоминаний"
+// @Failure 400      {object}  map[string]string "Некорректный ID"
+// @Failure 404      {object}  map[string]string "Настройки не заданы"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{user_id}/reminder-preferences [get]
+func (h *HttpHandler) GetReminderPreference(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.GetReminderPreference"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := chi.URLParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	pref, err := h.useCase.GetReminderPreference(ctx, userID)
+	if err != nil {
+		respondUseCaseError(w, r, log, err, "failed to fetch reminder preferences")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, pref)
+}
+
+// SetReminderPreference
+// @Summary Задать настройки напоминаний
+// @Description Сохраняет срок напоминания (0–90 дней до окончания подписки) и канал (email, push, sms). Существующие настройки перезаписываются
+// @Tags reminders
+// @Accept  json
+// @Produce  json
+// @Param   user_id  path      string                     true  "ID пользователя (UUID)"
+// @Param   input    body      domain.ReminderPreference  true  "Настройки напоминаний (user_id берется из пути)"
+// @Success 200      {object}  domain.ReminderPreference "Сохраненные настройки"
+// @Failure 400      {object}  map[string]string "Некорректный JSON или ID"
+// @Failure 422      {object}  ValidationErrorResponse "Ошибка валидации"
+// @Failure 500      {object}  map[string]string "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{user_id}/reminder-preferences [put]
+func (h *HttpHandler) SetReminderPreference(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.SetReminderPreference"
+	ctx := r.Context()
+
+	log := logger.FromContext(ctx, h.log).With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+	)
+
+	userIDStr := chi.URLParam(r, "user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, r, log, http.StatusBadRequest, "invalid user id", "id", userIDStr)
+		return
+	}
+
+	var req domain.ReminderPreference
+
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		respondDecodeError(w, r, log, err)
+		return
+	}
+
+	req.UserID = userID
+
+	if err := h.useCase.SetReminderPreference(ctx, req); err != nil {
+		respondUseCaseError(w, r, log, err, "failed to save reminder preferences")
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, req)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testovoe/internal/domain"
+	"testovoe/internal/validation"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const day = 24 * time.Hour
+
+// SubResponse is domain.UserSub enriched with values computed at read time.
+type SubResponse struct {
+	domain.UserSub
+	// StartedAt and EndedAt shadow those of UserSub to render them in
+	// http_server.time_format.
+	StartedAt     responseTime  `json:"started_at" swaggertype:"string" example:"2025-07-01T00:00:00Z"`
+	EndedAt       *responseTime `json:"ended_at,omitempty" swaggertype:"string" example:"2026-07-01T00:00:00Z"`
+	DaysActive    int           `json:"days_active" example:"30"`
+	DaysRemaining *int          `json:"days_remaining" example:"335"`
+	// BillingLabel is the display name of the cadence: monthly, yearly or
+	// one-time.
+	BillingLabel string `json:"billing_label" example:"monthly"`
+	// FormattedPrice is ServicePrice with the currency symbol from
+	// money.currency_symbols.
+	FormattedPrice string `json:"formatted_price" example:"9.90 ₽"`
+	// NextBillingDate is null for subscriptions that will not bill again.
+	NextBillingDate *time.Time `json:"next_billing_date" example:"2025-08-01T00:00:00Z"`
+	// Sandbox marks demo data of the sandbox user, which is reset periodically.
+	Sandbox bool `json:"sandbox,omitempty" example:"false"`
+	// Neighbors is only filled by GetUserSub with with_neighbors=true.
+	Neighbors *domain.SubNeighbors `json:"neighbors,omitempty"`
+}
+
+func newSubResponse(sub *domain.UserSub, now time.Time) SubResponse {
+	resp := SubResponse{
+		UserSub:         *sub,
+		BillingLabel:    sub.BillingLabel(),
+		NextBillingDate: sub.NextBillingDate(now),
+	}
+
+	activeUntil := now
+	if sub.EndedAt != nil && sub.EndedAt.Before(now) {
+		activeUntil = *sub.EndedAt
+	}
+	resp.DaysActive = daysBetween(sub.StartedAt, activeUntil)
+
+	if sub.EndedAt != nil {
+		remaining := daysBetween(now, *sub.EndedAt)
+		resp.DaysRemaining = &remaining
+	}
+
+	return resp
+}
+
+func (h *HttpHandler) subResponse(sub *domain.UserSub) SubResponse {
+	resp := newSubResponse(sub, time.Now())
+	resp.FormattedPrice = h.cfg.Money.FormatPrice(int64(sub.ServicePrice), sub.Currency)
+
+	layout := h.cfg.HttpServer.TimeFormat.Layout()
+	resp.StartedAt = responseTime{Time: sub.StartedAt, layout: layout}
+	if sub.EndedAt != nil {
+		resp.EndedAt = &responseTime{Time: *sub.EndedAt, layout: layout}
+	}
+	if sandboxID, ok := h.cfg.Sandbox.ID(); ok && sub.UserID == sandboxID {
+		resp.Sandbox = true
+	}
+
+	return resp
+}
+
+func (h *HttpHandler) subResponses(subs []*domain.UserSub) []SubResponse {
+	resp := make([]SubResponse, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, h.subResponse(sub))
+	}
+
+	return resp
+}
+
+// responseTime encodes in layout, or as time.Time does when layout is empty.
+type responseTime struct {
+	time.Time
+	layout string
+}
+
+func (t responseTime) MarshalJSON() ([]byte, error) {
+	if t.layout == "" {
+		return t.Time.MarshalJSON()
+	}
+
+	return []byte(`"` + t.Format(t.layout) + `"`), nil
+}
+
+func daysBetween(from, to time.Time) int {
+	if !to.After(from) {
+		return 0
+	}
+
+	return int(to.Sub(from) / day)
+}
+
+// ValidationErrorResponse is returned when a payload breaks business rules.
+type ValidationErrorResponse struct {
+	Error  string            `json:"error" example:"validation failed"`
+	Fields validation.Errors `json:"fields"`
+}
+
+func validationErrorResponse(errs validation.Errors) ValidationErrorResponse {
+	return ValidationErrorResponse{Error: "validation failed", Fields: errs}
+}
+
+// TotalsRequest is the body of POST /subscriptions/totals.
+type TotalsRequest struct {
+	UserIDs     []uuid.UUID `json:"user_ids"`
+	From        string      `json:"from" example:"01-2025"`
+	To          string      `json:"to" example:"12-2025"`
+	ServiceName string      `json:"service_name,omitempty" example:"Netflix,Spotify"`
+}
+
+type ShareSubRequest struct {
+	UserID uuid.UUID `json:"user_id" example:"550e8400-e29b-41d4-a716-446655442222"`
+}
+
+// RootResponse points operators probing the base URL at the docs.
+type RootResponse struct {
+	Service string `json:"service" example:"subscription-service"`
+	Version string `json:"version" example:"v1.2.3"`
+	Docs    string `json:"docs" example:"/swagger/index.html"`
+}
+
+type RenameServiceRequest struct {
+	From string `json:"from" example:"Netflx"`
+	To   string `json:"to" example:"Netflix"`
+}
+
+// BulkPriceRequest sets new_price on the subscriptions of service_name,
+// user_id or both.
+type BulkPriceRequest struct {
+	ServiceName string     `json:"service_name" example:"Netflix"`
+	NewPrice    *int       `json:"new_price" example:"1190"`
+	UserID      *uuid.UUID `json:"user_id" swaggertype:"string" example:"550e8400-e29b-41d4-a716-446655441111"`
+}
+
+type AddTagsRequest struct {
+	Tags []string `json:"tags" example:"work,streaming"`
+}
+
+// BatchItemResult reports what happened to one record of a non-atomic batch.
+type BatchItemResult struct {
+	Index  int               `json:"index" example:"0"`
+	Status int               `json:"status" example:"201"`
+	Error  string            `json:"error,omitempty" example:"validation failed"`
+	Fields validation.Errors `json:"fields,omitempty"`
+}
+
+func newBatchItemResults(errs []error) []BatchItemResult {
+	results := make([]BatchItemResult, len(errs))
+	for i, err := range errs {
+		results[i] = BatchItemResult{Index: i, Status: http.StatusCreated}
+
+		var fieldErrs validation.Errors
+		switch {
+		case err == nil:
+		case errors.As(err, &fieldErrs):
+			results[i].Status = http.StatusUnprocessableEntity
+			results[i].Error = "validation failed"
+			results[i].Fields = fieldErrs
+		default:
+			results[i].Status = http.StatusInternalServerError
+			results[i].Error = "internal server error"
+		}
+	}
+
+	return results
+}
+
+type MRRResponse struct {
+	Month        string `json:"month" example:"07-2025"`
+	MRR          int    `json:"mrr" example:"10990"`
+	MRRFormatted string `json:"mrrFormatted" example:"109.90"`
+}
+
+// SavingsResponse is what cancelling a subscription now saves over Months.
+type SavingsResponse struct {
+	ID               uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Months           int       `json:"months" example:"12"`
+	Savings          int       `json:"savings" example:"10890"`
+	SavingsFormatted string    `json:"savingsFormatted" example:"108.90"`
+}
+
+type ForecastResponse struct {
+	UserID uuid.UUID          `json:"user_id" example:"550e8400-e29b-41d4-a716-446655441111"`
+	Months []domain.MonthCost `json:"months"`
+	Total  int                `json:"total" example:"11880"`
+}
+
+func newForecastResponse(userID uuid.UUID, months []domain.MonthCost) ForecastResponse {
+	resp := ForecastResponse{UserID: userID, Months: months}
+	for _, m := range months {
+		resp.Total += m.Cost
+	}
+
+	return resp
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestNewSubResponseDays(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		t := now.AddDate(0, 0, -days)
+		return &t
+	}
+
+	tests := []struct {
+		name          string
+		start, end    *time.Time
+		wantActive    int
+		wantRemaining *int
+	}{
+		{name: "ongoing", start: daysAgo(30), wantActive: 30},
+		{name: "ending later", start: daysAgo(10), end: daysAgo(-20), wantActive: 10, wantRemaining: ptr(20)},
+		{name: "ended", start: daysAgo(100), end: daysAgo(40), wantActive: 60, wantRemaining: ptr(0)},
+		{name: "not started yet", start: daysAgo(-5), end: daysAgo(-35), wantActive: 0, wantRemaining: ptr(35)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newSubResponse(&domain.UserSub{StartedAt: *tt.start, EndedAt: tt.end}, now)
+
+			if resp.DaysActive != tt.wantActive {
+				t.Errorf("days_active = %d, want %d", resp.DaysActive, tt.wantActive)
+			}
+			switch {
+			case tt.wantRemaining == nil && resp.DaysRemaining != nil:
+				t.Errorf("days_remaining = %d, want null", *resp.DaysRemaining)
+			case tt.wantRemaining != nil && (resp.DaysRemaining == nil || *resp.DaysRemaining != *tt.wantRemaining):
+				t.Errorf("days_remaining = %v, want %d", resp.DaysRemaining, *tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
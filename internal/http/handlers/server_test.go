@@ -0,0 +1,116 @@
+package handlers_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/config"
+	"testovoe/internal/domain"
+	"testovoe/internal/events"
+	"testovoe/internal/http/handlers"
+	"testovoe/internal/http/router"
+	"testovoe/internal/storage/inmemory"
+	"testovoe/internal/usecase"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+// server is the whole HTTP stack over the in-memory storage, configured
+// from the repository's config.yaml.
+type server struct {
+	t       *testing.T
+	handler http.Handler
+	h       *handlers.HttpHandler
+	storage *inmemory.Storage
+	cfg     *config.Config
+}
+
+func newServer(t *testing.T, configure ...func(*config.Config)) *server {
+	t.Helper()
+
+	var cfg config.Config
+	if err := cleanenv.ReadConfig("../../../config.yaml", &cfg); err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	storage := inmemory.New()
+
+	mux := chi.NewRouter()
+	h := handlers.New(log, usecase.New(log, storage, &cfg, events.Noop{}), &cfg)
+	router.Router(mux, h, nil, log, &cfg)
+
+	return &server{t: t, handler: mux, h: h, storage: storage, cfg: &cfg}
+}
+
+// do serves one request; headers are name, value pairs.
+func (s *server) do(method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, r)
+
+	return w
+}
+
+// seed stores sub, filling an id, user, service, currency, period and
+// start date when they are left empty.
+func (s *server) seed(sub domain.UserSub) domain.UserSub {
+	s.t.Helper()
+
+	if sub.ID == uuid.Nil {
+		sub.ID = uuid.New()
+	}
+	if sub.UserID == uuid.Nil {
+		sub.UserID = uuid.New()
+	}
+	if sub.ServiceName == "" {
+		sub.ServiceName = "Netflix"
+	}
+	if sub.Currency == "" {
+		sub.Currency = "RUB"
+	}
+	if sub.BillingPeriod == "" {
+		sub.BillingPeriod = domain.BillingMonthly
+	}
+	if sub.StartedAt.IsZero() {
+		sub.StartedAt = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	if _, err := s.storage.CreateSub(context.Background(), sub); err != nil {
+		s.t.Fatalf("seed subscription: %v", err)
+	}
+
+	return sub
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+
+	if w.Code != want {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, want, w.Body.String())
+	}
+}
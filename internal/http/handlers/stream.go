@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// streamDeadline keeps a streamed response within http_server.write_timeout
+// per row rather than for the whole body: every extend moves the
+// connection's write deadline that far ahead, so a long export only fails
+// when the client or the query stalls.
+type streamDeadline struct {
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (h *HttpHandler) newStreamDeadline(w http.ResponseWriter) streamDeadline {
+	return streamDeadline{rc: http.NewResponseController(w), timeout: h.cfg.HttpServer.WriteTimeout}
+}
+
+func (d streamDeadline) extend() {
+	if d.timeout <= 0 {
+		return
+	}
+
+	// Writers that can't set deadlines, e.g. in tests, have none to extend.
+	_ = d.rc.SetWriteDeadline(time.Now().Add(d.timeout))
+}
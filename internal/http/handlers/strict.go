@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"testovoe/internal/http/middleware/logger"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Query parameter groups read by the shared parsers, for KnownParams.
+var (
+	FilterParams = []string{"user_id", "service_name", "services", "payment_method", "tags", "tag_mode", "exclude_free"}
+	PageParams   = []string{"limit", "offset"}
+	CostParams   = []string{"prorate", "tz", "rounding"}
+)
+
+// KnownParams declares the query parameters an endpoint reads. With
+// http_server.strict_query_params on, a request carrying any other parameter
+// is rejected with 400 naming them, so typos like user_di don't silently
+// widen a query. Otherwise it lets every request through.
+func (h *HttpHandler) KnownParams(groups ...[]string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !h.cfg.HttpServer.StrictQueryParams {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var unknown []string
+			for key := range r.URL.Query() {
+				if !slices.ContainsFunc(groups, func(group []string) bool { return slices.Contains(group, key) }) {
+					unknown = append(unknown, key)
+				}
+			}
+
+			if len(unknown) > 0 {
+				sort.Strings(unknown)
+
+				log := logger.FromContext(r.Context(), h.log).With(
+					slog.String("op", "httpHandler.KnownParams"),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				respondError(w, r, log, http.StatusBadRequest, "unknown query parameters: "+strings.Join(unknown, ", "))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
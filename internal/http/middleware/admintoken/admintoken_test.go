@@ -0,0 +1,41 @@
+package admintoken
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "valid token", header: "Bearer s3cret", want: http.StatusNoContent},
+		{name: "missing header", want: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer s3cre", want: http.StatusUnauthorized},
+		{name: "token with a suffix", header: "Bearer s3crets", want: http.StatusUnauthorized},
+		{name: "other scheme", header: "Basic s3cret", want: http.StatusUnauthorized},
+		{name: "bare token", header: "s3cret", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New("s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/admin/dedupe", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package fieldcase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// New rewrites the keys of JSON request bodies that match one of fields
+// except for case and underscores, so serviceName and ServiceName arrive as
+// service_name. Keys in nested objects and arrays are rewritten too; unknown
+// keys and bodies that are not valid JSON are passed on unchanged for the
+// handler to reject. A key sent in the canonical form wins over other
+// spellings of it; an object with two other spellings of the same field and
+// no canonical one is ambiguous and rejected with 400.
+func New(fields []string) func(next http.Handler) http.Handler {
+	canonical := make(map[string]string, len(fields))
+	for _, field := range fields {
+		canonical[fold(field)] = field
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && isJSON(r.Header.Get("Content-Type")) {
+				body, err := io.ReadAll(r.Body)
+				r.Body.Close()
+				if err != nil {
+					render.Status(r, http.StatusBadRequest)
+					render.JSON(w, r, map[string]string{"error": "invalid request body"})
+					return
+				}
+
+				body, err = normalize(body, canonical)
+				if err != nil {
+					render.Status(r, http.StatusBadRequest)
+					render.JSON(w, r, map[string]string{"error": err.Error()})
+					return
+				}
+
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// normalize rewrites the keys of body. Bodies that are not a single JSON
+// value are returned unchanged.
+func normalize(body []byte, canonical map[string]string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return body, nil
+	}
+
+	doc, err := rename(doc, canonical)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return body, nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+func rename(v any, canonical map[string]string) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		// spellings collects, per canonical name, the other keys sent for it.
+		spellings := make(map[string][]string)
+		for key, value := range v {
+			value, err := rename(value, canonical)
+			if err != nil {
+				return nil, err
+			}
+
+			if name, ok := canonical[fold(key)]; ok && name != key {
+				spellings[name] = append(spellings[name], key)
+			}
+			out[key] = value
+		}
+
+		for name, keys := range spellings {
+			// A key already sent in the canonical form wins.
+			if _, taken := v[name]; !taken {
+				if len(keys) > 1 {
+					slices.Sort(keys)
+					return nil, fmt.Errorf("fields %s all mean %s, send only one", strings.Join(keys, ", "), name)
+				}
+				out[name] = out[keys[0]]
+			}
+			for _, key := range keys {
+				delete(out, key)
+			}
+		}
+		return out, nil
+	case []any:
+		for i := range v {
+			item, err := rename(v[i], canonical)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = item
+		}
+		return v, nil
+	}
+
+	return v, nil
+}
+
+// fold reduces a key to lower case without underscores or dashes.
+func fold(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(key))
+}
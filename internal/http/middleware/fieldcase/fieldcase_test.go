@@ -0,0 +1,96 @@
+package fieldcase
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	fields := []string{"service_name", "user_id", "tags"}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantStatus  int
+	}{
+		{name: "camelCase", body: `{"serviceName":"Netflix","userId":"u"}`, want: `{"service_name":"Netflix","user_id":"u"}`},
+		{name: "PascalCase and upper case", body: `{"ServiceName":"Netflix","USER_ID":"u"}`, want: `{"service_name":"Netflix","user_id":"u"}`},
+		{name: "canonical wins", body: `{"serviceName":"a","service_name":"b","ServiceName":"c"}`, want: `{"service_name":"b"}`},
+		{name: "ambiguous spellings", body: `{"serviceName":"a","ServiceName":"b"}`, wantStatus: http.StatusBadRequest},
+		{name: "nested objects and arrays", body: `[{"serviceName":"a","Tags":["x"]},{"meta":{"userId":"u"}}]`, want: `[{"service_name":"a","tags":["x"]},{"meta":{"user_id":"u"}}]`},
+		{name: "ambiguous nested", body: `{"items":[{"userId":"a","UserID":"b"}]}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown keys kept", body: `{"servicePrice":1,"service_name":"a"}`, want: `{"servicePrice":1,"service_name":"a"}`},
+		{name: "large numbers kept exact", body: `{"userId":12345678901234567890}`, want: `{"user_id":12345678901234567890}`},
+		{name: "invalid JSON passed on", body: `{"serviceName":`, want: `{"serviceName":`},
+		{name: "other content types passed on", contentType: "text/csv", body: "serviceName\nNetflix", want: "serviceName\nNetflix"},
+		{name: "json suffix", contentType: "application/merge-patch+json", body: `{"serviceName":"a"}`, want: `{"service_name":"a"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := New(fields)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				got = string(body)
+			}))
+
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if tt.wantStatus != 0 {
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+				}
+				return
+			}
+
+			if !sameJSON(got, tt.want) {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// sameJSON compares JSON documents regardless of key order, and falls back
+// to comparing text for anything that is not JSON.
+func sameJSON(a, b string) bool {
+	var va, vb any
+	da := json.NewDecoder(strings.NewReader(a))
+	da.UseNumber()
+	db := json.NewDecoder(strings.NewReader(b))
+	db.UseNumber()
+	if da.Decode(&va) != nil || db.Decode(&vb) != nil {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestAmbiguousErrorIsDeterministic(t *testing.T) {
+	canonical := map[string]string{fold("service_name"): "service_name"}
+	doc := map[string]any{"serviceName": "a", "ServiceName": "b", "SERVICENAME": "c"}
+
+	for range 20 {
+		_, err := rename(doc, canonical)
+		if err == nil {
+			t.Fatal("rename accepted ambiguous spellings")
+		}
+		if want := "fields SERVICENAME, ServiceName, serviceName all mean service_name, send only one"; err.Error() != want {
+			t.Fatalf("err = %q, want %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Store remembers the responses of POST requests carrying an
+// Idempotency-Key for window, so a client retrying after a lost response
+// gets the original one instead of creating a second record. Keys are kept
+// in memory, per instance.
+type Store struct {
+	window time.Duration
+	// maxBytes caps both the request body read for hashing and the response
+	// body kept for replay. Zero means no cap.
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	hash    [sha256.Size]byte
+	expires time.Time
+	// done is false while the first request is still being handled.
+	done   bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+func New(window time.Duration, maxBytes int64) *Store {
+	return &Store{window: window, maxBytes: maxBytes, entries: make(map[string]*entry)}
+}
+
+// Cleanup drops expired keys every interval until ctx is done.
+func (s *Store) Cleanup(ctx context.Context, log *slog.Logger, interval time.Duration) {
+	const op = "idempotency.Cleanup"
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if removed := s.removeExpired(now); removed > 0 {
+				log.Debug("Expired idempotency keys removed", slog.String("op", op), slog.Int("count", removed))
+			}
+		}
+	}
+}
+
+func (s *Store) removeExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, e := range s.entries {
+		if e.done && now.After(e.expires) {
+			delete(s.entries, key)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// Middleware replays the stored response of a repeated POST with the same
+// key and payload. Reusing a key with a different payload, or while the
+// first request is still running, is answered with 409, and a body over the
+// size cap with 413. Responses with a 5xx status or a body over the cap are
+// not stored, so the request can be retried.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.maxBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, s.maxBytes)
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, map[string]string{"error": "request body too large"})
+				return
+			}
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "invalid request body"})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The same key on another endpoint is another request.
+		key = r.URL.Path + " " + key
+		hash := sha256.Sum256(body)
+
+		s.mu.Lock()
+		e, ok := s.entries[key]
+		if ok && e.done && time.Now().After(e.expires) {
+			ok = false
+		}
+		switch {
+		case ok && e.hash != hash:
+			s.mu.Unlock()
+			conflict(w, r, "idempotency key was already used with a different payload")
+			return
+		case ok && !e.done:
+			s.mu.Unlock()
+			conflict(w, r, "a request with this idempotency key is still in progress")
+			return
+		case ok:
+			s.mu.Unlock()
+			replay(w, e)
+			return
+		}
+		s.entries[key] = &entry{hash: hash}
+		s.mu.Unlock()
+
+		buf := &limitedBuffer{max: s.maxBytes}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(buf)
+
+		defer func() {
+			if p := recover(); p != nil {
+				s.forget(key)
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if status >= http.StatusInternalServerError || buf.overflow {
+			s.forget(key)
+			return
+		}
+
+		s.mu.Lock()
+		s.entries[key] = &entry{
+			hash:    hash,
+			expires: time.Now().Add(s.window),
+			done:    true,
+			status:  status,
+			header:  w.Header().Clone(),
+			body:    buf.buf.Bytes(),
+		}
+		s.mu.Unlock()
+	}
+	return http.HandlerFunc(fn)
+}
+
+// limitedBuffer keeps what is written to it up to max bytes and records
+// whether anything had to be dropped. Writes never fail, so the response
+// itself is not cut short.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int64
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return len(p), nil
+	}
+
+	if b.max > 0 && int64(b.buf.Len()+len(p)) > b.max {
+		b.overflow = true
+		b.buf.Reset()
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+func (s *Store) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+}
+
+func replay(w http.ResponseWriter, e *entry) {
+	for name, values := range e.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(e.status)
+	_, _ = w.Write(e.body)
+}
+
+func conflict(w http.ResponseWriter, r *http.Request, msg string) {
+	render.Status(r, http.StatusConflict)
+	render.JSON(w, r, map[string]string{"error": msg})
+}
@@ -0,0 +1,144 @@
+package idempotency
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// counting answers every request with its body and counts the calls.
+func counting(calls *int, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	})
+}
+
+func post(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/subscriptions", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBytes   int64
+		status     int
+		requests   []string // key and body, separated by a colon
+		wantCalls  int
+		wantStatus []int
+		replayed   []bool
+	}{
+		{
+			name:       "replays a repeated request",
+			status:     http.StatusCreated,
+			requests:   []string{"k:a", "k:a"},
+			wantCalls:  1,
+			wantStatus: []int{http.StatusCreated, http.StatusCreated},
+			replayed:   []bool{false, true},
+		},
+		{
+			name:       "rejects a key reused with another body",
+			status:     http.StatusCreated,
+			requests:   []string{"k:a", "k:b"},
+			wantCalls:  1,
+			wantStatus: []int{http.StatusCreated, http.StatusConflict},
+			replayed:   []bool{false, false},
+		},
+		{
+			name:       "passes requests without a key",
+			status:     http.StatusCreated,
+			requests:   []string{":a", ":a"},
+			wantCalls:  2,
+			wantStatus: []int{http.StatusCreated, http.StatusCreated},
+			replayed:   []bool{false, false},
+		},
+		{
+			name:       "does not keep server errors",
+			status:     http.StatusInternalServerError,
+			requests:   []string{"k:a", "k:a"},
+			wantCalls:  2,
+			wantStatus: []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			replayed:   []bool{false, false},
+		},
+		{
+			name:       "refuses a body over the cap",
+			maxBytes:   4,
+			status:     http.StatusCreated,
+			requests:   []string{"k:abcde"},
+			wantCalls:  0,
+			wantStatus: []int{http.StatusRequestEntityTooLarge},
+			replayed:   []bool{false},
+		},
+		{
+			name:       "keeps a body at the cap",
+			maxBytes:   4,
+			status:     http.StatusCreated,
+			requests:   []string{"k:abcd", "k:abcd"},
+			wantCalls:  1,
+			wantStatus: []int{http.StatusCreated, http.StatusCreated},
+			replayed:   []bool{false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			h := New(time.Hour, tt.maxBytes).Middleware(counting(&calls, tt.status))
+
+			for i, req := range tt.requests {
+				key, body, _ := strings.Cut(req, ":")
+				w := post(h, key, body)
+
+				if w.Code != tt.wantStatus[i] {
+					t.Errorf("request %d: status = %d, want %d", i, w.Code, tt.wantStatus[i])
+				}
+				if got := w.Header().Get(ReplayedHeader) == "true"; got != tt.replayed[i] {
+					t.Errorf("request %d: replayed = %v, want %v", i, got, tt.replayed[i])
+				}
+				if tt.replayed[i] && w.Body.String() != body {
+					t.Errorf("request %d: replayed body = %q, want %q", i, w.Body.String(), body)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMiddlewareSkipsOversizedResponse(t *testing.T) {
+	calls := 0
+	h := New(time.Hour, 8).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(strings.Repeat("x", 16)))
+	}))
+
+	for i := range 2 {
+		w := post(h, "k", "a")
+		if w.Code != http.StatusCreated || w.Body.Len() != 16 {
+			t.Errorf("request %d: status %d with %d bytes, want 201 with the whole body", i, w.Code, w.Body.Len())
+		}
+		if w.Header().Get(ReplayedHeader) != "" {
+			t.Errorf("request %d was replayed", i)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("handler calls = %d, want 2", calls)
+	}
+}
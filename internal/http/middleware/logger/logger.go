@@ -1,18 +1,104 @@
 package logger
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func New(log *slog.Logger) func(next http.Handler) http.Handler {
+type ctxKey struct{}
+
+// values collects the context values of one request that were set with Set
+// under one of the configured keys.
+type values struct {
+	mu     sync.Mutex
+	keys   []string
+	wanted map[string]bool
+	set    map[string]string
+}
+
+// attrs returns the values set so far, in the order the keys were
+// configured.
+func (v *values) attrs() []slog.Attr {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	attrs := make([]slog.Attr, 0, len(v.set))
+	for _, key := range v.keys {
+		if value, ok := v.set[key]; ok {
+			attrs = append(attrs, slog.String(key, value))
+		}
+	}
+
+	return attrs
+}
+
+// Set records value under key for the log lines of the request, when key is
+// one of the keys the middleware was configured with. Downstream middlewares
+// call it for what they resolve, e.g. the subscription id. Outside the
+// middleware or for keys not configured it does nothing.
+func Set(ctx context.Context, key, value string) {
+	v, ok := ctx.Value(ctxKey{}).(*values)
+	if !ok || !v.wanted[key] {
+		return
+	}
+	v.mu.Lock()
+	v.set[key] = value
+	v.mu.Unlock()
+}
+
+// FromContext returns log for lines about the request in ctx: every line it
+// writes carries the context values set with Set by the time it is written.
+// Outside the middleware, or with no keys configured, it returns log.
+func FromContext(ctx context.Context, log *slog.Logger) *slog.Logger {
+	v, ok := ctx.Value(ctxKey{}).(*values)
+	if !ok {
+		return log
+	}
+
+	return slog.New(contextHandler{Handler: log.Handler(), values: v})
+}
+
+// contextHandler appends the request's context values to every record.
+type contextHandler struct {
+	slog.Handler
+	values *values
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := h.values.attrs(); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs), values: h.values}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name), values: h.values}
+}
+
+// New logs every request. contextKeys lists the keys recorded with Set that
+// are appended to the completion line and to every line written through
+// FromContext; a key never set is omitted.
+func New(log *slog.Logger, contextKeys []string) func(next http.Handler) http.Handler {
+	wanted := make(map[string]bool, len(contextKeys))
+	for _, key := range contextKeys {
+		wanted[key] = true
+	}
+
 	return func(next http.Handler) http.Handler {
 		log = log.With(slog.String("component", "middleware/logger"))
 
-		log.Info("Logger middleware initialized")
+		log.Info("Logger middleware initialized", slog.Any("context_keys", contextKeys))
 
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			entry := log.With(
@@ -24,13 +110,25 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 			)
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
+			var vals *values
+			if len(wanted) > 0 {
+				vals = &values{keys: contextKeys, wanted: wanted, set: make(map[string]string)}
+				r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, vals))
+			}
+
 			t1 := time.Now()
 			defer func() {
-				entry.Info("request completed",
+				attrs := []any{
 					slog.Int("status", ww.Status()),
 					slog.Int("bites", ww.BytesWritten()),
 					slog.String("duration", time.Since(t1).String()),
-				)
+				}
+				if vals != nil {
+					for _, attr := range vals.attrs() {
+						attrs = append(attrs, attr)
+					}
+				}
+				entry.Info("request completed", attrs...)
 			}()
 
 			next.ServeHTTP(ww, r)
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func lines(t *testing.T, buf *bytes.Buffer) map[string]map[string]any {
+	t.Helper()
+
+	byMsg := make(map[string]map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decode log line %q: %v", line, err)
+		}
+		byMsg[entry["msg"].(string)] = entry
+	}
+
+	return byMsg
+}
+
+func TestContextKeysOnEveryLine(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := New(log, []string{"sub_id", "tenant"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqLog := FromContext(r.Context(), log).With(slog.String("op", "test"))
+		reqLog.Info("before set")
+
+		Set(r.Context(), "sub_id", "42")
+		Set(r.Context(), "ignored", "x")
+		reqLog.Info("after set")
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	got := lines(t, &buf)
+
+	if _, ok := got["before set"]["sub_id"]; ok {
+		t.Errorf("line before Set has sub_id: %v", got["before set"])
+	}
+	for _, msg := range []string{"after set", "request completed"} {
+		entry := got[msg]
+		if entry["sub_id"] != "42" {
+			t.Errorf("%s: sub_id = %v, want 42", msg, entry["sub_id"])
+		}
+		if _, ok := entry["tenant"]; ok {
+			t.Errorf("%s: has tenant, which was never set", msg)
+		}
+		if _, ok := entry["ignored"]; ok {
+			t.Errorf("%s: has a key that is not configured", msg)
+		}
+	}
+	if got["after set"]["op"] != "test" {
+		t.Errorf("after set: op = %v, want the logger's own attributes kept", got["after set"]["op"])
+	}
+}
+
+func TestFromContextOutsideMiddleware(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+
+	if got := FromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context(), log); got != log {
+		t.Error("FromContext outside the middleware did not return log")
+	}
+}
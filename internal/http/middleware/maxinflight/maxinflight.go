@@ -0,0 +1,53 @@
+package maxinflight
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// New caps the number of requests executing at once at limit. A request
+// arriving when all slots are taken waits up to wait for one to free up and
+// is then answered with 503, so a spike queues briefly instead of piling
+// onto the database pool.
+func New(limit int, wait time.Duration) func(next http.Handler) http.Handler {
+	slots := make(chan struct{}, limit)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				if !acquire(r, slots, wait) {
+					w.Header().Set("Retry-After", "1")
+					render.Status(r, http.StatusServiceUnavailable)
+					render.JSON(w, r, map[string]string{"error": "server is busy"})
+					return
+				}
+			}
+			defer func() { <-slots }()
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func acquire(r *http.Request, slots chan struct{}, wait time.Duration) bool {
+	if wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package maxinflight
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingHandler holds every request until release is closed and signals
+// on started once a request occupies a slot.
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func serve(h http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return w
+}
+
+func TestRejectsWhenFullWithoutWait(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	h := New(1, 0)(blockingHandler(started, release))
+
+	done := make(chan int)
+	go func() { done <- serve(h).Code }()
+	<-started
+
+	w := serve(h)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want %q", got, "1")
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusNoContent {
+		t.Errorf("first request status = %d, want %d", code, http.StatusNoContent)
+	}
+
+	// The slot is free again once the first request finishes.
+	if code := serve(h).Code; code != http.StatusNoContent {
+		t.Errorf("status after release = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestWaitsForAFreeSlot(t *testing.T) {
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	h := New(1, 5*time.Second)(blockingHandler(started, release))
+
+	first := make(chan int)
+	go func() { first <- serve(h).Code }()
+	<-started
+
+	second := make(chan int)
+	go func() { second <- serve(h).Code }()
+
+	close(release)
+	if code := <-first; code != http.StatusNoContent {
+		t.Errorf("first request status = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := <-second; code != http.StatusNoContent {
+		t.Errorf("queued request status = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestGivesUpAfterWait(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	h := New(1, 20*time.Millisecond)(blockingHandler(started, release))
+
+	done := make(chan int)
+	go func() { done <- serve(h).Code }()
+	<-started
+
+	if code := serve(h).Code; code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	close(release)
+	<-done
+}
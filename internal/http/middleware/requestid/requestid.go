@@ -0,0 +1,53 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// MaxLength is the longest incoming request id that is reused.
+const MaxLength = 128
+
+// New reuses the request id the caller sent in header, generating one when it
+// is absent or not a valid id, and echoes it back in the same response
+// header. The id is stored under chi's key, so middleware.GetReqID keeps
+// working.
+func New(header string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !valid(id) {
+				id = uuid.NewString()
+			}
+
+			w.Header().Set(header, id)
+
+			ctx := context.WithValue(r.Context(), middleware.RequestIDKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// valid accepts ids of up to MaxLength letters, digits and -_.: characters,
+// which covers UUIDs and the usual tracing ids while keeping the value safe
+// to log and echo.
+func valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+
+	for _, c := range []byte(id) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,59 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+func TestNew(t *testing.T) {
+	const header = "X-Correlation-Id"
+
+	tests := []struct {
+		name   string
+		id     string
+		reused bool
+	}{
+		{name: "uuid", id: "550e8400-e29b-41d4-a716-446655440000", reused: true},
+		{name: "tracing id", id: "00-4bf92f3577b34da6a3ce929d0e0e4736:b7ad6b7169203331.01_x", reused: true},
+		{name: "at the length limit", id: strings.Repeat("a", MaxLength), reused: true},
+		{name: "absent"},
+		{name: "too long", id: strings.Repeat("a", MaxLength+1)},
+		{name: "space", id: "abc def"},
+		{name: "log injection", id: "abc\ninjected=1"},
+		{name: "quote", id: `abc"`},
+		{name: "non-ASCII", id: "идентификатор"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := New(header)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = middleware.GetReqID(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.id != "" {
+				r.Header[header] = []string{tt.id}
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if tt.reused {
+				if got != tt.id {
+					t.Errorf("request id = %q, want %q", got, tt.id)
+				}
+			} else if _, err := uuid.Parse(got); err != nil {
+				t.Errorf("request id = %q, want a generated uuid", got)
+			}
+
+			if echoed := w.Header().Get(header); echoed != got {
+				t.Errorf("echoed %q, want %q", echoed, got)
+			}
+		})
+	}
+}
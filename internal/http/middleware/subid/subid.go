@@ -0,0 +1,40 @@
+// Package subid parses the {id} URL parameter of subscription routes once,
+// so handlers read a validated id instead of parsing it themselves.
+package subid
+
+import (
+	"context"
+	"net/http"
+	"testovoe/internal/http/middleware/logger"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type ctxKey struct{}
+
+// Middleware answers 400 when {id} is not a UUID and otherwise stores the
+// parsed id for FromContext. The id is also offered to the request log
+// under the "sub_id" context key.
+func Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "invalid subscription id"})
+			return
+		}
+
+		logger.Set(r.Context(), "sub_id", id.String())
+		ctx := context.WithValue(r.Context(), ctxKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// FromContext returns the id stored by Middleware, or uuid.Nil outside it.
+func FromContext(ctx context.Context) uuid.UUID {
+	id, _ := ctx.Value(ctxKey{}).(uuid.UUID)
+	return id
+}
@@ -0,0 +1,63 @@
+package subid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestMiddleware(t *testing.T) {
+	id := uuid.New()
+
+	tests := []struct {
+		name    string
+		path    string
+		status  int
+		reached bool
+	}{
+		{name: "valid", path: "/" + id.String(), status: http.StatusNoContent, reached: true},
+		{name: "uppercase", path: "/" + strings.ToUpper(id.String()), status: http.StatusNoContent, reached: true},
+		{name: "not a uuid", path: "/export", status: http.StatusBadRequest},
+		{name: "truncated", path: "/" + id.String()[:35], status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got uuid.UUID
+			reached := false
+
+			r := chi.NewRouter()
+			r.With(Middleware).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+				got = FromContext(r.Context())
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d; body %s", w.Code, tt.status, w.Body.String())
+			}
+			if reached != tt.reached {
+				t.Errorf("handler reached = %v, want %v", reached, tt.reached)
+			}
+			if tt.reached && got != id {
+				t.Errorf("FromContext = %s, want %s", got, id)
+			}
+			if !tt.reached && !strings.Contains(w.Body.String(), "invalid subscription id") {
+				t.Errorf("body = %s, want the invalid id error", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFromContextOutsideMiddleware(t *testing.T) {
+	if got := FromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); got != uuid.Nil {
+		t.Errorf("FromContext = %s, want uuid.Nil", got)
+	}
+}
@@ -1,36 +1,153 @@
 package router
 
 import (
+	"expvar"
 	"log/slog"
+	"net/http"
+	"strings"
+	"testovoe/internal/config"
 	"testovoe/internal/http/handlers"
+	"testovoe/internal/http/middleware/admintoken"
+	"testovoe/internal/http/middleware/fieldcase"
+	"testovoe/internal/http/middleware/idempotency"
 	"testovoe/internal/http/middleware/logger"
+	"testovoe/internal/http/middleware/maxinflight"
+	"testovoe/internal/http/middleware/requestid"
+	"testovoe/internal/http/middleware/secureheaders"
+	"testovoe/internal/http/middleware/subid"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func Router(router *chi.Mux, h *handlers.HttpHandler, log *slog.Logger) {
-	router.Use(middleware.RequestID)
+// Router mounts the routes on router. idem is nil when idempotency keys are
+// disabled.
+func Router(router *chi.Mux, h *handlers.HttpHandler, idem *idempotency.Store, log *slog.Logger, cfg *config.Config) {
+	router.Use(secureheaders.New(cfg.HttpServer.TLS.Enabled()))
+	router.Use(requestid.New(cfg.HttpServer.RequestIDHeader))
 	router.Use(middleware.RealIP)
-	router.Use(logger.New(log))
+	router.Use(logger.New(log, cfg.HttpServer.LogContextKeys))
 	router.Use(middleware.Recoverer)
+	if cfg.HttpServer.MaxInFlight > 0 {
+		router.Use(maxinflight.New(cfg.HttpServer.MaxInFlight, cfg.HttpServer.QueueTimeout))
+	}
+	if cfg.HttpServer.Timeout > 0 {
+		// Streams run as long as rows keep coming; their handlers extend the
+		// write deadline per row instead.
+		router.Use(except(streaming, middleware.Timeout(cfg.HttpServer.Timeout)))
+	}
+	if cfg.HttpServer.LenientFieldNames {
+		router.Use(fieldcase.New(handlers.BodyFields))
+	}
+	if idem != nil {
+		router.Use(idem.Middleware)
+	}
 
 	router.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("doc.json"),
 	))
 
+	router.Get("/", h.Root)
+	router.Get("/version", h.Version)
+	router.Get("/readyz", h.Readyz)
+
+	known := h.KnownParams
+
+	if cfg.Admin.Token != "" {
+		router.Route("/admin", func(r chi.Router) {
+			r.Use(admintoken.New(cfg.Admin.Token))
+			r.With(known()).Post("/deduplicate", h.DeduplicateSubs)
+			r.With(known()).Post("/rename-service", h.RenameService)
+			// expvar's variables, e.g. the storage_db_up gauge.
+			r.With(known()).Get("/vars", expvar.Handler().ServeHTTP)
+		})
+	}
+
 	router.Route("/api/v1", func(r chi.Router) {
 		r.Route("/subscriptions", func(r chi.Router) {
-			r.Post("/", h.CreateSub)
-			r.Get("/", h.ListSubs)
-			r.Get("/total", h.GetTotalCost)
+			r.With(known()).Post("/", h.CreateSub)
+			r.With(known([]string{"atomic"})).Post("/batch", h.CreateSubs)
+			r.With(known()).Post("/validate", h.ValidateSub)
+			r.With(known(handlers.FilterParams, handlers.PageParams, []string{"include_shared", "created_from", "created_to", "fields"})).Get("/", h.ListSubs)
+			r.With(known(handlers.FilterParams)).Delete("/", h.DeleteSubs)
+			r.With(known()).Patch("/price", h.UpdatePrices)
+			r.With(known([]string{"user_id", "within_days", "months"})).Post("/renew-expiring", h.RenewExpiring)
+			if cfg.Features.Enabled(config.FeatureExport) {
+				r.With(known(handlers.FilterParams)).Get("/export", h.ExportSubs)
+			} else {
+				// Otherwise /{id} would answer 400 for "export".
+				r.Get("/export", http.NotFound)
+			}
+			r.With(known(handlers.CostParams, []string{"user_id", "service_name", "from", "to", "require_match"})).Get("/total", h.GetTotalCost)
+			r.With(known()).Post("/totals", h.GetTotalCosts)
+			r.With(known(handlers.CostParams, []string{"user_id", "service_name", "period_a", "period_b"})).Get("/compare", h.ComparePeriods)
+			r.With(known([]string{"user_id", "months"})).Get("/forecast", h.Forecast)
+			r.With(known([]string{"user_id", "limit"})).Get("/top", h.TopSubs)
+			r.With(known([]string{"user_id"})).Get("/facets", h.Facets)
+			r.With(known([]string{"user_id"})).Get("/stats", h.Stats)
+			r.With(known([]string{"user_id", "from", "to"})).Get("/ended", h.EndedSubs)
+			r.With(known([]string{"user_id"})).Get("/currencies", h.Currencies)
+			r.With(known([]string{"user_id"})).Get("/timeline", h.Timeline)
+			r.With(known([]string{"service_name", "currency"})).Get("/service-price-stats", h.ServicePriceStats)
+			r.With(known([]string{"user_id", "service_name", "latest"})).Get("/by-service", h.SubByService)
 
 			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", h.GetUserSub)
-				r.Put("/", h.UpdateSub)
-				r.Delete("/", h.DeleteSub)
+				r.Use(subid.Middleware)
+				r.With(known([]string{"with_neighbors", "fields"})).Get("/", h.GetUserSub)
+				r.With(known()).Put("/", h.UpdateSub)
+				r.With(known()).Patch("/", h.PatchSub)
+				r.With(known([]string{"user_id"})).Delete("/", h.DeleteSub)
+				r.With(known([]string{"reset_started_at"})).Post("/reactivate", h.ReactivateSub)
+				r.With(known([]string{"months"})).Get("/savings", h.Savings)
+				r.With(known()).Get("/price-history", h.PriceHistory)
+				r.With(known()).Post("/tags", h.AddTags)
+				r.With(known()).Delete("/tags/{tag}", h.RemoveTag)
+				r.With(known()).Post("/shares", h.ShareSub)
+				r.With(known()).Delete("/shares/{user_id}", h.UnshareSub)
 			})
 		})
+
+		r.Route("/users/{user_id}/reminder-preferences", func(r chi.Router) {
+			r.With(known()).Get("/", h.GetReminderPreference)
+			r.With(known()).Put("/", h.SetReminderPreference)
+		})
+
+		r.Route("/reports", func(r chi.Router) {
+			r.With(known(handlers.FilterParams)).Get("/subscriptions-by-user", h.SubsByUser)
+			r.With(known([]string{"from", "to", "weighted"})).Get("/active-count", h.ActiveCounts)
+			if cfg.Features.Enabled(config.FeatureMRRReport) {
+				r.With(known([]string{"month"})).Get("/mrr", h.MRR)
+			}
+		})
 	})
 }
+
+// streaming reports whether r is answered with a body streamed row by row:
+// the CSV export and the NDJSON list.
+func streaming(r *http.Request) bool {
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/api/v1/subscriptions/export":
+		return true
+	case "/api/v1/subscriptions":
+		return r.Method == http.MethodGet && handlers.AcceptsNDJSON(r)
+	}
+
+	return false
+}
+
+// except applies mw to every request for which skip reports false.
+func except(skip func(*http.Request) bool, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,107 @@
+package router
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/config"
+	"testovoe/internal/events"
+	"testovoe/internal/http/handlers"
+	"testovoe/internal/storage/inmemory"
+	"testovoe/internal/usecase"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+func TestStreaming(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		accept string
+		want   bool
+	}{
+		{name: "export", method: http.MethodGet, path: "/api/v1/subscriptions/export", want: true},
+		{name: "NDJSON list", method: http.MethodGet, path: "/api/v1/subscriptions", accept: "application/x-ndjson", want: true},
+		{name: "NDJSON list with slash", method: http.MethodGet, path: "/api/v1/subscriptions/", accept: "application/json, application/x-ndjson", want: true},
+		{name: "JSON list", method: http.MethodGet, path: "/api/v1/subscriptions", accept: "application/json"},
+		{name: "create", method: http.MethodPost, path: "/api/v1/subscriptions", accept: "application/x-ndjson"},
+		{name: "other endpoint", method: http.MethodGet, path: "/api/v1/subscriptions/total", accept: "application/x-ndjson"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			if got := streaming(r); got != tt.want {
+				t.Errorf("streaming() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExceptSkipsTimeout(t *testing.T) {
+	var deadlines []bool
+	h := except(
+		func(r *http.Request) bool { return r.URL.Path == "/stream" },
+		middleware.Timeout(time.Minute),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		deadlines = append(deadlines, ok)
+	}))
+
+	for _, path := range []string{"/stream", "/other"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil).WithContext(context.Background())
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if len(deadlines) != 2 || deadlines[0] || !deadlines[1] {
+		t.Errorf("deadlines set = %v, want [false true]", deadlines)
+	}
+}
+
+func TestFeatureGatedRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		feature string
+		target  string
+	}{
+		{name: "export", feature: config.FeatureExport, target: "/api/v1/subscriptions/export?user_id=550e8400-e29b-41d4-a716-446655441111"},
+		{name: "mrr report", feature: config.FeatureMRRReport, target: "/api/v1/reports/mrr?month=01-2025"},
+	}
+
+	for _, tt := range tests {
+		for _, enabled := range []bool{false, true} {
+			var cfg config.Config
+			if err := cleanenv.ReadConfig("../../../config.yaml", &cfg); err != nil {
+				t.Fatalf("read config: %v", err)
+			}
+			cfg.Features = config.Features{tt.feature: enabled}
+
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			mux := chi.NewRouter()
+			h := handlers.New(log, usecase.New(log, inmemory.New(), &cfg, events.Noop{}), &cfg)
+			Router(mux, h, nil, log, &cfg)
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			want := http.StatusNotFound
+			if enabled {
+				want = http.StatusOK
+			}
+			if w.Code != want {
+				t.Errorf("%s enabled=%v: status = %d, want %d; body %s", tt.name, enabled, w.Code, want, w.Body.String())
+			}
+		}
+	}
+}
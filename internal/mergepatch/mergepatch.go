@@ -0,0 +1,54 @@
+// Package mergepatch applies JSON merge patches as defined by RFC 7396.
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const ContentType = "application/merge-patch+json"
+
+var ErrNotObject = errors.New("merge patch must be a JSON object")
+
+// Apply merges patch into doc and returns the resulting document. A null in
+// the patch removes the key, objects merge recursively, anything else
+// replaces the target value.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var patchVal interface{}
+	if err := json.Unmarshal(patch, &patchVal); err != nil {
+		return nil, err
+	}
+
+	if _, ok := patchVal.(map[string]interface{}); !ok {
+		return nil, ErrNotObject
+	}
+
+	var docVal interface{}
+	if err := json.Unmarshal(doc, &docVal); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(docVal, patchVal))
+}
+
+func merge(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = merge(targetObj[key], value)
+	}
+
+	return targetObj
+}
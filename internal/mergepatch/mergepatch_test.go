@@ -0,0 +1,58 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	// Cases from the examples of RFC 7396, appendix A.
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{doc: `{"e":null}`, patch: `{"a":1}`, want: `{"a":1,"e":null}`},
+		{doc: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Fatalf("Apply(%s, %s): %v", tt.doc, tt.patch, err)
+		}
+
+		var gotVal, wantVal interface{}
+		if err := json.Unmarshal(got, &gotVal); err != nil {
+			t.Fatalf("Apply(%s, %s) returned invalid JSON %s: %v", tt.doc, tt.patch, got, err)
+		}
+		if err := json.Unmarshal([]byte(tt.want), &wantVal); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(gotVal, wantVal) {
+			t.Errorf("Apply(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRejectsNonObjectPatch(t *testing.T) {
+	for _, patch := range []string{`["a"]`, `"a"`, `null`, `1`} {
+		if _, err := Apply([]byte(`{"a":"b"}`), []byte(patch)); !errors.Is(err, ErrNotObject) {
+			t.Errorf("Apply with patch %s: err = %v, want ErrNotObject", patch, err)
+		}
+	}
+
+	if _, err := Apply([]byte(`{"a":"b"}`), []byte(`{`)); err == nil || errors.Is(err, ErrNotObject) {
+		t.Errorf("Apply with malformed patch: err = %v, want a syntax error", err)
+	}
+}
@@ -0,0 +1,52 @@
+package reminders
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Sender sends the reminders falling due in (from, to] and reports how many
+// were sent.
+type Sender interface {
+	SendReminders(ctx context.Context, from, to time.Time) (int, error)
+}
+
+// Run sends the reminders falling due in each interval until ctx is done.
+// Every tick covers the time since the last successful one, so a reminder is
+// sent once per running instance; after a failure the same window is tried
+// again on the next tick.
+func Run(ctx context.Context, log *slog.Logger, sender Sender, interval time.Duration) {
+	const op = "reminders.Run"
+
+	log = log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now().UTC()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			last = tick(ctx, log, sender, last, now.UTC())
+		}
+	}
+}
+
+// tick sends the reminders due after from and up to now and returns where
+// the next window starts.
+func tick(ctx context.Context, log *slog.Logger, sender Sender, from, now time.Time) time.Time {
+	sent, err := sender.SendReminders(ctx, from, now)
+	if err != nil {
+		log.Error("Failed to send reminders", "error", err)
+		return from
+	}
+
+	if sent > 0 {
+		log.Info("Reminders sent", slog.Int("count", sent))
+	}
+
+	return now
+}
@@ -0,0 +1,44 @@
+package reminders
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type windowSender struct {
+	err     error
+	windows [][2]time.Time
+}
+
+func (s *windowSender) SendReminders(_ context.Context, from, to time.Time) (int, error) {
+	s.windows = append(s.windows, [2]time.Time{from, to})
+	return 1, s.err
+}
+
+func TestTickRetriesTheWindowAfterAFailure(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sender := &windowSender{err: errors.New("publisher down")}
+
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	first, second := start.Add(time.Hour), start.Add(2*time.Hour)
+
+	next := tick(context.Background(), log, sender, start, first)
+	if !next.Equal(start) {
+		t.Fatalf("after a failure next window starts at %s, want %s", next, start)
+	}
+
+	sender.err = nil
+	next = tick(context.Background(), log, sender, next, second)
+	if !next.Equal(second) {
+		t.Fatalf("after a success next window starts at %s, want %s", next, second)
+	}
+
+	want := [][2]time.Time{{start, first}, {start, second}}
+	if len(sender.windows) != len(want) || sender.windows[0] != want[0] || sender.windows[1] != want[1] {
+		t.Errorf("windows = %v, want %v", sender.windows, want)
+	}
+}
@@ -0,0 +1,54 @@
+package sandbox
+
+import (
+	"context"
+	"log/slog"
+	"testovoe/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Resetter interface {
+	ReplaceUserSubs(ctx context.Context, userID uuid.UUID, userSubs []domain.UserSub) (int64, error)
+}
+
+// Run resets the sandbox user's subscriptions to the demo set right away and
+// then every interval until ctx is done, so demo data does not pile up.
+func Run(ctx context.Context, log *slog.Logger, resetter Resetter, userID uuid.UUID, interval time.Duration) {
+	const op = "sandbox.Run"
+
+	log = log.With(slog.String("op", op), slog.String("user_id", userID.String()))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := resetter.ReplaceUserSubs(ctx, userID, Seed(userID, time.Now()))
+		if err != nil {
+			log.Error("Failed to reset sandbox user", "error", err)
+		} else {
+			log.Info("Sandbox user reset", slog.Int64("created", created))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Seed is the demo data the sandbox user starts from, dated relative to now.
+func Seed(userID uuid.UUID, now time.Time) []domain.UserSub {
+	month := domain.MonthStart(now.UTC())
+	ended := month.AddDate(0, -1, 0)
+	card := "visa-4242"
+
+	return []domain.UserSub{
+		{ServiceName: "Netflix", ServicePrice: 99900, UserID: userID, StartedAt: month.AddDate(0, -6, 0), BillingPeriod: domain.BillingMonthly, PaymentMethod: &card},
+		{ServiceName: "Spotify", ServicePrice: 29900, UserID: userID, StartedAt: month.AddDate(0, -14, 0), BillingPeriod: domain.BillingMonthly, AutoRenew: true},
+		{ServiceName: "iCloud", ServicePrice: 149000, UserID: userID, StartedAt: month.AddDate(-1, 0, 0), BillingPeriod: domain.BillingYearly, PaymentMethod: &card},
+		{ServiceName: "Yandex Plus", ServicePrice: 39900, UserID: userID, StartedAt: month.AddDate(0, -4, 0), EndedAt: &ended, BillingPeriod: domain.BillingMonthly},
+	}
+}
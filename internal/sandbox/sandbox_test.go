@@ -0,0 +1,67 @@
+package sandbox
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"testovoe/internal/config"
+	"testovoe/internal/domain"
+	"testovoe/internal/events"
+	"testovoe/internal/storage/inmemory"
+	"testovoe/internal/usecase"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRunResetsOnlyTheSandboxUser(t *testing.T) {
+	ctx := context.Background()
+	db := inmemory.New()
+	sandboxID, otherID := uuid.New(), uuid.New()
+
+	for _, sub := range []domain.UserSub{
+		{ID: uuid.New(), ServiceName: "Leftover", ServicePrice: 1, UserID: sandboxID, StartedAt: time.Now().UTC(), BillingPeriod: domain.BillingMonthly},
+		{ID: uuid.New(), ServiceName: "Real", ServicePrice: 1, UserID: otherID, StartedAt: time.Now().UTC(), BillingPeriod: domain.BillingMonthly},
+	} {
+		if _, err := db.CreateSub(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{}
+	cfg.Money.DefaultCurrency = "RUB"
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// A cancelled context still gets the initial reset before Run returns.
+	runCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	Run(runCtx, log, usecase.New(log, db, cfg, events.Noop{}), sandboxID, time.Hour)
+
+	names := func(userID uuid.UUID) []string {
+		subs, err := db.GetUserSubs(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]string, 0, len(subs))
+		for _, sub := range subs {
+			out = append(out, sub.ServiceName)
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	want := make([]string, 0)
+	for _, sub := range Seed(sandboxID, time.Now()) {
+		want = append(want, sub.ServiceName)
+	}
+	slices.Sort(want)
+
+	if got := names(sandboxID); !slices.Equal(got, want) {
+		t.Errorf("sandbox subscriptions = %v, want the seed %v", got, want)
+	}
+	if got := names(otherID); !slices.Equal(got, []string{"Real"}) {
+		t.Errorf("other user's subscriptions = %v, want them untouched", got)
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Backfill commands run by cmd/migrate next to the goose ones. They change
+// data rather than schema and only run when storage.backfill.enabled is set.
+const (
+	BackfillEndedAtCommand       = "backfill-ended-at"
+	RevertEndedAtBackfillCommand = "revert-ended-at-backfill"
+)
+
+var BackfillCommands = []string{BackfillEndedAtCommand, RevertEndedAtBackfillCommand}
+
+// BackfillEndedAt closes legacy open-ended subscriptions that started more
+// than maxAgeMonths before now, setting ended_at to started_at plus
+// maxAgeMonths.
+// Touched rows are marked so RevertEndedAtBackfill can undo exactly them.
+func (s *Storage) BackfillEndedAt(ctx context.Context, maxAgeMonths int, now time.Time) (int64, error) {
+	const op = "storage.storage.BackfillEndedAt"
+
+	query, args, err := sq.
+		Update("subscriptions").
+		Set("ended_at", sq.Expr("started_at + make_interval(months => ?)", maxAgeMonths)).
+		Set("ended_at_backfilled", true).
+		Where(sq.Eq{"ended_at": nil}).
+		Where(sq.Expr("started_at < ?::timestamp - make_interval(months => ?)", now, maxAgeMonths)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RevertEndedAtBackfill reopens the subscriptions closed by BackfillEndedAt
+// whose ended_at has not been changed since.
+func (s *Storage) RevertEndedAtBackfill(ctx context.Context) (int64, error) {
+	const op = "storage.storage.RevertEndedAtBackfill"
+
+	query, args, err := sq.
+		Update("subscriptions").
+		Set("ended_at", nil).
+		Set("ended_at_backfilled", false).
+		Where(sq.Eq{"ended_at_backfilled": true}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
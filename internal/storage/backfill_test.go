@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBackfillEndedAt(t *testing.T) {
+	url := testDBURL(t)
+	ctx := context.Background()
+
+	if err := Migrate(ctx, url, "up"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	db, err := New(ctx, url, time.Second)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.DB.Exec(ctx, "TRUNCATE subscriptions CASCADE"); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	ended := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	seed := func(start time.Time, end *time.Time) uuid.UUID {
+		sub := domain.UserSub{ID: uuid.New(), ServiceName: "Netflix", ServicePrice: 100, Currency: "RUB", UserID: uuid.New(), StartedAt: start, EndedAt: end, BillingPeriod: domain.BillingMonthly}
+		if _, err := db.CreateSub(ctx, sub); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+		return sub.ID
+	}
+	legacy := seed(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), nil)
+	recent := seed(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), nil)
+	closed := seed(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), &ended)
+
+	endedAt := func(id uuid.UUID) *time.Time {
+		sub, err := db.GetUserSub(ctx, id)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		return sub.EndedAt
+	}
+
+	affected, err := db.BackfillEndedAt(ctx, 12, now)
+	if err != nil || affected != 1 {
+		t.Fatalf("BackfillEndedAt = %d, %v; want 1", affected, err)
+	}
+	if got, want := endedAt(legacy), time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC); got == nil || !got.Equal(want) {
+		t.Errorf("legacy ended_at = %v, want %v", got, want)
+	}
+	if got := endedAt(recent); got != nil {
+		t.Errorf("recent ended_at = %v, want it left open", got)
+	}
+
+	affected, err = db.RevertEndedAtBackfill(ctx)
+	if err != nil || affected != 1 {
+		t.Fatalf("RevertEndedAtBackfill = %d, %v; want 1", affected, err)
+	}
+	if got := endedAt(legacy); got != nil {
+		t.Errorf("legacy ended_at after revert = %v, want null", got)
+	}
+	if got := endedAt(closed); got == nil || !got.Equal(ended) {
+		t.Errorf("closed ended_at after revert = %v, want %v unchanged", got, ended)
+	}
+}
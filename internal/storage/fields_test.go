@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestColumn(t *testing.T) {
+	if got := column("service_price"); got != "sub_price" {
+		t.Errorf("column(service_price) = %q, want sub_price", got)
+	}
+
+	for _, field := range []string{"sub_price", "tags", "days_active", "id; DROP TABLE subscriptions"} {
+		t.Run(field, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("column(%q) did not panic", field)
+				}
+			}()
+
+			column(field)
+		})
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		name string
+		sort domain.Sort
+		want []string
+	}{
+		{name: "sortable", sort: domain.Sort{Column: domain.SortServicePrice}, want: []string{"sub_price ASC", "id ASC"}},
+		{name: "descending", sort: domain.Sort{Column: domain.SortCreatedAt, Desc: true}, want: []string{"created_at DESC", "id DESC"}},
+		{name: "not sortable falls back", sort: domain.Sort{Column: "currency"}, want: []string{"started_at DESC", "id DESC"}},
+		{name: "unknown falls back", sort: domain.Sort{Column: "sub_price; --"}, want: []string{"started_at DESC", "id DESC"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orderBy(tt.sort); !slices.Equal(got, tt.want) {
+				t.Errorf("orderBy(%+v) = %q, want %q", tt.sort, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterWhereUsesAllowlistedColumns(t *testing.T) {
+	userID := uuid.New()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Every filter at once, so each column filterWhere needs is looked up.
+	sql, _, err := filterWhere(domain.SubFilter{
+		UserID:        &userID,
+		IncludeShared: true,
+		ServiceName:   "Netflix",
+		ServiceNames:  []string{"Netflix", "Spotify"},
+		PaymentMethod: "card",
+		Tags:          []string{"work"},
+		TagMode:       domain.TagModeAll,
+		ExcludeFree:   true,
+		CreatedFrom:   &now,
+		CreatedTo:     &now,
+	}).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql: %v", err)
+	}
+
+	for _, want := range []string{"user_id = ?", "service_name = ?", "payment_method = ?", "service_name = ANY(?)", "sub_price > ?", "created_at >= ?", "created_at <= ?"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("where = %s, want %s", sql, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// dbUp is the gauge of database connectivity, published over expvar as
+// storage_db_up once a Storage is opened: 1 while the last health check
+// reached the database, 0 after one failed.
+var (
+	dbUp        = new(expvar.Int)
+	publishDBUp sync.Once
+)
+
+// WatchHealth pings the pool every interval until ctx is done, so a lost
+// database shows up in the logs before a request hits it. Only changes in
+// connectivity are logged; Healthy and the storage_db_up gauge report the
+// latest result.
+func (s *Storage) WatchHealth(ctx context.Context, log *slog.Logger, interval time.Duration) {
+	const op = "storage.storage.WatchHealth"
+
+	log = log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkHealth(ctx, log, interval, s.DB.Ping)
+		}
+	}
+}
+
+func (s *Storage) checkHealth(ctx context.Context, log *slog.Logger, timeout time.Duration, ping func(context.Context) error) {
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := ping(pingCtx)
+	healthy := err == nil
+
+	if !s.markHealthy(healthy) {
+		return
+	}
+
+	if healthy {
+		log.Info("Database connection restored")
+	} else {
+		log.Error("Database ping failed", "error", err)
+	}
+}
+
+// markHealthy records the result of a health check and reports whether it
+// differs from the previous one.
+func (s *Storage) markHealthy(healthy bool) bool {
+	if healthy {
+		dbUp.Set(1)
+	} else {
+		dbUp.Set(0)
+	}
+
+	return s.healthy.Swap(healthy) != healthy
+}
+
+// Healthy reports whether the last health check reached the database.
+func (s *Storage) Healthy() bool {
+	return s.healthy.Load()
+}
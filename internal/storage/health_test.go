@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	errDown := errors.New("connection refused")
+
+	tests := []struct {
+		name     string
+		ping     error
+		wantUp   int64
+		wantLog  string
+		previous bool
+	}{
+		{name: "healthy pool stays quiet", previous: true, ping: nil, wantUp: 1},
+		{name: "unhealthy pool", previous: true, ping: errDown, wantUp: 0, wantLog: "Database ping failed"},
+		{name: "still unhealthy stays quiet", previous: false, ping: errDown, wantUp: 0},
+		{name: "recovered pool", previous: false, ping: nil, wantUp: 1, wantLog: "Database connection restored"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := slog.New(slog.NewTextHandler(&buf, nil))
+
+			s := &Storage{}
+			s.healthy.Store(tt.previous)
+
+			s.checkHealth(context.Background(), log, time.Second, func(ctx context.Context) error {
+				if _, ok := ctx.Deadline(); !ok {
+					t.Error("ping has no deadline")
+				}
+				return tt.ping
+			})
+
+			if got, want := s.Healthy(), tt.ping == nil; got != want {
+				t.Errorf("Healthy() = %v, want %v", got, want)
+			}
+			if got := dbUp.Value(); got != tt.wantUp {
+				t.Errorf("storage_db_up = %d, want %d", got, tt.wantUp)
+			}
+
+			logged := buf.String()
+			if tt.wantLog == "" && logged != "" {
+				t.Errorf("logged %q, want nothing", logged)
+			}
+			if tt.wantLog != "" && !strings.Contains(logged, tt.wantLog) {
+				t.Errorf("logged %q, want %q", logged, tt.wantLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,873 @@
+// Package inmemory is a map-backed implementation of the usecase storage for
+// demos and local runs without Postgres. It mirrors the queries of package
+// storage, including filters and ordering; data is lost on restart.
+package inmemory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"testovoe/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errDuplicateID = errors.New("duplicate subscription id")
+
+type Storage struct {
+	mu          sync.RWMutex
+	subs        map[uuid.UUID]*domain.UserSub
+	shares      map[uuid.UUID]map[uuid.UUID]struct{}
+	prices      map[uuid.UUID][]domain.PriceChange
+	preferences map[uuid.UUID]domain.ReminderPreference
+}
+
+func New() *Storage {
+	return &Storage{
+		subs:        make(map[uuid.UUID]*domain.UserSub),
+		shares:      make(map[uuid.UUID]map[uuid.UUID]struct{}),
+		prices:      make(map[uuid.UUID][]domain.PriceChange),
+		preferences: make(map[uuid.UUID]domain.ReminderPreference),
+	}
+}
+
+// clone copies sub so callers can't modify stored data.
+func clone(sub *domain.UserSub) *domain.UserSub {
+	c := *sub
+	if sub.EndedAt != nil {
+		endedAt := *sub.EndedAt
+		c.EndedAt = &endedAt
+	}
+	if sub.PaymentMethod != nil {
+		method := *sub.PaymentMethod
+		c.PaymentMethod = &method
+	}
+	c.Tags = slices.Clone(sub.Tags)
+
+	return &c
+}
+
+func compareIDs(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+func (s *Storage) insert(userSub domain.UserSub) error {
+	if _, ok := s.subs[userSub.ID]; ok {
+		return fmt.Errorf("%w: %s", errDuplicateID, userSub.ID)
+	}
+
+	stored := clone(&userSub)
+	// Tags are managed separately, as with the database.
+	stored.Tags = nil
+	stored.CreatedAt = time.Now().UTC()
+	s.subs[stored.ID] = stored
+
+	return nil
+}
+
+func (s *Storage) remove(subID uuid.UUID) {
+	delete(s.subs, subID)
+	delete(s.shares, subID)
+	delete(s.prices, subID)
+}
+
+func (s *Storage) CreateSub(ctx context.Context, userSub domain.UserSub) (int64, error) {
+	const op = "storage.inmemory.CreateSub"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.insert(userSub); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return 1, nil
+}
+
+// CreateSubs stores either every subscription or none.
+func (s *Storage) CreateSubs(ctx context.Context, userSubs []domain.UserSub) (int64, error) {
+	const op = "storage.inmemory.CreateSubs"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[uuid.UUID]bool, len(userSubs))
+	for i, userSub := range userSubs {
+		if _, ok := s.subs[userSub.ID]; ok || seen[userSub.ID] {
+			return 0, fmt.Errorf("%s: subscription %d: %w: %s", op, i, errDuplicateID, userSub.ID)
+		}
+		seen[userSub.ID] = true
+	}
+
+	for _, userSub := range userSubs {
+		_ = s.insert(userSub)
+	}
+
+	return int64(len(userSubs)), nil
+}
+
+func (s *Storage) ReplaceUserSubs(ctx context.Context, userID uuid.UUID, userSubs []domain.UserSub) (int64, error) {
+	const op = "storage.inmemory.ReplaceUserSubs"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[uuid.UUID]bool, len(userSubs))
+	for i, userSub := range userSubs {
+		existing, ok := s.subs[userSub.ID]
+		if (ok && existing.UserID != userID) || seen[userSub.ID] {
+			return 0, fmt.Errorf("%s: subscription %d: %w: %s", op, i, errDuplicateID, userSub.ID)
+		}
+		seen[userSub.ID] = true
+	}
+
+	for id, sub := range s.subs {
+		if sub.UserID == userID {
+			s.remove(id)
+		}
+	}
+
+	for _, userSub := range userSubs {
+		_ = s.insert(userSub)
+	}
+
+	return int64(len(userSubs)), nil
+}
+
+func (s *Storage) UpdateSub(ctx context.Context, update domain.SubUpdate, now time.Time, check func(domain.UserSub) error) (*domain.UserSub, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[update.ID]
+	if !ok || sub.UserID != update.UserID {
+		return nil, nil
+	}
+
+	// Apply to a copy, detached from the pointers taken from update.
+	updated := clone(sub)
+	update.Apply(updated)
+	updated = clone(updated)
+
+	if check != nil {
+		if err := check(*updated); err != nil {
+			return nil, err
+		}
+	}
+
+	if sub.ServicePrice != updated.ServicePrice {
+		s.recordPriceChange(sub.ID, sub.ServicePrice, updated.ServicePrice, now)
+	}
+	*sub = *updated
+
+	return clone(sub), nil
+}
+
+func (s *Storage) recordPriceChange(subID uuid.UUID, oldPrice, newPrice int, now time.Time) {
+	s.prices[subID] = append(s.prices[subID], domain.PriceChange{
+		SubscriptionID: subID,
+		OldPrice:       oldPrice,
+		NewPrice:       newPrice,
+		ChangedAt:      now,
+	})
+}
+
+func (s *Storage) PriceHistory(ctx context.Context, subID uuid.UUID) ([]domain.PriceChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]domain.PriceChange{}, s.prices[subID]...), nil
+}
+
+func (s *Storage) ReactivateSub(ctx context.Context, subID uuid.UUID, startedAt *time.Time, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[subID]
+	if !ok || sub.EndedAt == nil || sub.EndedAt.After(now) {
+		return 0, nil
+	}
+
+	sub.EndedAt = nil
+	if startedAt != nil {
+		sub.StartedAt = *startedAt
+	}
+
+	return 1, nil
+}
+
+func (s *Storage) RenewExpiring(ctx context.Context, userID uuid.UUID, now, until time.Time, months int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var renewed int64
+	for _, sub := range s.subs {
+		if sub.UserID == userID && sub.EndedAt != nil && sub.EndedAt.After(now) && !sub.EndedAt.After(until) {
+			endedAt := domain.AddMonthsClamped(*sub.EndedAt, months)
+			sub.EndedAt = &endedAt
+			renewed++
+		}
+	}
+
+	return renewed, nil
+}
+
+func (s *Storage) DeleteSub(ctx context.Context, subID, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[subID]
+	if !ok || sub.UserID != userID {
+		return 0, nil
+	}
+
+	s.remove(subID)
+
+	return 1, nil
+}
+
+func (s *Storage) UpdatePrices(ctx context.Context, filter domain.SubFilter, price int, now time.Time) (int64, error) {
+	const op = "storage.inmemory.UpdatePrices"
+
+	if filter.IsEmpty() {
+		return 0, fmt.Errorf("%s: %w", op, domain.ErrEmptyFilter)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var affected int64
+	for _, sub := range s.subs {
+		if s.matches(sub, filter) {
+			if sub.ServicePrice != price {
+				s.recordPriceChange(sub.ID, sub.ServicePrice, price, now)
+			}
+			sub.ServicePrice = price
+			affected++
+		}
+	}
+
+	return affected, nil
+}
+
+func (s *Storage) RenameService(ctx context.Context, from, to string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var renamed int64
+	for _, sub := range s.subs {
+		if sub.ServiceName == from {
+			sub.ServiceName = to
+			renamed++
+		}
+	}
+
+	return renamed, nil
+}
+
+func (s *Storage) DeleteSubsByFilter(ctx context.Context, filter domain.SubFilter) (int64, error) {
+	const op = "storage.inmemory.DeleteSubsByFilter"
+
+	if filter.IsEmpty() {
+		return 0, fmt.Errorf("%s: %w", op, domain.ErrEmptyFilter)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var affected int64
+	for id, sub := range s.subs {
+		if s.matches(sub, filter) {
+			s.remove(id)
+			affected++
+		}
+	}
+
+	return affected, nil
+}
+
+// matches is the counterpart of the storage package's filterWhere.
+func (s *Storage) matches(sub *domain.UserSub, filter domain.SubFilter) bool {
+	if filter.UserID != nil && sub.UserID != *filter.UserID {
+		if !filter.IncludeShared {
+			return false
+		}
+		if _, shared := s.shares[sub.ID][*filter.UserID]; !shared {
+			return false
+		}
+	}
+	if filter.ServiceName != "" && sub.ServiceName != filter.ServiceName {
+		return false
+	}
+	if filter.PaymentMethod != "" && (sub.PaymentMethod == nil || *sub.PaymentMethod != filter.PaymentMethod) {
+		return false
+	}
+	if len(filter.ServiceNames) > 0 && !slices.Contains(filter.ServiceNames, sub.ServiceName) {
+		return false
+	}
+	if len(filter.Tags) > 0 && !matchesTags(sub.Tags, filter.Tags, filter.TagMode) {
+		return false
+	}
+	if filter.ExcludeFree && sub.ServicePrice == 0 {
+		return false
+	}
+	if filter.CreatedFrom != nil && sub.CreatedAt.Before(*filter.CreatedFrom) {
+		return false
+	}
+	if filter.CreatedTo != nil && sub.CreatedAt.After(*filter.CreatedTo) {
+		return false
+	}
+
+	return true
+}
+
+func matchesTags(have, want []string, mode domain.TagMode) bool {
+	if mode == domain.TagModeAll {
+		for _, tag := range want {
+			if !slices.Contains(have, tag) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return slices.ContainsFunc(want, func(tag string) bool { return slices.Contains(have, tag) })
+}
+
+// selectSubs returns copies of the subscriptions accepted by keep, sorted
+// by cmpFn.
+func (s *Storage) selectSubs(keep func(*domain.UserSub) bool, cmpFn func(a, b *domain.UserSub) int) []*domain.UserSub {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var userSubs []*domain.UserSub
+	for _, sub := range s.subs {
+		if keep(sub) {
+			userSubs = append(userSubs, clone(sub))
+		}
+	}
+	slices.SortFunc(userSubs, cmpFn)
+
+	return userSubs
+}
+
+func (s *Storage) filtered(filter domain.SubFilter, cmpFn func(a, b *domain.UserSub) int) []*domain.UserSub {
+	return s.selectSubs(func(sub *domain.UserSub) bool { return s.matches(sub, filter) }, cmpFn)
+}
+
+func byStart(a, b *domain.UserSub) int {
+	return cmp.Or(a.StartedAt.Compare(b.StartedAt), compareIDs(a.ID, b.ID))
+}
+
+func byCreated(a, b *domain.UserSub) int {
+	return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), compareIDs(a.ID, b.ID))
+}
+
+// bySort is the counterpart of the storage package's orderBy.
+func bySort(sort domain.Sort) func(a, b *domain.UserSub) int {
+	if !sort.Valid() {
+		sort = domain.DefaultSort
+	}
+
+	return func(a, b *domain.UserSub) int {
+		var c int
+		switch sort.Column {
+		case domain.SortStartedAt:
+			c = a.StartedAt.Compare(b.StartedAt)
+		case domain.SortCreatedAt:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case domain.SortServiceName:
+			c = cmp.Compare(a.ServiceName, b.ServiceName)
+		case domain.SortServicePrice:
+			c = cmp.Compare(a.ServicePrice, b.ServicePrice)
+		}
+		c = cmp.Or(c, compareIDs(a.ID, b.ID))
+
+		if sort.Desc {
+			return -c
+		}
+		return c
+	}
+}
+
+func (s *Storage) ListSubs(ctx context.Context, filter domain.SubFilter, page domain.Page) ([]*domain.UserSub, error) {
+	order := bySort(page.Sort)
+	if filter.HasCreatedRange() {
+		order = byCreated
+	}
+
+	userSubs := s.filtered(filter, order)
+
+	if page.Offset >= uint64(len(userSubs)) {
+		return nil, nil
+	}
+	userSubs = userSubs[page.Offset:]
+
+	if page.Limit > 0 && page.Limit < uint64(len(userSubs)) {
+		userSubs = userSubs[:page.Limit]
+	}
+
+	return userSubs, nil
+}
+
+func (s *Storage) CountSubs(ctx context.Context, filter domain.SubFilter) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count uint64
+	for _, sub := range s.subs {
+		if s.matches(sub, filter) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// StreamSubs calls fn for every subscription matching filter, oldest first.
+// Iteration stops at the first error fn returns.
+func (s *Storage) StreamSubs(ctx context.Context, filter domain.SubFilter, fn func(*domain.UserSub) error) error {
+	for _, userSub := range s.filtered(filter, byStart) {
+		if err := fn(userSub); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) SubsByUser(ctx context.Context, filter domain.SubFilter) (map[uuid.UUID][]*domain.UserSub, error) {
+	grouped := make(map[uuid.UUID][]*domain.UserSub)
+	for _, userSub := range s.filtered(filter, byStart) {
+		grouped[userSub.UserID] = append(grouped[userSub.UserID], userSub)
+	}
+
+	return grouped, nil
+}
+
+func (s *Storage) GetUserSubs(ctx context.Context, userID uuid.UUID) ([]*domain.UserSub, error) {
+	return s.selectSubs(func(sub *domain.UserSub) bool { return sub.UserID == userID }, byStart), nil
+}
+
+func (s *Storage) GetUserSub(ctx context.Context, subID uuid.UUID) (*domain.UserSub, error) {
+	const op = "storage.inmemory.GetUserSub"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.subs[subID]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, domain.ErrSubNotFound)
+	}
+
+	return clone(sub), nil
+}
+
+func (s *Storage) SubNeighbors(ctx context.Context, subID uuid.UUID) (*domain.SubNeighbors, error) {
+	const op = "storage.inmemory.SubNeighbors"
+
+	sub, err := s.GetUserSub(ctx, subID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, domain.ErrSubNotFound)
+	}
+
+	userSubs, _ := s.GetUserSubs(ctx, sub.UserID)
+	i := slices.IndexFunc(userSubs, func(userSub *domain.UserSub) bool { return userSub.ID == subID })
+
+	var neighbors domain.SubNeighbors
+	if i > 0 {
+		neighbors.PrevID = &userSubs[i-1].ID
+	}
+	if i >= 0 && i < len(userSubs)-1 {
+		neighbors.NextID = &userSubs[i+1].ID
+	}
+
+	return &neighbors, nil
+}
+
+// DeduplicateSubs ends every active subscription except the most recent one
+// per user and service name.
+func (s *Storage) DeduplicateSubs(ctx context.Context, now time.Time) (*domain.DedupReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type key struct {
+		userID      uuid.UUID
+		serviceName string
+	}
+
+	active := make(map[key][]*domain.UserSub)
+	for _, sub := range s.subs {
+		if sub.ActiveAt(now) {
+			k := key{sub.UserID, sub.ServiceName}
+			active[k] = append(active[k], sub)
+		}
+	}
+
+	report := &domain.DedupReport{Groups: []domain.DedupGroup{}}
+	for k, subs := range active {
+		if len(subs) < 2 {
+			continue
+		}
+
+		slices.SortFunc(subs, func(a, b *domain.UserSub) int { return -byStart(a, b) })
+
+		group := domain.DedupGroup{UserID: k.userID, ServiceName: k.serviceName, KeptID: subs[0].ID}
+		for _, sub := range subs[1:] {
+			endedAt := now
+			sub.EndedAt = &endedAt
+			group.EndedIDs = append(group.EndedIDs, sub.ID)
+			report.Ended++
+		}
+		report.Groups = append(report.Groups, group)
+	}
+
+	slices.SortFunc(report.Groups, func(a, b domain.DedupGroup) int {
+		return cmp.Or(compareIDs(a.UserID, b.UserID), cmp.Compare(a.ServiceName, b.ServiceName))
+	})
+
+	return report, nil
+}
+
+func (s *Storage) TopSubs(ctx context.Context, userID uuid.UUID, limit uint64) ([]*domain.UserSub, error) {
+	userSubs := s.selectSubs(
+		func(sub *domain.UserSub) bool { return sub.UserID == userID },
+		func(a, b *domain.UserSub) int {
+			return cmp.Or(cmp.Compare(b.ServicePrice, a.ServicePrice), compareIDs(a.ID, b.ID))
+		},
+	)
+
+	if limit < uint64(len(userSubs)) {
+		userSubs = userSubs[:limit]
+	}
+
+	return userSubs, nil
+}
+
+func (s *Storage) Facets(ctx context.Context, userID uuid.UUID, now time.Time) (*domain.SubFacets, error) {
+	facets := &domain.SubFacets{
+		Services: []domain.FacetBucket{},
+		Statuses: []domain.FacetBucket{},
+	}
+
+	userSubs, _ := s.GetUserSubs(ctx, userID)
+	if len(userSubs) == 0 {
+		return facets, nil
+	}
+
+	services := make(map[string]int)
+	statuses := make(map[string]int)
+	sum := int64(0)
+	facets.Price.Min = userSubs[0].ServicePrice
+	for _, sub := range userSubs {
+		services[sub.ServiceName]++
+		switch {
+		case sub.StartedAt.After(now):
+			statuses[domain.StatusUpcoming]++
+		case sub.ActiveAt(now):
+			statuses[domain.StatusActive]++
+		default:
+			statuses[domain.StatusEnded]++
+		}
+
+		facets.Price.Min = min(facets.Price.Min, sub.ServicePrice)
+		facets.Price.Max = max(facets.Price.Max, sub.ServicePrice)
+		sum += int64(sub.ServicePrice)
+	}
+
+	facets.Services = buckets(services)
+	facets.Statuses = buckets(statuses)
+	facets.Price.Avg = int(domain.RoundHalfUp(big.NewRat(sum, int64(len(userSubs)))))
+
+	return facets, nil
+}
+
+func buckets(counts map[string]int) []domain.FacetBucket {
+	result := make([]domain.FacetBucket, 0, len(counts))
+	for value, count := range counts {
+		result = append(result, domain.FacetBucket{Value: value, Count: count})
+	}
+	slices.SortFunc(result, func(a, b domain.FacetBucket) int { return cmp.Compare(a.Value, b.Value) })
+
+	return result
+}
+
+func (s *Storage) Stats(ctx context.Context, userID uuid.UUID, now time.Time) (*domain.SubStats, error) {
+	userSubs, _ := s.GetUserSubs(ctx, userID)
+
+	var (
+		stats       domain.SubStats
+		sum         int64
+		spend       = new(big.Rat)
+		mostMonthly *big.Rat
+		mostService string
+	)
+	for _, sub := range userSubs {
+		stats.Total++
+		sum += int64(sub.ServicePrice)
+
+		if sub.StartedAt.After(now) || (sub.EndedAt != nil && !sub.EndedAt.After(now)) {
+			continue
+		}
+
+		stats.Active++
+		monthly := sub.MonthlyPrice()
+		spend.Add(spend, monthly)
+
+		c := 1
+		if mostMonthly != nil {
+			c = cmp.Or(monthly.Cmp(mostMonthly), cmp.Compare(mostService, sub.ServiceName))
+		}
+		if c > 0 {
+			mostMonthly, mostService = monthly, sub.ServiceName
+		}
+	}
+
+	stats.MonthlySpend = int(domain.RoundHalfUp(spend))
+	if stats.Total > 0 {
+		stats.AvgPrice = int(domain.RoundHalfUp(big.NewRat(sum, int64(stats.Total))))
+	}
+	if mostMonthly != nil {
+		stats.MostExpensiveService = &mostService
+	}
+
+	return &stats, nil
+}
+
+func (s *Storage) ActiveCounts(ctx context.Context, from, to time.Time) ([]domain.MonthCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := []domain.MonthCount{}
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		count := 0
+		for _, sub := range s.subs {
+			if sub.ActiveInMonth(month) {
+				count++
+			}
+		}
+		counts = append(counts, domain.MonthCount{Month: month.Format(domain.MonthLayout), Count: count})
+	}
+
+	return counts, nil
+}
+
+func (s *Storage) ServicePriceStats(ctx context.Context, service, currency string, now time.Time) (*domain.ServicePriceStats, error) {
+	subs := s.selectSubs(func(sub *domain.UserSub) bool {
+		return sub.ServiceName == service && sub.Currency == currency && sub.ActiveAt(now)
+	}, byStart)
+
+	stats := &domain.ServicePriceStats{ServiceName: service, Currency: currency, Count: len(subs)}
+	if len(subs) == 0 {
+		return stats, nil
+	}
+
+	prices := make([]*big.Rat, 0, len(subs))
+	sum := new(big.Rat)
+	for _, sub := range subs {
+		monthly := sub.MonthlyPrice()
+		prices = append(prices, monthly)
+		sum.Add(sum, monthly)
+	}
+	slices.SortFunc(prices, (*big.Rat).Cmp)
+
+	// The median of an even count is the mean of the two middle prices, as
+	// percentile_cont(0.5) computes it.
+	median := new(big.Rat).Set(prices[len(prices)/2])
+	if len(prices)%2 == 0 {
+		median.Add(median, prices[len(prices)/2-1])
+		median.Quo(median, big.NewRat(2, 1))
+	}
+
+	stats.Min = int(domain.RoundHalfUp(prices[0]))
+	stats.Max = int(domain.RoundHalfUp(prices[len(prices)-1]))
+	stats.Avg = int(domain.RoundHalfUp(sum.Quo(sum, big.NewRat(int64(len(prices)), 1))))
+	stats.Median = int(domain.RoundHalfUp(median))
+
+	return stats, nil
+}
+
+// AddTags attaches tags to the subscription; tags it already has are skipped.
+func (s *Storage) AddTags(ctx context.Context, subID uuid.UUID, tags []string) error {
+	const op = "storage.inmemory.AddTags"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[subID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, domain.ErrSubNotFound)
+	}
+
+	for _, tag := range tags {
+		if !slices.Contains(sub.Tags, tag) {
+			sub.Tags = append(sub.Tags, tag)
+		}
+	}
+	slices.Sort(sub.Tags)
+
+	return nil
+}
+
+func (s *Storage) RemoveTag(ctx context.Context, subID uuid.UUID, tag string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[subID]
+	if !ok {
+		return 0, nil
+	}
+
+	i := slices.Index(sub.Tags, tag)
+	if i < 0 {
+		return 0, nil
+	}
+	sub.Tags = slices.Delete(sub.Tags, i, i+1)
+
+	return 1, nil
+}
+
+func (s *Storage) ShareSub(ctx context.Context, subID, userID uuid.UUID) error {
+	const op = "storage.inmemory.ShareSub"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[subID]; !ok {
+		return fmt.Errorf("%s: %w", op, domain.ErrSubNotFound)
+	}
+
+	if s.shares[subID] == nil {
+		s.shares[subID] = make(map[uuid.UUID]struct{})
+	}
+	s.shares[subID][userID] = struct{}{}
+
+	return nil
+}
+
+func (s *Storage) UnshareSub(ctx context.Context, subID, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shares[subID][userID]; !ok {
+		return 0, nil
+	}
+	delete(s.shares[subID], userID)
+
+	return 1, nil
+}
+
+// SubsActiveBetween returns the subscriptions of all users that started
+// before to and had not ended before from.
+func (s *Storage) SubsActiveBetween(ctx context.Context, from, to time.Time) ([]*domain.UserSub, error) {
+	return s.selectSubs(func(sub *domain.UserSub) bool {
+		return sub.StartedAt.Before(to) && (sub.EndedAt == nil || !sub.EndedAt.Before(from))
+	}, byStart), nil
+}
+
+// EndedSubs returns the subscriptions that ended in [from, to), oldest end
+// first, limited to userID when it is set.
+func (s *Storage) EndedSubs(ctx context.Context, userID *uuid.UUID, from, to time.Time) ([]*domain.UserSub, error) {
+	return s.selectSubs(func(sub *domain.UserSub) bool {
+		return sub.EndedAt != nil && !sub.EndedAt.Before(from) && sub.EndedAt.Before(to) &&
+			(userID == nil || sub.UserID == *userID)
+	}, func(a, b *domain.UserSub) int {
+		return cmp.Or(a.EndedAt.Compare(*b.EndedAt), compareIDs(a.ID, b.ID))
+	}), nil
+}
+
+// Currencies returns the distinct currency codes of the subscriptions, in
+// alphabetical order, limited to userID when it is set.
+func (s *Storage) Currencies(ctx context.Context, userID *uuid.UUID) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	currencies := []string{}
+	for _, sub := range s.subs {
+		if userID == nil || sub.UserID == *userID {
+			currencies = append(currencies, sub.Currency)
+		}
+	}
+	slices.Sort(currencies)
+
+	return slices.Compact(currencies), nil
+}
+
+// GetTotalCosts sums, per user over userIDs, what the subscriptions bill in
+// every month from the month starting at from to the one starting at to. An
+// empty serviceNames matches every service. Users without billed
+// subscriptions are absent from the result.
+func (s *Storage) GetTotalCosts(ctx context.Context, userIDs []uuid.UUID, serviceNames []string, from, to time.Time) (map[uuid.UUID]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totals := make(map[uuid.UUID]int)
+	for _, sub := range s.subs {
+		if !slices.Contains(userIDs, sub.UserID) {
+			continue
+		}
+		if len(serviceNames) > 0 && !slices.Contains(serviceNames, sub.ServiceName) {
+			continue
+		}
+
+		for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+			if sub.BilledInMonth(month) {
+				totals[sub.UserID] += sub.ChargeInMonth(month)
+			}
+		}
+	}
+
+	return totals, nil
+}
+
+func (s *Storage) GetReminderPreference(ctx context.Context, userID uuid.UUID) (*domain.ReminderPreference, error) {
+	const op = "storage.inmemory.GetReminderPreference"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pref, ok := s.preferences[userID]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, domain.ErrReminderPreferenceNotFound)
+	}
+
+	return &pref, nil
+}
+
+func (s *Storage) SetReminderPreference(ctx context.Context, pref domain.ReminderPreference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.preferences[pref.UserID] = pref
+
+	return nil
+}
+
+func (s *Storage) DueReminders(ctx context.Context, from, to time.Time) ([]domain.Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reminders []domain.Reminder
+	for _, sub := range s.subs {
+		pref, ok := s.preferences[sub.UserID]
+		if !ok || sub.EndedAt == nil || sub.AutoRenew {
+			continue
+		}
+
+		reminder := domain.Reminder{Sub: *clone(sub), LeadTimeDays: pref.LeadTimeDays, Channel: pref.Channel}
+		if due := reminder.DueAt(); due.After(from) && !due.After(to) {
+			reminders = append(reminders, reminder)
+		}
+	}
+
+	slices.SortFunc(reminders, func(a, b domain.Reminder) int {
+		return cmp.Or(a.DueAt().Compare(b.DueAt()), compareIDs(a.Sub.ID, b.Sub.ID))
+	})
+
+	return reminders, nil
+}
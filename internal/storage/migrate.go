@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed migrations/*.sql
+var embedMigrations embed.FS
+
+const migrationsDir = "migrations"
+
+// MigrationCommands lists the goose commands supported by Migrate.
+var MigrationCommands = []string{"up", "down", "status", "version"}
+
+// MigrationError identifies the migration that failed to apply.
+type MigrationError struct {
+	Version int64
+	File    string
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %d (%s): %v", e.Version, e.File, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaAheadError reports a database migrated past the newest migration
+// embedded in this build, e.g. after rolling back a deploy. Nothing is
+// applied; callers may treat it as a warning rather than a failure.
+type SchemaAheadError struct {
+	DBVersion     int64
+	LatestVersion int64
+}
+
+func (e *SchemaAheadError) Error() string {
+	return fmt.Sprintf("database schema version %d is ahead of latest known migration %d", e.DBVersion, e.LatestVersion)
+}
+
+// Migrate runs a goose command against the embedded migrations. Pending
+// migrations are applied one at a time so a failure is reported as a
+// *MigrationError naming the migration at fault.
+func Migrate(ctx context.Context, dbURL, command string) error {
+	const op = "storage.Migrate"
+
+	if !slices.Contains(MigrationCommands, command) {
+		return fmt.Errorf("%s: unsupported command %q", op, command)
+	}
+
+	config, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	db := stdlib.OpenDB(*config.ConnConfig)
+	defer db.Close()
+
+	goose.SetBaseFS(embedMigrations)
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if command == "up" {
+		err = migrateUp(ctx, db)
+	} else {
+		err = goose.RunContext(ctx, command, db, migrationsDir)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", op, command, err)
+	}
+
+	return nil
+}
+
+func migrateUp(ctx context.Context, db *sql.DB) error {
+	current, err := goose.EnsureDBVersionContext(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	known, err := goose.CollectMigrations(migrationsDir, 0, goose.MaxVersion)
+	if err != nil {
+		return err
+	}
+
+	latest := known[len(known)-1].Version
+	if current > latest {
+		return &SchemaAheadError{DBVersion: current, LatestVersion: latest}
+	}
+
+	for _, m := range known {
+		if m.Version <= current {
+			continue
+		}
+
+		if err := goose.UpToContext(ctx, db, migrationsDir, m.Version); err != nil {
+			return &MigrationError{Version: m.Version, File: filepath.Base(m.Source), Err: err}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDBURL returns TEST_POSTGRES_URL, skipping the test when it is unset.
+func testDBURL(t *testing.T) string {
+	t.Helper()
+
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	return url
+}
+
+func TestMigrateRejectsUnknownCommand(t *testing.T) {
+	err := Migrate(context.Background(), "postgres://localhost/unused", "redo")
+	if err == nil || !strings.Contains(err.Error(), `unsupported command "redo"`) {
+		t.Errorf("err = %v, want unsupported command", err)
+	}
+}
+
+func TestMigrateStatus(t *testing.T) {
+	url := testDBURL(t)
+	ctx := context.Background()
+
+	if err := Migrate(ctx, url, "up"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	for _, command := range []string{"status", "version"} {
+		if err := Migrate(ctx, url, command); err != nil {
+			t.Errorf("%s: %v", command, err)
+		}
+	}
+}
+
+func TestMigrateUpOnASchemaAhead(t *testing.T) {
+	url := testDBURL(t)
+	ctx := context.Background()
+
+	if err := Migrate(ctx, url, "up"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	db, err := New(ctx, url, time.Second)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+
+	// A migration from a newer build that this one does not embed.
+	const ahead = 99999
+	if _, err := db.DB.Exec(ctx, "INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true)", ahead); err != nil {
+		t.Fatalf("mark ahead: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = db.DB.Exec(context.Background(), "DELETE FROM goose_db_version WHERE version_id = $1", ahead)
+	})
+
+	err = Migrate(ctx, url, "up")
+
+	var aheadErr *SchemaAheadError
+	if !errors.As(err, &aheadErr) {
+		t.Fatalf("up = %v, want *SchemaAheadError", err)
+	}
+	if aheadErr.DBVersion != ahead || aheadErr.LatestVersion >= ahead {
+		t.Errorf("err = %+v, want db version %d ahead of the latest", aheadErr, ahead)
+	}
+}
+
+func TestSchemaAheadErrorIsNotAMigrationError(t *testing.T) {
+	err := fmt.Errorf("storage.Migrate: up: %w", &SchemaAheadError{DBVersion: 14, LatestVersion: 12})
+
+	var aheadErr *SchemaAheadError
+	var migrationErr *MigrationError
+	if !errors.As(err, &aheadErr) || errors.As(err, &migrationErr) {
+		t.Fatalf("err %v classified wrongly", err)
+	}
+	if want := "database schema version 14 is ahead of latest known migration 12"; aheadErr.Error() != want {
+		t.Errorf("Error() = %q, want %q", aheadErr.Error(), want)
+	}
+}
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS billing_period VARCHAR(16) NOT NULL DEFAULT 'monthly';
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS billing_period;
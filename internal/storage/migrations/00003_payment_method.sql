@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS payment_method VARCHAR(64);
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS payment_method;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS auto_renew BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS auto_renew;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS reminder_preferences(
+    user_id UUID PRIMARY KEY,
+    lead_time_days INT NOT NULL,
+    channel VARCHAR(16) NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS reminder_preferences;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_created_at ON subscriptions(created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subscriptions_created_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT 'other';
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS category;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS price_changes(
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    old_price INT NOT NULL,
+    new_price INT NOT NULL,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_changes_subscription_id ON price_changes(subscription_id, changed_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS price_changes;
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testovoe/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Pool is a pgxpool.Pool whose queries give up waiting for a free connection
+// after AcquireTimeout with domain.ErrStorageBusy. The timeout only covers
+// the wait; the query itself runs under the caller's context.
+type Pool struct {
+	*pgxpool.Pool
+
+	// AcquireTimeout of zero waits as long as the caller's context allows.
+	AcquireTimeout time.Duration
+}
+
+func (p *Pool) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if p.AcquireTimeout <= 0 {
+		return p.Pool.Acquire(ctx)
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, p.AcquireTimeout)
+	defer cancel()
+
+	conn, err := p.Pool.Acquire(acquireCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(acquireCtx.Err(), context.DeadlineExceeded) {
+		return nil, domain.ErrStorageBusy
+	}
+
+	return conn, err
+}
+
+func (p *Pool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer conn.Release()
+
+	return conn.Exec(ctx, sql, args...)
+}
+
+func (p *Pool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	return &poolRows{Rows: rows, conn: conn}, nil
+}
+
+func (p *Pool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return errRow{err: err}
+	}
+
+	return poolRow{row: conn.QueryRow(ctx, sql, args...), conn: conn}
+}
+
+func (p *Pool) Begin(ctx context.Context) (pgx.Tx, error) {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	return &poolTx{Tx: tx, conn: conn}, nil
+}
+
+// poolRows returns its connection once the rows are read or closed.
+type poolRows struct {
+	pgx.Rows
+	conn *pgxpool.Conn
+}
+
+func (r *poolRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+
+	r.release()
+	return false
+}
+
+func (r *poolRows) Close() {
+	r.Rows.Close()
+	r.release()
+}
+
+func (r *poolRows) release() {
+	if r.conn != nil {
+		r.conn.Release()
+		r.conn = nil
+	}
+}
+
+// poolRow returns its connection once scanned.
+type poolRow struct {
+	row  pgx.Row
+	conn *pgxpool.Conn
+}
+
+func (r poolRow) Scan(dest ...any) error {
+	defer r.conn.Release()
+	return r.row.Scan(dest...)
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
+// poolTx returns its connection on commit or rollback.
+type poolTx struct {
+	pgx.Tx
+	conn *pgxpool.Conn
+}
+
+func (tx *poolTx) Commit(ctx context.Context) error {
+	err := tx.Tx.Commit(ctx)
+	tx.release()
+	return err
+}
+
+func (tx *poolTx) Rollback(ctx context.Context) error {
+	err := tx.Tx.Rollback(ctx)
+	tx.release()
+	return err
+}
+
+func (tx *poolTx) release() {
+	if tx.conn != nil {
+		tx.conn.Release()
+		tx.conn = nil
+	}
+}
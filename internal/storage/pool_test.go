@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newPool opens a pool of at most one connection to url.
+func newPool(t *testing.T, url string, acquireTimeout time.Duration) *Pool {
+	t.Helper()
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parse %s: %v", url, err)
+	}
+	cfg.MaxConns = 1
+
+	db, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("open pool: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	return &Pool{Pool: db, AcquireTimeout: acquireTimeout}
+}
+
+func TestPoolFailsFastWhenSaturated(t *testing.T) {
+	p := newPool(t, testDBURL(t), 50*time.Millisecond)
+	ctx := context.Background()
+
+	held, err := p.Pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	start := time.Now()
+	_, err = p.Exec(ctx, "SELECT 1")
+	elapsed := time.Since(start)
+	held.Release()
+
+	if !errors.Is(err, domain.ErrStorageBusy) {
+		t.Fatalf("Exec on a saturated pool: err = %v, want %v", err, domain.ErrStorageBusy)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Exec gave up after %v, want about the 50ms acquire timeout", elapsed)
+	}
+
+	if _, err := p.Exec(ctx, "SELECT 1"); err != nil {
+		t.Errorf("Exec once the connection is free: %v", err)
+	}
+}
+
+// silentServer accepts connections and never answers, so every connection
+// attempt hangs until its context ends.
+func silentServer(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				for _, conn := range conns {
+					conn.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	return "postgres://user:pass@" + ln.Addr().String() + "/subs?sslmode=disable"
+}
+
+func TestPoolBusyOnlyOnItsOwnTimeout(t *testing.T) {
+	url := silentServer(t)
+
+	p := newPool(t, url, 50*time.Millisecond)
+	if _, err := p.Exec(context.Background(), "SELECT 1"); !errors.Is(err, domain.ErrStorageBusy) {
+		t.Errorf("acquire timeout: err = %v, want %v", err, domain.ErrStorageBusy)
+	}
+
+	// The caller's own deadline is not the pool being busy.
+	p = newPool(t, url, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.Exec(ctx, "SELECT 1"); err == nil || errors.Is(err, domain.ErrStorageBusy) {
+		t.Errorf("caller deadline: err = %v, want a non-busy error", err)
+	}
+}
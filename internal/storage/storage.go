@@ -2,9 +2,10 @@ package storage
 
 import (
 	"context"
-	"embed"
 	"errors"
+	"expvar"
 	"fmt"
+	"sync/atomic"
 	"testovoe/internal/domain"
 	"time"
 
@@ -12,71 +13,902 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/stdlib"
-	"github.com/pressly/goose/v3"
 )
 
-//go:embed migrations/*.sql
-var embedMigrations embed.FS
-
 type Storage struct {
-	DB *pgxpool.Pool
+	DB *Pool
+
+	healthy atomic.Bool
+}
+
+// New opens the connection pool. Migrations are applied separately, see Migrate.
+// Queries waiting longer than acquireTimeout for a connection fail with
+// domain.ErrStorageBusy; zero disables the limit.
+func New(ctx context.Context, storagePath string, acquireTimeout time.Duration) (*Storage, error) {
+	poolConfig, err := pgxpool.ParseConfig(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Storage{DB: &Pool{Pool: db, AcquireTimeout: acquireTimeout}}
+	s.markHealthy(true)
+	publishDBUp.Do(func() { expvar.Publish("storage_db_up", dbUp) })
+
+	return s, nil
+}
+
+var subColumns = []string{
+	"id", "service_name", "sub_price", "currency", "user_id", "started_at", "ended_at", "billing_period", "payment_method", "auto_renew",
+	"ARRAY(SELECT tag FROM subscription_tags t WHERE t.subscription_id = subscriptions.id ORDER BY tag)",
+	"created_at", "category",
+}
+
+// scanSub reads a row selected with subColumns; extra receives the columns
+// selected after them.
+func scanSub(row pgx.Row, extra ...any) (*domain.UserSub, error) {
+	var userSub domain.UserSub
+
+	dest := []any{
+		&userSub.ID,
+		&userSub.ServiceName,
+		&userSub.ServicePrice,
+		&userSub.Currency,
+		&userSub.UserID,
+		&userSub.StartedAt,
+		&userSub.EndedAt,
+		&userSub.BillingPeriod,
+		&userSub.PaymentMethod,
+		&userSub.AutoRenew,
+		&userSub.Tags,
+		&userSub.CreatedAt,
+		&userSub.Category,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	return &userSub, nil
+}
+
+func filterWhere(filter domain.SubFilter) sq.And {
+	eq := sq.Eq{}
+	if filter.UserID != nil && !filter.IncludeShared {
+		eq[column("user_id")] = *filter.UserID
+	}
+	if filter.ServiceName != "" {
+		eq[column("service_name")] = filter.ServiceName
+	}
+	if filter.PaymentMethod != "" {
+		eq[column("payment_method")] = filter.PaymentMethod
+	}
+
+	where := sq.And{eq}
+	if filter.UserID != nil && filter.IncludeShared {
+		where = append(where, sq.Or{
+			sq.Eq{column("user_id"): *filter.UserID},
+			sq.Expr("id IN (SELECT subscription_id FROM subscription_shares WHERE user_id = ?)", *filter.UserID),
+		})
+	}
+	if len(filter.ServiceNames) > 0 {
+		where = append(where, sq.Expr(column("service_name")+" = ANY(?)", filter.ServiceNames))
+	}
+	if len(filter.Tags) > 0 {
+		where = append(where, tagsWhere(filter.Tags, filter.TagMode))
+	}
+	if filter.ExcludeFree {
+		where = append(where, sq.Gt{column("service_price"): 0})
+	}
+	if filter.CreatedFrom != nil {
+		where = append(where, sq.GtOrEq{column("created_at"): *filter.CreatedFrom})
+	}
+	if filter.CreatedTo != nil {
+		where = append(where, sq.LtOrEq{column("created_at"): *filter.CreatedTo})
+	}
+
+	return where
+}
+
+// tagsWhere matches subscriptions carrying any of tags, or all of them in
+// TagModeAll. tags must not repeat.
+func tagsWhere(tags []string, mode domain.TagMode) sq.Sqlizer {
+	if mode == domain.TagModeAll {
+		return sq.Expr(
+			"id IN (SELECT subscription_id FROM subscription_tags WHERE tag = ANY(?) GROUP BY subscription_id HAVING COUNT(*) = ?)",
+			tags, len(tags),
+		)
+	}
+
+	return sq.Expr("id IN (SELECT subscription_id FROM subscription_tags WHERE tag = ANY(?))", tags)
+}
+
+// column is the SQL column behind the API field name in domain.SubFields.
+// Callers pass constant names, so one without a column, unknown or computed
+// per response, is a bug and panics rather than building broken SQL.
+func column(field string) string {
+	col := domain.SubFields[field].Column
+	if col == "" {
+		panic(fmt.Sprintf("storage: field %q has no column", field))
+	}
+
+	return col
+}
+
+// orderBy renders sort as ORDER BY terms with id as the tiebreaker.
+func orderBy(sort domain.Sort) []string {
+	if !sort.Valid() {
+		sort = domain.DefaultSort
+	}
+
+	dir := " ASC"
+	if sort.Desc {
+		dir = " DESC"
+	}
+
+	sortColumn, _ := domain.SortColumn(sort.Column)
+
+	return []string{sortColumn + dir, column("id") + dir}
+}
+
+func (s *Storage) Close() error {
+	s.DB.Close()
+	return nil
+}
+
+func insertSubQuery(userSub domain.UserSub) (string, []interface{}, error) {
+	return sq.
+		Insert("subscriptions").
+		Columns("id", "service_name", "sub_price", "currency", "user_id", "started_at", "ended_at", "billing_period", "payment_method", "auto_renew", "category").
+		Values(userSub.ID, userSub.ServiceName, userSub.ServicePrice, userSub.Currency, userSub.UserID, userSub.StartedAt, userSub.EndedAt, userSub.BillingPeriod, userSub.PaymentMethod, userSub.AutoRenew, userSub.Category).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+}
+
+func (s *Storage) CreateSub(ctx context.Context, userSub domain.UserSub) (int64, error) {
+	const op = "storage.storage.CreateSub"
+
+	query, args, err := insertSubQuery(userSub)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// CreateSubs inserts all subscriptions in one transaction: either every row
+// is stored or none is.
+func (s *Storage) CreateSubs(ctx context.Context, userSubs []domain.UserSub) (int64, error) {
+	const op = "storage.storage.CreateSubs"
+
+	var affected int64
+
+	err := pgx.BeginFunc(ctx, s.DB, func(tx pgx.Tx) error {
+		for i, userSub := range userSubs {
+			query, args, err := insertSubQuery(userSub)
+			if err != nil {
+				return fmt.Errorf("subscription %d: %w", i, err)
+			}
+
+			tag, err := tx.Exec(ctx, query, args...)
+			if err != nil {
+				return fmt.Errorf("subscription %d: %w", i, err)
+			}
+
+			affected += tag.RowsAffected()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affected, nil
+}
+
+// ReplaceUserSubs deletes every subscription of userID and inserts userSubs
+// in their place within one transaction.
+func (s *Storage) ReplaceUserSubs(ctx context.Context, userID uuid.UUID, userSubs []domain.UserSub) (int64, error) {
+	const op = "storage.storage.ReplaceUserSubs"
+
+	var affected int64
+
+	err := pgx.BeginFunc(ctx, s.DB, func(tx pgx.Tx) error {
+		query, args, err := sq.
+			Delete("subscriptions").
+			Where(sq.Eq{"user_id": userID}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return err
+		}
+
+		for i, userSub := range userSubs {
+			query, args, err := insertSubQuery(userSub)
+			if err != nil {
+				return fmt.Errorf("subscription %d: %w", i, err)
+			}
+
+			tag, err := tx.Exec(ctx, query, args...)
+			if err != nil {
+				return fmt.Errorf("subscription %d: %w", i, err)
+			}
+
+			affected += tag.RowsAffected()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affected, nil
+}
+
+// UpdateSub updates the subscription matching update.ID and update.UserID
+// and returns the stored row afterwards, or nil when none matched. The row
+// is locked and read first, so check sees the subscription as the update
+// leaves it, including the stored started_at; an error from check aborts
+// the update. A changed price is recorded in price_changes at now, in the
+// same transaction.
+func (s *Storage) UpdateSub(ctx context.Context, update domain.SubUpdate, now time.Time, check func(domain.UserSub) error) (*domain.UserSub, error) {
+	const op = "storage.storage.UpdateSub"
+
+	values := map[string]interface{}{
+		"service_name": update.ServiceName,
+		"sub_price":    update.ServicePrice,
+	}
+	if update.EndedAt.Set {
+		values["ended_at"] = update.EndedAt.Value
+		// An explicit end date is no longer the backfill's to revert.
+		values["ended_at_backfilled"] = false
+	}
+	if update.Currency != "" {
+		values["currency"] = update.Currency
+	}
+	if update.BillingPeriod != "" {
+		values["billing_period"] = update.BillingPeriod
+	}
+	if update.PaymentMethod.Set {
+		values["payment_method"] = update.PaymentMethod.Value
+	}
+	if update.AutoRenew.Set {
+		values["auto_renew"] = update.AutoRenew.Value
+	}
+	if update.Category != "" {
+		values["category"] = update.Category
+	}
+
+	where := sq.Eq{"id": update.ID, "user_id": update.UserID}
+
+	selectQuery, selectArgs, err := sq.
+		Select(subColumns...).
+		From("subscriptions").
+		Where(where).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updateQuery, updateArgs, err := sq.
+		Update("subscriptions").
+		SetMap(values).
+		Where(where).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var updated *domain.UserSub
+	err = pgx.BeginFunc(ctx, s.DB, func(tx pgx.Tx) error {
+		current, err := scanSub(tx.QueryRow(ctx, selectQuery, selectArgs...))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		if check != nil {
+			next := *current
+			update.Apply(&next)
+			if err := check(next); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(ctx, updateQuery, updateArgs...); err != nil {
+			return err
+		}
+
+		if current.ServicePrice != update.ServicePrice {
+			query, args, err := sq.
+				Insert("price_changes").
+				Columns("subscription_id", "old_price", "new_price", "changed_at").
+				Values(update.ID, current.ServicePrice, update.ServicePrice, now).
+				PlaceholderFormat(sq.Dollar).
+				ToSql()
+			if err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec(ctx, query, args...); err != nil {
+				return err
+			}
+		}
+
+		// Read the row back so callers see exactly what was stored.
+		updated, err = scanSub(tx.QueryRow(ctx, selectQuery, selectArgs...))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return updated, nil
+}
+
+// PriceHistory returns the recorded price changes of subID, oldest first.
+func (s *Storage) PriceHistory(ctx context.Context, subID uuid.UUID) ([]domain.PriceChange, error) {
+	const op = "storage.storage.PriceHistory"
+
+	query, args, err := sq.
+		Select("subscription_id", "old_price", "new_price", "changed_at").
+		From("price_changes").
+		Where(sq.Eq{"subscription_id": subID}).
+		OrderBy("changed_at", "id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	changes := []domain.PriceChange{}
+
+	for rows.Next() {
+		var change domain.PriceChange
+		if err := rows.Scan(&change.SubscriptionID, &change.OldPrice, &change.NewPrice, &change.ChangedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changes, nil
+}
+
+// ReactivateSub clears ended_at of subID if it ended at or before now and,
+// when startedAt is set, moves the start to it. 0 rows means the
+// subscription does not exist or is not cancelled.
+func (s *Storage) ReactivateSub(ctx context.Context, subID uuid.UUID, startedAt *time.Time, now time.Time) (int64, error) {
+	const op = "storage.storage.ReactivateSub"
+
+	values := map[string]interface{}{
+		"ended_at":            nil,
+		"ended_at_backfilled": false,
+	}
+	if startedAt != nil {
+		values["started_at"] = *startedAt
+	}
+
+	query, args, err := sq.
+		Update("subscriptions").
+		SetMap(values).
+		Where(sq.Eq{"id": subID}).
+		Where(sq.LtOrEq{"ended_at": now}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RenewExpiring moves ended_at of the user's subscriptions ending in
+// (now, until] months later, in one transaction. Like AddMonthsClamped,
+// adding an interval clamps to the last day of a shorter month.
+func (s *Storage) RenewExpiring(ctx context.Context, userID uuid.UUID, now, until time.Time, months int) (int64, error) {
+	const op = "storage.storage.RenewExpiring"
+
+	query, args, err := sq.
+		Update("subscriptions").
+		Set("ended_at", sq.Expr("ended_at + make_interval(months => ?)", months)).
+		Set("ended_at_backfilled", false).
+		Where(sq.Eq{"user_id": userID}).
+		Where(sq.Gt{"ended_at": now}).
+		Where(sq.LtOrEq{"ended_at": until}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var renewed int64
+	err = pgx.BeginFunc(ctx, s.DB, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		renewed = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return renewed, nil
+}
+
+func (s *Storage) DeleteSub(ctx context.Context, subID, userID uuid.UUID) (int64, error) {
+	const op = "storage.storage.DeleteSub"
+
+	query, args, err := sq.
+		Delete("subscriptions").
+		Where(sq.Eq{"id": subID, "user_id": userID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// UpdatePrices sets sub_price of every subscription matching filter in one
+// statement and records each changed price in price_changes at now, in the
+// same transaction.
+func (s *Storage) UpdatePrices(ctx context.Context, filter domain.SubFilter, price int, now time.Time) (int64, error) {
+	const op = "storage.storage.UpdatePrices"
+
+	if filter.IsEmpty() {
+		return 0, fmt.Errorf("%s: %w", op, domain.ErrEmptyFilter)
+	}
+
+	// The locked subselect exposes each row's price from before the update.
+	current := sq.
+		Select("id", "sub_price").
+		From("subscriptions").
+		Where(filterWhere(filter)).
+		Suffix("FOR UPDATE")
+
+	query, args, err := sq.
+		Update("subscriptions AS s").
+		Set("sub_price", price).
+		FromSelect(current, "old").
+		Where("s.id = old.id").
+		Suffix("RETURNING s.id, old.sub_price").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var affected int64
+	err = pgx.BeginFunc(ctx, s.DB, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		history := sq.
+			Insert("price_changes").
+			Columns("subscription_id", "old_price", "new_price", "changed_at")
+		changed := false
+
+		for rows.Next() {
+			var (
+				subID    uuid.UUID
+				oldPrice int
+			)
+			if err := rows.Scan(&subID, &oldPrice); err != nil {
+				rows.Close()
+				return err
+			}
+
+			affected++
+			if oldPrice != price {
+				history = history.Values(subID, oldPrice, price, now)
+				changed = true
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if !changed {
+			return nil
+		}
+
+		query, args, err := history.PlaceholderFormat(sq.Dollar).ToSql()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affected, nil
+}
+
+// RenameService sets service_name to to on every subscription named from and
+// returns how many were renamed.
+func (s *Storage) RenameService(ctx context.Context, from, to string) (int64, error) {
+	const op = "storage.storage.RenameService"
+
+	query, args, err := sq.
+		Update("subscriptions").
+		Set("service_name", to).
+		Where(sq.Eq{"service_name": from}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var renamed int64
+	err = pgx.BeginFunc(ctx, s.DB, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		renamed = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return renamed, nil
+}
+
+func (s *Storage) DeleteSubsByFilter(ctx context.Context, filter domain.SubFilter) (int64, error) {
+	const op = "storage.storage.DeleteSubsByFilter"
+
+	if filter.IsEmpty() {
+		return 0, fmt.Errorf("%s: %w", op, domain.ErrEmptyFilter)
+	}
+
+	query, args, err := sq.
+		Delete("subscriptions").
+		Where(filterWhere(filter)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (s *Storage) ListSubs(ctx context.Context, filter domain.SubFilter, page domain.Page) ([]*domain.UserSub, error) {
+	const op = "storage.storage.ListSubs"
+
+	builder := sq.
+		Select(subColumns...).
+		From("subscriptions").
+		Where(filterWhere(filter)).
+		Offset(page.Offset).
+		PlaceholderFormat(sq.Dollar)
+
+	if filter.HasCreatedRange() {
+		builder = builder.OrderBy("created_at", "id")
+	} else {
+		builder = builder.OrderBy(orderBy(page.Sort)...)
+	}
+
+	if page.Limit > 0 {
+		builder = builder.Limit(page.Limit)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var userSubs []*domain.UserSub
+
+	for rows.Next() {
+		userSub, err := scanSub(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		userSubs = append(userSubs, userSub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userSubs, nil
+}
+
+// StreamSubs calls fn for every subscription matching filter, oldest first,
+// reading rows from the connection as it goes instead of loading them all.
+// Iteration stops at the first error fn returns.
+func (s *Storage) StreamSubs(ctx context.Context, filter domain.SubFilter, fn func(*domain.UserSub) error) error {
+	const op = "storage.storage.StreamSubs"
+
+	var fnErr error
+	err := s.streamSubs(ctx, filter, []string{"started_at", "id"}, func(userSub *domain.UserSub) error {
+		fnErr = fn(userSub)
+		return fnErr
+	})
+	if err != nil && fnErr == nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+func (s *Storage) streamSubs(ctx context.Context, filter domain.SubFilter, orderBy []string, fn func(*domain.UserSub) error) error {
+	query, args, err := sq.
+		Select(subColumns...).
+		From("subscriptions").
+		Where(filterWhere(filter)).
+		OrderBy(orderBy...).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		userSub, err := scanSub(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(userSub); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// SubsByUser returns the subscriptions matching filter grouped by user, read
+// in one query ordered by user and start date.
+func (s *Storage) SubsByUser(ctx context.Context, filter domain.SubFilter) (map[uuid.UUID][]*domain.UserSub, error) {
+	const op = "storage.storage.SubsByUser"
+
+	grouped := make(map[uuid.UUID][]*domain.UserSub)
+
+	err := s.streamSubs(ctx, filter, []string{"user_id", "started_at", "id"}, func(userSub *domain.UserSub) error {
+		grouped[userSub.UserID] = append(grouped[userSub.UserID], userSub)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return grouped, nil
+}
+
+func (s *Storage) CountSubs(ctx context.Context, filter domain.SubFilter) (uint64, error) {
+	const op = "storage.storage.CountSubs"
+
+	query, args, err := sq.
+		Select("COUNT(*)").
+		From("subscriptions").
+		Where(filterWhere(filter)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total uint64
+	err = s.DB.QueryRow(ctx, query, args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return total, nil
+}
+
+func (s *Storage) GetUserSubs(ctx context.Context, userID uuid.UUID) ([]*domain.UserSub, error) {
+	const op = "storage.storage.GetUserSubs"
+
+	query, args, err := sq.
+		Select(subColumns...).
+		From("subscriptions").
+		Where(sq.Eq{"user_id": userID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var userSubs []*domain.UserSub
+
+	for rows.Next() {
+		userSub, err := scanSub(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		userSubs = append(userSubs, userSub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userSubs, nil
+}
+
+func (s *Storage) TopSubs(ctx context.Context, userID uuid.UUID, limit uint64) ([]*domain.UserSub, error) {
+	const op = "storage.storage.TopSubs"
+
+	query, args, err := sq.
+		Select(subColumns...).
+		From("subscriptions").
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("sub_price DESC").
+		Limit(limit).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var userSubs []*domain.UserSub
+
+	for rows.Next() {
+		userSub, err := scanSub(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		userSubs = append(userSubs, userSub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userSubs, nil
 }
 
-func New(ctx context.Context, storagePath string) (*Storage, error) {
-	const op = "storage.postgresql.SQL.NEW"
+// AddTags attaches tags to the subscription; tags it already has are skipped.
+func (s *Storage) AddTags(ctx context.Context, subID uuid.UUID, tags []string) error {
+	const op = "storage.storage.AddTags"
 
-	if err := runMigrations(storagePath); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	builder := sq.
+		Insert("subscription_tags").
+		Columns("subscription_id", "tag").
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+
+	for _, tag := range tags {
+		builder = builder.Values(subID, tag)
 	}
 
-	poolConfig, err := pgxpool.ParseConfig(storagePath)
+	query, args, err := builder.ToSql()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	_, err = s.DB.Exec(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{DB: db}, nil
+	return nil
 }
 
-func runMigrations(dbURL string) error {
-	config, err := pgxpool.ParseConfig(dbURL)
-	if err != nil {
-		return err
-	}
-
-	db := stdlib.OpenDB(*config.ConnConfig)
-	defer db.Close()
+func (s *Storage) RemoveTag(ctx context.Context, subID uuid.UUID, tag string) (int64, error) {
+	const op = "storage.storage.RemoveTag"
 
-	goose.SetBaseFS(embedMigrations)
+	query, args, err := sq.
+		Delete("subscription_tags").
+		Where(sq.Eq{"subscription_id": subID, "tag": tag}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		return err
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := goose.Up(db, "migrations"); err != nil {
-		return err
+	cmd, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return nil
-}
-
-func (s *Storage) Close() error {
-	s.DB.Close()
-	return nil
+	return cmd.RowsAffected(), nil
 }
 
-func (s *Storage) CreateSub(ctx context.Context, userSub domain.UserSub) error {
-	const op = "storage.storage.CreateSub"
+func (s *Storage) ShareSub(ctx context.Context, subID, userID uuid.UUID) error {
+	const op = "storage.storage.ShareSub"
 
 	query, args, err := sq.
-		Insert("subscriptions").
-		Columns("service_name", "sub_price", "user_id", "started_at", "ended_at").
-		Values(userSub.ServiceName, userSub.ServicePrice, userSub.UserID, userSub.StartedAt, userSub.EndedAt).
+		Insert("subscription_shares").
+		Columns("subscription_id", "user_id").
+		Values(subID, userID).
+		Suffix("ON CONFLICT DO NOTHING").
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 
@@ -92,61 +924,84 @@ func (s *Storage) CreateSub(ctx context.Context, userSub domain.UserSub) error {
 	return nil
 }
 
-func (s *Storage) UpdateSub(ctx context.Context, userSub domain.UserSub) error {
-	const op = "storage.storage.UpdateSub"
+func (s *Storage) UnshareSub(ctx context.Context, subID, userID uuid.UUID) (int64, error) {
+	const op = "storage.storage.UnshareSub"
 
 	query, args, err := sq.
-		Update("subscriptions").
-		SetMap(map[string]interface{}{
-			"service_name": userSub.ServiceName,
-			"sub_price":    userSub.ServicePrice,
-			"ended_at":     userSub.EndedAt,
-		}).
-		Where(sq.Eq{"id": userSub.ID, "user_id": userSub.UserID}).
+		Delete("subscription_shares").
+		Where(sq.Eq{"subscription_id": subID, "user_id": userID}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = s.DB.Exec(ctx, query, args...)
+	cmd, err := s.DB.Exec(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return nil
+	return cmd.RowsAffected(), nil
 }
 
-func (s *Storage) DeleteSub(ctx context.Context, subID, userID uuid.UUID) error {
-	const op = "storage.storage.DeleteSub"
+// SubsActiveBetween returns the subscriptions of all users that started
+// before to and had not ended before from.
+func (s *Storage) SubsActiveBetween(ctx context.Context, from, to time.Time) ([]*domain.UserSub, error) {
+	const op = "storage.storage.SubsActiveBetween"
 
 	query, args, err := sq.
-		Delete("subscriptions").
-		Where(sq.Eq{"id": subID, "user_id": userID}).
+		Select(subColumns...).
+		From("subscriptions").
+		Where(sq.Lt{"started_at": to}).
+		Where(sq.Or{sq.Eq{"ended_at": nil}, sq.GtOrEq{"ended_at": from}}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = s.DB.Exec(ctx, query, args...)
+	rows, err := s.DB.Query(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
-	return nil
+	var userSubs []*domain.UserSub
+
+	for rows.Next() {
+		userSub, err := scanSub(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		userSubs = append(userSubs, userSub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userSubs, nil
 }
 
-func (s *Storage) GetSubs(ctx context.Context) ([]*domain.UserSub, error) {
-	const op = "storage.storage.GetSubs"
+// EndedSubs returns the subscriptions that ended in [from, to), oldest end
+// first, limited to userID when it is set.
+func (s *Storage) EndedSubs(ctx context.Context, userID *uuid.UUID, from, to time.Time) ([]*domain.UserSub, error) {
+	const op = "storage.storage.EndedSubs"
 
-	query, args, err := sq.
-		Select("service_name", "sub_price", "user_id", "started_at", "ended_at").
+	builder := sq.
+		Select(subColumns...).
 		From("subscriptions").
-		ToSql()
+		Where(sq.GtOrEq{"ended_at": from}).
+		Where(sq.Lt{"ended_at": to}).
+		OrderBy("ended_at", "id").
+		PlaceholderFormat(sq.Dollar)
+	if userID != nil {
+		builder = builder.Where(sq.Eq{"user_id": *userID})
+	}
 
+	query, args, err := builder.ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -160,12 +1015,11 @@ func (s *Storage) GetSubs(ctx context.Context) ([]*domain.UserSub, error) {
 	var userSubs []*domain.UserSub
 
 	for rows.Next() {
-		var userSub domain.UserSub
-		if err := rows.Scan(&userSub.ServiceName, &userSub.ServicePrice, &userSub.UserID, &userSub.StartedAt, &userSub.EndedAt); err != nil {
+		userSub, err := scanSub(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-
-		userSubs = append(userSubs, &userSub)
+		userSubs = append(userSubs, userSub)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -175,11 +1029,97 @@ func (s *Storage) GetSubs(ctx context.Context) ([]*domain.UserSub, error) {
 	return userSubs, nil
 }
 
-func (s *Storage) GetUserSubs(ctx context.Context, userID uuid.UUID) ([]*domain.UserSub, error) {
-	const op = "storage.storage.GetSubs"
+// Currencies returns the distinct currency codes of the subscriptions, in
+// alphabetical order, limited to userID when it is set.
+func (s *Storage) Currencies(ctx context.Context, userID *uuid.UUID) ([]string, error) {
+	const op = "storage.storage.Currencies"
+
+	builder := sq.
+		Select("DISTINCT currency").
+		From("subscriptions").
+		OrderBy("currency").
+		PlaceholderFormat(sq.Dollar)
+	if userID != nil {
+		builder = builder.Where(sq.Eq{"user_id": *userID})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	currencies := []string{}
+
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		currencies = append(currencies, currency)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return currencies, nil
+}
+
+const facetsQuery = `
+WITH s AS (
+    SELECT service_name,
+           CASE WHEN started_at > $2 THEN 'upcoming'
+                WHEN ended_at IS NULL OR ended_at > $2 THEN 'active'
+                ELSE 'ended'
+           END AS status
+    FROM subscriptions
+    WHERE user_id = $1
+)
+SELECT 'service', service_name, COUNT(*) FROM s GROUP BY service_name
+UNION ALL
+SELECT 'status', status, COUNT(*) FROM s GROUP BY status
+ORDER BY 1, 2`
+
+func (s *Storage) Facets(ctx context.Context, userID uuid.UUID, now time.Time) (*domain.SubFacets, error) {
+	const op = "storage.storage.Facets"
+
+	facets := &domain.SubFacets{
+		Services: []domain.FacetBucket{},
+		Statuses: []domain.FacetBucket{},
+	}
+
+	rows, err := s.DB.Query(ctx, facetsQuery, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet string
+		var bucket domain.FacetBucket
+		if err := rows.Scan(&facet, &bucket.Value, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if facet == "service" {
+			facets.Services = append(facets.Services, bucket)
+		} else {
+			facets.Statuses = append(facets.Statuses, bucket)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
 	query, args, err := sq.
-		Select("service_name", "sub_price", "user_id", "started_at", "ended_at").
+		Select("COALESCE(MIN(sub_price), 0)", "COALESCE(MAX(sub_price), 0)", "COALESCE(ROUND(AVG(sub_price)), 0)::int").
 		From("subscriptions").
 		Where(sq.Eq{"user_id": userID}).
 		PlaceholderFormat(sq.Dollar).
@@ -189,34 +1129,129 @@ func (s *Storage) GetUserSubs(ctx context.Context, userID uuid.UUID) ([]*domain.
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	rows, err := s.DB.Query(ctx, query, args...)
+	err = s.DB.QueryRow(ctx, query, args...).Scan(&facets.Price.Min, &facets.Price.Max, &facets.Price.Avg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return facets, nil
+}
+
+// statsQuery summarizes the subscriptions of user $1 as of $2.
+const statsQuery = `
+WITH s AS (
+    SELECT service_name,
+           sub_price,
+           CASE WHEN billing_period = 'yearly' THEN sub_price / 12.0 ELSE sub_price END AS monthly_price,
+           started_at <= $2 AND (ended_at IS NULL OR ended_at > $2) AS active
+    FROM subscriptions
+    WHERE user_id = $1
+)
+SELECT COUNT(*),
+       COUNT(*) FILTER (WHERE active),
+       COALESCE(ROUND(SUM(monthly_price) FILTER (WHERE active)), 0)::int,
+       COALESCE(ROUND(AVG(sub_price)), 0)::int,
+       (SELECT service_name FROM s WHERE active ORDER BY monthly_price DESC, service_name LIMIT 1)
+FROM s`
+
+func (s *Storage) Stats(ctx context.Context, userID uuid.UUID, now time.Time) (*domain.SubStats, error) {
+	const op = "storage.storage.Stats"
+
+	var stats domain.SubStats
+	err := s.DB.QueryRow(ctx, statsQuery, userID, now).Scan(
+		&stats.Total,
+		&stats.Active,
+		&stats.MonthlySpend,
+		&stats.AvgPrice,
+		&stats.MostExpensiveService,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &stats, nil
+}
+
+const servicePriceStatsQuery = `
+WITH s AS (
+    SELECT CASE WHEN billing_period = 'yearly' THEN sub_price / 12.0 ELSE sub_price END AS monthly_price
+    FROM subscriptions
+    WHERE service_name = $1
+      AND currency = $2
+      AND started_at <= $3 AND (ended_at IS NULL OR ended_at > $3)
+)
+SELECT COUNT(*),
+       COALESCE(ROUND(MIN(monthly_price)), 0)::int,
+       COALESCE(ROUND(MAX(monthly_price)), 0)::int,
+       COALESCE(ROUND(AVG(monthly_price)), 0)::int,
+       COALESCE(ROUND((percentile_cont(0.5) WITHIN GROUP (ORDER BY monthly_price))::numeric), 0)::int
+FROM s`
+
+// The month series is built in SQL so months without subscriptions are
+// still listed, with a zero count.
+const activeCountsQuery = `
+SELECT to_char(m, 'MM-YYYY'), COUNT(s.id)::int
+FROM generate_series($1::timestamp, $2::timestamp, interval '1 month') AS m
+LEFT JOIN subscriptions s
+       ON s.started_at < m + interval '1 month'
+      AND (s.ended_at IS NULL OR s.ended_at >= m)
+GROUP BY m
+ORDER BY m`
+
+// ActiveCounts counts, for every month from the month starting at from to
+// the one starting at to, the subscriptions of all users active in it at
+// all.
+func (s *Storage) ActiveCounts(ctx context.Context, from, to time.Time) ([]domain.MonthCount, error) {
+	const op = "storage.storage.ActiveCounts"
+
+	rows, err := s.DB.Query(ctx, activeCountsQuery, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	var userSubs []*domain.UserSub
+	counts := []domain.MonthCount{}
 
 	for rows.Next() {
-		var userSub domain.UserSub
-		if err := rows.Scan(&userSub.ServiceName, &userSub.ServicePrice, &userSub.UserID, &userSub.StartedAt, &userSub.EndedAt); err != nil {
+		var count domain.MonthCount
+		if err := rows.Scan(&count.Month, &count.Count); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		userSubs = append(userSubs, &userSub)
+		counts = append(counts, count)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return userSubs, nil
+	return counts, nil
+}
+
+// ServicePriceStats aggregates the prices of the subscriptions to service in
+// currency that are active at now, across all users.
+func (s *Storage) ServicePriceStats(ctx context.Context, service, currency string, now time.Time) (*domain.ServicePriceStats, error) {
+	const op = "storage.storage.ServicePriceStats"
+
+	stats := domain.ServicePriceStats{ServiceName: service, Currency: currency}
+	err := s.DB.QueryRow(ctx, servicePriceStatsQuery, service, currency, now).Scan(
+		&stats.Count,
+		&stats.Min,
+		&stats.Max,
+		&stats.Avg,
+		&stats.Median,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &stats, nil
 }
 
 func (s *Storage) GetUserSub(ctx context.Context, subID uuid.UUID) (*domain.UserSub, error) {
 	const op = "storage.storage.GetUserSub"
 
 	query, args, err := sq.
-		Select("service_name", "sub_price", "user_id", "started_at", "ended_at").
+		Select(subColumns...).
 		From("subscriptions").
 		Where(sq.Eq{"id": subID}).
 		PlaceholderFormat(sq.Dollar).
@@ -226,48 +1261,260 @@ func (s *Storage) GetUserSub(ctx context.Context, subID uuid.UUID) (*domain.User
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var userSub domain.UserSub
+	userSub, err := scanSub(s.DB.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, domain.ErrSubNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	err = s.DB.QueryRow(ctx, query, args...).Scan(
-		&userSub.ServiceName,
-		&userSub.ServicePrice,
-		&userSub.UserID,
-		&userSub.StartedAt,
-		&userSub.EndedAt,
-	)
+	return userSub, nil
+}
+
+const neighborsQuery = `
+WITH s AS (
+    SELECT id,
+           LAG(id) OVER w AS prev_id,
+           LEAD(id) OVER w AS next_id
+    FROM subscriptions
+    WHERE user_id = (SELECT user_id FROM subscriptions WHERE id = $1)
+    WINDOW w AS (ORDER BY started_at, id)
+)
+SELECT prev_id, next_id FROM s WHERE id = $1`
 
+// dedupQuery ends every subscription active at $1 (started and not yet
+// ended) except the most recent one per (user_id, service_name), returning
+// each ended row with the id it was merged into. Subscriptions starting
+// after $1 are left alone, as ending them at $1 would end them before they
+// start.
+const dedupQuery = `
+WITH ranked AS (
+    SELECT id,
+           FIRST_VALUE(id) OVER w AS kept_id,
+           ROW_NUMBER() OVER w AS rn
+    FROM subscriptions
+    WHERE started_at <= $1 AND (ended_at IS NULL OR ended_at > $1)
+    WINDOW w AS (PARTITION BY user_id, service_name ORDER BY started_at DESC, id DESC)
+)
+UPDATE subscriptions s
+SET ended_at = $1, ended_at_backfilled = false
+FROM ranked r
+WHERE s.id = r.id AND r.rn > 1
+RETURNING s.user_id, s.service_name, r.kept_id, s.id`
+
+// DeduplicateSubs ends duplicated active subscriptions in one transaction
+// and reports them grouped by the subscription kept.
+func (s *Storage) DeduplicateSubs(ctx context.Context, now time.Time) (*domain.DedupReport, error) {
+	const op = "storage.storage.DeduplicateSubs"
+
+	report := &domain.DedupReport{Groups: []domain.DedupGroup{}}
+
+	err := pgx.BeginFunc(ctx, s.DB, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, dedupQuery, now)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		groups := make(map[uuid.UUID]int)
+		for rows.Next() {
+			var (
+				group   domain.DedupGroup
+				endedID uuid.UUID
+			)
+			if err := rows.Scan(&group.UserID, &group.ServiceName, &group.KeptID, &endedID); err != nil {
+				return err
+			}
+
+			i, ok := groups[group.KeptID]
+			if !ok {
+				i = len(report.Groups)
+				groups[group.KeptID] = i
+				report.Groups = append(report.Groups, group)
+			}
+			report.Groups[i].EndedIDs = append(report.Groups[i].EndedIDs, endedID)
+			report.Ended++
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return report, nil
+}
+
+func (s *Storage) SubNeighbors(ctx context.Context, subID uuid.UUID) (*domain.SubNeighbors, error) {
+	const op = "storage.storage.SubNeighbors"
+
+	var neighbors domain.SubNeighbors
+	err := s.DB.QueryRow(ctx, neighborsQuery, subID).Scan(&neighbors.PrevID, &neighbors.NextID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, domain.ErrSubNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &neighbors, nil
+}
+
+// billedMonthJoin pairs every subscription with the months of the series
+// it bills in under the whole-month cost model: months it was active on some
+// day of, or the month a zero-length subscription starts in, and for yearly
+// subscriptions only their anniversary month.
+const billedMonthJoin = `generate_series(?::timestamp, ?::timestamp, interval '1 month') AS m
+   ON s.started_at < m + interval '1 month'
+  AND (s.ended_at IS NULL OR s.ended_at > m OR (s.ended_at = s.started_at AND s.ended_at >= m))
+  AND (s.billing_period <> 'yearly' OR EXTRACT(MONTH FROM s.started_at) = EXTRACT(MONTH FROM m))`
+
+// GetTotalCosts sums, per user over userIDs and in one grouped query, what
+// the subscriptions bill in every month from the month starting at from to
+// the one starting at to, charged the way GetTotalCost charges them.
+// An empty serviceNames matches every service. Users without billed
+// subscriptions are absent from the result.
+func (s *Storage) GetTotalCosts(ctx context.Context, userIDs []uuid.UUID, serviceNames []string, from, to time.Time) (map[uuid.UUID]int, error) {
+	const op = "storage.storage.GetTotalCosts"
+
+	builder := sq.
+		Select("s.user_id", "SUM(s.sub_price)").
+		From("subscriptions AS s").
+		Join(billedMonthJoin, from, to).
+		Where(sq.Expr("s.user_id = ANY(?)", userIDs)).
+		GroupBy("s.user_id").
+		PlaceholderFormat(sq.Dollar)
+
+	if len(serviceNames) > 0 {
+		builder = builder.Where(sq.Expr("s.service_name = ANY(?)", serviceNames))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	totals := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var (
+			userID uuid.UUID
+			total  int
+		)
+		if err := rows.Scan(&userID, &total); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
+		totals[userID] = total
+	}
+
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &userSub, nil
+	return totals, nil
 }
 
-func (s *Storage) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, from, to time.Time) (int, error) {
-	const op = "storage.storage.GetTotalCost"
+func (s *Storage) GetReminderPreference(ctx context.Context, userID uuid.UUID) (*domain.ReminderPreference, error) {
+	const op = "storage.storage.GetReminderPreference"
 
 	query, args, err := sq.
-		Select("COALESCE(SUM(sub_price), 0)").
-		From("subscriptions").
+		Select("user_id", "lead_time_days", "channel").
+		From("reminder_preferences").
 		Where(sq.Eq{"user_id": userID}).
-		Where(sq.Eq{"service_name": serviceName}).
-		Where(sq.GtOrEq{"started_at": from}).
-		Where(sq.LtOrEq{"started_at": to}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var total int
-	err = s.DB.QueryRow(ctx, query, args...).Scan(&total)
+	var pref domain.ReminderPreference
+	err = s.DB.QueryRow(ctx, query, args...).Scan(&pref.UserID, &pref.LeadTimeDays, &pref.Channel)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, domain.ErrReminderPreferenceNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return total, nil
+	return &pref, nil
+}
+
+func (s *Storage) SetReminderPreference(ctx context.Context, pref domain.ReminderPreference) error {
+	const op = "storage.storage.SetReminderPreference"
+
+	query, args, err := sq.
+		Insert("reminder_preferences").
+		Columns("user_id", "lead_time_days", "channel").
+		Values(pref.UserID, pref.LeadTimeDays, pref.Channel).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET lead_time_days = EXCLUDED.lead_time_days, channel = EXCLUDED.channel").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DueReminders returns the reminders falling due after from and no later
+// than to: for every user with reminder preferences, the subscriptions
+// whose ended_at minus the user's lead time lies in that window.
+// Auto-renewing subscriptions don't expire and get no reminder.
+func (s *Storage) DueReminders(ctx context.Context, from, to time.Time) ([]domain.Reminder, error) {
+	const op = "storage.storage.DueReminders"
+
+	dueAt := "ended_at - make_interval(days => p.lead_time_days)"
+
+	query, args, err := sq.
+		Select(subColumns...).
+		Columns("p.lead_time_days", "p.channel").
+		From("subscriptions").
+		Join("reminder_preferences p USING (user_id)").
+		Where(sq.NotEq{"ended_at": nil}).
+		Where(sq.Eq{"auto_renew": false}).
+		Where(sq.Expr(dueAt+" > ?", from)).
+		Where(sq.Expr(dueAt+" <= ?", to)).
+		OrderBy(dueAt, "id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var reminders []domain.Reminder
+	for rows.Next() {
+		var reminder domain.Reminder
+		sub, err := scanSub(rows, &reminder.LeadTimeDays, &reminder.Channel)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		reminder.Sub = *sub
+		reminders = append(reminders, reminder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return reminders, nil
 }
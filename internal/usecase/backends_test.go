@@ -0,0 +1,1124 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"slices"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/events"
+	pgstorage "testovoe/internal/storage"
+	"testovoe/internal/storage/inmemory"
+	"testovoe/internal/validation"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testPostgresURLEnv names the database the shared cases also run against.
+// The Postgres backend is skipped when it is unset; the database is migrated
+// and truncated before every case.
+const testPostgresURLEnv = "TEST_POSTGRES_URL"
+
+type backend struct {
+	name string
+	open func(t *testing.T) Storage
+}
+
+func backends() []backend {
+	return []backend{
+		{name: "memory", open: func(*testing.T) Storage { return inmemory.New() }},
+		{name: "postgres", open: openPostgres},
+	}
+}
+
+func openPostgres(t *testing.T) Storage {
+	t.Helper()
+
+	url := os.Getenv(testPostgresURLEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testPostgresURLEnv)
+	}
+	ctx := context.Background()
+
+	if err := pgstorage.Migrate(ctx, url, "up"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db, err := pgstorage.New(ctx, url, time.Second)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	const truncate = `TRUNCATE subscriptions, subscription_tags, subscription_shares,
+		reminder_preferences, price_changes CASCADE`
+	if _, err := db.DB.Exec(ctx, truncate); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	return db
+}
+
+// backendCases run unchanged against every storage backend, so the two
+// implementations cannot drift apart.
+var backendCases = []struct {
+	name string
+	run  func(t *testing.T, u *UseCase, db Storage)
+}{
+	{name: "update, price history and delete", run: testBackendLifecycle},
+	{name: "list, count and page", run: testBackendList},
+	{name: "total costs", run: testBackendTotals},
+	{name: "facets and stats", run: testBackendFacets},
+	{name: "deduplicate", run: testBackendDedup},
+	{name: "bulk price update and delete", run: testBackendBulk},
+	{name: "top by price", run: testBackendTop},
+	{name: "payment method", run: testBackendPaymentMethod},
+	{name: "auto renew", run: testBackendAutoRenew},
+	{name: "tag filters", run: testBackendTags},
+	{name: "stream", run: testBackendStream},
+	{name: "several services", run: testBackendServices},
+	{name: "neighbors", run: testBackendNeighbors},
+	{name: "grouped by user", run: testBackendSubsByUser},
+	{name: "sharing", run: testBackendShares},
+	{name: "created range", run: testBackendCreatedRange},
+	{name: "stable pages", run: testBackendStablePages},
+	{name: "stats", run: testBackendStats},
+	{name: "ended in a period", run: testBackendEndedSubs},
+	{name: "reactivate", run: testBackendReactivate},
+	{name: "bulk prices", run: testBackendUpdatePrices},
+	{name: "currencies", run: testBackendCurrencies},
+	{name: "rename service", run: testBackendRenameService},
+	{name: "exclude free", run: testBackendExcludeFree},
+	{name: "service price stats", run: testBackendServicePriceStats},
+	{name: "renew expiring", run: testBackendRenewExpiring},
+	{name: "active counts", run: testBackendActiveCounts},
+	{name: "weighted active counts", run: testBackendWeightedActiveCounts},
+}
+
+func TestBackends(t *testing.T) {
+	for _, b := range backends() {
+		for _, tc := range backendCases {
+			t.Run(b.name+"/"+tc.name, func(t *testing.T) {
+				db := b.open(t)
+				tc.run(t, New(discardLogger(), db, testConfig(), events.Noop{}), db)
+			})
+		}
+	}
+}
+
+func testBackendLifecycle(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	sub := seedSub(t, db, domain.UserSub{UserID: uuid.New(), ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+
+	affected, err := u.UpdateSub(ctx, domain.SubUpdate{ID: sub.ID, UserID: sub.UserID, ServiceName: "Netflix", ServicePrice: 150})
+	if err != nil || affected != 1 {
+		t.Fatalf("UpdateSub = %d, %v, want 1", affected, err)
+	}
+
+	got, err := u.GetUserSub(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetUserSub: %v", err)
+	}
+	if got.ServicePrice != 150 || !got.StartedAt.Equal(sub.StartedAt) || got.EndedAt != nil || got.Currency != "RUB" {
+		t.Errorf("updated = %+v, want price 150 and the rest unchanged", got)
+	}
+
+	history, err := u.PriceHistory(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("PriceHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].OldPrice != 100 || history[0].NewPrice != 150 {
+		t.Errorf("history = %+v, want one change 100 -> 150", history)
+	}
+
+	if affected, err := u.DeleteSub(ctx, sub.ID, sub.UserID); err != nil || affected != 1 {
+		t.Fatalf("DeleteSub = %d, %v, want 1", affected, err)
+	}
+	if _, err := u.GetUserSub(ctx, sub.ID); !errors.Is(err, domain.ErrSubNotFound) {
+		t.Errorf("GetUserSub after delete = %v, want ErrSubNotFound", err)
+	}
+	if affected, err := u.DeleteSub(ctx, sub.ID, sub.UserID); err != nil || affected != 0 {
+		t.Errorf("second DeleteSub = %d, %v, want 0", affected, err)
+	}
+}
+
+func testBackendList(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+
+	jan := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 200, StartedAt: date(2025, 2, 1)})
+	mar := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 300, StartedAt: date(2025, 3, 1)})
+	seedSub(t, db, domain.UserSub{UserID: uuid.New(), ServicePrice: 400, StartedAt: date(2025, 1, 1)})
+
+	filter := domain.SubFilter{UserID: &alice, ServiceName: "Netflix"}
+	ascending := domain.Sort{Column: domain.SortStartedAt}
+
+	subs, err := u.ListSubs(ctx, filter, domain.Page{Limit: 10, Sort: ascending})
+	if err != nil {
+		t.Fatalf("ListSubs: %v", err)
+	}
+	if ids := subIDs(subs); !slices.Equal(ids, []uuid.UUID{jan.ID, mar.ID}) {
+		t.Errorf("ListSubs = %v, want [%s %s]", ids, jan.ID, mar.ID)
+	}
+
+	subs, err = u.ListSubs(ctx, filter, domain.Page{Limit: 1, Offset: 1, Sort: ascending})
+	if err != nil {
+		t.Fatalf("ListSubs page: %v", err)
+	}
+	if ids := subIDs(subs); !slices.Equal(ids, []uuid.UUID{mar.ID}) {
+		t.Errorf("second page = %v, want [%s]", ids, mar.ID)
+	}
+
+	count, err := u.CountSubs(ctx, domain.SubFilter{UserID: &alice})
+	if err != nil || count != 3 {
+		t.Errorf("CountSubs = %d, %v, want 3", count, err)
+	}
+}
+
+func testBackendTotals(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	feb := date(2025, 2, 1)
+
+	seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2024, 12, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 50, StartedAt: date(2025, 2, 1)})
+	seedSub(t, db, domain.UserSub{UserID: bob, ServicePrice: 300, StartedAt: date(2024, 1, 1), EndedAt: &feb})
+	seedSub(t, db, domain.UserSub{UserID: bob, ServicePrice: 1200, StartedAt: date(2024, 3, 1), BillingPeriod: domain.BillingYearly})
+
+	totals, err := u.GetTotalCosts(ctx, []uuid.UUID{alice, bob, carol}, nil, "01-2025", "03-2025")
+	if err != nil {
+		t.Fatalf("GetTotalCosts: %v", err)
+	}
+
+	want := map[uuid.UUID]int{alice: 400, bob: 1500, carol: 0}
+	for userID, total := range want {
+		if totals[userID] != total {
+			t.Errorf("GetTotalCosts[%s] = %d, want %d", userID, totals[userID], total)
+		}
+		single, err := u.GetTotalCost(ctx, userID, nil, "01-2025", "03-2025", domain.CostOptions{})
+		if err != nil {
+			t.Fatalf("GetTotalCost: %v", err)
+		}
+		if single != total {
+			t.Errorf("GetTotalCost(%s) = %d, want %d", userID, single, total)
+		}
+	}
+}
+
+func testBackendFacets(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	ended := now.AddDate(0, -1, 0)
+
+	seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: now.AddDate(0, -2, 0)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 200, StartedAt: now.AddDate(0, -3, 0), EndedAt: &ended})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 300, StartedAt: now.AddDate(0, 1, 0)})
+
+	facets, err := u.Facets(ctx, alice)
+	if err != nil {
+		t.Fatalf("Facets: %v", err)
+	}
+	wantServices := []domain.FacetBucket{{Value: "Netflix", Count: 1}, {Value: "Spotify", Count: 2}}
+	if !slices.Equal(facets.Services, wantServices) {
+		t.Errorf("services = %+v, want %+v", facets.Services, wantServices)
+	}
+	wantStatuses := []domain.FacetBucket{
+		{Value: domain.StatusActive, Count: 1},
+		{Value: domain.StatusEnded, Count: 1},
+		{Value: domain.StatusUpcoming, Count: 1},
+	}
+	if !slices.Equal(facets.Statuses, wantStatuses) {
+		t.Errorf("statuses = %+v, want %+v", facets.Statuses, wantStatuses)
+	}
+
+	stats, err := u.Stats(ctx, alice)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.Total != 3 || stats.Active != 1 || stats.MonthlySpend != 100 {
+		t.Errorf("stats = %+v, want 3 total, 1 active spending 100", stats)
+	}
+}
+
+func testBackendDedup(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+
+	older := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: now.AddDate(0, -2, 0)})
+	newest := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: now.AddDate(0, -1, 0)})
+	future := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: now.AddDate(0, 1, 0)})
+
+	report, err := u.DeduplicateSubs(ctx)
+	if err != nil {
+		t.Fatalf("DeduplicateSubs: %v", err)
+	}
+	if report.Ended != 1 || len(report.Groups) != 1 || report.Groups[0].KeptID != newest.ID {
+		t.Fatalf("report = %+v, want %s kept and one ended", report, newest.ID)
+	}
+
+	for _, tt := range []struct {
+		sub   domain.UserSub
+		ended bool
+	}{
+		{sub: older, ended: true},
+		{sub: newest},
+		{sub: future},
+	} {
+		stored, err := u.GetUserSub(ctx, tt.sub.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (stored.EndedAt != nil) != tt.ended {
+			t.Errorf("%s ended_at = %v, want ended %v", stored.StartedAt.Format(time.DateOnly), stored.EndedAt, tt.ended)
+		}
+	}
+}
+
+func testBackendBulk(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+
+	first := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	second := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 200, StartedAt: date(2025, 2, 1)})
+	spotify := seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 300, StartedAt: date(2025, 1, 1)})
+
+	filter := domain.SubFilter{UserID: &alice, ServiceName: "Netflix"}
+	if affected, err := u.UpdatePrices(ctx, filter, 500); err != nil || affected != 2 {
+		t.Fatalf("UpdatePrices = %d, %v, want 2", affected, err)
+	}
+	for _, sub := range []domain.UserSub{first, second} {
+		history, err := u.PriceHistory(ctx, sub.ID)
+		if err != nil {
+			t.Fatalf("PriceHistory: %v", err)
+		}
+		if len(history) != 1 || history[0].OldPrice != sub.ServicePrice || history[0].NewPrice != 500 {
+			t.Errorf("history of %d = %+v, want one change to 500", sub.ServicePrice, history)
+		}
+	}
+
+	if affected, err := u.DeleteSubsByFilter(ctx, filter); err != nil || affected != 2 {
+		t.Fatalf("DeleteSubsByFilter = %d, %v, want 2", affected, err)
+	}
+	subs, err := u.ListSubs(ctx, domain.SubFilter{UserID: &alice}, domain.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListSubs: %v", err)
+	}
+	if ids := subIDs(subs); !slices.Equal(ids, []uuid.UUID{spotify.ID}) {
+		t.Errorf("remaining = %v, want [%s]", ids, spotify.ID)
+	}
+}
+
+func testBackendTop(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+
+	cheap := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	dear := seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 900, StartedAt: date(2025, 1, 1)})
+	tieA := seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "YouTube", ServicePrice: 500, StartedAt: date(2025, 1, 1)})
+	tieB := seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Kinopoisk", ServicePrice: 500, StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: uuid.New(), ServicePrice: 5000, StartedAt: date(2025, 1, 1)})
+
+	ties := []uuid.UUID{tieA.ID, tieB.ID}
+	slices.SortFunc(ties, compareUUIDs)
+
+	for _, tt := range []struct {
+		limit uint64
+		want  []uuid.UUID
+	}{
+		{limit: 10, want: []uuid.UUID{dear.ID, ties[0], ties[1], cheap.ID}},
+		{limit: 2, want: []uuid.UUID{dear.ID, ties[0]}},
+	} {
+		subs, err := u.TopSubs(ctx, alice, tt.limit)
+		if err != nil {
+			t.Fatalf("TopSubs: %v", err)
+		}
+		if ids := subIDs(subs); !slices.Equal(ids, tt.want) {
+			t.Errorf("TopSubs(limit %d) = %v, want %v", tt.limit, ids, tt.want)
+		}
+	}
+}
+
+func testBackendPaymentMethod(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+	visa, mastercard := "visa-1234", "mc-9999"
+
+	paid := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1), PaymentMethod: &visa})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 200, StartedAt: date(2025, 1, 1), PaymentMethod: &mastercard})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "YouTube", ServicePrice: 300, StartedAt: date(2025, 1, 1)})
+
+	subs, err := u.ListSubs(ctx, domain.SubFilter{UserID: &alice, PaymentMethod: visa}, domain.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListSubs: %v", err)
+	}
+	if ids := subIDs(subs); !slices.Equal(ids, []uuid.UUID{paid.ID}) {
+		t.Fatalf("by payment method = %v, want [%s]", ids, paid.ID)
+	}
+	if got := subs[0].PaymentMethod; got == nil || *got != visa {
+		t.Errorf("payment_method = %v, want %q", got, visa)
+	}
+
+	_, err = u.UpdateSub(ctx, domain.SubUpdate{ID: paid.ID, UserID: alice, ServiceName: "Netflix", ServicePrice: 100, PaymentMethod: domain.Some[*string](nil)})
+	if err != nil {
+		t.Fatalf("UpdateSub: %v", err)
+	}
+	got, err := u.GetUserSub(ctx, paid.ID)
+	if err != nil {
+		t.Fatalf("GetUserSub: %v", err)
+	}
+	if got.PaymentMethod != nil {
+		t.Errorf("payment_method after clearing = %q, want null", *got.PaymentMethod)
+	}
+}
+
+func testBackendAutoRenew(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	sub := seedSub(t, db, domain.UserSub{UserID: uuid.New(), ServicePrice: 100, StartedAt: date(2025, 1, 1), AutoRenew: true})
+
+	got, err := u.GetUserSub(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetUserSub: %v", err)
+	}
+	if !got.AutoRenew {
+		t.Fatal("auto_renew was not stored")
+	}
+
+	_, err = u.UpdateSub(ctx, domain.SubUpdate{ID: sub.ID, UserID: sub.UserID, ServiceName: "Netflix", ServicePrice: 100, AutoRenew: domain.Some(false)})
+	if err != nil {
+		t.Fatalf("UpdateSub: %v", err)
+	}
+	if got, err = u.GetUserSub(ctx, sub.ID); err != nil {
+		t.Fatalf("GetUserSub: %v", err)
+	}
+	if got.AutoRenew {
+		t.Error("auto_renew is still on after turning it off")
+	}
+}
+
+func testBackendTags(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+
+	both := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	work := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 2, 1)})
+	streaming := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 3, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 4, 1)})
+
+	for sub, tags := range map[uuid.UUID][]string{both.ID: {"work", "streaming"}, work.ID: {"work"}, streaming.ID: {"streaming"}} {
+		if _, err := u.AddTags(ctx, sub, tags); err != nil {
+			t.Fatalf("AddTags: %v", err)
+		}
+	}
+
+	list := func(mode domain.TagMode) []uuid.UUID {
+		t.Helper()
+
+		filter := domain.SubFilter{UserID: &alice, Tags: []string{"work", "streaming"}, TagMode: mode}
+		subs, err := u.ListSubs(ctx, filter, domain.Page{Limit: 10, Sort: domain.Sort{Column: domain.SortStartedAt}})
+		if err != nil {
+			t.Fatalf("ListSubs: %v", err)
+		}
+
+		return subIDs(subs)
+	}
+
+	if got, want := list(domain.TagModeAny), []uuid.UUID{both.ID, work.ID, streaming.ID}; !slices.Equal(got, want) {
+		t.Errorf("any = %v, want %v", got, want)
+	}
+	if got, want := list(domain.TagModeAll), []uuid.UUID{both.ID}; !slices.Equal(got, want) {
+		t.Errorf("all = %v, want %v", got, want)
+	}
+
+	if _, err := u.RemoveTag(ctx, both.ID, "streaming"); err != nil {
+		t.Fatalf("RemoveTag: %v", err)
+	}
+	if got := list(domain.TagModeAll); len(got) != 0 {
+		t.Errorf("all after removing a tag = %v, want none", got)
+	}
+}
+
+func testBackendStream(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+
+	const rows = 25
+	for i := range rows {
+		seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2024, 1, 1).AddDate(0, 0, rows-i)})
+	}
+	filter := domain.SubFilter{UserID: &alice}
+
+	var starts []time.Time
+	err := u.StreamSubs(ctx, filter, func(sub *domain.UserSub) error {
+		starts = append(starts, sub.StartedAt)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamSubs: %v", err)
+	}
+	if len(starts) != rows {
+		t.Fatalf("callback ran %d times, want once per row (%d)", len(starts), rows)
+	}
+	if !slices.IsSortedFunc(starts, time.Time.Compare) {
+		t.Errorf("rows are not in started_at order: %v", starts)
+	}
+
+	// An error from the callback stops the stream at that row.
+	errStop := errors.New("stop")
+	calls := 0
+	err = u.StreamSubs(ctx, filter, func(*domain.UserSub) error {
+		calls++
+		if calls == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) || calls != 3 {
+		t.Errorf("StreamSubs = %v after %d calls, want the callback error after 3", err, calls)
+	}
+}
+
+func testBackendServices(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+
+	netflix := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	spotify := seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 100, StartedAt: date(2025, 2, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "YouTube", ServicePrice: 100, StartedAt: date(2025, 3, 1)})
+	seedSub(t, db, domain.UserSub{UserID: uuid.New(), ServiceName: "Spotify", ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+
+	filter := domain.SubFilter{UserID: &alice, ServiceNames: []string{"Netflix", "Spotify"}}
+	subs, err := u.ListSubs(ctx, filter, domain.Page{Limit: 10, Sort: domain.Sort{Column: domain.SortStartedAt}})
+	if err != nil {
+		t.Fatalf("ListSubs: %v", err)
+	}
+	if got, want := subIDs(subs), []uuid.UUID{netflix.ID, spotify.ID}; !slices.Equal(got, want) {
+		t.Errorf("ListSubs = %v, want %v", got, want)
+	}
+}
+
+func testBackendNeighbors(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+
+	first := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: uuid.New(), ServicePrice: 100, StartedAt: date(2025, 2, 1)})
+	middle := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 3, 1)})
+	last := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 5, 1)})
+
+	for _, tt := range []struct {
+		name       string
+		sub        uuid.UUID
+		prev, next *uuid.UUID
+	}{
+		{name: "first", sub: first.ID, next: &middle.ID},
+		{name: "middle", sub: middle.ID, prev: &first.ID, next: &last.ID},
+		{name: "last", sub: last.ID, prev: &middle.ID},
+	} {
+		neighbors, err := u.SubNeighbors(ctx, tt.sub)
+		if err != nil {
+			t.Fatalf("SubNeighbors(%s): %v", tt.name, err)
+		}
+		if !equalIDs(neighbors.PrevID, tt.prev) || !equalIDs(neighbors.NextID, tt.next) {
+			t.Errorf("%s: neighbors = %v, %v; want %v, %v", tt.name, neighbors.PrevID, neighbors.NextID, tt.prev, tt.next)
+		}
+	}
+}
+
+func equalIDs(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func testBackendSubsByUser(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+
+	aliceLate := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 3, 1)})
+	aliceEarly := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	bobs := seedSub(t, db, domain.UserSub{UserID: bob, ServicePrice: 100, StartedAt: date(2025, 2, 1)})
+	seedSub(t, db, domain.UserSub{UserID: bob, ServiceName: "Spotify", ServicePrice: 100, StartedAt: date(2025, 2, 1)})
+
+	grouped, err := u.SubsByUser(ctx, domain.SubFilter{ServiceName: "Netflix"})
+	if err != nil {
+		t.Fatalf("SubsByUser: %v", err)
+	}
+	if len(grouped) != 2 {
+		t.Fatalf("got %d users, want 2", len(grouped))
+	}
+	if got, want := subIDs(grouped[alice]), []uuid.UUID{aliceEarly.ID, aliceLate.ID}; !slices.Equal(got, want) {
+		t.Errorf("alice = %v, want %v", got, want)
+	}
+	if got, want := subIDs(grouped[bob]), []uuid.UUID{bobs.ID}; !slices.Equal(got, want) {
+		t.Errorf("bob = %v, want %v", got, want)
+	}
+}
+
+func testBackendShares(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	shared := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	own := seedSub(t, db, domain.UserSub{UserID: bob, ServicePrice: 100, StartedAt: date(2025, 2, 1)})
+
+	if err := u.ShareSub(ctx, shared.ID, bob); err != nil {
+		t.Fatalf("ShareSub: %v", err)
+	}
+	var fieldErrs validation.Errors
+	if err := u.ShareSub(ctx, shared.ID, alice); !errors.As(err, &fieldErrs) {
+		t.Errorf("sharing with the owner: err = %v, want a validation error", err)
+	}
+
+	list := func(userID uuid.UUID, includeShared bool) []uuid.UUID {
+		t.Helper()
+
+		filter := domain.SubFilter{UserID: &userID, IncludeShared: includeShared}
+		subs, err := u.ListSubs(ctx, filter, domain.Page{Limit: 10, Sort: domain.Sort{Column: domain.SortStartedAt}})
+		if err != nil {
+			t.Fatalf("ListSubs: %v", err)
+		}
+
+		return subIDs(subs)
+	}
+
+	if got, want := list(bob, true), []uuid.UUID{shared.ID, own.ID}; !slices.Equal(got, want) {
+		t.Errorf("bob with shared = %v, want %v", got, want)
+	}
+	if got, want := list(bob, false), []uuid.UUID{own.ID}; !slices.Equal(got, want) {
+		t.Errorf("bob without shared = %v, want %v", got, want)
+	}
+	if got := list(carol, true); len(got) != 0 {
+		t.Errorf("carol = %v, want nothing", got)
+	}
+
+	if affected, err := u.UnshareSub(ctx, shared.ID, bob); err != nil || affected != 1 {
+		t.Fatalf("UnshareSub = %d, %v; want 1", affected, err)
+	}
+	if got, want := list(bob, true), []uuid.UUID{own.ID}; !slices.Equal(got, want) {
+		t.Errorf("bob after unsharing = %v, want %v", got, want)
+	}
+}
+
+func testBackendCreatedRange(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	// created_at is stamped by the backend, so read it back after each insert
+	// and keep the inserts far enough apart to tell them apart.
+	var created []domain.UserSub
+	for range 4 {
+		sub := seedSub(t, db, domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+		got, err := u.GetUserSub(ctx, sub.ID)
+		if err != nil {
+			t.Fatalf("GetUserSub: %v", err)
+		}
+		created = append(created, *got)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	from, to := created[1].CreatedAt, created[2].CreatedAt
+	filter := domain.SubFilter{UserID: &userID, CreatedFrom: &from, CreatedTo: &to}
+	subs, err := u.ListSubs(ctx, filter, domain.Page{Limit: 10, Sort: domain.Sort{Column: domain.SortStartedAt}})
+	if err != nil {
+		t.Fatalf("ListSubs: %v", err)
+	}
+
+	if got, want := subIDs(subs), []uuid.UUID{created[1].ID, created[2].ID}; !slices.Equal(got, want) {
+		t.Errorf("created in [%v, %v] = %v, want both bounds %v", from, to, got, want)
+	}
+}
+
+func testBackendStablePages(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	var want []uuid.UUID
+	for range 5 {
+		want = append(want, seedSub(t, db, domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: date(2025, 1, 1)}).ID)
+	}
+	// started_at DESC ties on every row, so id DESC decides.
+	slices.SortFunc(want, func(a, b uuid.UUID) int { return compareUUIDs(b, a) })
+
+	filter := domain.SubFilter{UserID: &userID}
+	sort := domain.Sort{Column: domain.SortStartedAt, Desc: true}
+
+	for range 2 {
+		var got []uuid.UUID
+		for offset := uint64(0); offset < uint64(len(want)); offset += 2 {
+			subs, err := u.ListSubs(ctx, filter, domain.Page{Limit: 2, Offset: offset, Sort: sort})
+			if err != nil {
+				t.Fatalf("ListSubs: %v", err)
+			}
+			got = append(got, subIDs(subs)...)
+		}
+
+		if !slices.Equal(got, want) {
+			t.Fatalf("pages = %v, want %v", got, want)
+		}
+	}
+}
+
+func testBackendStats(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	ended := now.AddDate(0, -1, 0)
+
+	seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 999, StartedAt: now.AddDate(-1, 0, 0)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Adobe", ServicePrice: 24000, BillingPeriod: domain.BillingYearly, StartedAt: now.AddDate(0, -2, 0)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 300, StartedAt: now.AddDate(0, -3, 0), EndedAt: &ended})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Kinopoisk", ServicePrice: 100, StartedAt: now.AddDate(0, 1, 0)})
+
+	stats, err := u.Stats(ctx, alice)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+
+	// Adobe is 24000 a year, so 2000 a month: the most expensive and, with
+	// Netflix's 999, the whole monthly spend. The average is over every
+	// subscription at its own price, 25399/4 rounded half-up.
+	if stats.Total != 4 {
+		t.Errorf("Total = %d, want 4", stats.Total)
+	}
+	if stats.Active != 2 {
+		t.Errorf("Active = %d, want 2", stats.Active)
+	}
+	if stats.MonthlySpend != 2999 {
+		t.Errorf("MonthlySpend = %d, want 2999", stats.MonthlySpend)
+	}
+	if stats.AvgPrice != 6350 {
+		t.Errorf("AvgPrice = %d, want 6350", stats.AvgPrice)
+	}
+	if stats.MostExpensiveService == nil || *stats.MostExpensiveService != "Adobe" {
+		t.Errorf("MostExpensiveService = %v, want Adobe", stats.MostExpensiveService)
+	}
+
+	empty, err := u.Stats(ctx, uuid.New())
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if *empty != (domain.SubStats{}) {
+		t.Errorf("stats of a user without subscriptions = %+v, want zeros", empty)
+	}
+}
+
+func testBackendEndedSubs(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+	ended := func(year int, month time.Month, day int) *time.Time {
+		end := date(year, month, day)
+		return &end
+	}
+
+	seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: ended(2025, 1, 31)})
+	lateMarch := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: ended(2025, 3, 31)})
+	firstFeb := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: ended(2025, 2, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: ended(2025, 4, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1)})
+	bobs := seedSub(t, db, domain.UserSub{UserID: bob, StartedAt: date(2024, 1, 1), EndedAt: ended(2025, 2, 10)})
+
+	subs, err := u.EndedSubs(ctx, &alice, "02-2025", "03-2025")
+	if err != nil {
+		t.Fatalf("EndedSubs: %v", err)
+	}
+	if got, want := subIDs(subs), []uuid.UUID{firstFeb.ID, lateMarch.ID}; !slices.Equal(got, want) {
+		t.Errorf("alice's ended February to March = %v, want %v", got, want)
+	}
+
+	subs, err = u.EndedSubs(ctx, nil, "02-2025", "02-2025")
+	if err != nil {
+		t.Fatalf("EndedSubs: %v", err)
+	}
+	if got, want := subIDs(subs), []uuid.UUID{firstFeb.ID, bobs.ID}; !slices.Equal(got, want) {
+		t.Errorf("everyone's ended in February = %v, want %v", got, want)
+	}
+}
+
+func testBackendReactivate(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	past, future := now.AddDate(0, -1, 0), now.AddDate(0, 1, 0)
+
+	cancelled := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: &past})
+	restarted := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: &past})
+	active := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1)})
+	ending := seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: &future})
+
+	sub, err := u.ReactivateSub(ctx, cancelled.ID, false)
+	if err != nil {
+		t.Fatalf("ReactivateSub: %v", err)
+	}
+	stored, err := u.GetUserSub(ctx, cancelled.ID)
+	if err != nil {
+		t.Fatalf("GetUserSub: %v", err)
+	}
+	if sub.EndedAt != nil || stored.EndedAt != nil || !stored.StartedAt.Equal(cancelled.StartedAt) {
+		t.Errorf("reactivated = %+v, stored %+v; want no end and the original start", sub, stored)
+	}
+
+	before := time.Now().UTC().Add(-time.Second)
+	if _, err := u.ReactivateSub(ctx, restarted.ID, true); err != nil {
+		t.Fatalf("ReactivateSub with reset: %v", err)
+	}
+	stored, err = u.GetUserSub(ctx, restarted.ID)
+	if err != nil {
+		t.Fatalf("GetUserSub: %v", err)
+	}
+	if stored.EndedAt != nil || stored.StartedAt.Before(before) {
+		t.Errorf("restarted = %+v, want no end and a start of about now", stored)
+	}
+
+	for _, tt := range []struct {
+		name string
+		id   uuid.UUID
+		want error
+	}{
+		{name: "active", id: active.ID, want: domain.ErrSubNotCancelled},
+		{name: "ending later", id: ending.ID, want: domain.ErrSubNotCancelled},
+		{name: "already reactivated", id: cancelled.ID, want: domain.ErrSubNotCancelled},
+		{name: "unknown", id: uuid.New(), want: domain.ErrSubNotFound},
+	} {
+		if _, err := u.ReactivateSub(ctx, tt.id, false); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func testBackendUpdatePrices(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+
+	alicesNetflix := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	bobsNetflix := seedSub(t, db, domain.UserSub{UserID: bob, ServicePrice: 150, StartedAt: date(2025, 1, 1)})
+	alicesSpotify := seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 300, StartedAt: date(2025, 1, 1)})
+
+	prices := func() map[uuid.UUID]int {
+		t.Helper()
+
+		out := make(map[uuid.UUID]int)
+		for _, id := range []uuid.UUID{alicesNetflix.ID, bobsNetflix.ID, alicesSpotify.ID} {
+			sub, err := u.GetUserSub(ctx, id)
+			if err != nil {
+				t.Fatalf("GetUserSub: %v", err)
+			}
+			out[id] = sub.ServicePrice
+		}
+
+		return out
+	}
+
+	// Service-wide, across users.
+	if affected, err := u.UpdatePrices(ctx, domain.SubFilter{ServiceName: " Netflix "}, 200); err != nil || affected != 2 {
+		t.Fatalf("UpdatePrices(Netflix) = %d, %v; want 2", affected, err)
+	}
+	want := map[uuid.UUID]int{alicesNetflix.ID: 200, bobsNetflix.ID: 200, alicesSpotify.ID: 300}
+	if got := prices(); !maps.Equal(got, want) {
+		t.Errorf("after the service-wide change prices = %v, want %v", got, want)
+	}
+
+	// Scoped to one user.
+	if affected, err := u.UpdatePrices(ctx, domain.SubFilter{UserID: &bob, ServiceName: "Netflix"}, 250); err != nil || affected != 1 {
+		t.Fatalf("UpdatePrices(bob's Netflix) = %d, %v; want 1", affected, err)
+	}
+	want[bobsNetflix.ID] = 250
+	if got := prices(); !maps.Equal(got, want) {
+		t.Errorf("after bob's change prices = %v, want %v", got, want)
+	}
+
+	if _, err := u.UpdatePrices(ctx, domain.SubFilter{}, 100); !errors.Is(err, domain.ErrEmptyFilter) {
+		t.Errorf("no filter: err = %v, want %v", err, domain.ErrEmptyFilter)
+	}
+	var fieldErrs validation.Errors
+	if _, err := u.UpdatePrices(ctx, domain.SubFilter{ServiceName: "Netflix"}, -1); !errors.As(err, &fieldErrs) {
+		t.Errorf("negative price: err = %v, want a validation error", err)
+	}
+	if got := prices(); !maps.Equal(got, want) {
+		t.Errorf("rejected changes wrote prices = %v, want %v", got, want)
+	}
+}
+
+func testBackendCurrencies(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	seedSub(t, db, domain.UserSub{UserID: alice, Currency: "USD", StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, Currency: "RUB", StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, Currency: "USD", StartedAt: date(2025, 2, 1)})
+	seedSub(t, db, domain.UserSub{UserID: bob, Currency: "EUR", StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: bob, Currency: "RUB", StartedAt: date(2025, 1, 1)})
+
+	for _, tt := range []struct {
+		name   string
+		userID *uuid.UUID
+		want   []string
+	}{
+		{name: "alice", userID: &alice, want: []string{"RUB", "USD"}},
+		{name: "everyone", want: []string{"EUR", "RUB", "USD"}},
+		{name: "carol without subscriptions", userID: &carol, want: []string{}},
+	} {
+		got, err := u.Currencies(ctx, tt.userID)
+		if err != nil {
+			t.Fatalf("Currencies: %v", err)
+		}
+		if got == nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: currencies = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testBackendRenameService(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Netflix", ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Netflx", ServicePrice: 200, StartedAt: date(2025, 1, 1)})
+	seedSub(t, db, domain.UserSub{UserID: bob, ServiceName: "Netflx", ServicePrice: 300, StartedAt: date(2025, 1, 1)})
+	spotify := seedSub(t, db, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 400, StartedAt: date(2025, 1, 1)})
+
+	january := func(userID uuid.UUID) int {
+		t.Helper()
+
+		total, err := u.GetTotalCost(ctx, userID, []string{"Netflix"}, "01-2025", "01-2025", domain.CostOptions{})
+		if err != nil {
+			t.Fatalf("GetTotalCost: %v", err)
+		}
+
+		return total
+	}
+	if got := january(alice); got != 100 {
+		t.Fatalf("alice's Netflix before the rename = %d, want 100", got)
+	}
+
+	renamed, err := u.RenameService(ctx, " Netflx ", "Netflix")
+	if err != nil || renamed != 2 {
+		t.Fatalf("RenameService = %d, %v; want 2", renamed, err)
+	}
+
+	if got := january(alice); got != 300 {
+		t.Errorf("alice's Netflix after the rename = %d, want 300", got)
+	}
+	if got := january(bob); got != 300 {
+		t.Errorf("bob's Netflix after the rename = %d, want 300", got)
+	}
+	if stored, err := u.GetUserSub(ctx, spotify.ID); err != nil || stored.ServiceName != "Spotify" {
+		t.Errorf("Spotify after the rename = %+v, %v; want it untouched", stored, err)
+	}
+
+	var fieldErrs validation.Errors
+	if _, err := u.RenameService(ctx, "Netflix", "Netflix"); !errors.As(err, &fieldErrs) {
+		t.Errorf("renaming to the same name: err = %v, want a validation error", err)
+	}
+	if _, err := u.RenameService(ctx, "", "Netflix"); !errors.As(err, &fieldErrs) {
+		t.Errorf("renaming an empty name: err = %v, want a validation error", err)
+	}
+}
+
+func testBackendExcludeFree(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice := uuid.New()
+
+	trial := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 0, StartedAt: date(2025, 1, 1)})
+	paid := seedSub(t, db, domain.UserSub{UserID: alice, ServicePrice: 1, StartedAt: date(2025, 2, 1)})
+
+	for _, tt := range []struct {
+		excludeFree bool
+		want        []uuid.UUID
+	}{
+		{excludeFree: false, want: []uuid.UUID{trial.ID, paid.ID}},
+		{excludeFree: true, want: []uuid.UUID{paid.ID}},
+	} {
+		filter := domain.SubFilter{UserID: &alice, ExcludeFree: tt.excludeFree}
+		subs, err := u.ListSubs(ctx, filter, domain.Page{Limit: 10, Sort: domain.Sort{Column: domain.SortStartedAt}})
+		if err != nil {
+			t.Fatalf("ListSubs: %v", err)
+		}
+		if got := subIDs(subs); !slices.Equal(got, tt.want) {
+			t.Errorf("exclude_free=%v: subs = %v, want %v", tt.excludeFree, got, tt.want)
+		}
+
+		count, err := u.CountSubs(ctx, filter)
+		if err != nil {
+			t.Fatalf("CountSubs: %v", err)
+		}
+		if count != uint64(len(tt.want)) {
+			t.Errorf("exclude_free=%v: count = %d, want %d", tt.excludeFree, count, len(tt.want))
+		}
+	}
+}
+
+func testBackendServicePriceStats(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	start, ended := now.AddDate(0, -2, 0), now.AddDate(0, -1, 0)
+
+	for _, sub := range []domain.UserSub{
+		{ServicePrice: 100},
+		{ServicePrice: 600},
+		{ServicePrice: 300},
+		{ServicePrice: 12000, BillingPeriod: domain.BillingYearly},
+		{ServicePrice: 50, EndedAt: &ended},
+		{ServicePrice: 10, Currency: "USD"},
+		{ServicePrice: 5, ServiceName: "Spotify"},
+	} {
+		sub.UserID, sub.StartedAt = uuid.New(), start
+		seedSub(t, db, sub)
+	}
+
+	tests := []struct {
+		name     string
+		service  string
+		currency string
+		want     domain.ServicePriceStats
+	}{
+		{
+			// The yearly 12000 counts as 1000 a month; the ended, the USD and
+			// the Spotify subscriptions don't count at all. The median of
+			// 100, 300, 600 and 1000 is the mean of the middle two.
+			name:    "default currency",
+			service: "Netflix",
+			want:    domain.ServicePriceStats{ServiceName: "Netflix", Currency: "RUB", Count: 4, Min: 100, Max: 1000, Avg: 500, Median: 450},
+		},
+		{
+			name:     "other currency",
+			service:  "Netflix",
+			currency: "usd",
+			want:     domain.ServicePriceStats{ServiceName: "Netflix", Currency: "USD", Count: 1, Min: 10, Max: 10, Avg: 10, Median: 10},
+		},
+		{
+			name:    "no subscriptions",
+			service: "Okko",
+			want:    domain.ServicePriceStats{ServiceName: "Okko", Currency: "RUB"},
+		},
+	}
+
+	for _, tt := range tests {
+		stats, err := u.ServicePriceStats(ctx, tt.service, tt.currency)
+		if err != nil {
+			t.Fatalf("ServicePriceStats: %v", err)
+		}
+		if *stats != tt.want {
+			t.Errorf("%s: stats = %+v, want %+v", tt.name, *stats, tt.want)
+		}
+	}
+}
+
+func testBackendRenewExpiring(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	in := func(days int) *time.Time {
+		end := now.AddDate(0, 0, days)
+		return &end
+	}
+
+	subs := map[string]domain.UserSub{
+		"soon":       seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: in(10)}),
+		"later":      seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: in(60)}),
+		"ended":      seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1), EndedAt: in(-5)}),
+		"open-ended": seedSub(t, db, domain.UserSub{UserID: alice, StartedAt: date(2024, 1, 1)}),
+		"bob's soon": seedSub(t, db, domain.UserSub{UserID: bob, StartedAt: date(2024, 1, 1), EndedAt: in(10)}),
+	}
+
+	renewed, err := u.RenewExpiring(ctx, alice, 30, 1)
+	if err != nil || renewed != 1 {
+		t.Fatalf("RenewExpiring = %d, %v; want 1", renewed, err)
+	}
+
+	for name, sub := range subs {
+		stored, err := u.GetUserSub(ctx, sub.ID)
+		if err != nil {
+			t.Fatalf("GetUserSub: %v", err)
+		}
+
+		want := sub.EndedAt
+		if name == "soon" {
+			extended := domain.AddMonthsClamped(*sub.EndedAt, 1)
+			want = &extended
+		}
+		if !equalTimes(stored.EndedAt, want) {
+			t.Errorf("%s: ended_at = %v, want %v", name, stored.EndedAt, want)
+		}
+	}
+}
+
+func testBackendActiveCounts(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	end := func(year int, month time.Month, day int) *time.Time {
+		ended := date(year, month, day)
+		return &ended
+	}
+
+	for _, sub := range []domain.UserSub{
+		{StartedAt: date(2025, 1, 1)},
+		{StartedAt: date(2024, 12, 1), EndedAt: end(2025, 1, 31)},
+		{StartedAt: date(2025, 2, 15), EndedAt: end(2025, 3, 10)},
+		// Ending on the first of April still counts towards April.
+		{StartedAt: date(2025, 3, 1), EndedAt: end(2025, 4, 1)},
+		{StartedAt: date(2025, 4, 1)},
+		{StartedAt: date(2025, 6, 1)},
+	} {
+		sub.UserID = uuid.New()
+		seedSub(t, db, sub)
+	}
+
+	counts, err := u.ActiveCounts(ctx, "01-2025", "04-2025")
+	if err != nil {
+		t.Fatalf("ActiveCounts: %v", err)
+	}
+
+	want := []domain.MonthCount{
+		{Month: "01-2025", Count: 2},
+		{Month: "02-2025", Count: 2},
+		{Month: "03-2025", Count: 3},
+		{Month: "04-2025", Count: 3},
+	}
+	if !slices.Equal(counts, want) {
+		t.Errorf("counts = %+v, want %+v", counts, want)
+	}
+}
+
+func testBackendWeightedActiveCounts(t *testing.T, u *UseCase, db Storage) {
+	ctx := context.Background()
+	midFebruary := date(2025, 2, 15)
+
+	seedSub(t, db, domain.UserSub{UserID: uuid.New(), StartedAt: date(2025, 1, 16)})
+	seedSub(t, db, domain.UserSub{UserID: uuid.New(), StartedAt: date(2024, 12, 1), EndedAt: &midFebruary})
+
+	counts, err := u.ActiveCounts(ctx, "01-2025", "02-2025")
+	if err != nil {
+		t.Fatalf("ActiveCounts: %v", err)
+	}
+	weighted, err := u.WeightedActiveCounts(ctx, "01-2025", "02-2025")
+	if err != nil {
+		t.Fatalf("WeightedActiveCounts: %v", err)
+	}
+
+	// The mid-January start is active 16 of January's 31 days and the
+	// mid-February end 14 of February's 28, while both count whole unweighted.
+	wantCounts := []domain.MonthCount{{Month: "01-2025", Count: 2}, {Month: "02-2025", Count: 2}}
+	wantWeighted := []domain.WeightedMonthCount{{Month: "01-2025", Count: "1.52"}, {Month: "02-2025", Count: "1.50"}}
+	if !slices.Equal(counts, wantCounts) {
+		t.Errorf("counts = %+v, want %+v", counts, wantCounts)
+	}
+	if !slices.Equal(weighted, wantWeighted) {
+		t.Errorf("weighted counts = %+v, want %+v", weighted, wantWeighted)
+	}
+}
+
+func subIDs(subs []*domain.UserSub) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"strings"
+	"testovoe/internal/domain"
+)
+
+// serviceCategories maps lowercased service names to the category a
+// subscription gets when the client sends none.
+var serviceCategories = map[string]string{
+	"netflix":         domain.CategoryEntertainment,
+	"youtube premium": domain.CategoryEntertainment,
+	"disney+":         domain.CategoryEntertainment,
+	"hbo max":         domain.CategoryEntertainment,
+	"kinopoisk":       domain.CategoryEntertainment,
+	"ivi":             domain.CategoryEntertainment,
+	"okko":            domain.CategoryEntertainment,
+	"twitch":          domain.CategoryEntertainment,
+	"spotify":         domain.CategoryMusic,
+	"apple music":     domain.CategoryMusic,
+	"yandex music":    domain.CategoryMusic,
+	"deezer":          domain.CategoryMusic,
+	"github":          domain.CategoryWork,
+	"gitlab":          domain.CategoryWork,
+	"jetbrains":       domain.CategoryWork,
+	"slack":           domain.CategoryWork,
+	"notion":          domain.CategoryWork,
+	"figma":           domain.CategoryWork,
+	"microsoft 365":   domain.CategoryWork,
+	"zoom":            domain.CategoryWork,
+	"icloud":          domain.CategoryCloud,
+	"google one":      domain.CategoryCloud,
+	"dropbox":         domain.CategoryCloud,
+	"yandex 360":      domain.CategoryCloud,
+	"coursera":        domain.CategoryEducation,
+	"duolingo":        domain.CategoryEducation,
+	"skillbox":        domain.CategoryEducation,
+}
+
+// inferCategory returns the category of a known service, or
+// domain.CategoryOther.
+func inferCategory(serviceName string) string {
+	if category, ok := serviceCategories[strings.ToLower(strings.TrimSpace(serviceName))]; ok {
+		return category
+	}
+
+	return domain.CategoryOther
+}
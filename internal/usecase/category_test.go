@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"testovoe/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestInferCategory(t *testing.T) {
+	tests := []struct {
+		service string
+		want    string
+	}{
+		{service: "Netflix", want: domain.CategoryEntertainment},
+		{service: "  YouTube Premium ", want: domain.CategoryEntertainment},
+		{service: "spotify", want: domain.CategoryMusic},
+		{service: "GitHub", want: domain.CategoryWork},
+		{service: "iCloud", want: domain.CategoryCloud},
+		{service: "Duolingo", want: domain.CategoryEducation},
+		{service: "Local Gym", want: domain.CategoryOther},
+		{service: "", want: domain.CategoryOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			if got := inferCategory(tt.service); got != tt.want {
+				t.Errorf("inferCategory(%q) = %q, want %q", tt.service, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSubFillsCategory(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	for _, sub := range []domain.UserSub{
+		{ServiceName: "GitHub", ServicePrice: 400, UserID: userID, StartedAt: date(2025, 1, 1)},
+		{ServiceName: "Local Gym", ServicePrice: 2000, UserID: userID, StartedAt: date(2025, 1, 1)},
+		{ServiceName: "Netflix", ServicePrice: 999, UserID: userID, StartedAt: date(2025, 1, 1), Category: domain.CategoryWork},
+	} {
+		if _, err := u.CreateSub(ctx, sub); err != nil {
+			t.Fatalf("CreateSub(%s): %v", sub.ServiceName, err)
+		}
+	}
+
+	subs, err := storage.GetUserSubs(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserSubs: %v", err)
+	}
+
+	got := make(map[string]string, len(subs))
+	for _, sub := range subs {
+		got[sub.ServiceName] = sub.Category
+	}
+
+	// A category the client sent wins over the inferred one.
+	want := map[string]string{
+		"GitHub":    domain.CategoryWork,
+		"Local Gym": domain.CategoryOther,
+		"Netflix":   domain.CategoryWork,
+	}
+	for service, category := range want {
+		if got[service] != category {
+			t.Errorf("%s: category = %q, want %q", service, got[service], category)
+		}
+	}
+}
@@ -2,95 +2,664 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
 	"testovoe/internal/config"
 	"testovoe/internal/domain"
+	"testovoe/internal/mergepatch"
+	"testovoe/internal/validation"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Storage interface {
-	CreateSub(ctx context.Context, userSub domain.UserSub) error
-	UpdateSub(ctx context.Context, userSub domain.UserSub) error
-	DeleteSub(ctx context.Context, subID uuid.UUID, userID uuid.UUID) error
-	GetSubs(ctx context.Context) ([]*domain.UserSub, error)
+	CreateSub(ctx context.Context, userSub domain.UserSub) (int64, error)
+	CreateSubs(ctx context.Context, userSubs []domain.UserSub) (int64, error)
+	ReplaceUserSubs(ctx context.Context, userID uuid.UUID, userSubs []domain.UserSub) (int64, error)
+	UpdateSub(ctx context.Context, update domain.SubUpdate, now time.Time, check func(domain.UserSub) error) (*domain.UserSub, error)
+	PriceHistory(ctx context.Context, subID uuid.UUID) ([]domain.PriceChange, error)
+	ReactivateSub(ctx context.Context, subID uuid.UUID, startedAt *time.Time, now time.Time) (int64, error)
+	DeleteSub(ctx context.Context, subID uuid.UUID, userID uuid.UUID) (int64, error)
+	DeleteSubsByFilter(ctx context.Context, filter domain.SubFilter) (int64, error)
+	UpdatePrices(ctx context.Context, filter domain.SubFilter, price int, now time.Time) (int64, error)
+	RenameService(ctx context.Context, from, to string) (int64, error)
+	RenewExpiring(ctx context.Context, userID uuid.UUID, now, until time.Time, months int) (int64, error)
+	ListSubs(ctx context.Context, filter domain.SubFilter, page domain.Page) ([]*domain.UserSub, error)
+	CountSubs(ctx context.Context, filter domain.SubFilter) (uint64, error)
+	StreamSubs(ctx context.Context, filter domain.SubFilter, fn func(*domain.UserSub) error) error
+	SubsByUser(ctx context.Context, filter domain.SubFilter) (map[uuid.UUID][]*domain.UserSub, error)
 	GetUserSubs(ctx context.Context, userID uuid.UUID) ([]*domain.UserSub, error)
 	GetUserSub(ctx context.Context, subID uuid.UUID) (*domain.UserSub, error)
-	GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, from, to time.Time) (int, error)
+	SubNeighbors(ctx context.Context, subID uuid.UUID) (*domain.SubNeighbors, error)
+	DeduplicateSubs(ctx context.Context, now time.Time) (*domain.DedupReport, error)
+	TopSubs(ctx context.Context, userID uuid.UUID, limit uint64) ([]*domain.UserSub, error)
+	Facets(ctx context.Context, userID uuid.UUID, now time.Time) (*domain.SubFacets, error)
+	Stats(ctx context.Context, userID uuid.UUID, now time.Time) (*domain.SubStats, error)
+	AddTags(ctx context.Context, subID uuid.UUID, tags []string) error
+	RemoveTag(ctx context.Context, subID uuid.UUID, tag string) (int64, error)
+	ShareSub(ctx context.Context, subID, userID uuid.UUID) error
+	UnshareSub(ctx context.Context, subID, userID uuid.UUID) (int64, error)
+	SubsActiveBetween(ctx context.Context, from, to time.Time) ([]*domain.UserSub, error)
+	EndedSubs(ctx context.Context, userID *uuid.UUID, from, to time.Time) ([]*domain.UserSub, error)
+	Currencies(ctx context.Context, userID *uuid.UUID) ([]string, error)
+	ServicePriceStats(ctx context.Context, service, currency string, now time.Time) (*domain.ServicePriceStats, error)
+	ActiveCounts(ctx context.Context, from, to time.Time) ([]domain.MonthCount, error)
+	GetTotalCosts(ctx context.Context, userIDs []uuid.UUID, serviceNames []string, from, to time.Time) (map[uuid.UUID]int, error)
+	GetReminderPreference(ctx context.Context, userID uuid.UUID) (*domain.ReminderPreference, error)
+	SetReminderPreference(ctx context.Context, pref domain.ReminderPreference) error
+	DueReminders(ctx context.Context, from, to time.Time) ([]domain.Reminder, error)
+}
+
+// EventPublisher receives an event after every successful create, update
+// and delete of a subscription, and the reminders of subscriptions about to
+// expire.
+type EventPublisher interface {
+	Publish(ctx context.Context, event domain.SubEvent) error
 }
 
 type UseCase struct {
-	log     *slog.Logger
-	storage Storage
-	cfg     *config.Config
+	log       *slog.Logger
+	storage   Storage
+	cfg       *config.Config
+	publisher EventPublisher
 }
 
-func New(log *slog.Logger, storage Storage, cfg *config.Config) *UseCase {
+func New(log *slog.Logger, storage Storage, cfg *config.Config, publisher EventPublisher) *UseCase {
 	return &UseCase{
-		log:     log,
-		storage: storage,
-		cfg:     cfg,
+		log:       log,
+		storage:   storage,
+		cfg:       cfg,
+		publisher: publisher,
+	}
+}
+
+// publish reports a committed change. The change is already stored, so a
+// delivery failure is logged rather than returned.
+func (u *UseCase) publish(ctx context.Context, event domain.SubEvent) {
+	if err := u.publisher.Publish(ctx, event); err != nil {
+		u.log.Error("Failed to publish event", "type", event.Type, "subscription_id", event.SubscriptionID, "error", err)
 	}
 }
 
-func (u *UseCase) CreateSub(ctx context.Context, userSub domain.UserSub) error {
+// logFailure logs a failed step of op. Errors caused by the request, such as
+// invalid input or an unknown subscription, log at WARN since the handler
+// answers them with a 4xx; anything else logs at ERROR.
+func (u *UseCase) logFailure(ctx context.Context, op, msg string, err error) {
+	level := slog.LevelError
+	if clientError(err) {
+		level = slog.LevelWarn
+	}
+
+	u.log.Log(ctx, level, msg, "op", op, "error", err)
+}
+
+// clientError reports whether err is the caller's mistake rather than a
+// failure of the service.
+func clientError(err error) bool {
+	var fieldErrs validation.Errors
+
+	return errors.As(err, &fieldErrs) ||
+		errors.Is(err, domain.ErrSubNotFound) ||
+		errors.Is(err, domain.ErrReminderPreferenceNotFound) ||
+		errors.Is(err, domain.ErrSubNotCancelled) ||
+		errors.Is(err, domain.ErrAmbiguousSub) ||
+		errors.Is(err, domain.ErrInvalidPatch) ||
+		errors.Is(err, domain.ErrEmptyFilter) ||
+		errors.Is(err, domain.ErrInvalidPeriod) ||
+		errors.Is(err, domain.ErrPeriodTooLong) ||
+		errors.Is(err, domain.ErrNoMatch)
+}
+
+// CreateSub returns the number of rows inserted.
+func (u *UseCase) CreateSub(ctx context.Context, userSub domain.UserSub) (int64, error) {
 	const op = "usecase.CreateSub"
 
-	if err := validatePrice(userSub.ServicePrice); err != nil {
-		u.log.Error("Validation failed", "op", op, "error", err)
-		return err
+	userSub, err := u.prepareNewSub(userSub)
+	if err != nil {
+		u.log.Warn("Validation failed", "op", op, "error", err)
+		return 0, err
 	}
 
-	err := u.storage.CreateSub(ctx, userSub)
+	affected, err := u.storage.CreateSub(ctx, userSub)
 	if err != nil {
-		u.log.Error("Failed to create subscription", "op", op, "error", err)
-		return err
+		u.logFailure(ctx, op, "Failed to create subscription", err)
+		return 0, err
 	}
 
-	return nil
+	u.publish(ctx, domain.NewSubEvent(domain.EventSubscriptionCreated, userSub.ID, userSub.UserID, &userSub))
+
+	return affected, nil
+}
+
+// CreateSubs validates every subscription and stores them in a single
+// transaction. Nothing is stored if any record is invalid; the error names
+// the index of the first bad one.
+func (u *UseCase) CreateSubs(ctx context.Context, userSubs []domain.UserSub) (int64, error) {
+	const op = "usecase.CreateSubs"
+
+	prepared := make([]domain.UserSub, 0, len(userSubs))
+	for i, userSub := range userSubs {
+		userSub, err := u.prepareNewSub(userSub)
+		if err != nil {
+			u.log.Warn("Validation failed", "op", op, "index", i, "error", err)
+			return 0, fmt.Errorf("subscription %d: %w", i, err)
+		}
+
+		prepared = append(prepared, userSub)
+	}
+
+	affected, err := u.storage.CreateSubs(ctx, prepared)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to create subscriptions", err)
+		return 0, err
+	}
+
+	for i := range prepared {
+		u.publish(ctx, domain.NewSubEvent(domain.EventSubscriptionCreated, prepared[i].ID, prepared[i].UserID, &prepared[i]))
+	}
+
+	return affected, nil
+}
+
+// ReplaceUserSubs validates userSubs and swaps them in for all of userID's
+// current subscriptions in one transaction.
+func (u *UseCase) ReplaceUserSubs(ctx context.Context, userID uuid.UUID, userSubs []domain.UserSub) (int64, error) {
+	const op = "usecase.ReplaceUserSubs"
+
+	prepared := make([]domain.UserSub, 0, len(userSubs))
+	for i, userSub := range userSubs {
+		userSub.UserID = userID
+		userSub, err := u.prepareNewSub(userSub)
+		if err != nil {
+			u.log.Warn("Validation failed", "op", op, "index", i, "error", err)
+			return 0, fmt.Errorf("subscription %d: %w", i, err)
+		}
+
+		prepared = append(prepared, userSub)
+	}
+
+	affected, err := u.storage.ReplaceUserSubs(ctx, userID, prepared)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to replace subscriptions", err)
+		return 0, err
+	}
+
+	return affected, nil
+}
+
+// CreateSubsEach validates and stores every subscription independently, so
+// one bad record does not stop the rest. The returned slice holds the error
+// for each input, nil where the record was stored.
+func (u *UseCase) CreateSubsEach(ctx context.Context, userSubs []domain.UserSub) []error {
+	errs := make([]error, len(userSubs))
+	for i, userSub := range userSubs {
+		_, errs[i] = u.CreateSub(ctx, userSub)
+	}
+
+	return errs
+}
+
+// ValidateSub runs the create pipeline's defaults and validation without
+// touching storage.
+func (u *UseCase) ValidateSub(userSub domain.UserSub) error {
+	_, err := u.prepareNewSub(userSub)
+	return err
+}
+
+// prepareNewSub assigns an id and fills defaults for a subscription about to
+// be created and validates the result.
+func (u *UseCase) prepareNewSub(userSub domain.UserSub) (domain.UserSub, error) {
+	// The id is assigned here rather than by the database so events and
+	// callers know it without reading the row back.
+	userSub.ID = uuid.New()
+	if userSub.BillingPeriod == "" {
+		userSub.BillingPeriod = domain.BillingMonthly
+	}
+	if userSub.Currency == "" {
+		userSub.Currency = u.cfg.Money.DefaultCurrency
+	}
+	if userSub.Category == "" {
+		userSub.Category = inferCategory(userSub.ServiceName)
+	}
+
+	if err := validation.ValidateUserSub(userSub, u.validationRules()); err != nil {
+		return userSub, err
+	}
+
+	return userSub, nil
+}
+
+func (u *UseCase) validationRules() validation.Rules {
+	return validation.Rules{
+		Currencies:       u.cfg.Money.Currencies,
+		MaxPrice:         u.cfg.Money.MaxPrice,
+		ServiceMaxPrices: u.cfg.Money.ServiceMaxPrices,
+		RejectZeroLength: u.cfg.Billing.ZeroLength == domain.ZeroLengthReject,
+	}
 }
 
-func (u *UseCase) UpdateSub(ctx context.Context, userSub domain.UserSub) error {
+// UpdateSub returns the number of rows updated; 0 means no subscription
+// matched the id and user_id. The subscription is validated as the update
+// leaves it, so ended_at is checked against the stored started_at.
+func (u *UseCase) UpdateSub(ctx context.Context, update domain.SubUpdate) (int64, error) {
 	const op = "usecase.UpdateSub"
 
-	if err := validatePrice(userSub.ServicePrice); err != nil {
-		u.log.Error("Validation failed", "op", op, "error", err)
-		return err
+	rules := u.validationRules()
+	updated, err := u.storage.UpdateSub(ctx, update, time.Now().UTC(), func(sub domain.UserSub) error {
+		return validation.ValidateUserSub(sub, rules)
+	})
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to update subscription", err)
+		return 0, err
+	}
+
+	if updated == nil {
+		return 0, nil
+	}
+
+	u.publish(ctx, domain.NewSubEvent(domain.EventSubscriptionUpdated, updated.ID, updated.UserID, updated))
+
+	return 1, nil
+}
+
+// PatchSub applies a JSON merge patch (RFC 7396) to the stored subscription
+// and persists the result. id, user_id and started_at cannot be patched.
+func (u *UseCase) PatchSub(ctx context.Context, subID uuid.UUID, patch []byte) (*domain.UserSub, error) {
+	const op = "usecase.PatchSub"
+
+	current, err := u.storage.GetUserSub(ctx, subID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get subscription", err)
+		return nil, err
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	merged, err := mergepatch.Apply(doc, patch)
+	if err != nil {
+		u.log.Warn("Invalid merge patch", "op", op, "error", err)
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidPatch, err)
+	}
+
+	var patched domain.UserSub
+	if err := json.Unmarshal(merged, &patched); err != nil {
+		u.log.Warn("Invalid merge patch", "op", op, "error", err)
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidPatch, err)
+	}
+
+	patched.ID = current.ID
+	patched.UserID = current.UserID
+	patched.StartedAt = current.StartedAt
+	if patched.BillingPeriod == "" {
+		patched.BillingPeriod = domain.BillingMonthly
+	}
+	if patched.Currency == "" {
+		patched.Currency = current.Currency
+	}
+	if patched.Category == "" {
+		patched.Category = inferCategory(patched.ServiceName)
+	}
+
+	if err := validation.ValidateUserSub(patched, u.validationRules()); err != nil {
+		u.log.Warn("Validation failed", "op", op, "error", err)
+		return nil, err
 	}
 
-	err := u.storage.UpdateSub(ctx, userSub)
+	updated, err := u.storage.UpdateSub(ctx, domain.SubUpdate{
+		ID:            patched.ID,
+		ServiceName:   patched.ServiceName,
+		ServicePrice:  patched.ServicePrice,
+		Currency:      patched.Currency,
+		UserID:        patched.UserID,
+		EndedAt:       domain.Some(patched.EndedAt),
+		BillingPeriod: patched.BillingPeriod,
+		PaymentMethod: domain.Some(patched.PaymentMethod),
+		AutoRenew:     domain.Some(patched.AutoRenew),
+		Category:      patched.Category,
+	}, time.Now().UTC(), nil)
 	if err != nil {
-		u.log.Error("Failed to update subscription", "op", op, "error", err)
+		u.logFailure(ctx, op, "Failed to update subscription", err)
+		return nil, err
+	}
+	if updated == nil {
+		// Deleted since it was read.
+		return nil, domain.ErrSubNotFound
+	}
+
+	u.publish(ctx, domain.NewSubEvent(domain.EventSubscriptionUpdated, updated.ID, updated.UserID, updated))
+
+	return updated, nil
+}
+
+// ReactivateSub makes a cancelled subscription, one whose ended_at has
+// passed, active again. With resetStart the subscription restarts now.
+func (u *UseCase) ReactivateSub(ctx context.Context, subID uuid.UUID, resetStart bool) (*domain.UserSub, error) {
+	const op = "usecase.ReactivateSub"
+
+	sub, err := u.storage.GetUserSub(ctx, subID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get subscription", err)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if sub.EndedAt == nil || sub.EndedAt.After(now) {
+		return nil, domain.ErrSubNotCancelled
+	}
+
+	var startedAt *time.Time
+	if resetStart {
+		startedAt = &now
+	}
+
+	affected, err := u.storage.ReactivateSub(ctx, subID, startedAt, now)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to reactivate subscription", err)
+		return nil, err
+	}
+	// Reactivated, deleted or moved out of the past since it was read.
+	if affected == 0 {
+		return nil, domain.ErrSubNotCancelled
+	}
+
+	sub.EndedAt = nil
+	if startedAt != nil {
+		sub.StartedAt = *startedAt
+	}
+
+	u.publish(ctx, domain.NewSubEvent(domain.EventSubscriptionUpdated, sub.ID, sub.UserID, sub))
+
+	return sub, nil
+}
+
+// AddTags attaches tags to the subscription and returns it with its tags.
+// Surrounding whitespace is dropped and repeated tags collapse into one.
+func (u *UseCase) AddTags(ctx context.Context, subID uuid.UUID, tags []string) (*domain.UserSub, error) {
+	const op = "usecase.AddTags"
+
+	if err := validation.ValidateTags(tags); err != nil {
+		u.log.Warn("Validation failed", "op", op, "error", err)
+		return nil, err
+	}
+
+	if _, err := u.storage.GetUserSub(ctx, subID); err != nil {
+		u.logFailure(ctx, op, "Failed to get subscription", err)
+		return nil, err
+	}
+
+	if err := u.storage.AddTags(ctx, subID, normalizeTags(tags)); err != nil {
+		u.logFailure(ctx, op, "Failed to add tags", err)
+		return nil, err
+	}
+
+	return u.storage.GetUserSub(ctx, subID)
+}
+
+// RemoveTag detaches tag from the subscription and returns it with the tags
+// left. Removing a tag the subscription does not have is not an error.
+func (u *UseCase) RemoveTag(ctx context.Context, subID uuid.UUID, tag string) (*domain.UserSub, error) {
+	const op = "usecase.RemoveTag"
+
+	if _, err := u.storage.GetUserSub(ctx, subID); err != nil {
+		u.logFailure(ctx, op, "Failed to get subscription", err)
+		return nil, err
+	}
+
+	if _, err := u.storage.RemoveTag(ctx, subID, strings.TrimSpace(tag)); err != nil {
+		u.logFailure(ctx, op, "Failed to remove tag", err)
+		return nil, err
+	}
+
+	return u.storage.GetUserSub(ctx, subID)
+}
+
+// ShareSub lets userID see the subscription in lists requested with
+// include_shared. Sharing again is not an error.
+func (u *UseCase) ShareSub(ctx context.Context, subID, userID uuid.UUID) error {
+	const op = "usecase.ShareSub"
+
+	sub, err := u.storage.GetUserSub(ctx, subID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get subscription", err)
+		return err
+	}
+
+	if sub.UserID == userID {
+		err := validation.Errors{{Field: "user_id", Message: "is the subscription owner"}}
+		u.log.Warn("Validation failed", "op", op, "error", err)
+		return err
+	}
+
+	if err := u.storage.ShareSub(ctx, subID, userID); err != nil {
+		u.logFailure(ctx, op, "Failed to share subscription", err)
 		return err
 	}
 
 	return nil
 }
 
-func (u *UseCase) DeleteSub(ctx context.Context, subID, userID uuid.UUID) error {
+// UnshareSub revokes a share. Revoking a share that does not exist is not an
+// error.
+func (u *UseCase) UnshareSub(ctx context.Context, subID, userID uuid.UUID) (int64, error) {
+	const op = "usecase.UnshareSub"
+
+	if _, err := u.storage.GetUserSub(ctx, subID); err != nil {
+		u.logFailure(ctx, op, "Failed to get subscription", err)
+		return 0, err
+	}
+
+	affected, err := u.storage.UnshareSub(ctx, subID, userID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to unshare subscription", err)
+		return 0, err
+	}
+
+	return affected, nil
+}
+
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized
+}
+
+// DeleteSub returns the number of rows deleted; 0 means no subscription
+// matched the id and user_id.
+func (u *UseCase) DeleteSub(ctx context.Context, subID, userID uuid.UUID) (int64, error) {
 	const op = "usecase.DeleteSub"
 
-	err := u.storage.DeleteSub(ctx, subID, userID)
+	affected, err := u.storage.DeleteSub(ctx, subID, userID)
 	if err != nil {
-		u.log.Error("Failed to delete subscription", "op", op, "error", err)
+		u.logFailure(ctx, op, "Failed to delete subscription", err)
+		return 0, err
+	}
+
+	if affected > 0 {
+		u.publish(ctx, domain.NewSubEvent(domain.EventSubscriptionDeleted, subID, userID, nil))
+	}
+
+	return affected, nil
+}
+
+// UpdatePrices sets price on every subscription matching filter, e.g. for a
+// service-wide price change.
+func (u *UseCase) UpdatePrices(ctx context.Context, filter domain.SubFilter, price int) (int64, error) {
+	const op = "usecase.UpdatePrices"
+
+	filter.ServiceName = strings.TrimSpace(filter.ServiceName)
+	if filter.IsEmpty() {
+		u.log.Warn("Validation failed", "op", op, "error", domain.ErrEmptyFilter)
+		return 0, domain.ErrEmptyFilter
+	}
+
+	if err := validation.ValidateNewPrice(filter.ServiceName, price, u.validationRules()); err != nil {
+		u.log.Warn("Validation failed", "op", op, "error", err)
+		return 0, err
+	}
+
+	updated, err := u.storage.UpdatePrices(ctx, filter, price, time.Now().UTC())
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to update prices", err)
+		return 0, err
+	}
+
+	u.log.Info("subscription prices updated", "op", op, "updated", updated)
+	return updated, nil
+}
+
+func (u *UseCase) DeleteSubsByFilter(ctx context.Context, filter domain.SubFilter) (int64, error) {
+	const op = "usecase.DeleteSubsByFilter"
+
+	if filter.IsEmpty() {
+		u.log.Warn("Validation failed", "op", op, "error", domain.ErrEmptyFilter)
+		return 0, domain.ErrEmptyFilter
+	}
+
+	deleted, err := u.storage.DeleteSubsByFilter(ctx, filter)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to delete subscriptions", err)
+		return 0, err
+	}
+
+	u.log.Info("subscriptions deleted", "op", op, "deleted", deleted)
+	return deleted, nil
+}
+
+func (u *UseCase) ListSubs(ctx context.Context, filter domain.SubFilter, page domain.Page) ([]*domain.UserSub, error) {
+	const op = "usecase.ListSubs"
+
+	subs, err := u.storage.ListSubs(ctx, filter, page)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get subscriptions", err)
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// StreamSubs calls fn for every subscription matching filter, ordered by
+// start date, without holding the whole result in memory.
+func (u *UseCase) StreamSubs(ctx context.Context, filter domain.SubFilter, fn func(*domain.UserSub) error) error {
+	const op = "usecase.StreamSubs"
+
+	if err := u.storage.StreamSubs(ctx, filter, fn); err != nil {
+		u.logFailure(ctx, op, "Failed to stream subscriptions", err)
 		return err
 	}
 
 	return nil
 }
 
-func (u *UseCase) GetSubs(ctx context.Context) ([]*domain.UserSub, error) {
-	const op = "usecase.GetSubs"
+// SubsByUser returns the subscriptions matching filter grouped by user id.
+func (u *UseCase) SubsByUser(ctx context.Context, filter domain.SubFilter) (map[uuid.UUID][]*domain.UserSub, error) {
+	const op = "usecase.SubsByUser"
 
-	subs, err := u.storage.GetSubs(ctx)
+	grouped, err := u.storage.SubsByUser(ctx, filter)
 	if err != nil {
-		u.log.Error("Failed to get subscriptions", "op", op, "error", err)
+		u.logFailure(ctx, op, "Failed to get subscriptions", err)
 		return nil, err
 	}
 
-	return subs, nil
+	return grouped, nil
+}
+
+// CountSubs returns how many subscriptions match filter across all pages.
+func (u *UseCase) CountSubs(ctx context.Context, filter domain.SubFilter) (uint64, error) {
+	const op = "usecase.CountSubs"
+
+	total, err := u.storage.CountSubs(ctx, filter)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to count subscriptions", err)
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// SubNeighbors returns the ids of the user's subscriptions started right
+// before and after subID.
+func (u *UseCase) SubNeighbors(ctx context.Context, subID uuid.UUID) (*domain.SubNeighbors, error) {
+	const op = "usecase.SubNeighbors"
+
+	neighbors, err := u.storage.SubNeighbors(ctx, subID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get neighbors", err)
+		return nil, err
+	}
+
+	return neighbors, nil
+}
+
+// DeduplicateSubs keeps the most recently started of the active
+// subscriptions sharing a user and service and ends the others now.
+func (u *UseCase) DeduplicateSubs(ctx context.Context) (*domain.DedupReport, error) {
+	const op = "usecase.DeduplicateSubs"
+
+	// Timestamps are stored as UTC wall clock.
+	report, err := u.storage.DeduplicateSubs(ctx, time.Now().UTC())
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to deduplicate subscriptions", err)
+		return nil, err
+	}
+
+	u.log.Info("Subscriptions deduplicated", "op", op, "groups", len(report.Groups), "ended", report.Ended)
+	return report, nil
+}
+
+// RenewExpiring extends by months every subscription of the user that ends
+// within the next withinDays days. Subscriptions that already ended are left
+// alone; use ReactivateSub for those.
+func (u *UseCase) RenewExpiring(ctx context.Context, userID uuid.UUID, withinDays, months int) (int64, error) {
+	const op = "usecase.RenewExpiring"
+
+	// Timestamps are stored as UTC wall clock.
+	now := time.Now().UTC()
+
+	renewed, err := u.storage.RenewExpiring(ctx, userID, now, now.AddDate(0, 0, withinDays), months)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to renew subscriptions", err)
+		return 0, err
+	}
+
+	u.log.Info("Expiring subscriptions renewed", "op", op, "user_id", userID, "renewed", renewed)
+	return renewed, nil
+}
+
+// RenameService renames the service from to to across all subscriptions, e.g.
+// to merge a misspelled name that split totals. Categories are kept as they
+// are.
+func (u *UseCase) RenameService(ctx context.Context, from, to string) (int64, error) {
+	const op = "usecase.RenameService"
+
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if err := validation.ValidateRename(from, to); err != nil {
+		u.log.Warn("Validation failed", "op", op, "error", err)
+		return 0, err
+	}
+
+	renamed, err := u.storage.RenameService(ctx, from, to)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to rename service", err)
+		return 0, err
+	}
+
+	u.log.Info("Service renamed", "op", op, "from", from, "to", to, "renamed", renamed)
+	return renamed, nil
 }
 
 func (u *UseCase) GetUserSub(ctx context.Context, subID uuid.UUID) (*domain.UserSub, error) {
@@ -98,7 +667,7 @@ func (u *UseCase) GetUserSub(ctx context.Context, subID uuid.UUID) (*domain.User
 
 	sub, err := u.storage.GetUserSub(ctx, subID)
 	if err != nil {
-		u.log.Error("Failed to get subscriptions", "op", op, "error", err)
+		u.logFailure(ctx, op, "Failed to get subscriptions", err)
 		return nil, err
 	}
 
@@ -110,50 +679,517 @@ func (u *UseCase) GetUserSubs(ctx context.Context, userID uuid.UUID) ([]*domain.
 
 	subs, err := u.storage.GetUserSubs(ctx, userID)
 	if err != nil {
-		u.log.Error("Failed to get subscriptions", "op", op, "error", err)
+		u.logFailure(ctx, op, "Failed to get subscriptions", err)
 		return nil, err
 	}
 
 	return subs, nil
 }
 
-func (u *UseCase) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName, fromStr, toStr string) (int, error) {
+func (u *UseCase) TopSubs(ctx context.Context, userID uuid.UUID, limit uint64) ([]*domain.UserSub, error) {
+	const op = "usecase.TopSubs"
+
+	subs, err := u.storage.TopSubs(ctx, userID, limit)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get top subscriptions", err)
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// SubByService returns the user's active subscription to serviceName. When
+// several are active it fails with ErrAmbiguousSub, unless latest is set and
+// the most recently started one is returned.
+func (u *UseCase) SubByService(ctx context.Context, userID uuid.UUID, serviceName string, latest bool) (*domain.UserSub, error) {
+	const op = "usecase.SubByService"
+
+	filter := domain.SubFilter{UserID: &userID, ServiceName: strings.TrimSpace(serviceName)}
+
+	subs, err := u.storage.ListSubs(ctx, filter, domain.Page{Sort: domain.DefaultSort})
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to list subscriptions", err)
+		return nil, err
+	}
+
+	// Timestamps are stored as UTC wall clock.
+	now := time.Now().UTC()
+
+	var active []*domain.UserSub
+	for _, sub := range subs {
+		if sub.ActiveAt(now) {
+			active = append(active, sub)
+		}
+	}
+
+	switch {
+	case len(active) == 0:
+		return nil, domain.ErrSubNotFound
+	case len(active) > 1 && !latest:
+		return nil, domain.ErrAmbiguousSub
+	}
+
+	// DefaultSort lists the most recently started first.
+	return active[0], nil
+}
+
+// EndedSubs returns the subscriptions that ended between the start of the
+// month fromStr and the end of the month toStr (MM-YYYY), for all users when
+// userID is nil.
+func (u *UseCase) EndedSubs(ctx context.Context, userID *uuid.UUID, fromStr, toStr string) ([]*domain.UserSub, error) {
+	const op = "usecase.EndedSubs"
+
+	log := u.log.With(slog.String("op", op))
+
+	from, to, err := u.parseMonths(log, fromStr, toStr, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := u.storage.EndedSubs(ctx, userID, from, to.AddDate(0, 1, 0))
+	if err != nil {
+		log.Error("failed to get ended subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// Currencies lists the currency codes in use, by userID's subscriptions or by
+// all of them when userID is nil.
+func (u *UseCase) Currencies(ctx context.Context, userID *uuid.UUID) ([]string, error) {
+	const op = "usecase.Currencies"
+
+	currencies, err := u.storage.Currencies(ctx, userID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get currencies", err)
+		return nil, err
+	}
+
+	return currencies, nil
+}
+
+// ServicePriceStats summarizes the current prices of service across users. An
+// empty currency means money.default_currency, since prices in different
+// currencies can't be compared.
+func (u *UseCase) ServicePriceStats(ctx context.Context, service, currency string) (*domain.ServicePriceStats, error) {
+	const op = "usecase.ServicePriceStats"
+
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = u.cfg.Money.DefaultCurrency
+	}
+
+	// Timestamps are stored as UTC wall clock.
+	stats, err := u.storage.ServicePriceStats(ctx, strings.TrimSpace(service), currency, time.Now().UTC())
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get service price stats", err)
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// Stats summarizes the user's subscriptions as of now.
+func (u *UseCase) Stats(ctx context.Context, userID uuid.UUID) (*domain.SubStats, error) {
+	const op = "usecase.Stats"
+
+	// Timestamps are stored as UTC wall clock.
+	stats, err := u.storage.Stats(ctx, userID, time.Now().UTC())
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get stats", err)
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (u *UseCase) Facets(ctx context.Context, userID uuid.UUID) (*domain.SubFacets, error) {
+	const op = "usecase.Facets"
+
+	facets, err := u.storage.Facets(ctx, userID, time.Now().UTC())
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get facets", err)
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// GetTotalCost sums what the user spent on any of serviceNames between the
+// months fromStr and toStr. With opts.RequireMatch it fails with
+// domain.ErrNoMatch when no subscription counted towards the total.
+func (u *UseCase) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceNames []string, fromStr, toStr string, opts domain.CostOptions) (int, error) {
 	const op = "usecase.GetTotalCost"
 
 	log := u.log.With(
 		slog.String("op", op),
 		slog.String("user_id", userID.String()),
-		slog.String("service", serviceName),
+		slog.Any("services", serviceNames),
 	)
 
-	from, err := time.Parse("01-2006", fromStr)
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	from, toRaw, err := u.parseMonths(log, fromStr, toStr, loc)
 	if err != nil {
-		log.Error("invalid from_date format", slog.String("val", fromStr))
 		return 0, err
 	}
 
-	toRaw, err := time.Parse("01-2006", toStr)
+	cost, matched, err := u.totalCost(ctx, log, userID, serviceNames, from, toRaw, opts)
 	if err != nil {
-		log.Error("invalid to_date format", slog.String("val", toStr))
 		return 0, err
 	}
 
-	to := toRaw.AddDate(0, 1, 0).Add(-time.Second)
+	if opts.RequireMatch && matched == 0 {
+		return 0, domain.ErrNoMatch
+	}
+
+	return cost, nil
+}
+
+// GetTotalCosts sums what each of userIDs spent between the months fromStr
+// and toStr, optionally limited to serviceNames, in one grouped storage
+// query charged the way GetTotalCost charges by default. Every requested
+// user is present in the result, with 0 when nothing matched.
+func (u *UseCase) GetTotalCosts(ctx context.Context, userIDs []uuid.UUID, serviceNames []string, fromStr, toStr string) (map[uuid.UUID]int, error) {
+	const op = "usecase.GetTotalCosts"
 
-	cost, err := u.storage.GetTotalCost(ctx, userID, serviceName, from, to)
+	log := u.log.With(
+		slog.String("op", op),
+		slog.Int("users", len(userIDs)),
+		slog.Any("services", serviceNames),
+	)
+
+	from, toRaw, err := u.parseMonths(log, fromStr, toStr, time.UTC)
 	if err != nil {
-		log.Error("failed to get total cost from storage", slog.Any("err", err))
+		return nil, err
+	}
+
+	totals, err := u.storage.GetTotalCosts(ctx, userIDs, serviceNames, from, toRaw)
+	if err != nil {
+		log.Error("failed to get totals from storage", slog.Any("err", err))
+		return nil, err
+	}
+
+	for _, userID := range userIDs {
+		if _, ok := totals[userID]; !ok {
+			totals[userID] = 0
+		}
+	}
+
+	return totals, nil
+}
+
+// parseMonths parses the MM-YYYY bounds of a period in loc and rejects
+// periods longer than reports.max_range_months.
+func (u *UseCase) parseMonths(log *slog.Logger, fromStr, toStr string, loc *time.Location) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation(domain.MonthLayout, fromStr, loc)
+	if err != nil {
+		log.Warn("invalid from_date format", slog.String("val", fromStr))
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %w", domain.ErrInvalidPeriod, err)
+	}
+
+	to, err := time.ParseInLocation(domain.MonthLayout, toStr, loc)
+	if err != nil {
+		log.Warn("invalid to_date format", slog.String("val", toStr))
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %w", domain.ErrInvalidPeriod, err)
+	}
+
+	if limit := u.cfg.Reports.MaxRangeMonths; limit > 0 && domain.MonthsBetween(from, to)+1 > limit {
+		log.Warn("period too long", slog.String("from", fromStr), slog.String("to", toStr))
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: at most %d months", domain.ErrPeriodTooLong, limit)
+	}
+
+	return from, to, nil
+}
+
+// ComparePeriods computes the total cost of two periods the same way
+// GetTotalCost does and reports the change from a to b.
+func (u *UseCase) ComparePeriods(ctx context.Context, userID uuid.UUID, serviceNames []string, a, b domain.Period, opts domain.CostOptions) (*domain.PeriodComparison, error) {
+	totalA, err := u.GetTotalCost(ctx, userID, serviceNames, a.From, a.To, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	totalB, err := u.GetTotalCost(ctx, userID, serviceNames, b.From, b.To, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewPeriodComparison(
+		domain.PeriodTotal{Period: a, Total: totalA},
+		domain.PeriodTotal{Period: b, Total: totalB},
+	), nil
+}
+
+// totalCost sums, for every month from fromMonth to toMonth inclusive, what
+// each subscription bills in it. By default a month the subscription was
+// active in bills in full, as ChargeInMonth does; with opts.Prorate it bills
+// the monthly price scaled by the share of the month it was active, so the
+// two differ only in partially active months (and in yearly subscriptions,
+// which bill whole in their anniversary month but a twelfth per month when
+// prorated). The exact prorated sum is rounded with opts.Rounding once at
+// the end. totalCost also returns how many subscriptions were billed in the
+// months at all.
+func (u *UseCase) totalCost(ctx context.Context, log *slog.Logger, userID uuid.UUID, serviceNames []string, fromMonth, toMonth time.Time, opts domain.CostOptions) (int, int, error) {
+	subs, err := u.storage.ListSubs(ctx, domain.SubFilter{UserID: &userID, ServiceNames: serviceNames}, domain.Page{})
+	if err != nil {
+		log.Error("failed to get subscriptions from storage", slog.Any("err", err))
+		return 0, 0, err
+	}
+
+	total := new(big.Rat)
+	matched := 0
+	for _, sub := range subs {
+		billed := false
+		for month := fromMonth; !month.After(toMonth); month = month.AddDate(0, 1, 0) {
+			if !sub.BilledInMonth(month) {
+				continue
+			}
+			billed = true
+			if opts.Prorate {
+				total.Add(total, sub.ProratedChargeInMonth(month))
+			} else {
+				total.Add(total, big.NewRat(int64(sub.ChargeInMonth(month)), 1))
+			}
+		}
+		if billed {
+			matched++
+		}
+	}
+
+	cost := int(opts.Rounding.Round(total))
+
+	log.Info("total cost calculated", slog.Int("result", cost), slog.Bool("prorate", opts.Prorate))
+	return cost, matched, nil
+}
+
+// ActiveCounts counts the subscriptions of all users active in each month
+// from fromStr to toStr (MM-YYYY), for growth charts.
+func (u *UseCase) ActiveCounts(ctx context.Context, fromStr, toStr string) ([]domain.MonthCount, error) {
+	const op = "usecase.ActiveCounts"
+
+	log := u.log.With(slog.String("op", op))
+
+	from, to, err := u.parseMonths(log, fromStr, toStr, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := u.storage.ActiveCounts(ctx, from, to)
+	if err != nil {
+		log.Error("failed to count active subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// WeightedActiveCounts is ActiveCounts with every subscription counted by
+// the share of the month's days it was active, as the prorated cost model
+// charges it.
+func (u *UseCase) WeightedActiveCounts(ctx context.Context, fromStr, toStr string) ([]domain.WeightedMonthCount, error) {
+	const op = "usecase.WeightedActiveCounts"
+
+	log := u.log.With(slog.String("op", op))
+
+	from, to, err := u.parseMonths(log, fromStr, toStr, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := u.storage.SubsActiveBetween(ctx, from, to.AddDate(0, 1, 0))
+	if err != nil {
+		log.Error("failed to get subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+
+	counts := make([]domain.WeightedMonthCount, 0, domain.MonthsBetween(from, to)+1)
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		counts = append(counts, domain.WeightedMonthCount{
+			Month: month.Format(domain.MonthLayout),
+			Count: domain.WeightedActiveCount(subs, month).FloatString(2),
+		})
+	}
+
+	return counts, nil
+}
+
+// MRR computes the monthly recurring revenue across all users for the month
+// given as MM-YYYY, with yearly subscriptions counted at a twelfth of their
+// price.
+func (u *UseCase) MRR(ctx context.Context, monthStr string) (int, error) {
+	const op = "usecase.MRR"
+
+	log := u.log.With(slog.String("op", op))
+
+	month, err := time.Parse(domain.MonthLayout, monthStr)
+	if err != nil {
+		log.Warn("invalid month format", slog.String("val", monthStr))
+		return 0, fmt.Errorf("%w: %w", domain.ErrInvalidPeriod, err)
+	}
+
+	subs, err := u.storage.SubsActiveBetween(ctx, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		log.Error("failed to get subscriptions", slog.Any("err", err))
 		return 0, err
 	}
 
-	log.Info("total cost calculated", slog.Int("result", cost))
-	return cost, nil
+	return int(domain.RoundHalfUp(domain.MRR(subs, month))), nil
+}
+
+// healthChecker is implemented by storages that track their connectivity.
+type healthChecker interface {
+	Healthy() bool
 }
 
-func validatePrice(price int) error {
-	if price < 0 {
-		return errors.New("price cannot be negative")
+// Healthy reports whether the storage was reachable at its last check.
+// Storages that don't track it are always healthy.
+func (u *UseCase) Healthy() bool {
+	if hc, ok := u.storage.(healthChecker); ok {
+		return hc.Healthy()
+	}
+
+	return true
+}
+
+// Timeline lists when each of the user's subscriptions started and ended.
+func (u *UseCase) Timeline(ctx context.Context, userID uuid.UUID) ([]domain.TimelineEvent, error) {
+	const op = "usecase.Timeline"
+
+	subs, err := u.storage.GetUserSubs(ctx, userID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get subscriptions", err)
+		return nil, err
+	}
+
+	return domain.Timeline(subs), nil
+}
+
+// Forecast projects a user's spend for the given number of calendar months,
+// starting with the current one, from the subscriptions active right now.
+// Subscriptions stop contributing after the month they end in, unless they
+// auto-renew.
+func (u *UseCase) Forecast(ctx context.Context, userID uuid.UUID, months int) ([]domain.MonthCost, error) {
+	const op = "usecase.Forecast"
+
+	log := u.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+	)
+
+	subs, err := u.storage.GetUserSubs(ctx, userID)
+	if err != nil {
+		log.Error("failed to get subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	active := make([]*domain.UserSub, 0, len(subs))
+	for _, sub := range subs {
+		projected := sub.Projected()
+		if projected.ActiveAt(now) {
+			active = append(active, &projected)
+		}
+	}
+
+	forecast := make([]domain.MonthCost, 0, months)
+	month := domain.MonthStart(now)
+	for range months {
+		cost := 0
+		for _, sub := range active {
+			cost += sub.ChargeInMonth(month)
+		}
+
+		forecast = append(forecast, domain.MonthCost{Month: month.Format(domain.MonthLayout), Cost: cost})
+		month = month.AddDate(0, 1, 0)
+	}
+
+	return forecast, nil
+}
+
+// Savings estimates what cancelling the subscription now would save over the
+// next months months.
+func (u *UseCase) Savings(ctx context.Context, subID uuid.UUID, months int) (int, error) {
+	const op = "usecase.Savings"
+
+	sub, err := u.storage.GetUserSub(ctx, subID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get subscription", err)
+		return 0, err
+	}
+
+	return sub.SavingsIfCancelled(time.Now().UTC(), months), nil
+}
+
+// PriceHistory returns the price changes recorded for subID, oldest first.
+func (u *UseCase) PriceHistory(ctx context.Context, subID uuid.UUID) ([]domain.PriceChange, error) {
+	const op = "usecase.PriceHistory"
+
+	if _, err := u.storage.GetUserSub(ctx, subID); err != nil {
+		u.logFailure(ctx, op, "Failed to get subscription", err)
+		return nil, err
+	}
+
+	changes, err := u.storage.PriceHistory(ctx, subID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get price history", err)
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (u *UseCase) GetReminderPreference(ctx context.Context, userID uuid.UUID) (*domain.ReminderPreference, error) {
+	const op = "usecase.GetReminderPreference"
+
+	pref, err := u.storage.GetReminderPreference(ctx, userID)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get reminder preferences", err)
+		return nil, err
+	}
+
+	return pref, nil
+}
+
+func (u *UseCase) SetReminderPreference(ctx context.Context, pref domain.ReminderPreference) error {
+	const op = "usecase.SetReminderPreference"
+
+	if err := validation.ValidateReminderPreference(pref); err != nil {
+		u.log.Warn("Validation failed", "op", op, "error", err)
+		return err
+	}
+
+	if err := u.storage.SetReminderPreference(ctx, pref); err != nil {
+		u.logFailure(ctx, op, "Failed to set reminder preferences", err)
+		return err
 	}
 
 	return nil
 }
+
+// SendReminders publishes a subscription.expiring event for every reminder
+// falling due after from and no later than to, each user's lead time before
+// ended_at, and returns how many were sent. Calling it for consecutive
+// windows sends every reminder once.
+func (u *UseCase) SendReminders(ctx context.Context, from, to time.Time) (int, error) {
+	const op = "usecase.SendReminders"
+
+	reminders, err := u.storage.DueReminders(ctx, from, to)
+	if err != nil {
+		u.logFailure(ctx, op, "Failed to get due reminders", err)
+		return 0, err
+	}
+
+	for _, reminder := range reminders {
+		event := domain.NewSubEvent(domain.EventSubscriptionExpiring, reminder.Sub.ID, reminder.Sub.UserID, &reminder.Sub)
+		event.Channel = reminder.Channel
+		u.publish(ctx, event)
+	}
+
+	return len(reminders), nil
+}
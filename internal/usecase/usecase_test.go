@@ -0,0 +1,1177 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"testovoe/internal/config"
+	"testovoe/internal/domain"
+	"testovoe/internal/events"
+	"testovoe/internal/storage/inmemory"
+	"testovoe/internal/validation"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestUseCase(t *testing.T) (*UseCase, *inmemory.Storage) {
+	t.Helper()
+
+	storage := inmemory.New()
+
+	return New(discardLogger(), storage, testConfig(), events.Noop{}), storage
+}
+
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Reports.MaxRangeMonths = 60
+	cfg.Money.DefaultCurrency = "RUB"
+	cfg.Money.Currencies = []string{"RUB"}
+
+	return cfg
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func seedSub(t *testing.T, storage Storage, sub domain.UserSub) domain.UserSub {
+	t.Helper()
+
+	if sub.ID == uuid.Nil {
+		sub.ID = uuid.New()
+	}
+	if sub.ServiceName == "" {
+		sub.ServiceName = "Netflix"
+	}
+	if sub.Currency == "" {
+		sub.Currency = "RUB"
+	}
+	if sub.BillingPeriod == "" {
+		sub.BillingPeriod = domain.BillingMonthly
+	}
+
+	if _, err := storage.CreateSub(context.Background(), sub); err != nil {
+		t.Fatalf("seed subscription: %v", err)
+	}
+
+	return sub
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestGetTotalCostWholeMonthVsProrated(t *testing.T) {
+	userID := uuid.New()
+	halfEnd := date(2025, 1, 16)
+
+	tests := []struct {
+		name      string
+		sub       domain.UserSub
+		from, to  string
+		whole     int
+		prorated  int
+		unmatched bool
+	}{
+		{
+			name:     "active all months",
+			sub:      domain.UserSub{ServicePrice: 3000, StartedAt: date(2024, 12, 1)},
+			from:     "01-2025",
+			to:       "03-2025",
+			whole:    9000,
+			prorated: 9000,
+		},
+		{
+			// Active on 16 of January's 31 days: 3000 * 16 / 31 = 1548.39.
+			name:     "started mid-month",
+			sub:      domain.UserSub{ServicePrice: 3000, StartedAt: halfEnd},
+			from:     "01-2025",
+			to:       "01-2025",
+			whole:    3000,
+			prorated: 1548,
+		},
+		{
+			// Active on January 1..15: 3000 * 15 / 31 = 1451.61.
+			name:     "ended mid-month",
+			sub:      domain.UserSub{ServicePrice: 3000, StartedAt: date(2024, 6, 1), EndedAt: &halfEnd},
+			from:     "01-2025",
+			to:       "03-2025",
+			whole:    3000,
+			prorated: 1452,
+		},
+		{
+			name:     "started mid-month over a longer window",
+			sub:      domain.UserSub{ServicePrice: 3000, StartedAt: halfEnd},
+			from:     "01-2025",
+			to:       "03-2025",
+			whole:    9000,
+			prorated: 7548,
+		},
+		{
+			name:      "outside the window",
+			sub:       domain.UserSub{ServicePrice: 3000, StartedAt: date(2025, 6, 1)},
+			from:      "01-2025",
+			to:        "03-2025",
+			unmatched: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, storage := newTestUseCase(t)
+			tt.sub.UserID = userID
+			seedSub(t, storage, tt.sub)
+
+			ctx := context.Background()
+			services := []string{"Netflix"}
+
+			whole, err := u.GetTotalCost(ctx, userID, services, tt.from, tt.to, domain.CostOptions{})
+			if err != nil {
+				t.Fatalf("whole-month total: %v", err)
+			}
+			if whole != tt.whole {
+				t.Errorf("whole-month total = %d, want %d", whole, tt.whole)
+			}
+
+			prorated, err := u.GetTotalCost(ctx, userID, services, tt.from, tt.to, domain.CostOptions{Prorate: true})
+			if err != nil {
+				t.Fatalf("prorated total: %v", err)
+			}
+			if prorated != tt.prorated {
+				t.Errorf("prorated total = %d, want %d", prorated, tt.prorated)
+			}
+
+			for _, prorate := range []bool{false, true} {
+				_, err := u.GetTotalCost(ctx, userID, services, tt.from, tt.to, domain.CostOptions{Prorate: prorate, RequireMatch: true})
+				if got := errors.Is(err, domain.ErrNoMatch); got != tt.unmatched {
+					t.Errorf("prorate=%v: ErrNoMatch = %v, want %v (err %v)", prorate, got, tt.unmatched, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTotalCostMonthBoundaries(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+
+	at := func(loc *time.Location, y int, m time.Month, d, hh, mm, ss int) *time.Time {
+		t := time.Date(y, m, d, hh, mm, ss, 0, loc).UTC()
+		return &t
+	}
+
+	tests := []struct {
+		name       string
+		start, end *time.Time
+		period     string
+		loc        *time.Location
+		prorate    bool
+		price      int
+		want       int
+	}{
+		{name: "started on the last second of the month", start: at(time.UTC, 2025, 1, 31, 23, 59, 59), period: "01-2025", want: 100},
+		{name: "started on the first instant of the next month", start: at(time.UTC, 2025, 2, 1, 0, 0, 0), period: "01-2025"},
+		{name: "ended on the first instant of the month", start: at(time.UTC, 2024, 6, 1, 0, 0, 0), end: at(time.UTC, 2025, 2, 1, 0, 0, 0), period: "02-2025"},
+		{name: "ended a second into the month", start: at(time.UTC, 2024, 6, 1, 0, 0, 0), end: at(time.UTC, 2025, 2, 1, 0, 0, 1), period: "02-2025", want: 100},
+
+		// October 2026 in Berlin has 31 days and a 25-hour day on the 25th,
+		// when clocks go back from CEST to CET.
+		{name: "dst month last second", start: at(berlin, 2026, 10, 31, 23, 59, 59), period: "10-2026", loc: berlin, want: 100},
+		{name: "dst month next month in the zone", start: at(berlin, 2026, 11, 1, 0, 0, 0), period: "10-2026", loc: berlin},
+		{name: "dst month next month is still october in UTC", start: at(berlin, 2026, 11, 1, 0, 0, 0), period: "10-2026", want: 100},
+		{name: "dst month ended on its first instant", start: at(berlin, 2026, 1, 1, 0, 0, 0), end: at(berlin, 2026, 10, 1, 0, 0, 0), period: "10-2026", loc: berlin},
+		{name: "dst month prorated from the 25th", start: at(berlin, 2026, 10, 25, 0, 0, 0), period: "10-2026", loc: berlin, prorate: true, price: 310, want: 70},
+		{name: "dst month prorated whole", start: at(berlin, 2026, 9, 15, 0, 0, 0), period: "10-2026", loc: berlin, prorate: true, price: 310, want: 310},
+
+		{name: "leap day last second", start: at(time.UTC, 2024, 2, 29, 23, 59, 59), period: "02-2024", want: 100},
+		{name: "leap month next month", start: at(time.UTC, 2024, 3, 1, 0, 0, 0), period: "02-2024"},
+		{name: "leap month prorated from the 15th", start: at(time.UTC, 2024, 2, 15, 0, 0, 0), period: "02-2024", prorate: true, price: 290, want: 150},
+		{name: "leap month prorated leap day only", start: at(time.UTC, 2024, 2, 29, 0, 0, 0), period: "02-2024", prorate: true, price: 290, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, storage := newTestUseCase(t)
+			userID := uuid.New()
+
+			price := tt.price
+			if price == 0 {
+				price = 100
+			}
+			seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: price, StartedAt: *tt.start, EndedAt: tt.end})
+
+			got, err := u.GetTotalCost(context.Background(), userID, []string{"Netflix"}, tt.period, tt.period, domain.CostOptions{Prorate: tt.prorate, Location: tt.loc})
+			if err != nil {
+				t.Fatalf("GetTotalCost: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetTotalCost = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTotalCostTimeZones(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+
+	u, storage := newTestUseCase(t)
+	userID := uuid.New()
+
+	// 1 February to 1 March, 05:00 in Tokyo: still January and February in UTC.
+	end := time.Date(2025, 2, 28, 20, 0, 0, 0, time.UTC)
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: time.Date(2025, 1, 31, 20, 0, 0, 0, time.UTC), EndedAt: &end})
+
+	tests := []struct {
+		period     string
+		utc, local int
+	}{
+		{period: "01-2025", utc: 100},
+		{period: "02-2025", utc: 100, local: 100},
+		{period: "03-2025", local: 100},
+	}
+
+	for _, tt := range tests {
+		for _, zone := range []struct {
+			loc  *time.Location
+			want int
+		}{{loc: nil, want: tt.utc}, {loc: tokyo, want: tt.local}} {
+			got, err := u.GetTotalCost(context.Background(), userID, nil, tt.period, tt.period, domain.CostOptions{Location: zone.loc})
+			if err != nil {
+				t.Fatalf("GetTotalCost: %v", err)
+			}
+			if got != zone.want {
+				t.Errorf("%s in %v: GetTotalCost = %d, want %d", tt.period, zone.loc, got, zone.want)
+			}
+		}
+	}
+}
+
+func TestGetTotalCostAcrossServices(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	userID := uuid.New()
+
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServiceName: "Spotify", ServicePrice: 30, StartedAt: date(2025, 1, 1)})
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServiceName: "YouTube", ServicePrice: 7, StartedAt: date(2025, 1, 1)})
+
+	tests := []struct {
+		services []string
+		want     int
+	}{
+		{services: []string{"Netflix"}, want: 200},
+		{services: []string{"Netflix", "Spotify"}, want: 260},
+		{services: []string{"Spotify", "Unknown"}, want: 60},
+	}
+
+	for _, tt := range tests {
+		got, err := u.GetTotalCost(context.Background(), userID, tt.services, "01-2025", "02-2025", domain.CostOptions{})
+		if err != nil {
+			t.Fatalf("GetTotalCost(%v): %v", tt.services, err)
+		}
+		if got != tt.want {
+			t.Errorf("GetTotalCost(%v) = %d, want %d", tt.services, got, tt.want)
+		}
+	}
+}
+
+func TestGetTotalCostYearly(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	userID := uuid.New()
+	seedSub(t, storage, domain.UserSub{
+		UserID:        userID,
+		ServicePrice:  12000,
+		BillingPeriod: domain.BillingYearly,
+		StartedAt:     date(2024, 3, 1),
+	})
+
+	ctx := context.Background()
+	services := []string{"Netflix"}
+
+	// The anniversary month bills the whole year.
+	whole, err := u.GetTotalCost(ctx, userID, services, "01-2025", "06-2025", domain.CostOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if whole != 12000 {
+		t.Errorf("whole-month total = %d, want 12000", whole)
+	}
+
+	// Prorating spreads it over the months, a twelfth each.
+	prorated, err := u.GetTotalCost(ctx, userID, services, "01-2025", "06-2025", domain.CostOptions{Prorate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prorated != 6000 {
+		t.Errorf("prorated total = %d, want 6000", prorated)
+	}
+}
+
+func TestGetTotalCostRequireMatch(t *testing.T) {
+	ctx := context.Background()
+	services := []string{"Netflix"}
+
+	t.Run("no match", func(t *testing.T) {
+		u, _ := newTestUseCase(t)
+
+		total, err := u.GetTotalCost(ctx, uuid.New(), services, "01-2025", "03-2025", domain.CostOptions{})
+		if err != nil || total != 0 {
+			t.Fatalf("without require_match = %d, %v; want 0, nil", total, err)
+		}
+
+		_, err = u.GetTotalCost(ctx, uuid.New(), services, "01-2025", "03-2025", domain.CostOptions{RequireMatch: true})
+		if !errors.Is(err, domain.ErrNoMatch) {
+			t.Fatalf("with require_match err = %v, want ErrNoMatch", err)
+		}
+	})
+
+	t.Run("zero-priced match", func(t *testing.T) {
+		u, storage := newTestUseCase(t)
+		userID := uuid.New()
+		seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 0, StartedAt: date(2025, 1, 1)})
+
+		total, err := u.GetTotalCost(ctx, userID, services, "01-2025", "03-2025", domain.CostOptions{RequireMatch: true})
+		if err != nil || total != 0 {
+			t.Fatalf("GetTotalCost = %d, %v; want 0, nil", total, err)
+		}
+	})
+}
+
+// countingStorage counts the storage reads behind a usecase call.
+type countingStorage struct {
+	Storage
+	totals, lists int
+}
+
+func (s *countingStorage) GetTotalCosts(ctx context.Context, userIDs []uuid.UUID, serviceNames []string, from, to time.Time) (map[uuid.UUID]int, error) {
+	s.totals++
+	return s.Storage.GetTotalCosts(ctx, userIDs, serviceNames, from, to)
+}
+
+func (s *countingStorage) ListSubs(ctx context.Context, filter domain.SubFilter, page domain.Page) ([]*domain.UserSub, error) {
+	s.lists++
+	return s.Storage.ListSubs(ctx, filter, page)
+}
+
+func TestGetTotalCostsMatchesGetTotalCost(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	ctx := context.Background()
+
+	alice, bob, carol, dave := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	endedFeb := date(2025, 2, 15)
+	oneTime := date(2025, 3, 5)
+	seedSub(t, storage, domain.UserSub{UserID: alice, ServicePrice: 500, StartedAt: date(2024, 11, 1)})
+	seedSub(t, storage, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 200, StartedAt: date(2025, 2, 10)})
+	seedSub(t, storage, domain.UserSub{UserID: bob, ServicePrice: 700, StartedAt: date(2025, 3, 31)})
+	seedSub(t, storage, domain.UserSub{UserID: bob, ServiceName: "Spotify", ServicePrice: 300, StartedAt: date(2024, 6, 1), EndedAt: &endedFeb})
+	seedSub(t, storage, domain.UserSub{UserID: dave, ServicePrice: 1200, BillingPeriod: domain.BillingYearly, StartedAt: date(2024, 2, 1)})
+	seedSub(t, storage, domain.UserSub{UserID: dave, ServiceName: "Spotify", ServicePrice: 90, StartedAt: oneTime, EndedAt: &oneTime})
+	seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 999, StartedAt: date(2025, 1, 1)})
+
+	counting := &countingStorage{Storage: storage}
+	u.storage = counting
+
+	tests := []struct {
+		name     string
+		services []string
+		want     map[uuid.UUID]int
+	}{
+		{name: "every service", want: map[uuid.UUID]int{alice: 1500 + 400, bob: 700 + 600, carol: 0, dave: 1200 + 90}},
+		{name: "one service", services: []string{"Spotify"}, want: map[uuid.UUID]int{alice: 400, bob: 600, carol: 0, dave: 90}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counting.totals, counting.lists = 0, 0
+
+			totals, err := u.GetTotalCosts(ctx, []uuid.UUID{alice, bob, carol, dave}, tt.services, "01-2025", "03-2025")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if counting.totals != 1 || counting.lists != 0 {
+				t.Errorf("GetTotalCosts read storage with %d grouped and %d per-user queries, want 1 and 0", counting.totals, counting.lists)
+			}
+			if len(totals) != len(tt.want) {
+				t.Errorf("totals = %v, want %v", totals, tt.want)
+			}
+
+			services := tt.services
+			if services == nil {
+				services = []string{"Netflix", "Spotify"}
+			}
+			for userID, want := range tt.want {
+				if totals[userID] != want {
+					t.Errorf("total of %s = %d, want %d", userID, totals[userID], want)
+				}
+
+				single, err := u.GetTotalCost(ctx, userID, services, "01-2025", "03-2025", domain.CostOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if single != totals[userID] {
+					t.Errorf("GetTotalCost of %s = %d, GetTotalCosts = %d", userID, single, totals[userID])
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateSubChecksEndedAtAgainstStoredStart(t *testing.T) {
+	before := date(2025, 2, 1)
+	start := date(2025, 3, 1)
+	after := date(2025, 4, 1)
+
+	tests := []struct {
+		name    string
+		policy  domain.ZeroLengthPolicy
+		endedAt time.Time
+		wantErr string
+	}{
+		{name: "before start", policy: domain.ZeroLengthOneTime, endedAt: before, wantErr: "must not be before started_at"},
+		{name: "zero length rejected", policy: domain.ZeroLengthReject, endedAt: start, wantErr: "must be after started_at"},
+		{name: "zero length as one-time charge", policy: domain.ZeroLengthOneTime, endedAt: start},
+		{name: "after start", policy: domain.ZeroLengthReject, endedAt: after},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, storage := newTestUseCase(t)
+			u.cfg.Billing.ZeroLength = tt.policy
+			sub := seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 500, StartedAt: start})
+
+			endedAt := tt.endedAt
+			affected, err := u.UpdateSub(context.Background(), domain.SubUpdate{
+				ID:           sub.ID,
+				UserID:       sub.UserID,
+				ServiceName:  sub.ServiceName,
+				ServicePrice: 600,
+				EndedAt:      domain.Some(&endedAt),
+			})
+
+			stored, getErr := storage.GetUserSub(context.Background(), sub.ID)
+			if getErr != nil {
+				t.Fatal(getErr)
+			}
+
+			if tt.wantErr == "" {
+				if err != nil || affected != 1 {
+					t.Fatalf("UpdateSub = %d, %v; want 1, nil", affected, err)
+				}
+				if stored.EndedAt == nil || !stored.EndedAt.Equal(endedAt) || stored.ServicePrice != 600 {
+					t.Errorf("stored = %+v, want the update applied", stored)
+				}
+				return
+			}
+
+			var fieldErrs validation.Errors
+			if !errors.As(err, &fieldErrs) {
+				t.Fatalf("UpdateSub err = %v, want validation errors", err)
+			}
+			if len(fieldErrs) != 1 || fieldErrs[0].Field != "ended_at" || fieldErrs[0].Message != tt.wantErr {
+				t.Errorf("errors = %v, want ended_at: %s", fieldErrs, tt.wantErr)
+			}
+			if stored.EndedAt != nil || stored.ServicePrice != 500 {
+				t.Errorf("stored = %+v, want it unchanged", stored)
+			}
+		})
+	}
+}
+
+func TestUpdateSubEndedAtIntents(t *testing.T) {
+	start := date(2025, 1, 1)
+	ended := date(2025, 6, 1)
+	moved := date(2025, 9, 1)
+
+	tests := []struct {
+		name    string
+		endedAt domain.Optional[*time.Time]
+		want    *time.Time
+	}{
+		{name: "omitted keeps the end date", want: &ended},
+		{name: "null clears it", endedAt: domain.Some[*time.Time](nil)},
+		{name: "value replaces it", endedAt: domain.Some(&moved), want: &moved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, storage := newTestUseCase(t)
+			sub := seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 500, StartedAt: start, EndedAt: &ended})
+
+			affected, err := u.UpdateSub(context.Background(), domain.SubUpdate{
+				ID:           sub.ID,
+				UserID:       sub.UserID,
+				ServiceName:  sub.ServiceName,
+				ServicePrice: 600,
+				EndedAt:      tt.endedAt,
+			})
+			if err != nil || affected != 1 {
+				t.Fatalf("UpdateSub = %d, %v; want 1, nil", affected, err)
+			}
+
+			stored, err := storage.GetUserSub(context.Background(), sub.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalTimes(stored.EndedAt, tt.want) {
+				t.Errorf("ended_at = %v, want %v", stored.EndedAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateSubUnknown(t *testing.T) {
+	u, _ := newTestUseCase(t)
+
+	affected, err := u.UpdateSub(context.Background(), domain.SubUpdate{
+		ID:           uuid.New(),
+		UserID:       uuid.New(),
+		ServiceName:  "Netflix",
+		ServicePrice: 100,
+	})
+	if err != nil || affected != 0 {
+		t.Fatalf("UpdateSub = %d, %v; want 0, nil", affected, err)
+	}
+}
+
+type recordingPublisher struct {
+	events []domain.SubEvent
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event domain.SubEvent) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestUpdatedEventCarriesStoredRow(t *testing.T) {
+	method := "visa-1234"
+	endedAt := date(2026, 1, 1)
+
+	tests := []struct {
+		name   string
+		update func(u *UseCase, sub domain.UserSub) error
+	}{
+		{
+			name: "put",
+			update: func(u *UseCase, sub domain.UserSub) error {
+				// ended_at and payment_method are omitted, so they stay.
+				_, err := u.UpdateSub(context.Background(), domain.SubUpdate{
+					ID:           sub.ID,
+					UserID:       sub.UserID,
+					ServiceName:  sub.ServiceName,
+					ServicePrice: 900,
+				})
+				return err
+			},
+		},
+		{
+			name: "patch",
+			update: func(u *UseCase, sub domain.UserSub) error {
+				_, err := u.PatchSub(context.Background(), sub.ID, []byte(`{"service_price": 900}`))
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, storage := newTestUseCase(t)
+			publisher := &recordingPublisher{}
+			u.publisher = publisher
+
+			sub := seedSub(t, storage, domain.UserSub{
+				UserID:        uuid.New(),
+				ServicePrice:  500,
+				StartedAt:     date(2025, 3, 1),
+				EndedAt:       &endedAt,
+				PaymentMethod: &method,
+			})
+			if err := storage.AddTags(context.Background(), sub.ID, []string{"work"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.update(u, sub); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(publisher.events) != 1 {
+				t.Fatalf("published %d events, want 1", len(publisher.events))
+			}
+			event := publisher.events[0]
+			got := event.Sub
+			if event.Type != domain.EventSubscriptionUpdated || got == nil {
+				t.Fatalf("event = %+v, want %s with a payload", event, domain.EventSubscriptionUpdated)
+			}
+
+			if got.ServicePrice != 900 || !got.StartedAt.Equal(sub.StartedAt) || got.CreatedAt.IsZero() {
+				t.Errorf("payload = %+v, want the stored row with price 900", got)
+			}
+			if got.EndedAt == nil || !got.EndedAt.Equal(endedAt) {
+				t.Errorf("payload ended_at = %v, want %v", got.EndedAt, endedAt)
+			}
+			if got.PaymentMethod == nil || *got.PaymentMethod != method {
+				t.Errorf("payload payment_method = %v, want %q", got.PaymentMethod, method)
+			}
+			if len(got.Tags) != 1 || got.Tags[0] != "work" {
+				t.Errorf("payload tags = %v, want [work]", got.Tags)
+			}
+		})
+	}
+}
+
+func TestUpdatePricesRecordsPriceHistory(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	ctx := context.Background()
+
+	cheap := seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 500, StartedAt: date(2025, 1, 1)})
+	current := seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 800, StartedAt: date(2025, 1, 1)})
+	other := seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServiceName: "Spotify", ServicePrice: 300, StartedAt: date(2025, 1, 1)})
+
+	updated, err := u.UpdatePrices(ctx, domain.SubFilter{ServiceName: "Netflix"}, 800)
+	if err != nil {
+		t.Fatalf("UpdatePrices: %v", err)
+	}
+	if updated != 2 {
+		t.Errorf("updated = %d, want 2", updated)
+	}
+
+	history, err := u.PriceHistory(ctx, cheap.ID)
+	if err != nil {
+		t.Fatalf("PriceHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].OldPrice != 500 || history[0].NewPrice != 800 || history[0].ChangedAt.IsZero() {
+		t.Errorf("history of the repriced subscription = %+v, want one change from 500 to 800", history)
+	}
+
+	for _, sub := range []domain.UserSub{current, other} {
+		history, err := u.PriceHistory(ctx, sub.ID)
+		if err != nil {
+			t.Fatalf("PriceHistory: %v", err)
+		}
+		if len(history) != 0 {
+			t.Errorf("history of %s at %d = %+v, want none", sub.ServiceName, sub.ServicePrice, history)
+		}
+	}
+}
+
+func TestFacets(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	userID := uuid.New()
+	past := date(2025, 2, 1)
+	future := time.Now().UTC().AddDate(1, 0, 0)
+
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 200, StartedAt: date(2025, 1, 1), EndedAt: &future})
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServiceName: "Spotify", ServicePrice: 301, StartedAt: date(2025, 1, 1), EndedAt: &past})
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServiceName: "Spotify", ServicePrice: 200, StartedAt: future})
+	seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 5000, StartedAt: date(2025, 1, 1)})
+
+	facets, err := u.Facets(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("Facets: %v", err)
+	}
+
+	wantServices := []domain.FacetBucket{{Value: "Netflix", Count: 2}, {Value: "Spotify", Count: 2}}
+	if !slices.Equal(facets.Services, wantServices) {
+		t.Errorf("services = %+v, want %+v", facets.Services, wantServices)
+	}
+
+	wantStatuses := []domain.FacetBucket{
+		{Value: domain.StatusActive, Count: 2},
+		{Value: domain.StatusEnded, Count: 1},
+		{Value: domain.StatusUpcoming, Count: 1},
+	}
+	if !slices.Equal(facets.Statuses, wantStatuses) {
+		t.Errorf("statuses = %+v, want %+v", facets.Statuses, wantStatuses)
+	}
+
+	if want := (domain.PriceFacet{Min: 100, Max: 301, Avg: 200}); facets.Price != want {
+		t.Errorf("price = %+v, want %+v", facets.Price, want)
+	}
+}
+
+func TestSendRemindersHonorsEachUsersLeadTime(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	publisher := &recordingPublisher{}
+	u.publisher = publisher
+	ctx := context.Background()
+
+	endsAt := date(2025, 6, 20)
+	early, late := uuid.New(), uuid.New()
+	for userID, lead := range map[uuid.UUID]int{early: 10, late: 3} {
+		if err := u.SetReminderPreference(ctx, domain.ReminderPreference{UserID: userID, LeadTimeDays: lead, Channel: domain.ReminderEmail}); err != nil {
+			t.Fatalf("SetReminderPreference: %v", err)
+		}
+	}
+
+	earlySub := seedSub(t, storage, domain.UserSub{UserID: early, ServicePrice: 100, StartedAt: date(2025, 1, 1), EndedAt: &endsAt})
+	lateSub := seedSub(t, storage, domain.UserSub{UserID: late, ServicePrice: 100, StartedAt: date(2025, 1, 1), EndedAt: &endsAt})
+	// Neither an auto-renewing subscription nor a user without preferences
+	// gets a reminder.
+	seedSub(t, storage, domain.UserSub{UserID: early, ServicePrice: 100, StartedAt: date(2025, 1, 1), EndedAt: &endsAt, AutoRenew: true})
+	seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 100, StartedAt: date(2025, 1, 1), EndedAt: &endsAt})
+
+	// Daily windows ending at midnight, as a job ticking daily would cover.
+	tests := []struct {
+		to   time.Time
+		want []uuid.UUID
+	}{
+		{to: date(2025, 6, 9)},
+		{to: date(2025, 6, 10), want: []uuid.UUID{earlySub.ID}},
+		{to: date(2025, 6, 11)},
+		{to: date(2025, 6, 16)},
+		{to: date(2025, 6, 17), want: []uuid.UUID{lateSub.ID}},
+		{to: date(2025, 6, 18)},
+		{to: date(2025, 6, 21)},
+	}
+
+	for _, tt := range tests {
+		publisher.events = nil
+		from := tt.to.AddDate(0, 0, -1)
+
+		sent, err := u.SendReminders(ctx, from, tt.to)
+		if err != nil {
+			t.Fatalf("SendReminders(%s): %v", tt.to.Format(time.DateOnly), err)
+		}
+
+		var got []uuid.UUID
+		for _, event := range publisher.events {
+			if event.Type != domain.EventSubscriptionExpiring || event.Channel != domain.ReminderEmail || event.Sub == nil {
+				t.Errorf("%s: event = %+v, want an expiring email reminder with the subscription", tt.to.Format(time.DateOnly), event)
+			}
+			got = append(got, event.SubscriptionID)
+		}
+
+		if sent != len(tt.want) || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: sent %d reminders for %v, want %v", tt.to.Format(time.DateOnly), sent, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteSubsByFilterRemovesOnlyMatches(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	ctx := context.Background()
+
+	alice, bob := uuid.New(), uuid.New()
+	seedSub(t, storage, domain.UserSub{UserID: alice, ServiceName: "Netflix", ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+	spotify := seedSub(t, storage, domain.UserSub{UserID: alice, ServiceName: "Spotify", ServicePrice: 200, StartedAt: date(2025, 1, 1)})
+	bobs := seedSub(t, storage, domain.UserSub{UserID: bob, ServiceName: "Netflix", ServicePrice: 100, StartedAt: date(2025, 1, 1)})
+
+	for _, filter := range []domain.SubFilter{{}, {ExcludeFree: true}} {
+		if _, err := u.DeleteSubsByFilter(ctx, filter); !errors.Is(err, domain.ErrEmptyFilter) {
+			t.Errorf("DeleteSubsByFilter(%+v) err = %v, want ErrEmptyFilter", filter, err)
+		}
+	}
+
+	deleted, err := u.DeleteSubsByFilter(ctx, domain.SubFilter{UserID: &alice, ServiceName: "Netflix"})
+	if err != nil {
+		t.Fatalf("DeleteSubsByFilter: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+
+	remaining, err := storage.ListSubs(ctx, domain.SubFilter{}, domain.Page{})
+	if err != nil {
+		t.Fatalf("ListSubs: %v", err)
+	}
+
+	var got []uuid.UUID
+	for _, sub := range remaining {
+		got = append(got, sub.ID)
+	}
+	want := []uuid.UUID{spotify.ID, bobs.ID}
+	slices.SortFunc(got, compareUUIDs)
+	slices.SortFunc(want, compareUUIDs)
+
+	if !slices.Equal(got, want) {
+		t.Errorf("remaining = %v, want %v", got, want)
+	}
+}
+
+func compareUUIDs(a, b uuid.UUID) int {
+	return strings.Compare(a.String(), b.String())
+}
+
+func TestComparePeriods(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	userID := uuid.New()
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: date(2024, 12, 1)})
+
+	got, err := u.ComparePeriods(context.Background(), userID, nil,
+		domain.ParsePeriod("01-2025:02-2025"), domain.ParsePeriod("03-2025:05-2025"), domain.CostOptions{})
+	if err != nil {
+		t.Fatalf("ComparePeriods: %v", err)
+	}
+
+	if got.PeriodA.Total != 200 || got.PeriodB.Total != 300 || got.Delta != 100 {
+		t.Errorf("totals = %d, %d, delta %d; want 200, 300, delta 100", got.PeriodA.Total, got.PeriodB.Total, got.Delta)
+	}
+	if got.PercentChange == nil || *got.PercentChange != "50.00" {
+		t.Errorf("percent change = %v, want 50.00", got.PercentChange)
+	}
+
+	if _, err := u.ComparePeriods(context.Background(), userID, nil,
+		domain.ParsePeriod("01-2025"), domain.ParsePeriod("2025-03"), domain.CostOptions{}); !errors.Is(err, domain.ErrInvalidPeriod) {
+		t.Errorf("ComparePeriods with a malformed period: err = %v, want ErrInvalidPeriod", err)
+	}
+}
+
+func TestForecast(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	userID := uuid.New()
+
+	now := time.Now().UTC()
+	thisMonth := domain.MonthStart(now)
+	yearAgo := thisMonth.AddDate(-1, 0, 0)
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+	yesterday := now.AddDate(0, 0, -1)
+	nextMonthMid := thisMonth.AddDate(0, 1, 14)
+
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: yearAgo})
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 500, StartedAt: yearAgo, EndedAt: &lastMonth})
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 50, StartedAt: yearAgo, EndedAt: &yesterday, AutoRenew: true})
+	seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 200, StartedAt: yearAgo, EndedAt: &nextMonthMid})
+
+	forecast, err := u.Forecast(context.Background(), userID, 3)
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+
+	want := []domain.MonthCost{
+		{Month: thisMonth.Format(domain.MonthLayout), Cost: 350},
+		{Month: thisMonth.AddDate(0, 1, 0).Format(domain.MonthLayout), Cost: 350},
+		{Month: thisMonth.AddDate(0, 2, 0).Format(domain.MonthLayout), Cost: 150},
+	}
+	if !slices.Equal(forecast, want) {
+		t.Errorf("Forecast = %v, want %v", forecast, want)
+	}
+}
+
+func TestForecastAutoRenew(t *testing.T) {
+	thisMonth := domain.MonthStart(time.Now().UTC())
+	endsNextMonth := thisMonth.AddDate(0, 1, 14)
+
+	tests := []struct {
+		autoRenew bool
+		want      []int
+	}{
+		{autoRenew: false, want: []int{100, 100, 0, 0}},
+		{autoRenew: true, want: []int{100, 100, 100, 100}},
+	}
+
+	for _, tt := range tests {
+		u, storage := newTestUseCase(t)
+		userID := uuid.New()
+		seedSub(t, storage, domain.UserSub{UserID: userID, ServicePrice: 100, StartedAt: thisMonth.AddDate(0, -6, 0), EndedAt: &endsNextMonth, AutoRenew: tt.autoRenew})
+
+		forecast, err := u.Forecast(context.Background(), userID, len(tt.want))
+		if err != nil {
+			t.Fatalf("Forecast: %v", err)
+		}
+		costs := make([]int, 0, len(forecast))
+		for _, month := range forecast {
+			costs = append(costs, month.Cost)
+		}
+		if !slices.Equal(costs, tt.want) {
+			t.Errorf("auto_renew=%v: forecast = %v, want %v", tt.autoRenew, costs, tt.want)
+		}
+	}
+}
+
+func TestMRR(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	ended := date(2025, 2, 1)
+
+	seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 1000, StartedAt: date(2024, 1, 1)})
+	seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 1200, StartedAt: date(2024, 6, 1), BillingPeriod: domain.BillingYearly})
+	seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 1000, StartedAt: date(2024, 9, 1), BillingPeriod: domain.BillingYearly})
+	seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 500, StartedAt: date(2024, 1, 1), EndedAt: &ended})
+	seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 700, StartedAt: date(2025, 4, 1)})
+
+	// 1000 + 1200/12 + 1000/12 = 1183.33.
+	got, err := u.MRR(context.Background(), "03-2025")
+	if err != nil {
+		t.Fatalf("MRR: %v", err)
+	}
+	if got != 1183 {
+		t.Errorf("MRR = %d, want 1183", got)
+	}
+
+	if _, err := u.MRR(context.Background(), "2025-03"); !errors.Is(err, domain.ErrInvalidPeriod) {
+		t.Errorf("MRR of a malformed month: err = %v, want ErrInvalidPeriod", err)
+	}
+}
+
+func TestSavings(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	now := time.Now().UTC()
+	thisMonth := domain.MonthStart(now)
+	yearAgo := thisMonth.AddDate(-1, 0, 0)
+	yesterday := now.AddDate(0, 0, -1)
+	nextMonthMid := thisMonth.AddDate(0, 1, 14)
+
+	tests := []struct {
+		name string
+		sub  domain.UserSub
+		want int
+	}{
+		{name: "open", sub: domain.UserSub{ServicePrice: 100, StartedAt: yearAgo}, want: 300},
+		{name: "auto-renewing past its end", sub: domain.UserSub{ServicePrice: 50, StartedAt: yearAgo, EndedAt: &yesterday, AutoRenew: true}, want: 150},
+		{name: "ending next month", sub: domain.UserSub{ServicePrice: 200, StartedAt: yearAgo, EndedAt: &nextMonthMid}, want: 200},
+		{name: "ended", sub: domain.UserSub{ServicePrice: 500, StartedAt: yearAgo, EndedAt: &yesterday}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sub.UserID = userID
+			sub := seedSub(t, storage, tt.sub)
+
+			got, err := u.Savings(ctx, sub.ID, 3)
+			if err != nil {
+				t.Fatalf("Savings: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Savings = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := u.Savings(ctx, uuid.New(), 3); !errors.Is(err, domain.ErrSubNotFound) {
+		t.Errorf("Savings of an unknown subscription: err = %v, want ErrSubNotFound", err)
+	}
+}
+
+// failingStorage fails every subscription lookup with err.
+type failingStorage struct {
+	Storage
+	err error
+}
+
+func (s failingStorage) GetUserSub(ctx context.Context, subID uuid.UUID) (*domain.UserSub, error) {
+	return nil, s.err
+}
+
+func TestFailuresLogAtTheirLevel(t *testing.T) {
+	start := date(2025, 3, 1)
+	before := date(2025, 2, 1)
+
+	tests := []struct {
+		name  string
+		call  func(u *UseCase, sub domain.UserSub) error
+		level string
+	}{
+		{
+			name: "invalid update",
+			call: func(u *UseCase, sub domain.UserSub) error {
+				_, err := u.UpdateSub(context.Background(), domain.SubUpdate{ID: sub.ID, UserID: sub.UserID, ServiceName: sub.ServiceName, ServicePrice: 1, EndedAt: domain.Some(&before)})
+				return err
+			},
+			level: "WARN",
+		},
+		{
+			name: "invalid create",
+			call: func(u *UseCase, sub domain.UserSub) error {
+				sub.ServicePrice = -1
+				_, err := u.CreateSub(context.Background(), sub)
+				return err
+			},
+			level: "WARN",
+		},
+		{
+			name: "unknown subscription",
+			call: func(u *UseCase, sub domain.UserSub) error {
+				_, err := u.GetUserSub(context.Background(), uuid.New())
+				return err
+			},
+			level: "WARN",
+		},
+		{
+			name: "malformed period",
+			call: func(u *UseCase, sub domain.UserSub) error {
+				_, err := u.GetTotalCost(context.Background(), sub.UserID, nil, "2025-01", "03-2025", domain.CostOptions{})
+				return err
+			},
+			level: "WARN",
+		},
+		{
+			name: "storage failure",
+			call: func(u *UseCase, sub domain.UserSub) error {
+				u.storage = failingStorage{Storage: u.storage, err: errors.New("connection reset")}
+				_, err := u.GetUserSub(context.Background(), sub.ID)
+				return err
+			},
+			level: "ERROR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, storage := newTestUseCase(t)
+			sub := seedSub(t, storage, domain.UserSub{UserID: uuid.New(), ServicePrice: 500, StartedAt: start})
+
+			var buf bytes.Buffer
+			u.log = slog.New(slog.NewTextHandler(&buf, nil))
+
+			if err := tt.call(u, sub); err == nil {
+				t.Fatal("err = nil, want a failure")
+			}
+
+			if got := buf.String(); !strings.Contains(got, "level="+tt.level) || strings.Count(got, "level=") != 1 {
+				t.Errorf("logged %q, want one %s line", got, tt.level)
+			}
+		})
+	}
+}
+
+func TestDeduplicateSubsLeavesOneActive(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	alice, bob := uuid.New(), uuid.New()
+	ended := now.AddDate(0, -1, 0)
+	later := now.AddDate(0, 1, 0)
+
+	oldest := seedSub(t, storage, domain.UserSub{UserID: alice, StartedAt: now.AddDate(0, -3, 0)})
+	older := seedSub(t, storage, domain.UserSub{UserID: alice, StartedAt: now.AddDate(0, -2, 0)})
+	newest := seedSub(t, storage, domain.UserSub{UserID: alice, StartedAt: now.AddDate(0, 0, -1)})
+	future := seedSub(t, storage, domain.UserSub{UserID: alice, StartedAt: later})
+	past := seedSub(t, storage, domain.UserSub{UserID: alice, StartedAt: now.AddDate(-1, 0, 0), EndedAt: &ended})
+	spotify := seedSub(t, storage, domain.UserSub{UserID: alice, ServiceName: "Spotify", StartedAt: now.AddDate(0, -3, 0)})
+	bobs := seedSub(t, storage, domain.UserSub{UserID: bob, StartedAt: now.AddDate(0, -3, 0)})
+
+	report, err := u.DeduplicateSubs(ctx)
+	if err != nil {
+		t.Fatalf("DeduplicateSubs: %v", err)
+	}
+
+	if report.Ended != 2 || len(report.Groups) != 1 {
+		t.Fatalf("report = %+v, want one group with two ended", report)
+	}
+	group := report.Groups[0]
+	endedIDs := slices.Clone(group.EndedIDs)
+	slices.SortFunc(endedIDs, compareUUIDs)
+	want := []uuid.UUID{oldest.ID, older.ID}
+	slices.SortFunc(want, compareUUIDs)
+	if group.UserID != alice || group.ServiceName != "Netflix" || group.KeptID != newest.ID || !slices.Equal(endedIDs, want) {
+		t.Errorf("group = %+v, want %s kept and %v ended", group, newest.ID, want)
+	}
+
+	for _, tt := range []struct {
+		sub    domain.UserSub
+		active bool
+	}{
+		{sub: oldest},
+		{sub: older},
+		{sub: newest, active: true},
+		{sub: spotify, active: true},
+		{sub: bobs, active: true},
+	} {
+		stored, err := storage.GetUserSub(ctx, tt.sub.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.ActiveAt(now.Add(time.Second)) != tt.active {
+			t.Errorf("%s active = %v, want %v", stored.StartedAt.Format(time.DateOnly), !tt.active, tt.active)
+		}
+	}
+
+	for _, untouched := range []domain.UserSub{future, past} {
+		stored, err := storage.GetUserSub(ctx, untouched.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalTimes(stored.EndedAt, untouched.EndedAt) {
+			t.Errorf("subscription starting %s ended_at = %v, want %v", untouched.StartedAt.Format(time.DateOnly), stored.EndedAt, untouched.EndedAt)
+		}
+	}
+
+	again, err := u.DeduplicateSubs(ctx)
+	if err != nil {
+		t.Fatalf("second DeduplicateSubs: %v", err)
+	}
+	if again.Ended != 0 {
+		t.Errorf("second run ended %d, want 0", again.Ended)
+	}
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+func TestMutationsReportRowsAffected(t *testing.T) {
+	u, storage := newTestUseCase(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	newSub := func(service string) domain.UserSub {
+		return domain.UserSub{UserID: userID, ServiceName: service, ServicePrice: 100, Currency: "RUB", StartedAt: date(2025, 1, 1)}
+	}
+
+	created, err := u.CreateSub(ctx, newSub("Netflix"))
+	if err != nil || created != 1 {
+		t.Fatalf("CreateSub = %d, %v; want 1, nil", created, err)
+	}
+
+	created, err = u.CreateSubs(ctx, []domain.UserSub{newSub("Spotify"), newSub("YouTube"), newSub("Kinopoisk")})
+	if err != nil || created != 3 {
+		t.Fatalf("CreateSubs = %d, %v; want 3, nil", created, err)
+	}
+
+	subs, err := storage.GetUserSubs(ctx, userID)
+	if err != nil || len(subs) != 4 {
+		t.Fatalf("GetUserSubs = %d subscriptions, %v; want 4", len(subs), err)
+	}
+	sub := subs[0]
+
+	updated, err := u.UpdateSub(ctx, domain.SubUpdate{ID: sub.ID, UserID: userID, ServiceName: sub.ServiceName, ServicePrice: 150})
+	if err != nil || updated != 1 {
+		t.Errorf("UpdateSub = %d, %v; want 1, nil", updated, err)
+	}
+
+	for name, update := range map[string]domain.SubUpdate{
+		"unknown id":    {ID: uuid.New(), UserID: userID, ServiceName: "Netflix", ServicePrice: 150},
+		"another owner": {ID: sub.ID, UserID: uuid.New(), ServiceName: "Netflix", ServicePrice: 150},
+	} {
+		if updated, err := u.UpdateSub(ctx, update); err != nil || updated != 0 {
+			t.Errorf("UpdateSub of %s = %d, %v; want 0, nil", name, updated, err)
+		}
+	}
+
+	priced, err := u.UpdatePrices(ctx, domain.SubFilter{UserID: &userID, ServiceNames: []string{"Spotify", "YouTube"}}, 90)
+	if err != nil || priced != 2 {
+		t.Errorf("UpdatePrices = %d, %v; want 2, nil", priced, err)
+	}
+
+	if deleted, err := u.DeleteSub(ctx, sub.ID, uuid.New()); err != nil || deleted != 0 {
+		t.Errorf("DeleteSub by another owner = %d, %v; want 0, nil", deleted, err)
+	}
+	if deleted, err := u.DeleteSub(ctx, sub.ID, userID); err != nil || deleted != 1 {
+		t.Errorf("DeleteSub = %d, %v; want 1, nil", deleted, err)
+	}
+	if deleted, err := u.DeleteSub(ctx, sub.ID, userID); err != nil || deleted != 0 {
+		t.Errorf("DeleteSub again = %d, %v; want 0, nil", deleted, err)
+	}
+
+	deleted, err := u.DeleteSubsByFilter(ctx, domain.SubFilter{UserID: &userID})
+	if err != nil || deleted != 3 {
+		t.Errorf("DeleteSubsByFilter = %d, %v; want 3, nil", deleted, err)
+	}
+}
@@ -0,0 +1,235 @@
+package validation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testovoe/internal/domain"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxServiceNameLen   = 255
+	MaxPaymentMethodLen = 64
+	MaxTagLen           = 64
+	MaxCategoryLen      = 32
+	MaxReminderLeadDays = 90
+)
+
+type FieldError struct {
+	Field   string `json:"field" example:"service_price"`
+	Message string `json:"message" example:"must not be negative"`
+}
+
+// Errors collects every rule a payload broke, so clients can fix them in one go.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *Errors) add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Rules are the operator-configurable parts of validation.
+type Rules struct {
+	// Currencies lists the accepted currency codes. Empty accepts any.
+	Currencies []string
+	// MaxPrice caps service_price for services without an entry in
+	// ServiceMaxPrices. Zero means no cap.
+	MaxPrice int
+	// ServiceMaxPrices caps service_price per service name, matched
+	// case-insensitively.
+	ServiceMaxPrices map[string]int
+	// RejectZeroLength refuses an ended_at equal to started_at.
+	RejectZeroLength bool
+}
+
+// maxPriceFor returns the price cap for service and whether one applies.
+func (r Rules) maxPriceFor(service string) (int, bool) {
+	for name, max := range r.ServiceMaxPrices {
+		if strings.EqualFold(name, service) {
+			return max, true
+		}
+	}
+
+	return r.MaxPrice, r.MaxPrice > 0
+}
+
+// strictestMaxPrice returns the lowest of all price caps and whether any
+// applies.
+func (r Rules) strictestMaxPrice() (int, bool) {
+	lowest, ok := r.MaxPrice, r.MaxPrice > 0
+	for _, max := range r.ServiceMaxPrices {
+		if !ok || max < lowest {
+			lowest, ok = max, true
+		}
+	}
+
+	return lowest, ok
+}
+
+func validatePrice(errs *Errors, field string, price, max int, capped bool) {
+	if price < 0 {
+		errs.add(field, "must not be negative")
+	} else if capped && price > max {
+		errs.add(field, fmt.Sprintf("must be at most %d", max))
+	}
+}
+
+// ValidateNewPrice checks a price set on many subscriptions at once. Without
+// service the subscriptions may belong to any service, so the price must
+// satisfy every cap.
+func ValidateNewPrice(service string, price int, rules Rules) error {
+	var errs Errors
+
+	if service = strings.TrimSpace(service); service != "" {
+		max, capped := rules.maxPriceFor(service)
+		validatePrice(&errs, "new_price", price, max, capped)
+	} else {
+		max, capped := rules.strictestMaxPrice()
+		validatePrice(&errs, "new_price", price, max, capped)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateServiceName(errs *Errors, field, name string) {
+	switch {
+	case name == "":
+		errs.add(field, "is required")
+	case utf8.RuneCountInString(name) > MaxServiceNameLen:
+		errs.add(field, "must be at most 255 characters")
+	}
+}
+
+// ValidateRename checks the service names of a rename: both are required and
+// must differ.
+func ValidateRename(from, to string) error {
+	var errs Errors
+
+	validateServiceName(&errs, "from", from)
+	validateServiceName(&errs, "to", to)
+
+	if from != "" && from == to {
+		errs.add("to", "must differ from from")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// ValidateUserSub checks a subscription payload against the business rules
+// shared by create, update and import. It returns Errors or nil.
+func ValidateUserSub(sub domain.UserSub, rules Rules) error {
+	var errs Errors
+
+	name := strings.TrimSpace(sub.ServiceName)
+	validateServiceName(&errs, "service_name", name)
+
+	max, capped := rules.maxPriceFor(name)
+	validatePrice(&errs, "service_price", sub.ServicePrice, max, capped)
+
+	if sub.UserID == uuid.Nil {
+		errs.add("user_id", "is required")
+	}
+
+	if sub.Currency != "" && len(rules.Currencies) > 0 && !slices.Contains(rules.Currencies, sub.Currency) {
+		errs.add("currency", "must be one of "+strings.Join(rules.Currencies, ", "))
+	}
+
+	if sub.BillingPeriod != "" && !sub.BillingPeriod.Valid() {
+		errs.add("billing_period", "must be monthly or yearly")
+	}
+
+	if sub.PaymentMethod != nil {
+		method := strings.TrimSpace(*sub.PaymentMethod)
+		switch {
+		case method == "":
+			errs.add("payment_method", "must not be blank")
+		case utf8.RuneCountInString(method) > MaxPaymentMethodLen:
+			errs.add("payment_method", "must be at most 64 characters")
+		}
+	}
+
+	if utf8.RuneCountInString(sub.Category) > MaxCategoryLen {
+		errs.add("category", "must be at most 32 characters")
+	}
+
+	if sub.EndedAt != nil && !sub.StartedAt.IsZero() {
+		switch {
+		case sub.EndedAt.Before(sub.StartedAt):
+			errs.add("ended_at", "must not be before started_at")
+		case rules.RejectZeroLength && sub.EndedAt.Equal(sub.StartedAt):
+			errs.add("ended_at", "must be after started_at")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// ValidateTags checks tags about to be attached to a subscription.
+func ValidateTags(tags []string) error {
+	var errs Errors
+
+	if len(tags) == 0 {
+		errs.add("tags", "must not be empty")
+	}
+
+	for i, tag := range tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		switch {
+		case strings.TrimSpace(tag) == "":
+			errs.add(field, "must not be blank")
+		case utf8.RuneCountInString(tag) > MaxTagLen:
+			errs.add(field, "must be at most 64 characters")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func ValidateReminderPreference(pref domain.ReminderPreference) error {
+	var errs Errors
+
+	if pref.UserID == uuid.Nil {
+		errs.add("user_id", "is required")
+	}
+
+	if pref.LeadTimeDays < 0 || pref.LeadTimeDays > MaxReminderLeadDays {
+		errs.add("lead_time_days", "must be between 0 and 90")
+	}
+
+	if !pref.Channel.Valid() {
+		errs.add("channel", "must be email, push or sms")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
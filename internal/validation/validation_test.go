@@ -0,0 +1,228 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func fields(err error) []string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+
+	out := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		out = append(out, fe.Field+": "+fe.Message)
+	}
+
+	return out
+}
+
+func assertFields(t *testing.T, err error, want []string) {
+	t.Helper()
+
+	if len(want) == 0 {
+		if err != nil {
+			t.Fatalf("err = %v, want nil", err)
+		}
+		return
+	}
+
+	got := fields(err)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+}
+
+func validSub() domain.UserSub {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	return domain.UserSub{
+		ServiceName:   "Netflix",
+		ServicePrice:  990,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartedAt:     start,
+		BillingPeriod: domain.BillingMonthly,
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestValidateUserSub(t *testing.T) {
+	start := validSub().StartedAt
+	rules := Rules{
+		Currencies:       []string{"RUB", "USD"},
+		MaxPrice:         10000,
+		ServiceMaxPrices: map[string]int{"Spotify": 500},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*domain.UserSub)
+		rules  *Rules
+		want   []string
+	}{
+		{name: "valid", modify: func(*domain.UserSub) {}},
+		{name: "blank service name", modify: func(s *domain.UserSub) { s.ServiceName = "  " }, want: []string{"service_name: is required"}},
+		{name: "long service name", modify: func(s *domain.UserSub) { s.ServiceName = strings.Repeat("я", MaxServiceNameLen+1) }, want: []string{"service_name: must be at most 255 characters"}},
+		{name: "service name at the limit", modify: func(s *domain.UserSub) { s.ServiceName = strings.Repeat("я", MaxServiceNameLen) }},
+		{name: "negative price", modify: func(s *domain.UserSub) { s.ServicePrice = -1 }, want: []string{"service_price: must not be negative"}},
+		{name: "free", modify: func(s *domain.UserSub) { s.ServicePrice = 0 }},
+		{name: "over the global cap", modify: func(s *domain.UserSub) { s.ServicePrice = 10001 }, want: []string{"service_price: must be at most 10000"}},
+		{name: "over the service cap", modify: func(s *domain.UserSub) { s.ServiceName, s.ServicePrice = "spotify", 501 }, want: []string{"service_price: must be at most 500"}},
+		{name: "at the service cap", modify: func(s *domain.UserSub) { s.ServiceName, s.ServicePrice = "Spotify", 500 }},
+		{name: "no cap configured", modify: func(s *domain.UserSub) { s.ServicePrice = 1 << 30 }, rules: &Rules{}},
+		{name: "missing user", modify: func(s *domain.UserSub) { s.UserID = uuid.Nil }, want: []string{"user_id: is required"}},
+		{name: "unknown currency", modify: func(s *domain.UserSub) { s.Currency = "EUR" }, want: []string{"currency: must be one of RUB, USD"}},
+		{name: "any currency without a list", modify: func(s *domain.UserSub) { s.Currency = "EUR" }, rules: &Rules{}},
+		{name: "empty currency", modify: func(s *domain.UserSub) { s.Currency = "" }},
+		{name: "unknown billing period", modify: func(s *domain.UserSub) { s.BillingPeriod = "weekly" }, want: []string{"billing_period: must be monthly or yearly"}},
+		{name: "blank payment method", modify: func(s *domain.UserSub) { s.PaymentMethod = ptr(" ") }, want: []string{"payment_method: must not be blank"}},
+		{name: "long payment method", modify: func(s *domain.UserSub) { s.PaymentMethod = ptr(strings.Repeat("x", MaxPaymentMethodLen+1)) }, want: []string{"payment_method: must be at most 64 characters"}},
+		{name: "long category", modify: func(s *domain.UserSub) { s.Category = strings.Repeat("x", MaxCategoryLen+1) }, want: []string{"category: must be at most 32 characters"}},
+		{name: "ended before start", modify: func(s *domain.UserSub) { s.EndedAt = ptr(start.AddDate(0, 0, -1)) }, want: []string{"ended_at: must not be before started_at"}},
+		{name: "ended after start", modify: func(s *domain.UserSub) { s.EndedAt = ptr(start.AddDate(0, 1, 0)) }},
+		{name: "zero length allowed", modify: func(s *domain.UserSub) { s.EndedAt = ptr(start) }},
+		{name: "zero length rejected", modify: func(s *domain.UserSub) { s.EndedAt = ptr(start) }, rules: &Rules{RejectZeroLength: true}, want: []string{"ended_at: must be after started_at"}},
+		{
+			name: "every error at once",
+			modify: func(s *domain.UserSub) {
+				s.ServiceName = ""
+				s.ServicePrice = -5
+				s.UserID = uuid.Nil
+				s.Currency = "XXX"
+				s.BillingPeriod = "daily"
+			},
+			want: []string{
+				"service_name: is required",
+				"service_price: must not be negative",
+				"user_id: is required",
+				"currency: must be one of RUB, USD",
+				"billing_period: must be monthly or yearly",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := validSub()
+			tt.modify(&sub)
+
+			r := rules
+			if tt.rules != nil {
+				r = *tt.rules
+			}
+
+			assertFields(t, ValidateUserSub(sub, r), tt.want)
+		})
+	}
+}
+
+func TestValidateNewPrice(t *testing.T) {
+	rules := Rules{MaxPrice: 1000, ServiceMaxPrices: map[string]int{"Spotify": 500}}
+
+	tests := []struct {
+		name    string
+		service string
+		price   int
+		rules   Rules
+		want    []string
+	}{
+		{name: "within the service cap", service: "spotify", price: 500, rules: rules},
+		{name: "over the service cap", service: "Spotify", price: 501, rules: rules, want: []string{"new_price: must be at most 500"}},
+		{name: "other service uses the global cap", service: "Netflix", price: 1000, rules: rules},
+		{name: "any service must satisfy every cap", price: 600, rules: rules, want: []string{"new_price: must be at most 500"}},
+		{name: "service caps only", price: 600, rules: Rules{ServiceMaxPrices: map[string]int{"Spotify": 500}}, want: []string{"new_price: must be at most 500"}},
+		{name: "no caps", price: 1 << 30},
+		{name: "negative", service: "Netflix", price: -1, rules: rules, want: []string{"new_price: must not be negative"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFields(t, ValidateNewPrice(tt.service, tt.price, tt.rules), tt.want)
+		})
+	}
+}
+
+func TestValidateRename(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     []string
+	}{
+		{name: "valid", from: "Netflix", to: "Netflix Premium"},
+		{name: "missing both", want: []string{"from: is required", "to: is required"}},
+		{name: "same name", from: "Netflix", to: "Netflix", want: []string{"to: must differ from from"}},
+		{name: "long target", from: "Netflix", to: strings.Repeat("x", MaxServiceNameLen+1), want: []string{"to: must be at most 255 characters"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFields(t, ValidateRename(tt.from, tt.to), tt.want)
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{name: "valid", tags: []string{"work", "streaming"}},
+		{name: "empty", tags: nil, want: []string{"tags: must not be empty"}},
+		{name: "blank and long", tags: []string{"ok", " ", strings.Repeat("x", MaxTagLen+1)}, want: []string{
+			"tags[1]: must not be blank",
+			"tags[2]: must be at most 64 characters",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFields(t, ValidateTags(tt.tags), tt.want)
+		})
+	}
+}
+
+func TestValidateReminderPreference(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name string
+		pref domain.ReminderPreference
+		want []string
+	}{
+		{name: "valid", pref: domain.ReminderPreference{UserID: userID, LeadTimeDays: 7, Channel: domain.ReminderEmail}},
+		{name: "bounds", pref: domain.ReminderPreference{UserID: userID, LeadTimeDays: MaxReminderLeadDays, Channel: domain.ReminderSMS}},
+		{name: "invalid", pref: domain.ReminderPreference{LeadTimeDays: MaxReminderLeadDays + 1, Channel: "pigeon"}, want: []string{
+			"user_id: is required",
+			"lead_time_days: must be between 0 and 90",
+			"channel: must be email, push or sms",
+		}},
+		{name: "negative lead time", pref: domain.ReminderPreference{UserID: userID, LeadTimeDays: -1, Channel: domain.ReminderPush}, want: []string{
+			"lead_time_days: must be between 0 and 90",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFields(t, ValidateReminderPreference(tt.pref), tt.want)
+		})
+	}
+}
+
+func TestErrorsMessage(t *testing.T) {
+	err := Errors{{Field: "a", Message: "is bad"}, {Field: "b", Message: "is worse"}}
+
+	if got, want := err.Error(), "validation failed: a: is bad; b: is worse"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}